@@ -133,6 +133,7 @@ func (a *Agent) Start() {
 		updateTime := time.Duration(flowtableUpdate) * time.Second
 		expireTime := time.Duration(flowtableExpire) * time.Second
 		a.FlowTableAllocator = flow.NewTableAllocator(updateTime, expireTime)
+		a.FlowTableAllocator.RegisterMetrics()
 
 		// expose a flow server through the client connections
 		flow.NewServer(a.FlowTableAllocator, a.WSAsyncClientPool)
@@ -188,8 +189,12 @@ func NewAgent() *Agent {
 	}
 
 	g := graph.NewGraphFromConfig(backend)
+	graph.RegisterMetrics(g, common.AgentService.String())
 
-	tm := topology.NewTIDMapper(g)
+	tm, err := topology.NewTIDMapperFromConfig(g)
+	if err != nil {
+		panic(err)
+	}
 	tm.Start()
 
 	hserver, err := shttp.NewServerFromConfig(common.AgentService)
@@ -69,6 +69,20 @@ func NewTopologyProbeBundleFromConfig(g *graph.Graph, n *graph.Node, wspool *sht
 			probes["neutron"] = neutron
 		case "opencontrail":
 			probes[t] = tprobes.NewOpenContrailMapper(g, n)
+		case "sriov":
+			probes[t] = tprobes.NewSriovProbe(g, n)
+		case "lldp":
+			lldpProbe, err := tprobes.NewLLDPProbeFromConfig(g, n)
+			if err != nil {
+				return nil, err
+			}
+			probes[t] = lldpProbe
+		case "bgp":
+			bgpProbe, err := tprobes.NewBGPProbeFromConfig(g, n)
+			if err != nil {
+				return nil, err
+			}
+			probes[t] = bgpProbe
 		default:
 			logging.GetLogger().Errorf("unknown probe type %s", t)
 		}
@@ -23,7 +23,10 @@
 package analyzer
 
 import (
+	"github.com/skydive-project/skydive/config"
+	"github.com/skydive-project/skydive/logging"
 	"github.com/skydive-project/skydive/probe"
+	"github.com/skydive-project/skydive/topology"
 	"github.com/skydive-project/skydive/topology/graph"
 	tprobes "github.com/skydive-project/skydive/topology/probes"
 )
@@ -32,5 +35,36 @@ func NewTopologyProbeBundleFromConfig(g *graph.Graph) (*probe.ProbeBundle, error
 	probes := make(map[string]probe.Probe)
 	probes["fabric"] = tprobes.NewFabricProbe(g)
 	probes["peering"] = tprobes.NewPeeringProbe(g)
+
+	for _, t := range config.GetConfig().GetStringSlice("analyzer.topology.probes") {
+		switch t {
+		case "k8s":
+			probes[t] = tprobes.NewKubernetesProbeFromConfig(g)
+		case "swarm":
+			swarmProbe, err := tprobes.NewSwarmProbeFromConfig(g)
+			if err != nil {
+				return nil, err
+			}
+			probes[t] = swarmProbe
+		default:
+			logging.GetLogger().Errorf("unknown probe type %s", t)
+		}
+	}
+
 	return probe.NewProbeBundle(probes), nil
 }
+
+// clusterProbes are the probes creating nodes not reported by the agents,
+// their TIDs having to be set by the analyzer
+var clusterProbes = []string{"k8s", "swarm"}
+
+// NewTIDMapperFromConfig returns a TIDMapper setting the TIDs of the nodes
+// created by the cluster probes of the bundle, nil if there is none
+func NewTIDMapperFromConfig(g *graph.Graph, bundle *probe.ProbeBundle) (*topology.TIDMapper, error) {
+	for _, t := range clusterProbes {
+		if bundle.GetProbe(t) != nil {
+			return topology.NewTIDMapperFromConfig(g)
+		}
+	}
+	return nil, nil
+}
@@ -36,6 +36,7 @@ import (
 	"github.com/skydive-project/skydive/config"
 	"github.com/skydive-project/skydive/etcd"
 	"github.com/skydive-project/skydive/flow"
+	"github.com/skydive-project/skydive/flow/exporter"
 	"github.com/skydive-project/skydive/flow/mappings"
 	ondemand "github.com/skydive-project/skydive/flow/ondemand/client"
 	"github.com/skydive-project/skydive/flow/storage"
@@ -43,6 +44,8 @@ import (
 	"github.com/skydive-project/skydive/logging"
 	"github.com/skydive-project/skydive/packet_injector"
 	"github.com/skydive-project/skydive/probe"
+	"github.com/skydive-project/skydive/topology"
+	"github.com/skydive-project/skydive/topology/graph"
 )
 
 type Server struct {
@@ -55,7 +58,10 @@ type Server struct {
 	OnDemandClient      *ondemand.OnDemandProbeClient
 	FlowMappingPipeline *mappings.FlowMappingPipeline
 	ProbeBundle         *probe.ProbeBundle
+	TIDMapper           *topology.TIDMapper
 	Storage             storage.Storage
+	Exporters           []*exporter.Exporter
+	ConsistencyChecker  *graph.ConsistencyChecker
 	FlowTable           *flow.Table
 	TableClient         *flow.TableClient
 	conn                *FlowServerConn
@@ -71,6 +77,12 @@ func (s *Server) flowExpireUpdate(flows []*flow.Flow) {
 		s.Storage.StoreFlows(flows)
 		logging.GetLogger().Debugf("%d flows stored", len(flows))
 	}
+
+	if len(flows) > 0 {
+		for _, e := range s.Exporters {
+			e.ExportFlows(flows)
+		}
+	}
 }
 
 func (s *Server) AnalyzeFlows(flows []*flow.Flow) {
@@ -119,11 +131,21 @@ func (s *Server) ListenAndServe() {
 		s.Storage.Start()
 	}
 
+	for _, e := range s.Exporters {
+		e.Start()
+	}
+
 	s.TopologyForwarder.ConnectAll()
 
+	if s.TIDMapper != nil {
+		s.TIDMapper.Start()
+	}
 	s.ProbeBundle.Start()
 	s.OnDemandClient.Start()
 	s.AlertServer.Start()
+	if s.ConsistencyChecker != nil {
+		s.ConsistencyChecker.Start()
+	}
 
 	s.wgServers.Add(3)
 	go func() {
@@ -183,9 +205,18 @@ func (s *Server) Stop() {
 	if s.Storage != nil {
 		s.Storage.Stop()
 	}
+	for _, e := range s.Exporters {
+		e.Stop()
+	}
 	s.ProbeBundle.Stop()
+	if s.TIDMapper != nil {
+		s.TIDMapper.Stop()
+	}
 	s.OnDemandClient.Stop()
 	s.AlertServer.Stop()
+	if s.ConsistencyChecker != nil {
+		s.ConsistencyChecker.Stop()
+	}
 	s.EtcdClient.Stop()
 	s.conn.Cleanup()
 	s.wgServers.Wait()
@@ -211,12 +242,18 @@ func NewServerFromConfig() (*Server, error) {
 	wsServer := shttp.NewWSServerFromConfig(common.AnalyzerService, httpServer, "/ws")
 
 	topology := NewTopologyServerFromConfig(wsServer)
+	graph.RegisterMetrics(topology.Graph, common.AnalyzerService.String())
 
 	probeBundle, err := NewTopologyProbeBundleFromConfig(topology.Graph)
 	if err != nil {
 		return nil, err
 	}
 
+	tidMapper, err := NewTIDMapperFromConfig(topology.Graph, probeBundle)
+	if err != nil {
+		return nil, err
+	}
+
 	var etcdServer *etcd.EmbeddedEtcd
 	if embedEtcd {
 		if etcdServer, err = etcd.NewEmbeddedEtcdFromConfig(); err != nil {
@@ -263,6 +300,7 @@ func NewServerFromConfig() (*Server, error) {
 	}
 
 	onDemandClient := ondemand.NewOnDemandProbeClient(topology.Graph, captureAPIHandler, wsServer, etcdClient)
+	onDemandClient.RegisterStatusAPI(httpServer)
 
 	pipeline := mappings.NewFlowMappingPipeline(mappings.NewGraphFlowEnhancer(topology.Graph))
 
@@ -295,9 +333,30 @@ func NewServerFromConfig() (*Server, error) {
 		EmbeddedEtcd:        etcdServer,
 		EtcdClient:          etcdClient,
 		ProbeBundle:         probeBundle,
+		TIDMapper:           tidMapper,
 		Storage:             store,
 	}
 
+	if interval := config.GetConfig().GetInt("analyzer.topology.consistency_check_interval"); interval > 0 {
+		server.ConsistencyChecker = graph.NewConsistencyChecker(topology.Graph, time.Duration(interval)*time.Second)
+	}
+
+	if config.GetConfig().GetString("analyzer.export.ipfix.collector") != "" {
+		ipfix, err := exporter.NewIPFIXExporterFromConfig()
+		if err != nil {
+			return nil, err
+		}
+		server.Exporters = append(server.Exporters, ipfix.Exporter)
+	}
+
+	if config.GetConfig().GetString("analyzer.export.netflow.collector") != "" {
+		netflow, err := exporter.NewNetFlowExporterFromConfig()
+		if err != nil {
+			return nil, err
+		}
+		server.Exporters = append(server.Exporters, netflow.Exporter)
+	}
+
 	wsServer.AddEventHandler(server)
 
 	updateHandler := flow.NewFlowHandler(server.flowExpireUpdate, time.Second*time.Duration(analyzerUpdate))
@@ -24,6 +24,7 @@ package api
 
 import (
 	"fmt"
+	"time"
 
 	"github.com/nu7hatch/gouuid"
 
@@ -35,13 +36,20 @@ import (
 
 type Capture struct {
 	UUID         string
-	GremlinQuery string `json:"GremlinQuery,omitempty" valid:"isGremlinExpr"`
-	BPFFilter    string `json:"BPFFilter,omitempty"`
-	Name         string `json:"Name,omitempty"`
-	Description  string `json:"Description,omitempty"`
-	Type         string `json:"Type,omitempty"`
-	Count        int    `json:"Count,omitempty"`
-	PCAPSocket   string `json:"PCAPSocket,omitempty"`
+	GremlinQuery string        `json:"GremlinQuery,omitempty" valid:"isGremlinExpr"`
+	BPFFilter    string        `json:"BPFFilter,omitempty" valid:"isBPFFilter"`
+	Name         string        `json:"Name,omitempty"`
+	Description  string        `json:"Description,omitempty"`
+	Type         string        `json:"Type,omitempty"`
+	Count        int           `json:"Count,omitempty"`
+	PCAPSocket   string        `json:"PCAPSocket,omitempty"`
+	Priority     int           `json:"Priority,omitempty"`
+	Duration     time.Duration `json:"Duration,omitempty"`
+	SamplingRate float64       `json:"SamplingRate,omitempty" valid:"isSamplingRate"`
+	MaxPackets   int64         `json:"MaxPackets,omitempty"`
+	MaxBytes     int64         `json:"MaxBytes,omitempty"`
+	PCAPFilePath string        `json:"PCAPFilePath,omitempty"`
+	PCAPRealTime bool          `json:"PCAPRealTime,omitempty"`
 }
 
 type CaptureResourceHandler struct {
@@ -118,6 +126,10 @@ func (c *Capture) SetID(i string) {
 // Create tests that resource GremlinQuery does not exists already
 func (c *CaptureAPIHandler) Create(r APIResource) error {
 	capture := r.(*Capture)
+	if capture.PCAPFilePath != "" && capture.Type != "pcap" {
+		return fmt.Errorf("A PCAP file can only be replayed by a pcap capture")
+	}
+
 	resources := c.BasicAPIHandler.Index()
 	for _, resource := range resources {
 		if resource.(*Capture).GremlinQuery == capture.GremlinQuery {
@@ -0,0 +1,104 @@
+/*
+ * Copyright (C) 2016 Red Hat, Inc.
+ *
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ *
+ */
+
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/abbot/go-http-auth"
+	"golang.org/x/net/context"
+
+	"github.com/skydive-project/skydive/logging"
+	"github.com/skydive-project/skydive/topology/graph"
+)
+
+// eventsFilter returns the metadata filter built from the query parameters,
+// for instance /api/events?Type=netns
+func eventsFilter(r *auth.AuthenticatedRequest) []graph.Metadata {
+	values := r.URL.Query()
+	if len(values) == 0 {
+		return nil
+	}
+
+	m := graph.Metadata{}
+	for key := range values {
+		m[key] = values.Get(key)
+	}
+	return []graph.Metadata{m}
+}
+
+// topologyEvents streams the graph events as Server-Sent Events, for the
+// clients not able to use a WebSocket
+func (t *TopologyAPI) topologyEvents(w http.ResponseWriter, r *auth.AuthenticatedRequest) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		w.WriteHeader(http.StatusNotImplemented)
+		w.Write([]byte("Streaming not supported"))
+		return
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	if notifier, ok := w.(http.CloseNotifier); ok {
+		closed := notifier.CloseNotify()
+		go func() {
+			select {
+			case <-closed:
+				cancel()
+			case <-ctx.Done():
+			}
+		}()
+	}
+
+	events := t.Graph.Watch(ctx, eventsFilter(r)...)
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	for ev := range events {
+		var element interface{} = ev.Node
+		if ev.Edge != nil {
+			element = ev.Edge
+		}
+
+		t.Graph.RLock()
+		data, err := json.Marshal(element)
+		t.Graph.RUnlock()
+
+		if err != nil {
+			logging.GetLogger().Errorf("Unable to encode graph event: %s", err.Error())
+			continue
+		}
+
+		if _, err := fmt.Fprintf(w, "event: %s\ndata: %s\n\n", ev.EventType, data); err != nil {
+			return
+		}
+		flusher.Flush()
+	}
+}
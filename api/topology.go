@@ -29,6 +29,7 @@ import (
 	"strings"
 
 	"github.com/abbot/go-http-auth"
+	"github.com/skydive-project/skydive/common"
 	"github.com/skydive-project/skydive/flow"
 	"github.com/skydive-project/skydive/flow/storage"
 	ftraversal "github.com/skydive-project/skydive/flow/traversal"
@@ -108,6 +109,42 @@ func (t *TopologyAPI) topologySearch(w http.ResponseWriter, r *auth.Authenticate
 	}
 }
 
+func (t *TopologyAPI) topologyValidate(w http.ResponseWriter, r *auth.AuthenticatedRequest) {
+	errs, err := t.Graph.Validate()
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(err.Error()))
+		return
+	}
+
+	if errs == nil {
+		errs = []graph.ConsistencyError{}
+	}
+
+	w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+	w.WriteHeader(http.StatusOK)
+	if err := json.NewEncoder(w).Encode(errs); err != nil {
+		panic(err)
+	}
+}
+
+func (t *TopologyAPI) topologyPatch(w http.ResponseWriter, r *auth.AuthenticatedRequest) {
+	var patch []graph.JSONPatchOp
+	if err := common.JsonDecode(r.Body, &patch); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(err.Error()))
+		return
+	}
+
+	if err := t.Graph.ApplyPatch(patch); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(err.Error()))
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
 func (t *TopologyAPI) registerEndpoints(r *shttp.Server) {
 	routes := []shttp.Route{
 		{
@@ -122,6 +159,24 @@ func (t *TopologyAPI) registerEndpoints(r *shttp.Server) {
 			Path:        "/api/topology",
 			HandlerFunc: t.topologySearch,
 		},
+		{
+			Name:        "TopologyPatch",
+			Method:      "PATCH",
+			Path:        "/api/topology",
+			HandlerFunc: t.topologyPatch,
+		},
+		{
+			Name:        "TopologyValidate",
+			Method:      "GET",
+			Path:        "/api/topology/validate",
+			HandlerFunc: t.topologyValidate,
+		},
+		{
+			Name:        "TopologyEvents",
+			Method:      "GET",
+			Path:        "/api/events",
+			HandlerFunc: t.topologyEvents,
+		},
 	}
 
 	r.RegisterRoutes(routes)
@@ -25,6 +25,7 @@ package client
 import (
 	"fmt"
 	"os"
+	"time"
 
 	"github.com/skydive-project/skydive/api"
 	"github.com/skydive-project/skydive/common"
@@ -39,6 +40,13 @@ var (
 	captureName        string
 	captureDescription string
 	captureType        string
+	capturePriority    int
+	captureDuration    time.Duration
+	samplingRate       float64
+	maxPackets         int64
+	maxBytes           int64
+	pcapFilePath       string
+	pcapRealTime       bool
 	nodeTID            string
 )
 
@@ -71,6 +79,13 @@ var CaptureCreate = &cobra.Command{
 		capture.Name = captureName
 		capture.Description = captureDescription
 		capture.Type = captureType
+		capture.Priority = capturePriority
+		capture.Duration = captureDuration
+		capture.SamplingRate = samplingRate
+		capture.MaxPackets = maxPackets
+		capture.MaxBytes = maxBytes
+		capture.PCAPFilePath = pcapFilePath
+		capture.PCAPRealTime = pcapRealTime
 		if err := validator.Validate(capture); err != nil {
 			logging.GetLogger().Fatalf(err.Error())
 		}
@@ -163,6 +178,13 @@ func addCaptureFlags(cmd *cobra.Command) {
 	cmd.Flags().StringVarP(&captureName, "name", "", "", "capture name")
 	cmd.Flags().StringVarP(&captureDescription, "description", "", "", "capture description")
 	cmd.Flags().StringVarP(&captureType, "type", "", "", helpText)
+	cmd.Flags().IntVarP(&capturePriority, "priority", "", 0, "capture priority, the highest wins when several captures match a node")
+	cmd.Flags().DurationVarP(&captureDuration, "duration", "", 0, "capture duration, the capture being deleted once elapsed (ex: 10m)")
+	cmd.Flags().Float64VarP(&samplingRate, "sampling-rate", "", 0, "rate of the packets captured, between 0 and 1 (ex: 0.01 for 1%)")
+	cmd.Flags().Int64VarP(&maxPackets, "max-packets", "", 0, "number of packets after which the capture is stopped")
+	cmd.Flags().Int64VarP(&maxBytes, "max-bytes", "", 0, "number of bytes after which the capture is stopped")
+	cmd.Flags().StringVarP(&pcapFilePath, "pcap-file", "", "", "PCAP file of the agent to replay instead of capturing, pcap capture type only")
+	cmd.Flags().BoolVarP(&pcapRealTime, "pcap-realtime", "", false, "replay the PCAP file at the pace of the capture rather than as fast as possible")
 }
 
 func init() {
@@ -55,18 +55,34 @@ func init() {
 	cfg.SetDefault("ovs.ovsdb", "unix:///var/run/openvswitch/db.sock")
 	cfg.SetDefault("graph.backend", "memory")
 	cfg.SetDefault("graph.gremlin", "ws://127.0.0.1:8182")
+	cfg.SetDefault("graph.shortest_path_cache_size", 1000)
+	cfg.SetDefault("topology.tid_max_depth", 10)
 	cfg.SetDefault("sflow.port_min", 6345)
 	cfg.SetDefault("sflow.port_max", 6355)
 	cfg.SetDefault("analyzer.listen", "127.0.0.1:8082")
 	cfg.SetDefault("analyzer.flowtable_expire", 600)
 	cfg.SetDefault("analyzer.flowtable_update", 60)
 	cfg.SetDefault("analyzer.flowtable_agent_ratio", 0.5)
+	cfg.SetDefault("analyzer.export.ipfix.observation_domain", 0)
+	cfg.SetDefault("analyzer.export.ipfix.template_refresh", 60)
+	cfg.SetDefault("analyzer.export.netflow.source_id", 0)
+	cfg.SetDefault("analyzer.export.netflow.template_refresh", 60)
 	cfg.SetDefault("storage.elasticsearch.host", "127.0.0.1:9200")
 	cfg.SetDefault("storage.elasticsearch.maxconns", 10)
 	cfg.SetDefault("storage.elasticsearch.retry", 60)
 	cfg.SetDefault("storage.elasticsearch.bulk_maxdocs", 0)
 	cfg.SetDefault("ws_pong_timeout", 5)
+	cfg.SetDefault("ws_ping_interval", 0)
+	cfg.SetDefault("ws_compression", false)
+	cfg.SetDefault("ws_compression_threshold", 1024)
+	cfg.SetDefault("ws_send_buffer_size", 1024)
+	cfg.SetDefault("ws_send_drop_policy", "disconnect")
+	cfg.SetDefault("ws_batch_window", 0)
+	cfg.SetDefault("ws_batch_max_size", 100)
 	cfg.SetDefault("docker.url", "unix:///var/run/docker.sock")
+	cfg.SetDefault("docker.swarm_poll_interval", 10)
+	cfg.SetDefault("k8s.url", "http://127.0.0.1:8080")
+	cfg.SetDefault("k8s.poll_interval", 10)
 	cfg.SetDefault("netns.run_path", "/var/run/netns")
 	cfg.SetDefault("etcd.data_dir", "/var/lib/skydive/etcd")
 	cfg.SetDefault("etcd.embedded", true)
@@ -80,10 +96,15 @@ func init() {
 	cfg.SetDefault("openstack.endpoint_type", "public")
 	cfg.SetDefault("agent.topology.probes", []string{"netlink", "netns"})
 	cfg.SetDefault("agent.topology.netlink.metrics_update", 30)
+	cfg.SetDefault("agent.topology.lldp.poll_interval", 30)
+	cfg.SetDefault("agent.topology.bgp.daemon", "bird")
+	cfg.SetDefault("agent.topology.bgp.socket", "/var/run/bird/bird.ctl")
+	cfg.SetDefault("agent.topology.bgp.poll_interval", 10)
 	cfg.SetDefault("agent.flow.pcapsocket.bind_address", "127.0.0.1")
 	cfg.SetDefault("agent.flow.pcapsocket.min_port", 8100)
 	cfg.SetDefault("agent.flow.pcapsocket.max_port", 8132)
 	cfg.SetDefault("analyzer.topology.probes", []string{})
+	cfg.SetDefault("analyzer.topology.consistency_check_interval", 300)
 	cfg.SetDefault("opencontrail.mpls_udp_port", 51234)
 	cfg.SetDefault("agent.flow.stats_update", 1)
 
@@ -72,7 +72,7 @@ func NewEtcdClient(etcdServers []string, clientTimeout time.Duration) (*EtcdClie
 		return nil, fmt.Errorf("Failed to connect to etcd: %s", err)
 	}
 
-	kapi := etcd.NewKeysAPI(etcdClient)
+	kapi := &instrumentedKeysAPI{KeysAPI: etcd.NewKeysAPI(etcdClient)}
 
 	return &EtcdClient{
 		Client:  &etcdClient,
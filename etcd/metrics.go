@@ -0,0 +1,81 @@
+/*
+ * Copyright (C) 2016 Red Hat, Inc.
+ *
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ *
+ */
+
+package etcd
+
+import (
+	"time"
+
+	"golang.org/x/net/context"
+
+	etcd "github.com/coreos/etcd/client"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var etcdRequestDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+	Name: "skydive_etcd_request_duration_seconds",
+	Help: "Duration of the requests to etcd by operation",
+}, []string{"operation"})
+
+// instrumentedKeysAPI measures the duration of the requests of a KeysAPI,
+// the watchers being left aside as they block until a change
+type instrumentedKeysAPI struct {
+	etcd.KeysAPI
+}
+
+func observe(operation string, start time.Time) {
+	etcdRequestDuration.WithLabelValues(operation).Observe(time.Since(start).Seconds())
+}
+
+func (k *instrumentedKeysAPI) Get(ctx context.Context, key string, opts *etcd.GetOptions) (*etcd.Response, error) {
+	defer observe("get", time.Now())
+	return k.KeysAPI.Get(ctx, key, opts)
+}
+
+func (k *instrumentedKeysAPI) Set(ctx context.Context, key, value string, opts *etcd.SetOptions) (*etcd.Response, error) {
+	defer observe("set", time.Now())
+	return k.KeysAPI.Set(ctx, key, value, opts)
+}
+
+func (k *instrumentedKeysAPI) Delete(ctx context.Context, key string, opts *etcd.DeleteOptions) (*etcd.Response, error) {
+	defer observe("delete", time.Now())
+	return k.KeysAPI.Delete(ctx, key, opts)
+}
+
+func (k *instrumentedKeysAPI) Create(ctx context.Context, key, value string) (*etcd.Response, error) {
+	defer observe("create", time.Now())
+	return k.KeysAPI.Create(ctx, key, value)
+}
+
+func (k *instrumentedKeysAPI) CreateInOrder(ctx context.Context, dir, value string, opts *etcd.CreateInOrderOptions) (*etcd.Response, error) {
+	defer observe("create", time.Now())
+	return k.KeysAPI.CreateInOrder(ctx, dir, value, opts)
+}
+
+func (k *instrumentedKeysAPI) Update(ctx context.Context, key, value string) (*etcd.Response, error) {
+	defer observe("update", time.Now())
+	return k.KeysAPI.Update(ctx, key, value)
+}
+
+func init() {
+	prometheus.MustRegister(etcdRequestDuration)
+}
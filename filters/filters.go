@@ -22,13 +22,26 @@
 
 package filters
 
-import "regexp"
+import (
+	"fmt"
+	"net"
+	"regexp"
+	"strings"
+	"sync"
+)
 
 type Getter interface {
 	GetFieldInt64(field string) (int64, error)
+	GetFieldFloat64(field string) (float64, error)
 	GetFieldString(field string) (string, error)
 }
 
+// FieldGetter is implemented by the getters able to return the raw value of
+// a field
+type FieldGetter interface {
+	GetField(field string) (interface{}, bool)
+}
+
 func (f *Filter) Eval(g Getter) bool {
 	if f.BoolFilter != nil {
 		return f.BoolFilter.Eval(g)
@@ -54,6 +67,36 @@ func (f *Filter) Eval(g Getter) bool {
 	if f.RegexFilter != nil {
 		return f.RegexFilter.Eval(g)
 	}
+	if f.GtFloat64Filter != nil {
+		return f.GtFloat64Filter.Eval(g)
+	}
+	if f.LtFloat64Filter != nil {
+		return f.LtFloat64Filter.Eval(g)
+	}
+	if f.GteFloat64Filter != nil {
+		return f.GteFloat64Filter.Eval(g)
+	}
+	if f.LteFloat64Filter != nil {
+		return f.LteFloat64Filter.Eval(g)
+	}
+	if f.ContainsFilter != nil {
+		return f.ContainsFilter.Eval(g)
+	}
+	if f.PrefixFilter != nil {
+		return f.PrefixFilter.Eval(g)
+	}
+	if f.SuffixFilter != nil {
+		return f.SuffixFilter.Eval(g)
+	}
+	if f.IPFilter != nil {
+		return f.IPFilter.Eval(g)
+	}
+	if f.NullFilter != nil {
+		return f.NullFilter.Eval(g)
+	}
+	if f.ArrayContainsFilter != nil {
+		return f.ArrayContainsFilter.Eval(g)
+	}
 
 	return true
 }
@@ -121,6 +164,42 @@ func (r *LteInt64Filter) Eval(g Getter) bool {
 	return false
 }
 
+func (r *GtFloat64Filter) Eval(g Getter) bool {
+	field, err := g.GetFieldFloat64(r.Key)
+	if err != nil {
+		return false
+	}
+
+	return field > r.Value
+}
+
+func (r *LtFloat64Filter) Eval(g Getter) bool {
+	field, err := g.GetFieldFloat64(r.Key)
+	if err != nil {
+		return false
+	}
+
+	return field < r.Value
+}
+
+func (r *GteFloat64Filter) Eval(g Getter) bool {
+	field, err := g.GetFieldFloat64(r.Key)
+	if err != nil {
+		return false
+	}
+
+	return field >= r.Value
+}
+
+func (r *LteFloat64Filter) Eval(g Getter) bool {
+	field, err := g.GetFieldFloat64(r.Key)
+	if err != nil {
+		return false
+	}
+
+	return field <= r.Value
+}
+
 func (t *TermStringFilter) Eval(g Getter) bool {
 	field, err := g.GetFieldString(t.Key)
 	if err != nil {
@@ -149,6 +228,195 @@ func (r *RegexFilter) Eval(g Getter) bool {
 	return re.MatchString(field)
 }
 
+func (c *ContainsFilter) Eval(g Getter) bool {
+	field, err := g.GetFieldString(c.Key)
+	if err != nil {
+		return false
+	}
+
+	return strings.Contains(field, c.Value)
+}
+
+func (p *PrefixFilter) Eval(g Getter) bool {
+	field, err := g.GetFieldString(p.Key)
+	if err != nil {
+		return false
+	}
+
+	return strings.HasPrefix(field, p.Value)
+}
+
+func (s *SuffixFilter) Eval(g Getter) bool {
+	field, err := g.GetFieldString(s.Key)
+	if err != nil {
+		return false
+	}
+
+	return strings.HasSuffix(field, s.Value)
+}
+
+// ipNetworks caches the networks of the IP filters, parsed once per CIDR
+var ipNetworks = struct {
+	sync.RWMutex
+	networks map[string]*net.IPNet
+}{networks: make(map[string]*net.IPNet)}
+
+func (i *IPFilter) network() (*net.IPNet, error) {
+	ipNetworks.RLock()
+	network, ok := ipNetworks.networks[i.Cidr]
+	ipNetworks.RUnlock()
+	if ok {
+		return network, nil
+	}
+
+	_, network, err := net.ParseCIDR(i.Cidr)
+	if err != nil {
+		return nil, err
+	}
+
+	ipNetworks.Lock()
+	ipNetworks.networks[i.Cidr] = network
+	ipNetworks.Unlock()
+
+	return network, nil
+}
+
+// Eval returns true if one of the comma separated IPs of the field belongs
+// to the network of the filter. IPs of the field can be given in CIDR
+// notation as well.
+func (i *IPFilter) Eval(g Getter) bool {
+	field, err := g.GetFieldString(i.Key)
+	if err != nil {
+		return false
+	}
+
+	cidr, err := i.network()
+	if err != nil {
+		return false
+	}
+
+	for _, s := range strings.Split(field, ",") {
+		s = strings.TrimSpace(s)
+
+		ip := net.ParseIP(s)
+		if ip == nil {
+			if ip, _, err = net.ParseCIDR(s); err != nil {
+				continue
+			}
+		}
+
+		if cidr.Contains(ip) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Regex returns a regular expression matching the whole field when one of
+// its comma separated IPs belongs to the network of the filter, for the
+// storages which can't evaluate the filter. Only the syntax common to the
+// Lucene and Java regular expressions is used. IPv6 networks are supported
+// when made of whole non-zero groups, ok being false otherwise.
+func (i *IPFilter) Regex() (regex string, ok bool) {
+	network, err := i.network()
+	if err != nil {
+		return "", false
+	}
+
+	ones, _ := network.Mask.Size()
+
+	var address string
+	if ip := network.IP.To4(); ip != nil {
+		var octets []string
+		for j, b := range ip {
+			bits := ones - 8*j
+			switch {
+			case bits >= 8:
+				octets = append(octets, fmt.Sprintf("%d", b))
+			case bits <= 0:
+				octets = append(octets, "[0-9]+")
+			default:
+				var values []string
+				for v := int(b); v <= int(b|0xff>>uint(bits)); v++ {
+					values = append(values, fmt.Sprintf("%d", v))
+				}
+				octets = append(octets, "("+strings.Join(values, "|")+")")
+			}
+		}
+		address = strings.Join(octets, "[.]")
+	} else {
+		if ones == 0 || ones%16 != 0 {
+			return "", false
+		}
+
+		var groups []string
+		for j := 0; j < ones/8; j += 2 {
+			group := int(network.IP[j])<<8 | int(network.IP[j+1])
+			if group == 0 {
+				return "", false
+			}
+			groups = append(groups, fmt.Sprintf("%x", group))
+		}
+
+		address = strings.Join(groups, ":")
+		if ones < 128 {
+			address += ":[0-9a-f:]*"
+		}
+	}
+
+	return "(.*, *)?" + address + "(/[0-9]+)?( *,.*)?", true
+}
+
+// Eval returns true if the field is absent or nil
+func (n *NullFilter) Eval(g Getter) bool {
+	if fg, ok := g.(FieldGetter); ok {
+		v, found := fg.GetField(n.Key)
+		return !found || v == nil
+	}
+
+	if _, err := g.GetFieldString(n.Key); err == nil {
+		return false
+	}
+	if _, err := g.GetFieldInt64(n.Key); err == nil {
+		return false
+	}
+	if _, err := g.GetFieldFloat64(n.Key); err == nil {
+		return false
+	}
+	return true
+}
+
+// Eval returns true if the field is a slice containing the value
+func (a *ArrayContainsFilter) Eval(g Getter) bool {
+	fg, ok := g.(FieldGetter)
+	if !ok {
+		return false
+	}
+
+	field, found := fg.GetField(a.Key)
+	if !found {
+		return false
+	}
+
+	switch field := field.(type) {
+	case []string:
+		for _, v := range field {
+			if v == a.Value {
+				return true
+			}
+		}
+	case []interface{}:
+		for _, v := range field {
+			if s, ok := v.(string); ok && s == a.Value {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
 func NewBoolFilter(op BoolFilterOp, filters ...*Filter) *Filter {
 	boolFilter := &BoolFilter{
 		Op:      op,
@@ -192,6 +460,22 @@ func NewLteInt64Filter(key string, value int64) *Filter {
 	return &Filter{LteInt64Filter: &LteInt64Filter{Key: key, Value: value}}
 }
 
+func NewGtFloat64Filter(key string, value float64) *Filter {
+	return &Filter{GtFloat64Filter: &GtFloat64Filter{Key: key, Value: value}}
+}
+
+func NewGteFloat64Filter(key string, value float64) *Filter {
+	return &Filter{GteFloat64Filter: &GteFloat64Filter{Key: key, Value: value}}
+}
+
+func NewLtFloat64Filter(key string, value float64) *Filter {
+	return &Filter{LtFloat64Filter: &LtFloat64Filter{Key: key, Value: value}}
+}
+
+func NewLteFloat64Filter(key string, value float64) *Filter {
+	return &Filter{LteFloat64Filter: &LteFloat64Filter{Key: key, Value: value}}
+}
+
 func NewTermInt64Filter(key string, value int64) *Filter {
 	return &Filter{TermInt64Filter: &TermInt64Filter{Key: key, Value: value}}
 }
@@ -200,6 +484,46 @@ func NewTermStringFilter(key string, value string) *Filter {
 	return &Filter{TermStringFilter: &TermStringFilter{Key: key, Value: value}}
 }
 
+func NewContainsFilter(key string, value string) *Filter {
+	return &Filter{ContainsFilter: &ContainsFilter{Key: key, Value: value}}
+}
+
+func NewPrefixFilter(key string, value string) *Filter {
+	return &Filter{PrefixFilter: &PrefixFilter{Key: key, Value: value}}
+}
+
+func NewSuffixFilter(key string, value string) *Filter {
+	return &Filter{SuffixFilter: &SuffixFilter{Key: key, Value: value}}
+}
+
+// NewIPFilter returns a filter matching the elements having an IP of the
+// given field in the network of the CIDR
+func NewIPFilter(key string, cidr string) (*Filter, error) {
+	_, ipnet, err := net.ParseCIDR(cidr)
+	if err != nil {
+		return nil, err
+	}
+	return &Filter{IPFilter: &IPFilter{Key: key, Cidr: ipnet.String()}}, nil
+}
+
+// NewNullFilter returns a filter matching the elements for which the field
+// is absent or nil
+func NewNullFilter(key string) *Filter {
+	return &Filter{NullFilter: &NullFilter{Key: key}}
+}
+
+// NewNotNullFilter returns a filter matching the elements for which the field
+// is present and not nil
+func NewNotNullFilter(key string) *Filter {
+	return NewNotFilter(NewNullFilter(key))
+}
+
+// NewArrayContainsFilter returns a filter matching the elements for which the
+// field is a slice containing the value
+func NewArrayContainsFilter(key string, value string) *Filter {
+	return &Filter{ArrayContainsFilter: &ArrayContainsFilter{Key: key, Value: value}}
+}
+
 func NewFilterForIds(uuids []string, attrs ...string) *Filter {
 	terms := make([]*Filter, len(uuids)*len(attrs))
 	for i, uuid := range uuids {
@@ -0,0 +1,91 @@
+/*
+ * Copyright (C) 2016 Red Hat, Inc.
+ *
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ *
+ */
+
+package filters
+
+import (
+	"fmt"
+	"regexp"
+	"testing"
+)
+
+type stringGetter map[string]string
+
+func (g stringGetter) GetFieldInt64(field string) (int64, error) {
+	return 0, fmt.Errorf("No int64 field %s", field)
+}
+
+func (g stringGetter) GetFieldFloat64(field string) (float64, error) {
+	return 0, fmt.Errorf("No float64 field %s", field)
+}
+
+func (g stringGetter) GetFieldString(field string) (string, error) {
+	if v, ok := g[field]; ok {
+		return v, nil
+	}
+	return "", fmt.Errorf("No string field %s", field)
+}
+
+func TestIPFilterRegex(t *testing.T) {
+	tests := []struct {
+		cidr  string
+		value string
+		match bool
+	}{
+		{"10.0.0.0/8", "10.1.2.3", true},
+		{"10.0.0.0/8", "110.1.2.3", false},
+		{"10.0.0.0/8", "192.168.0.1/24, 10.0.0.1/8", true},
+		{"192.168.4.0/22", "192.168.7.255", true},
+		{"192.168.4.0/22", "192.168.8.1", false},
+		{"192.168.1.1/32", "192.168.1.1", true},
+		{"192.168.1.1/32", "192.168.1.10", false},
+		{"2001:db8::/32", "2001:db8::1", true},
+		{"2001:db8::/32", "fe80::1,2001:db8:1::1/64", true},
+		{"2001:db8::/32", "2001:db9::1", false},
+	}
+
+	for _, test := range tests {
+		f, err := NewIPFilter("IP", test.cidr)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		regex, ok := f.IPFilter.Regex()
+		if !ok {
+			t.Fatalf("No regex for %s", test.cidr)
+		}
+
+		re := regexp.MustCompile("^" + regex + "$")
+		if re.MatchString(test.value) != test.match {
+			t.Errorf("Regex %s of %s should match %s: %v", regex, test.cidr, test.value, test.match)
+		}
+
+		if f.Eval(stringGetter{"IP": test.value}) != test.match {
+			t.Errorf("Filter %s should match %s: %v", test.cidr, test.value, test.match)
+		}
+	}
+
+	f, _ := NewIPFilter("IP", "fd00::/8")
+	if _, ok := f.IPFilter.Regex(); ok {
+		t.Error("No regex should be returned for a network not made of whole groups")
+	}
+}
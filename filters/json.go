@@ -0,0 +1,235 @@
+/*
+ * Copyright (C) 2016 Red Hat, Inc.
+ *
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ *
+ */
+
+package filters
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+)
+
+type stringTerm struct {
+	Key   string
+	Value string
+}
+
+type int64Term struct {
+	Key   string
+	Value int64
+}
+
+type float64Term struct {
+	Key   string
+	Value float64
+}
+
+type keyTerm struct {
+	Key string
+}
+
+type ipTerm struct {
+	Key  string
+	Cidr string
+}
+
+// MarshalJSON serializes the filter as an object with a single key, the name
+// of the filter variant, as {"TermStringFilter": {"Key": "Name", "Value": "eth0"}}.
+// Boolean filters are serialized as AndFilter and OrFilter holding a list of
+// filters, and NotFilter holding the negated filter.
+func (f *Filter) MarshalJSON() ([]byte, error) {
+	var name string
+	var value interface{}
+
+	switch {
+	case f.BoolFilter != nil:
+		switch f.BoolFilter.Op {
+		case BoolFilterOp_AND:
+			name, value = "AndFilter", f.BoolFilter.Filters
+		case BoolFilterOp_OR:
+			name, value = "OrFilter", f.BoolFilter.Filters
+		case BoolFilterOp_NOT:
+			name, value = "NotFilter", f.BoolFilter.Filters
+			if len(f.BoolFilter.Filters) == 1 {
+				value = f.BoolFilter.Filters[0]
+			}
+		default:
+			return nil, fmt.Errorf("Unknown boolean filter operator: %d", f.BoolFilter.Op)
+		}
+	case f.TermStringFilter != nil:
+		name, value = "TermStringFilter", stringTerm{f.TermStringFilter.Key, f.TermStringFilter.Value}
+	case f.TermInt64Filter != nil:
+		name, value = "TermInt64Filter", int64Term{f.TermInt64Filter.Key, f.TermInt64Filter.Value}
+	case f.GtInt64Filter != nil:
+		name, value = "GtInt64Filter", int64Term{f.GtInt64Filter.Key, f.GtInt64Filter.Value}
+	case f.LtInt64Filter != nil:
+		name, value = "LtInt64Filter", int64Term{f.LtInt64Filter.Key, f.LtInt64Filter.Value}
+	case f.GteInt64Filter != nil:
+		name, value = "GteInt64Filter", int64Term{f.GteInt64Filter.Key, f.GteInt64Filter.Value}
+	case f.LteInt64Filter != nil:
+		name, value = "LteInt64Filter", int64Term{f.LteInt64Filter.Key, f.LteInt64Filter.Value}
+	case f.GtFloat64Filter != nil:
+		name, value = "GtFloat64Filter", float64Term{f.GtFloat64Filter.Key, f.GtFloat64Filter.Value}
+	case f.LtFloat64Filter != nil:
+		name, value = "LtFloat64Filter", float64Term{f.LtFloat64Filter.Key, f.LtFloat64Filter.Value}
+	case f.GteFloat64Filter != nil:
+		name, value = "GteFloat64Filter", float64Term{f.GteFloat64Filter.Key, f.GteFloat64Filter.Value}
+	case f.LteFloat64Filter != nil:
+		name, value = "LteFloat64Filter", float64Term{f.LteFloat64Filter.Key, f.LteFloat64Filter.Value}
+	case f.RegexFilter != nil:
+		name, value = "RegexFilter", stringTerm{f.RegexFilter.Key, f.RegexFilter.Value}
+	case f.ContainsFilter != nil:
+		name, value = "ContainsFilter", stringTerm{f.ContainsFilter.Key, f.ContainsFilter.Value}
+	case f.PrefixFilter != nil:
+		name, value = "PrefixFilter", stringTerm{f.PrefixFilter.Key, f.PrefixFilter.Value}
+	case f.SuffixFilter != nil:
+		name, value = "SuffixFilter", stringTerm{f.SuffixFilter.Key, f.SuffixFilter.Value}
+	case f.IPFilter != nil:
+		name, value = "IPFilter", ipTerm{f.IPFilter.Key, f.IPFilter.Cidr}
+	case f.NullFilter != nil:
+		name, value = "NullFilter", keyTerm{f.NullFilter.Key}
+	case f.ArrayContainsFilter != nil:
+		name, value = "ArrayContainsFilter", stringTerm{f.ArrayContainsFilter.Key, f.ArrayContainsFilter.Value}
+	default:
+		return []byte("{}"), nil
+	}
+
+	return json.Marshal(map[string]interface{}{name: value})
+}
+
+func unmarshalFilters(data []byte) ([]*Filter, error) {
+	var filters []*Filter
+	if err := json.Unmarshal(data, &filters); err != nil {
+		return nil, err
+	}
+	return filters, nil
+}
+
+// UnmarshalJSON restores a filter serialized by MarshalJSON
+func (f *Filter) UnmarshalJSON(data []byte) error {
+	var obj map[string]json.RawMessage
+	if err := json.Unmarshal(data, &obj); err != nil {
+		return err
+	}
+
+	if len(obj) > 1 {
+		return errors.New("A filter must have only one variant")
+	}
+
+	*f = Filter{}
+	for name, raw := range obj {
+		var st stringTerm
+		var it int64Term
+		var ft float64Term
+		var err error
+
+		switch name {
+		case "AndFilter", "OrFilter", "NotFilter":
+			var filters []*Filter
+			if name == "NotFilter" && len(raw) > 0 && raw[0] == '{' {
+				var filter Filter
+				if err = json.Unmarshal(raw, &filter); err == nil {
+					filters = []*Filter{&filter}
+				}
+			} else {
+				filters, err = unmarshalFilters(raw)
+			}
+			if err != nil {
+				return err
+			}
+
+			op := BoolFilterOp_AND
+			if name == "OrFilter" {
+				op = BoolFilterOp_OR
+			} else if name == "NotFilter" {
+				op = BoolFilterOp_NOT
+			}
+			f.BoolFilter = &BoolFilter{Op: op, Filters: filters}
+			continue
+		case "TermStringFilter", "RegexFilter", "ContainsFilter", "PrefixFilter", "SuffixFilter", "ArrayContainsFilter":
+			err = json.Unmarshal(raw, &st)
+		case "TermInt64Filter", "GtInt64Filter", "LtInt64Filter", "GteInt64Filter", "LteInt64Filter":
+			err = json.Unmarshal(raw, &it)
+		case "GtFloat64Filter", "LtFloat64Filter", "GteFloat64Filter", "LteFloat64Filter":
+			err = json.Unmarshal(raw, &ft)
+		case "IPFilter":
+			var ip ipTerm
+			if err = json.Unmarshal(raw, &ip); err == nil {
+				f.IPFilter = &IPFilter{Key: ip.Key, Cidr: ip.Cidr}
+			}
+		case "NullFilter":
+			var kt keyTerm
+			if err = json.Unmarshal(raw, &kt); err == nil {
+				f.NullFilter = &NullFilter{Key: kt.Key}
+			}
+		default:
+			return fmt.Errorf("Unknown filter: %s", name)
+		}
+		if err != nil {
+			return err
+		}
+
+		switch name {
+		case "TermStringFilter":
+			f.TermStringFilter = &TermStringFilter{Key: st.Key, Value: st.Value}
+		case "RegexFilter":
+			f.RegexFilter = &RegexFilter{Key: st.Key, Value: st.Value}
+		case "ContainsFilter":
+			f.ContainsFilter = &ContainsFilter{Key: st.Key, Value: st.Value}
+		case "PrefixFilter":
+			f.PrefixFilter = &PrefixFilter{Key: st.Key, Value: st.Value}
+		case "SuffixFilter":
+			f.SuffixFilter = &SuffixFilter{Key: st.Key, Value: st.Value}
+		case "ArrayContainsFilter":
+			f.ArrayContainsFilter = &ArrayContainsFilter{Key: st.Key, Value: st.Value}
+		case "TermInt64Filter":
+			f.TermInt64Filter = &TermInt64Filter{Key: it.Key, Value: it.Value}
+		case "GtInt64Filter":
+			f.GtInt64Filter = &GtInt64Filter{Key: it.Key, Value: it.Value}
+		case "LtInt64Filter":
+			f.LtInt64Filter = &LtInt64Filter{Key: it.Key, Value: it.Value}
+		case "GteInt64Filter":
+			f.GteInt64Filter = &GteInt64Filter{Key: it.Key, Value: it.Value}
+		case "LteInt64Filter":
+			f.LteInt64Filter = &LteInt64Filter{Key: it.Key, Value: it.Value}
+		case "GtFloat64Filter":
+			f.GtFloat64Filter = &GtFloat64Filter{Key: ft.Key, Value: ft.Value}
+		case "LtFloat64Filter":
+			f.LtFloat64Filter = &LtFloat64Filter{Key: ft.Key, Value: ft.Value}
+		case "GteFloat64Filter":
+			f.GteFloat64Filter = &GteFloat64Filter{Key: ft.Key, Value: ft.Value}
+		case "LteFloat64Filter":
+			f.LteFloat64Filter = &LteFloat64Filter{Key: ft.Key, Value: ft.Value}
+		}
+	}
+
+	return nil
+}
+
+// ParseFilterJSON returns the filter serialized in JSON by MarshalJSON
+func ParseFilterJSON(data []byte) (*Filter, error) {
+	f := &Filter{}
+	if err := json.Unmarshal(data, f); err != nil {
+		return nil, err
+	}
+	return f, nil
+}
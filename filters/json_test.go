@@ -0,0 +1,71 @@
+/*
+ * Copyright (C) 2016 Red Hat, Inc.
+ *
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ *
+ */
+
+package filters
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+func TestFilterJSON(t *testing.T) {
+	ipFilter, err := NewIPFilter("IPV4", "10.0.0.0/8")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	filter := NewAndFilter(
+		NewOrFilter(
+			NewTermStringFilter("Name", "eth0"),
+			NewTermInt64Filter("MTU", 1500),
+			&Filter{RegexFilter: &RegexFilter{Key: "Driver", Value: "veth.*"}},
+		),
+		NewNotFilter(NewGtInt64Filter("Value", 0)),
+		NewLtInt64Filter("Value", 10),
+		NewGteInt64Filter("Value", 1),
+		NewLteInt64Filter("Value", 9),
+		NewGtFloat64Filter("Ratio", 0.5),
+		NewContainsFilter("Name", "eth"),
+		ipFilter,
+		NewNotNullFilter("TID"),
+		NewArrayContainsFilter("BridgePorts", "eth1"),
+	)
+
+	data, err := json.Marshal(filter)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	parsed, err := ParseFilterJSON(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !reflect.DeepEqual(filter, parsed) {
+		t.Errorf("Filter not restored, expected %s, got %+v", string(data), parsed)
+	}
+
+	if _, err := ParseFilterJSON([]byte(`{"UnknownFilter": {}}`)); err == nil {
+		t.Error("Unknown filter should return an error")
+	}
+}
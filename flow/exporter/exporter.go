@@ -0,0 +1,409 @@
+/*
+ * Copyright (C) 2016 Red Hat, Inc.
+ *
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ *
+ */
+
+package exporter
+
+import (
+	"bytes"
+	"encoding/binary"
+	"net"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/skydive-project/skydive/flow"
+	"github.com/skydive-project/skydive/logging"
+)
+
+const (
+	// maxDatagramSize keeps the datagrams below the usual MTU
+	maxDatagramSize = 1400
+	// flowsQueueSize is the number of flow batches waiting to be exported,
+	// the batches being dropped once reached
+	flowsQueueSize = 100
+	// deltaTTL is the time after which a flow not updated is forgotten
+	deltaTTL = time.Hour
+)
+
+// Information elements, the ones used here having the same identifier in
+// IPFIX and NetFlow v9 except the flow times
+const (
+	ieOctetDeltaCount          uint16 = 1
+	iePacketDeltaCount         uint16 = 2
+	ieProtocolIdentifier       uint16 = 4
+	ieSourceTransportPort      uint16 = 7
+	ieSourceIPv4Address        uint16 = 8
+	ieDestinationTransportPort uint16 = 11
+	ieDestinationIPv4Address   uint16 = 12
+	ieLastSwitched             uint16 = 21
+	ieFirstSwitched            uint16 = 22
+	ieSourceIPv6Address        uint16 = 27
+	ieDestinationIPv6Address   uint16 = 28
+	ieSourceMacAddress         uint16 = 56
+	ieDestinationMacAddress    uint16 = 80
+	ieFlowStartSeconds         uint16 = 150
+	ieFlowEndSeconds           uint16 = 151
+)
+
+type field struct {
+	id     uint16
+	length uint16
+}
+
+// template describes the layout of the data records
+type template struct {
+	id     uint16
+	fields []field
+}
+
+func (t *template) recordSize() int {
+	size := 0
+	for _, f := range t.fields {
+		size += int(f.length)
+	}
+	return size
+}
+
+// record is a unidirectional flow record, the bidirectional Skydive flows
+// being exported as two records
+type record struct {
+	srcMAC   net.HardwareAddr
+	dstMAC   net.HardwareAddr
+	srcIP    net.IP
+	dstIP    net.IP
+	protocol uint8
+	srcPort  uint16
+	dstPort  uint16
+	octets   uint64
+	packets  uint64
+	start    int64
+	end      int64
+}
+
+func (r *record) isIPv6() bool {
+	return r.srcIP.To4() == nil
+}
+
+// put writes the value of the information element to the buffer, the times
+// being converted by the given function when not in seconds
+func (r *record) put(buf *bytes.Buffer, f field, timestamp func(sec int64) uint32) {
+	switch f.id {
+	case ieOctetDeltaCount:
+		binary.Write(buf, binary.BigEndian, r.octets)
+	case iePacketDeltaCount:
+		binary.Write(buf, binary.BigEndian, r.packets)
+	case ieProtocolIdentifier:
+		buf.WriteByte(r.protocol)
+	case ieSourceTransportPort:
+		binary.Write(buf, binary.BigEndian, r.srcPort)
+	case ieDestinationTransportPort:
+		binary.Write(buf, binary.BigEndian, r.dstPort)
+	case ieSourceIPv4Address:
+		buf.Write(r.srcIP.To4())
+	case ieDestinationIPv4Address:
+		buf.Write(r.dstIP.To4())
+	case ieSourceIPv6Address:
+		buf.Write(r.srcIP.To16())
+	case ieDestinationIPv6Address:
+		buf.Write(r.dstIP.To16())
+	case ieSourceMacAddress:
+		putMAC(buf, r.srcMAC)
+	case ieDestinationMacAddress:
+		putMAC(buf, r.dstMAC)
+	case ieFlowStartSeconds, ieFirstSwitched:
+		binary.Write(buf, binary.BigEndian, timestamp(r.start))
+	case ieFlowEndSeconds, ieLastSwitched:
+		binary.Write(buf, binary.BigEndian, timestamp(r.end))
+	default:
+		buf.Write(make([]byte, f.length))
+	}
+}
+
+func putMAC(buf *bytes.Buffer, mac net.HardwareAddr) {
+	if len(mac) != 6 {
+		mac = make(net.HardwareAddr, 6)
+	}
+	buf.Write(mac)
+}
+
+func transportProtocol(p flow.FlowProtocol) uint8 {
+	switch p {
+	case flow.FlowProtocol_TCPPORT:
+		return 6
+	case flow.FlowProtocol_UDPPORT:
+		return 17
+	case flow.FlowProtocol_SCTPPORT:
+		return 132
+	}
+	return 0
+}
+
+func parsePort(s string) uint16 {
+	port, _ := strconv.ParseUint(s, 10, 16)
+	return uint16(port)
+}
+
+// deltaTracker computes the amount of data of the flows since their previous
+// export, the flows received by the analyzer holding their total amount
+type deltaTracker struct {
+	metrics map[string]*flow.FlowMetric
+	seen    map[string]time.Time
+}
+
+func (d *deltaTracker) delta(f *flow.Flow, now time.Time) *flow.FlowMetric {
+	m := f.Metric.Copy()
+	delta := m.Copy()
+
+	if prev, ok := d.metrics[f.UUID]; ok && m.ABPackets >= prev.ABPackets && m.BAPackets >= prev.BAPackets {
+		delta.ABPackets -= prev.ABPackets
+		delta.ABBytes -= prev.ABBytes
+		delta.BAPackets -= prev.BAPackets
+		delta.BABytes -= prev.BABytes
+		delta.Start = prev.Last
+	}
+
+	d.metrics[f.UUID] = m
+	d.seen[f.UUID] = now
+
+	return delta
+}
+
+// expire forgets the flows not updated for a while
+func (d *deltaTracker) expire(now time.Time) {
+	for uuid, seen := range d.seen {
+		if now.Sub(seen) > deltaTTL {
+			delete(d.metrics, uuid)
+			delete(d.seen, uuid)
+		}
+	}
+}
+
+// records returns the records of the data of the flow since its previous
+// export, only the IP flows being exported
+func (d *deltaTracker) records(f *flow.Flow, now time.Time) []*record {
+	if f.Network == nil || f.Metric == nil {
+		return nil
+	}
+
+	srcIP, dstIP := net.ParseIP(f.Network.A), net.ParseIP(f.Network.B)
+	if srcIP == nil || dstIP == nil {
+		return nil
+	}
+
+	ab := &record{srcIP: srcIP, dstIP: dstIP}
+	if f.Link != nil {
+		ab.srcMAC, _ = net.ParseMAC(f.Link.A)
+		ab.dstMAC, _ = net.ParseMAC(f.Link.B)
+	}
+	if f.Transport != nil {
+		ab.protocol = transportProtocol(f.Transport.Protocol)
+		ab.srcPort, ab.dstPort = parsePort(f.Transport.A), parsePort(f.Transport.B)
+	}
+
+	delta := d.delta(f, now)
+	ab.start, ab.end = delta.Start, delta.Last
+
+	ba := &record{
+		srcMAC:   ab.dstMAC,
+		dstMAC:   ab.srcMAC,
+		srcIP:    ab.dstIP,
+		dstIP:    ab.srcIP,
+		protocol: ab.protocol,
+		srcPort:  ab.dstPort,
+		dstPort:  ab.srcPort,
+		start:    ab.start,
+		end:      ab.end,
+	}
+
+	ab.octets, ab.packets = uint64(delta.ABBytes), uint64(delta.ABPackets)
+	ba.octets, ba.packets = uint64(delta.BABytes), uint64(delta.BAPackets)
+
+	var records []*record
+	if ab.packets > 0 {
+		records = append(records, ab)
+	}
+	if ba.packets > 0 {
+		records = append(records, ba)
+	}
+	return records
+}
+
+// appendTemplateSet appends the set describing the templates
+func appendTemplateSet(buf *bytes.Buffer, setID uint16, templates []*template) {
+	length := 4
+	for _, t := range templates {
+		length += 4 + 4*len(t.fields)
+	}
+
+	binary.Write(buf, binary.BigEndian, setID)
+	binary.Write(buf, binary.BigEndian, uint16(length))
+	for _, t := range templates {
+		binary.Write(buf, binary.BigEndian, t.id)
+		binary.Write(buf, binary.BigEndian, uint16(len(t.fields)))
+		for _, f := range t.fields {
+			binary.Write(buf, binary.BigEndian, f.id)
+			binary.Write(buf, binary.BigEndian, f.length)
+		}
+	}
+}
+
+// appendDataSet appends a data set with as many records of the template as
+// fit in the given size, padding the set to 4 bytes if required. It returns
+// the number of records appended.
+func appendDataSet(buf *bytes.Buffer, t *template, records []*record, size int, pad bool, timestamp func(sec int64) uint32) int {
+	recordSize := t.recordSize()
+
+	count := (size - 4) / recordSize
+	if pad {
+		count = (size - 4 - 3) / recordSize
+	}
+	if count <= 0 {
+		return 0
+	}
+	if count > len(records) {
+		count = len(records)
+	}
+
+	length := 4 + count*recordSize
+	padding := 0
+	if pad && length%4 != 0 {
+		padding = 4 - length%4
+	}
+
+	binary.Write(buf, binary.BigEndian, t.id)
+	binary.Write(buf, binary.BigEndian, uint16(length+padding))
+	for _, r := range records[:count] {
+		for _, f := range t.fields {
+			r.put(buf, f, timestamp)
+		}
+	}
+	buf.Write(make([]byte, padding))
+
+	return count
+}
+
+// encoder encodes the records in the datagrams of an export protocol
+type encoder interface {
+	encode(v4, v6 []*record, withTemplates bool, now time.Time) [][]byte
+}
+
+// Exporter sends the flows stored by the analyzer to a collector, the flows
+// being encoded by the encoder of the export protocol
+type Exporter struct {
+	collector string
+	conn      net.Conn
+	encoder   encoder
+	refresh   time.Duration
+	templates time.Time
+	deltas    *deltaTracker
+	flows     chan []*flow.Flow
+	quit      chan struct{}
+	wg        sync.WaitGroup
+}
+
+// ExportFlows queues the flows to be exported
+func (e *Exporter) ExportFlows(flows []*flow.Flow) {
+	select {
+	case e.flows <- flows:
+	default:
+		logging.GetLogger().Errorf("Flow export queue to %s full, %d flows dropped", e.collector, len(flows))
+	}
+}
+
+func (e *Exporter) export(flows []*flow.Flow, now time.Time) {
+	var v4, v6 []*record
+	for _, f := range flows {
+		for _, r := range e.deltas.records(f, now) {
+			if r.isIPv6() {
+				v6 = append(v6, r)
+			} else {
+				v4 = append(v4, r)
+			}
+		}
+	}
+
+	// the templates are sent periodically, UDP being not reliable
+	withTemplates := now.Sub(e.templates) >= e.refresh
+	if len(v4) == 0 && len(v6) == 0 && !withTemplates {
+		return
+	}
+	if withTemplates {
+		e.templates = now
+	}
+
+	for _, datagram := range e.encoder.encode(v4, v6, withTemplates, now) {
+		if _, err := e.conn.Write(datagram); err != nil {
+			logging.GetLogger().Errorf("Failed to export flows to %s: %s", e.collector, err.Error())
+			return
+		}
+	}
+}
+
+func (e *Exporter) run() {
+	defer e.wg.Done()
+
+	ticker := time.NewTicker(time.Minute)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case flows := <-e.flows:
+			e.export(flows, time.Now())
+		case now := <-ticker.C:
+			e.deltas.expire(now)
+			e.export(nil, now)
+		case <-e.quit:
+			return
+		}
+	}
+}
+
+func (e *Exporter) Start() {
+	e.wg.Add(1)
+	go e.run()
+}
+
+func (e *Exporter) Stop() {
+	close(e.quit)
+	e.wg.Wait()
+	e.conn.Close()
+}
+
+func newExporter(collector string, refresh time.Duration, enc encoder) (*Exporter, error) {
+	conn, err := net.Dial("udp", collector)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Exporter{
+		collector: collector,
+		conn:      conn,
+		encoder:   enc,
+		refresh:   refresh,
+		deltas: &deltaTracker{
+			metrics: make(map[string]*flow.FlowMetric),
+			seen:    make(map[string]time.Time),
+		},
+		flows: make(chan []*flow.Flow, flowsQueueSize),
+		quit:  make(chan struct{}),
+	}, nil
+}
@@ -0,0 +1,142 @@
+/*
+ * Copyright (C) 2016 Red Hat, Inc.
+ *
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ *
+ */
+
+package exporter
+
+import (
+	"bytes"
+	"encoding/binary"
+	"time"
+
+	"github.com/skydive-project/skydive/config"
+)
+
+const (
+	ipfixVersion       uint16 = 10
+	ipfixHeaderSize           = 16
+	ipfixTemplateSetID uint16 = 2
+)
+
+var ipfixTemplates = []*template{
+	{
+		id: 256,
+		fields: []field{
+			{ieSourceMacAddress, 6},
+			{ieDestinationMacAddress, 6},
+			{ieSourceIPv4Address, 4},
+			{ieDestinationIPv4Address, 4},
+			{ieProtocolIdentifier, 1},
+			{ieSourceTransportPort, 2},
+			{ieDestinationTransportPort, 2},
+			{ieOctetDeltaCount, 8},
+			{iePacketDeltaCount, 8},
+			{ieFlowStartSeconds, 4},
+			{ieFlowEndSeconds, 4},
+		},
+	},
+	{
+		id: 257,
+		fields: []field{
+			{ieSourceMacAddress, 6},
+			{ieDestinationMacAddress, 6},
+			{ieSourceIPv6Address, 16},
+			{ieDestinationIPv6Address, 16},
+			{ieProtocolIdentifier, 1},
+			{ieSourceTransportPort, 2},
+			{ieDestinationTransportPort, 2},
+			{ieOctetDeltaCount, 8},
+			{iePacketDeltaCount, 8},
+			{ieFlowStartSeconds, 4},
+			{ieFlowEndSeconds, 4},
+		},
+	},
+}
+
+// IPFIXExporter exports the flows to an IPFIX collector over UDP, RFC 7011
+type IPFIXExporter struct {
+	*Exporter
+	domainID uint32
+	sequence uint32
+}
+
+func ipfixTimestamp(sec int64) uint32 {
+	return uint32(sec)
+}
+
+// encode returns the IPFIX messages holding the records, the sequence number
+// being the number of data records sent before the message
+func (e *IPFIXExporter) encode(v4, v6 []*record, withTemplates bool, now time.Time) [][]byte {
+	var messages [][]byte
+
+	for withTemplates || len(v4) > 0 || len(v6) > 0 {
+		var body bytes.Buffer
+		if withTemplates {
+			appendTemplateSet(&body, ipfixTemplateSetID, ipfixTemplates)
+			withTemplates = false
+		}
+
+		count := 0
+		if len(v4) > 0 {
+			n := appendDataSet(&body, ipfixTemplates[0], v4, maxDatagramSize-ipfixHeaderSize-body.Len(), false, ipfixTimestamp)
+			v4, count = v4[n:], count+n
+		}
+		if len(v6) > 0 {
+			n := appendDataSet(&body, ipfixTemplates[1], v6, maxDatagramSize-ipfixHeaderSize-body.Len(), false, ipfixTimestamp)
+			v6, count = v6[n:], count+n
+		}
+
+		var msg bytes.Buffer
+		binary.Write(&msg, binary.BigEndian, ipfixVersion)
+		binary.Write(&msg, binary.BigEndian, uint16(ipfixHeaderSize+body.Len()))
+		binary.Write(&msg, binary.BigEndian, uint32(now.Unix()))
+		binary.Write(&msg, binary.BigEndian, e.sequence)
+		binary.Write(&msg, binary.BigEndian, e.domainID)
+		msg.Write(body.Bytes())
+
+		e.sequence += uint32(count)
+		messages = append(messages, msg.Bytes())
+	}
+
+	return messages
+}
+
+// NewIPFIXExporter returns an exporter sending the flows to the collector
+// address, the templates being sent again every refresh interval
+func NewIPFIXExporter(collector string, domainID uint32, refresh time.Duration) (*IPFIXExporter, error) {
+	e := &IPFIXExporter{domainID: domainID}
+
+	exporter, err := newExporter(collector, refresh, e)
+	if err != nil {
+		return nil, err
+	}
+	e.Exporter = exporter
+
+	return e, nil
+}
+
+func NewIPFIXExporterFromConfig() (*IPFIXExporter, error) {
+	collector := config.GetConfig().GetString("analyzer.export.ipfix.collector")
+	domainID := uint32(config.GetConfig().GetInt("analyzer.export.ipfix.observation_domain"))
+	refresh := time.Duration(config.GetConfig().GetInt("analyzer.export.ipfix.template_refresh")) * time.Second
+
+	return NewIPFIXExporter(collector, domainID, refresh)
+}
@@ -0,0 +1,120 @@
+/*
+ * Copyright (C) 2016 Red Hat, Inc.
+ *
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ *
+ */
+
+package exporter
+
+import (
+	"encoding/binary"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/skydive-project/skydive/flow"
+)
+
+func newTestFlow() *flow.Flow {
+	return &flow.Flow{
+		UUID:      "flow1",
+		Link:      &flow.FlowLayer{Protocol: flow.FlowProtocol_ETHERNET, A: "00:11:22:33:44:55", B: "66:77:88:99:aa:bb"},
+		Network:   &flow.FlowLayer{Protocol: flow.FlowProtocol_IPV4, A: "192.168.0.1", B: "192.168.0.2"},
+		Transport: &flow.FlowLayer{Protocol: flow.FlowProtocol_TCPPORT, A: "12345", B: "80"},
+		Metric:    &flow.FlowMetric{Start: 1000, Last: 1010, ABPackets: 3, ABBytes: 300, BAPackets: 2, BABytes: 200},
+	}
+}
+
+func newTestCollector(t *testing.T) *net.UDPConn {
+	conn, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.ParseIP("127.0.0.1")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return conn
+}
+
+func readDatagram(t *testing.T, conn *net.UDPConn) []byte {
+	conn.SetReadDeadline(time.Now().Add(time.Second))
+
+	b := make([]byte, 65535)
+	n, err := conn.Read(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return b[:n]
+}
+
+func TestIPFIXExport(t *testing.T) {
+	conn := newTestCollector(t)
+	defer conn.Close()
+
+	e, err := NewIPFIXExporter(conn.LocalAddr().String(), 42, time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer e.conn.Close()
+
+	f := newTestFlow()
+	e.export([]*flow.Flow{f}, time.Now())
+
+	msg := readDatagram(t, conn)
+	if version := binary.BigEndian.Uint16(msg[0:2]); version != 10 {
+		t.Fatalf("Wrong IPFIX version: %d", version)
+	}
+	if length := binary.BigEndian.Uint16(msg[2:4]); int(length) != len(msg) {
+		t.Fatalf("Wrong message length: %d, expected %d", length, len(msg))
+	}
+	if domain := binary.BigEndian.Uint32(msg[12:16]); domain != 42 {
+		t.Fatalf("Wrong observation domain: %d", domain)
+	}
+
+	// template set then data set of the two directions of the flow
+	sets := msg[ipfixHeaderSize:]
+	if id := binary.BigEndian.Uint16(sets[0:2]); id != ipfixTemplateSetID {
+		t.Fatalf("Expected a template set first, got %d", id)
+	}
+	sets = sets[binary.BigEndian.Uint16(sets[2:4]):]
+
+	if id := binary.BigEndian.Uint16(sets[0:2]); id != 256 {
+		t.Fatalf("Expected an IPv4 data set, got %d", id)
+	}
+	if length := binary.BigEndian.Uint16(sets[2:4]); int(length) != 4+2*ipfixTemplates[0].recordSize() {
+		t.Fatalf("Expected two records, got a set of %d bytes", length)
+	}
+
+	// the next export only holds the data received meanwhile
+	f.Metric.ABPackets, f.Metric.ABBytes = 5, 500
+	e.export([]*flow.Flow{f}, time.Now())
+
+	msg = readDatagram(t, conn)
+	if seq := binary.BigEndian.Uint32(msg[8:12]); seq != 2 {
+		t.Fatalf("Wrong sequence number: %d", seq)
+	}
+
+	sets = msg[ipfixHeaderSize:]
+	if length := binary.BigEndian.Uint16(sets[2:4]); int(length) != 4+ipfixTemplates[0].recordSize() {
+		t.Fatalf("Expected one record, got a set of %d bytes", length)
+	}
+
+	record := sets[4:]
+	// octetDeltaCount follows the MACs, addresses, protocol and ports
+	if octets := binary.BigEndian.Uint64(record[25:33]); octets != 200 {
+		t.Fatalf("Wrong octet delta count: %d", octets)
+	}
+}
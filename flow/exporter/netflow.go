@@ -0,0 +1,154 @@
+/*
+ * Copyright (C) 2016 Red Hat, Inc.
+ *
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ *
+ */
+
+package exporter
+
+import (
+	"bytes"
+	"encoding/binary"
+	"time"
+
+	"github.com/skydive-project/skydive/config"
+)
+
+const (
+	netflowVersion       uint16 = 9
+	netflowHeaderSize           = 20
+	netflowTemplateSetID uint16 = 0
+)
+
+var netflowTemplates = []*template{
+	{
+		id: 256,
+		fields: []field{
+			{ieSourceMacAddress, 6},
+			{ieDestinationMacAddress, 6},
+			{ieSourceIPv4Address, 4},
+			{ieDestinationIPv4Address, 4},
+			{ieProtocolIdentifier, 1},
+			{ieSourceTransportPort, 2},
+			{ieDestinationTransportPort, 2},
+			{ieOctetDeltaCount, 8},
+			{iePacketDeltaCount, 8},
+			{ieFirstSwitched, 4},
+			{ieLastSwitched, 4},
+		},
+	},
+	{
+		id: 257,
+		fields: []field{
+			{ieSourceMacAddress, 6},
+			{ieDestinationMacAddress, 6},
+			{ieSourceIPv6Address, 16},
+			{ieDestinationIPv6Address, 16},
+			{ieProtocolIdentifier, 1},
+			{ieSourceTransportPort, 2},
+			{ieDestinationTransportPort, 2},
+			{ieOctetDeltaCount, 8},
+			{iePacketDeltaCount, 8},
+			{ieFirstSwitched, 4},
+			{ieLastSwitched, 4},
+		},
+	},
+}
+
+// NetFlowExporter exports the flows to a NetFlow v9 collector over UDP,
+// RFC 3954
+type NetFlowExporter struct {
+	*Exporter
+	sourceID uint32
+	sequence uint32
+	boot     time.Time
+}
+
+// uptime returns the time in milliseconds since the start of the exporter,
+// NetFlow v9 flow times being relative to the system uptime
+func (e *NetFlowExporter) uptime(sec int64) uint32 {
+	ms := sec*1000 - e.boot.UnixNano()/int64(time.Millisecond)
+	if ms < 0 {
+		return 0
+	}
+	return uint32(ms)
+}
+
+// encode returns the NetFlow v9 packets holding the records. Unlike IPFIX,
+// the count of the header includes the template records and the sequence
+// number is the number of packets sent before.
+func (e *NetFlowExporter) encode(v4, v6 []*record, withTemplates bool, now time.Time) [][]byte {
+	var packets [][]byte
+
+	for withTemplates || len(v4) > 0 || len(v6) > 0 {
+		var body bytes.Buffer
+
+		count := 0
+		if withTemplates {
+			appendTemplateSet(&body, netflowTemplateSetID, netflowTemplates)
+			count += len(netflowTemplates)
+			withTemplates = false
+		}
+
+		if len(v4) > 0 {
+			n := appendDataSet(&body, netflowTemplates[0], v4, maxDatagramSize-netflowHeaderSize-body.Len(), true, e.uptime)
+			v4, count = v4[n:], count+n
+		}
+		if len(v6) > 0 {
+			n := appendDataSet(&body, netflowTemplates[1], v6, maxDatagramSize-netflowHeaderSize-body.Len(), true, e.uptime)
+			v6, count = v6[n:], count+n
+		}
+
+		var packet bytes.Buffer
+		binary.Write(&packet, binary.BigEndian, netflowVersion)
+		binary.Write(&packet, binary.BigEndian, uint16(count))
+		binary.Write(&packet, binary.BigEndian, e.uptime(now.Unix()))
+		binary.Write(&packet, binary.BigEndian, uint32(now.Unix()))
+		binary.Write(&packet, binary.BigEndian, e.sequence)
+		binary.Write(&packet, binary.BigEndian, e.sourceID)
+		packet.Write(body.Bytes())
+
+		e.sequence++
+		packets = append(packets, packet.Bytes())
+	}
+
+	return packets
+}
+
+// NewNetFlowExporter returns an exporter sending the flows to the collector
+// address, the templates being sent again every refresh interval
+func NewNetFlowExporter(collector string, sourceID uint32, refresh time.Duration) (*NetFlowExporter, error) {
+	e := &NetFlowExporter{sourceID: sourceID, boot: time.Now()}
+
+	exporter, err := newExporter(collector, refresh, e)
+	if err != nil {
+		return nil, err
+	}
+	e.Exporter = exporter
+
+	return e, nil
+}
+
+func NewNetFlowExporterFromConfig() (*NetFlowExporter, error) {
+	collector := config.GetConfig().GetString("analyzer.export.netflow.collector")
+	sourceID := uint32(config.GetConfig().GetInt("analyzer.export.netflow.source_id"))
+	refresh := time.Duration(config.GetConfig().GetInt("analyzer.export.netflow.template_refresh")) * time.Second
+
+	return NewNetFlowExporter(collector, sourceID, refresh)
+}
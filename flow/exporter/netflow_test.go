@@ -0,0 +1,89 @@
+/*
+ * Copyright (C) 2016 Red Hat, Inc.
+ *
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ *
+ */
+
+package exporter
+
+import (
+	"encoding/binary"
+	"testing"
+	"time"
+
+	"github.com/skydive-project/skydive/flow"
+)
+
+func TestNetFlowExport(t *testing.T) {
+	conn := newTestCollector(t)
+	defer conn.Close()
+
+	e, err := NewNetFlowExporter(conn.LocalAddr().String(), 42, time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer e.conn.Close()
+
+	f := newTestFlow()
+	e.export([]*flow.Flow{f}, time.Now())
+
+	msg := readDatagram(t, conn)
+	if version := binary.BigEndian.Uint16(msg[0:2]); version != 9 {
+		t.Fatalf("Wrong NetFlow version: %d", version)
+	}
+	// two templates and the two directions of the flow
+	if count := binary.BigEndian.Uint16(msg[2:4]); count != 4 {
+		t.Fatalf("Wrong record count: %d", count)
+	}
+	if seq := binary.BigEndian.Uint32(msg[12:16]); seq != 0 {
+		t.Fatalf("Wrong sequence number: %d", seq)
+	}
+	if source := binary.BigEndian.Uint32(msg[16:20]); source != 42 {
+		t.Fatalf("Wrong source ID: %d", source)
+	}
+
+	sets := msg[netflowHeaderSize:]
+	if id := binary.BigEndian.Uint16(sets[0:2]); id != netflowTemplateSetID {
+		t.Fatalf("Expected a template flowset first, got %d", id)
+	}
+	sets = sets[binary.BigEndian.Uint16(sets[2:4]):]
+
+	if id := binary.BigEndian.Uint16(sets[0:2]); id != 256 {
+		t.Fatalf("Expected an IPv4 data flowset, got %d", id)
+	}
+	length := binary.BigEndian.Uint16(sets[2:4])
+	if length%4 != 0 {
+		t.Fatalf("Data flowset not padded: %d bytes", length)
+	}
+	if int(length) != len(sets) {
+		t.Fatalf("Wrong data flowset length: %d, expected %d", length, len(sets))
+	}
+
+	// the next export only holds the data received meanwhile
+	f.Metric.ABPackets, f.Metric.ABBytes = 5, 500
+	e.export([]*flow.Flow{f}, time.Now())
+
+	msg = readDatagram(t, conn)
+	if count := binary.BigEndian.Uint16(msg[2:4]); count != 1 {
+		t.Fatalf("Wrong record count: %d", count)
+	}
+	if seq := binary.BigEndian.Uint32(msg[12:16]); seq != 1 {
+		t.Fatalf("Wrong sequence number: %d", seq)
+	}
+}
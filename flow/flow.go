@@ -647,6 +647,18 @@ func (f *Flow) GetFieldInt64(field string) (int64, error) {
 	return 0, common.ErrFieldNotFound
 }
 
+func (f *Flow) GetFieldFloat64(field string) (float64, error) {
+	if field == "SamplingRate" {
+		return f.SamplingRate, nil
+	}
+
+	i, err := f.GetFieldInt64(field)
+	if err != nil {
+		return 0, err
+	}
+	return float64(i), nil
+}
+
 func (f *Flow) GetFields() []interface{} {
 	return fields
 }
@@ -0,0 +1,98 @@
+/*
+ * Copyright (C) 2016 Red Hat, Inc.
+ *
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ *
+ */
+
+package flow
+
+import (
+	"sync"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var (
+	flowsCreated = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "skydive_flows_created_total",
+		Help: "Number of flows created in the flow tables",
+	})
+
+	flowTableFlowsDesc = prometheus.NewDesc(
+		"skydive_flow_table_flows",
+		"Number of flows in the flow tables",
+		nil, nil,
+	)
+	flowTablesDesc = prometheus.NewDesc(
+		"skydive_flow_tables",
+		"Number of allocated flow tables",
+		nil, nil,
+	)
+)
+
+// allocatorCollector exports the size of the flow tables of an allocator,
+// computed when the metrics are collected
+type allocatorCollector struct {
+	sync.RWMutex
+	allocator *TableAllocator
+}
+
+var allocatorMetrics = &allocatorCollector{}
+
+func (c *allocatorCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- flowTableFlowsDesc
+	ch <- flowTablesDesc
+}
+
+func (c *allocatorCollector) Collect(ch chan<- prometheus.Metric) {
+	c.RLock()
+	a := c.allocator
+	c.RUnlock()
+
+	if a == nil {
+		return
+	}
+
+	a.RLock()
+	tables := len(a.tables)
+
+	flows := 0
+	for table := range a.tables {
+		table.RLock()
+		flows += len(table.table)
+		table.RUnlock()
+	}
+	a.RUnlock()
+
+	ch <- prometheus.MustNewConstMetric(flowTableFlowsDesc, prometheus.GaugeValue, float64(flows))
+	ch <- prometheus.MustNewConstMetric(flowTablesDesc, prometheus.GaugeValue, float64(tables))
+}
+
+// RegisterMetrics exports the size of the flow tables of the allocator,
+// replacing the allocator previously registered
+func (a *TableAllocator) RegisterMetrics() {
+	allocatorMetrics.Lock()
+	allocatorMetrics.allocator = a
+	allocatorMetrics.Unlock()
+}
+
+func init() {
+	prometheus.MustRegister(flowsCreated)
+	prometheus.MustRegister(allocatorMetrics)
+}
@@ -23,6 +23,7 @@
 package client
 
 import (
+	"sort"
 	"sync"
 
 	"github.com/skydive-project/skydive/api"
@@ -38,64 +39,116 @@ import (
 type OnDemandProbeClient struct {
 	sync.RWMutex
 	graph.DefaultGraphListener
+	shttp.DefaultWSServerEventHandler
 	graph          *graph.Graph
 	captureHandler *api.CaptureAPIHandler
 	wsServer       *shttp.WSServer
 	captures       map[string]*api.Capture
 	watcher        api.StoppableWatcher
 	elector        *etcd.EtcdMasterElector
+	retriesLock    sync.Mutex
+	retries        map[string]*agentRetry
+	statusLock     sync.RWMutex
+	status         CaptureStatus
+	nodesLock      sync.Mutex
+	owners         map[graph.Identifier]*api.Capture
+	waiting        map[graph.Identifier][]*api.Capture
+	pendingLock    sync.Mutex
+	pending        map[string][]*pendingMessage
+	listsLock      sync.Mutex
+	lists          map[string]chan []ondemand.CaptureQuery
+	syncing        int32
+	expiriesLock   sync.Mutex
+	expiries       map[string]*captureExpiry
+	quit           chan struct{}
+	wg             sync.WaitGroup
 }
 
+// registerNode returns the host of the node and whether the capture has to be
+// started on it. The capture preempted on the node, if any, is stopped.
+func (o *OnDemandProbeClient) registerNode(node *graph.Node, capture *api.Capture) (string, bool) {
+	o.graph.RLock()
+	nodeID := node.ID
+	host := node.Host()
+	captureID, _ := node.GetFieldString("Capture/ID")
+	o.graph.RUnlock()
+
+	if o.isCaptureCompleted(capture.UUID, nodeID) {
+		return host, false
+	}
+
+	// only one capture at a time per node, the others are queued
+	claimed, preempted := o.claimNode(nodeID, capture)
+	if !claimed || captureID == capture.UUID {
+		return host, false
+	}
+
+	if preempted != nil {
+		logging.GetLogger().Infof("Capture %s preempted by capture %s on node %s", preempted.UUID, capture.UUID, nodeID)
+		if !o.unregisterProbe(node, preempted) {
+			logging.GetLogger().Errorf("Failed to stop capture on %s", nodeID)
+		}
+	}
+
+	return host, true
+}
+
+// registerProbes starts the capture on the nodes, a single message being
+// sent per agent
 func (o *OnDemandProbeClient) registerProbes(nodes []interface{}, capture *api.Capture) {
+	batches := make(map[string][]graph.Identifier)
+	addNode := func(node *graph.Node) {
+		if host, ok := o.registerNode(node, capture); ok {
+			batches[host] = append(batches[host], node.ID)
+		}
+	}
+
 	for _, i := range nodes {
 		switch i.(type) {
 		case *graph.Node:
-			o.graph.RLock()
-			node := i.(*graph.Node)
-			if _, err := node.GetFieldString("Capture/ID"); err == nil {
-				o.graph.RUnlock()
-				return
-			}
-			nodeID := node.ID
-			host := node.Host()
-			o.graph.RUnlock()
-			o.registerProbe(nodeID, host, capture)
+			addNode(i.(*graph.Node))
 		case []*graph.Node:
 			// case of shortestpath that return a list of nodes
 			for _, node := range i.([]*graph.Node) {
-				o.graph.RLock()
-				if _, err := node.GetFieldString("Capture/ID"); err == nil {
-					o.graph.RUnlock()
-					return
-				}
-				nodeID := node.ID
-				host := node.Host()
-				o.graph.RUnlock()
-				o.registerProbe(nodeID, host, capture)
+				addNode(node)
 			}
 		}
 	}
-}
 
-func (o *OnDemandProbeClient) registerProbe(id graph.Identifier, host string, capture *api.Capture) bool {
-	cq := ondemand.CaptureQuery{
-		NodeID:  string(id),
-		Capture: *capture,
+	for host, ids := range batches {
+		o.registerProbe(ids, host, capture)
 	}
+}
 
-	msg := shttp.NewWSMessage(ondemand.Namespace, "CaptureStart", cq)
+// registerProbe starts the capture on nodes of the same agent, a batch
+// message being sent for several nodes
+func (o *OnDemandProbeClient) registerProbe(ids []graph.Identifier, host string, capture *api.Capture) bool {
+	cqs := make([]ondemand.CaptureQuery, len(ids))
+	for i, id := range ids {
+		cqs[i] = ondemand.CaptureQuery{
+			NodeID:  string(id),
+			Capture: *capture,
+		}
+		o.setCaptureState(capture.UUID, id, CapturePending)
+	}
 
-	if !o.wsServer.SendWSMessageTo(msg, host) {
+	// queued if the agent is disconnected
+	if !o.sendToAgent(host, "CaptureStart", cqs...) {
 		logging.GetLogger().Errorf("Unable to send message to agent: %s", host)
 		return false
 	}
+
 	return true
 }
 
-func (o *OnDemandProbeClient) unregisterProbe(node *graph.Node) bool {
-	msg := shttp.NewWSMessage(ondemand.Namespace, "CaptureStop", ondemand.CaptureQuery{NodeID: string(node.ID)})
+func (o *OnDemandProbeClient) unregisterProbe(node *graph.Node, capture *api.Capture) bool {
+	cq := ondemand.CaptureQuery{
+		NodeID:  string(node.ID),
+		Capture: *capture,
+	}
 
-	if !o.wsServer.SendWSMessageTo(msg, node.Host()) {
+	// queued if the agent is disconnected to not leave the capture running
+	if !o.sendToAgent(node.Host(), "CaptureStop", cq) {
 		logging.GetLogger().Errorf("Unable to send message to agent: %s", node.Host())
 		return false
 	}
@@ -113,16 +166,30 @@ func (o *OnDemandProbeClient) applyGremlinExpr(query string) []interface{} {
 }
 
 func (o *OnDemandProbeClient) onNodeEvent() {
-	if !o.elector.IsMaster() {
+	if !o.elector.IsMaster() || o.isSyncing() {
 		return
 	}
 
+	// register the captures with the highest priority first so that they
+	// win over the others on the nodes they share
+	captures := make(capturesByPriority, 0, len(o.captures))
 	for _, capture := range o.captures {
-		res := o.applyGremlinExpr(capture.GremlinQuery)
-		if len(res) > 0 {
-			go o.registerProbes(res, capture)
-		}
+		captures = append(captures, capture)
+	}
+	sort.Sort(captures)
+
+	var results [][]interface{}
+	for _, capture := range captures {
+		results = append(results, o.applyGremlinExpr(capture.GremlinQuery))
 	}
+
+	go func() {
+		for i, res := range results {
+			if len(res) > 0 {
+				o.registerProbes(res, captures[i])
+			}
+		}
+	}()
 }
 
 func (o *OnDemandProbeClient) OnNodeAdded(n *graph.Node) {
@@ -137,6 +204,13 @@ func (o *OnDemandProbeClient) OnEdgeAdded(e *graph.Edge) {
 	o.onNodeEvent()
 }
 
+// OnNodeDeleted frees the deleted node, the captures queued to be started on
+// it being dropped
+func (o *OnDemandProbeClient) OnNodeDeleted(n *graph.Node) {
+	o.forgetNode(n.ID)
+	o.prunePending()
+}
+
 func (o *OnDemandProbeClient) onCaptureAdded(capture *api.Capture) {
 	if !o.elector.IsMaster() {
 		return
@@ -149,6 +223,13 @@ func (o *OnDemandProbeClient) onCaptureAdded(capture *api.Capture) {
 	defer o.graph.RUnlock()
 
 	o.captures[capture.UUID] = capture
+	o.scheduleExpiry(capture)
+
+	// the capture will be registered once the captures of the previous
+	// leader taken over
+	if o.isSyncing() {
+		return
+	}
 
 	nodes := o.applyGremlinExpr(capture.GremlinQuery)
 	if len(nodes) > 0 {
@@ -168,6 +249,8 @@ func (o *OnDemandProbeClient) onCaptureDeleted(capture *api.Capture) {
 	defer o.graph.Unlock()
 
 	delete(o.captures, capture.UUID)
+	o.cancelExpiry(capture.UUID)
+	o.deleteCaptureStatus(capture.UUID)
 
 	res, err := topology.ExecuteGremlinQuery(o.graph, capture.GremlinQuery)
 	if err != nil {
@@ -175,22 +258,60 @@ func (o *OnDemandProbeClient) onCaptureDeleted(capture *api.Capture) {
 		return
 	}
 
+	var nodes []*graph.Node
 	for _, value := range res.Values() {
 		switch e := value.(type) {
 		case *graph.Node:
-			if !o.unregisterProbe(e) {
-				logging.GetLogger().Errorf("Failed to stop capture on %s", e.ID)
-			}
+			nodes = append(nodes, e)
 		case []*graph.Node:
-			for _, node := range e {
-				if !o.unregisterProbe(node) {
-					logging.GetLogger().Errorf("Failed to stop capture on %s", node.ID)
-				}
-			}
-		default:
-			return
+			nodes = append(nodes, e...)
+		}
+	}
+
+	for _, node := range nodes {
+		// do not stop the capture of another capture matching the same node
+		if id, _ := node.GetFieldString("Capture/ID"); id != capture.UUID && !o.isOwner(node.ID, capture) {
+			continue
+		}
+
+		if !o.unregisterProbe(node, capture) {
+			logging.GetLogger().Errorf("Failed to stop capture on %s", node.ID)
 		}
 	}
+
+	// start the next waiting captures on the released nodes
+	for id, next := range o.releaseCapture(capture) {
+		if node := o.graph.GetNode(id); node != nil {
+			go o.registerProbes([]interface{}{node}, next)
+		}
+	}
+
+	// the capture is not started on the nodes of disconnected agents
+	o.prunePending()
+}
+
+// onCaptureCompleted releases the node on which the capture reached its
+// maximum number of packets or bytes, starting the next waiting capture
+func (o *OnDemandProbeClient) onCaptureCompleted(id graph.Identifier, capture *api.Capture) {
+	logging.GetLogger().Infof("Capture %s completed on node %s", capture.UUID, id)
+	o.updateCaptureState(capture.UUID, id, CaptureCompleted)
+
+	if !o.elector.IsMaster() {
+		return
+	}
+
+	next := o.releaseNode(id, capture)
+	if next == nil {
+		return
+	}
+
+	o.graph.RLock()
+	node := o.graph.GetNode(id)
+	o.graph.RUnlock()
+
+	if node != nil {
+		go o.registerProbes([]interface{}{node}, next)
+	}
 }
 
 func (o *OnDemandProbeClient) onAPIWatcherEvent(action string, id string, resource api.APIResource) {
@@ -207,15 +328,24 @@ func (o *OnDemandProbeClient) onAPIWatcherEvent(action string, id string, resour
 }
 
 func (o *OnDemandProbeClient) Start() {
+	o.elector.AddEventListener(o)
 	o.elector.StartAndWait()
 
 	o.watcher = o.captureHandler.AsyncWatch(o.onAPIWatcherEvent)
 	o.graph.AddEventListener(o)
+	o.wsServer.AddEventHandler(o)
+
+	o.wg.Add(1)
+	go o.retryLoop()
 }
 
 func (o *OnDemandProbeClient) Stop() {
 	o.watcher.Stop()
 	o.elector.Stop()
+	o.cancelAllExpiries()
+
+	close(o.quit)
+	o.wg.Wait()
 }
 
 func NewOnDemandProbeClient(g *graph.Graph, ch *api.CaptureAPIHandler, w *shttp.WSServer, etcdClient *etcd.EtcdClient) *OnDemandProbeClient {
@@ -233,5 +363,13 @@ func NewOnDemandProbeClient(g *graph.Graph, ch *api.CaptureAPIHandler, w *shttp.
 		wsServer:       w,
 		captures:       captures,
 		elector:        elector,
+		retries:        make(map[string]*agentRetry),
+		status:         make(CaptureStatus),
+		owners:         make(map[graph.Identifier]*api.Capture),
+		waiting:        make(map[graph.Identifier][]*api.Capture),
+		lists:          make(map[string]chan []ondemand.CaptureQuery),
+		pending:        make(map[string][]*pendingMessage),
+		expiries:       make(map[string]*captureExpiry),
+		quit:           make(chan struct{}),
 	}
 }
@@ -0,0 +1,129 @@
+/*
+ * Copyright (C) 2016 Red Hat, Inc.
+ *
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ *
+ */
+
+package client
+
+import (
+	"testing"
+
+	"github.com/skydive-project/skydive/api"
+	"github.com/skydive-project/skydive/flow/ondemand"
+	shttp "github.com/skydive-project/skydive/http"
+	"github.com/skydive-project/skydive/topology/graph"
+)
+
+// newTestClient returns a client whose agents are all disconnected
+func newTestClient(t *testing.T) *OnDemandProbeClient {
+	backend, err := graph.NewMemoryBackend()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return &OnDemandProbeClient{
+		graph:    graph.NewGraph("analyzer", backend),
+		wsServer: &shttp.WSServer{},
+		retries:  make(map[string]*agentRetry),
+		status:   make(CaptureStatus),
+		owners:   make(map[graph.Identifier]*api.Capture),
+		waiting:  make(map[graph.Identifier][]*api.Capture),
+		pending:  make(map[string][]*pendingMessage),
+	}
+}
+
+func newTestNode(t *testing.T, o *OnDemandProbeClient) *graph.Node {
+	o.graph.Lock()
+	defer o.graph.Unlock()
+
+	return o.graph.NewNode(graph.GenID(), graph.Metadata{"Type": "netns"}, "agent")
+}
+
+func TestRegisterProbeQueued(t *testing.T) {
+	o := newTestClient(t)
+	node := newTestNode(t, o)
+
+	capture := &api.Capture{UUID: "capture"}
+	o.claimNode(node.ID, capture)
+
+	if o.registerProbe([]graph.Identifier{node.ID}, "agent", capture) {
+		t.Fatal("The agent should not be reachable")
+	}
+	o.registerProbe([]graph.Identifier{node.ID}, "agent", capture)
+
+	if len(o.pending["agent"]) != 1 || o.pending["agent"][0].msgType != "CaptureStart" {
+		t.Fatalf("The start should be queued once, got: %v", o.pending["agent"])
+	}
+
+	r, ok := o.retries["agent"]
+	if !ok || r.delay != retryMinDelay {
+		t.Fatalf("A retry should be scheduled for the agent, got: %v", o.retries)
+	}
+
+	o.backoffRetry("agent")
+	if r.delay != 2*retryMinDelay {
+		t.Errorf("The retry delay should double, got: %s", r.delay)
+	}
+}
+
+func TestNodeDeletedDropsPending(t *testing.T) {
+	o := newTestClient(t)
+	node := newTestNode(t, o)
+
+	first := &api.Capture{UUID: "first", Priority: 1}
+	second := &api.Capture{UUID: "second"}
+	if claimed, _ := o.claimNode(node.ID, first); !claimed {
+		t.Fatal("The node should be claimed by the first capture")
+	}
+	o.claimNode(node.ID, second)
+	o.registerProbe([]graph.Identifier{node.ID}, "agent", first)
+
+	o.graph.AddEventListener(o)
+
+	o.graph.Lock()
+	o.graph.DelNode(node)
+	o.graph.Unlock()
+
+	if len(o.pending) != 0 || len(o.retries) != 0 {
+		t.Errorf("The start and its retry should be dropped, got %v and %v", o.pending, o.retries)
+	}
+	if len(o.owners) != 0 || len(o.waiting) != 0 {
+		t.Errorf("The node should be released, got owners %v and waiting %v", o.owners, o.waiting)
+	}
+}
+
+func TestPendingStaleStop(t *testing.T) {
+	o := newTestClient(t)
+	node := newTestNode(t, o)
+
+	// the capture is stopped while the agent is disconnected, then started
+	// again on the node
+	capture := &api.Capture{UUID: "capture"}
+	o.sendToAgent("agent", "CaptureStop", ondemand.CaptureQuery{NodeID: string(node.ID), Capture: *capture})
+	o.claimNode(node.ID, capture)
+
+	// the stop would stop the capture restarted on the node
+	if !o.flushPending("agent") {
+		t.Fatal("The stale stop should be dropped instead of being sent")
+	}
+	if len(o.pending) != 0 || len(o.retries) != 0 {
+		t.Errorf("Nothing should be pending, got %v and %v", o.pending, o.retries)
+	}
+}
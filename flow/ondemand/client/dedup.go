@@ -0,0 +1,168 @@
+/*
+ * Copyright (C) 2016 Red Hat, Inc.
+ *
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ *
+ */
+
+package client
+
+import (
+	"sort"
+
+	"github.com/skydive-project/skydive/api"
+	"github.com/skydive-project/skydive/topology/graph"
+)
+
+// capturesByPriority sorts the captures by priority, highest first
+type capturesByPriority []*api.Capture
+
+func (c capturesByPriority) Len() int           { return len(c) }
+func (c capturesByPriority) Swap(i, j int)      { c[i], c[j] = c[j], c[i] }
+func (c capturesByPriority) Less(i, j int) bool { return c[i].Priority > c[j].Priority }
+
+// enqueue adds the capture to the queue of the node, keeping the queue
+// sorted by priority
+func (o *OnDemandProbeClient) enqueue(id graph.Identifier, capture *api.Capture) {
+	for _, c := range o.waiting[id] {
+		if c.UUID == capture.UUID {
+			return
+		}
+	}
+
+	captures := append(o.waiting[id], capture)
+	sort.Stable(capturesByPriority(captures))
+	o.waiting[id] = captures
+}
+
+// claimNode reserves the node for the capture. It returns false if a capture
+// with a higher or equal priority already runs on the node, the capture being
+// then queued to be started once the running one is deleted. A running
+// capture with a lower priority is preempted, queued and returned.
+func (o *OnDemandProbeClient) claimNode(id graph.Identifier, capture *api.Capture) (bool, *api.Capture) {
+	o.nodesLock.Lock()
+	defer o.nodesLock.Unlock()
+
+	owner, ok := o.owners[id]
+	if !ok {
+		o.owners[id] = capture
+		return true, nil
+	}
+
+	if owner.UUID == capture.UUID {
+		return true, nil
+	}
+
+	if capture.Priority > owner.Priority {
+		o.owners[id] = capture
+		o.enqueue(id, owner)
+		return true, owner
+	}
+
+	o.enqueue(id, capture)
+
+	return false, nil
+}
+
+// isOwner returns whether the capture runs on the node
+func (o *OnDemandProbeClient) isOwner(id graph.Identifier, capture *api.Capture) bool {
+	o.nodesLock.Lock()
+	defer o.nodesLock.Unlock()
+
+	owner, ok := o.owners[id]
+	return ok && owner.UUID == capture.UUID
+}
+
+// releaseCapture frees the nodes on which the capture runs and removes it
+// from the waiting queues. For each node released, the next waiting capture,
+// if any, becomes the owner of the node and is returned.
+func (o *OnDemandProbeClient) releaseCapture(capture *api.Capture) map[graph.Identifier]*api.Capture {
+	o.nodesLock.Lock()
+	defer o.nodesLock.Unlock()
+
+	for id, captures := range o.waiting {
+		for i, c := range captures {
+			if c.UUID == capture.UUID {
+				captures = append(captures[:i], captures[i+1:]...)
+				break
+			}
+		}
+
+		if len(captures) == 0 {
+			delete(o.waiting, id)
+		} else {
+			o.waiting[id] = captures
+		}
+	}
+
+	next := make(map[graph.Identifier]*api.Capture)
+	for id, owner := range o.owners {
+		if owner.UUID != capture.UUID {
+			continue
+		}
+
+		if c := o.promoteNext(id); c != nil {
+			next[id] = c
+		}
+	}
+
+	return next
+}
+
+// releaseNode frees the node on which the capture completed. The next waiting
+// capture, if any, becomes the owner of the node and is returned.
+func (o *OnDemandProbeClient) releaseNode(id graph.Identifier, capture *api.Capture) *api.Capture {
+	o.nodesLock.Lock()
+	defer o.nodesLock.Unlock()
+
+	if owner, ok := o.owners[id]; !ok || owner.UUID != capture.UUID {
+		return nil
+	}
+
+	return o.promoteNext(id)
+}
+
+// forgetNode frees the deleted node, the captures waiting for it being
+// dropped from its queue
+func (o *OnDemandProbeClient) forgetNode(id graph.Identifier) {
+	o.nodesLock.Lock()
+	delete(o.owners, id)
+	delete(o.waiting, id)
+	o.nodesLock.Unlock()
+}
+
+// promoteNext makes the first waiting capture of the node its owner and
+// returns it, the node having no owner left if none is waiting. The nodes lock
+// has to be held.
+func (o *OnDemandProbeClient) promoteNext(id graph.Identifier) *api.Capture {
+	delete(o.owners, id)
+
+	captures := o.waiting[id]
+	if len(captures) == 0 {
+		return nil
+	}
+
+	o.owners[id] = captures[0]
+	if len(captures) == 1 {
+		delete(o.waiting, id)
+	} else {
+		o.waiting[id] = captures[1:]
+	}
+
+	return captures[0]
+}
@@ -0,0 +1,116 @@
+/*
+ * Copyright (C) 2016 Red Hat, Inc.
+ *
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ *
+ */
+
+package client
+
+import (
+	"time"
+
+	"github.com/skydive-project/skydive/api"
+	"github.com/skydive-project/skydive/logging"
+)
+
+// captureExpiry is the timer stopping a capture once its duration elapsed
+type captureExpiry struct {
+	timer    *time.Timer
+	deadline time.Time
+}
+
+// scheduleExpiry arms the expiration timer of the capture if it has a
+// duration. The duration starts when the capture is seen by the leader.
+func (o *OnDemandProbeClient) scheduleExpiry(capture *api.Capture) {
+	if capture.Duration <= 0 {
+		return
+	}
+
+	o.expiriesLock.Lock()
+	defer o.expiriesLock.Unlock()
+
+	if _, ok := o.expiries[capture.UUID]; ok {
+		return
+	}
+
+	uuid := capture.UUID
+	o.expiries[uuid] = &captureExpiry{
+		timer:    time.AfterFunc(capture.Duration, func() { o.expireCapture(uuid) }),
+		deadline: time.Now().Add(capture.Duration),
+	}
+}
+
+func (o *OnDemandProbeClient) cancelExpiry(uuid string) {
+	o.expiriesLock.Lock()
+	defer o.expiriesLock.Unlock()
+
+	if e, ok := o.expiries[uuid]; ok {
+		e.timer.Stop()
+		delete(o.expiries, uuid)
+	}
+}
+
+func (o *OnDemandProbeClient) cancelAllExpiries() {
+	o.expiriesLock.Lock()
+	defer o.expiriesLock.Unlock()
+
+	for uuid, e := range o.expiries {
+		e.timer.Stop()
+		delete(o.expiries, uuid)
+	}
+}
+
+// remainingDuration returns the time left before the expiration of the
+// capture, if it has a duration
+func (o *OnDemandProbeClient) remainingDuration(uuid string) (time.Duration, bool) {
+	o.expiriesLock.Lock()
+	defer o.expiriesLock.Unlock()
+
+	e, ok := o.expiries[uuid]
+	if !ok {
+		return 0, false
+	}
+
+	remaining := e.deadline.Sub(time.Now())
+	if remaining < 0 {
+		remaining = 0
+	}
+	return remaining, true
+}
+
+// expireCapture stops the capture and deletes it
+func (o *OnDemandProbeClient) expireCapture(uuid string) {
+	o.cancelExpiry(uuid)
+
+	o.RLock()
+	capture, ok := o.captures[uuid]
+	o.RUnlock()
+
+	if !ok {
+		return
+	}
+
+	logging.GetLogger().Infof("Capture %s expired after %s", uuid, capture.Duration)
+
+	o.onCaptureDeleted(capture)
+
+	if err := o.captureHandler.Delete(uuid); err != nil {
+		logging.GetLogger().Errorf("Unable to delete expired capture %s: %s", uuid, err.Error())
+	}
+}
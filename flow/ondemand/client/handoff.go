@@ -0,0 +1,160 @@
+/*
+ * Copyright (C) 2016 Red Hat, Inc.
+ *
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ *
+ */
+
+package client
+
+import (
+	"encoding/json"
+	"sync/atomic"
+	"time"
+
+	"github.com/skydive-project/skydive/api"
+	"github.com/skydive-project/skydive/common"
+	"github.com/skydive-project/skydive/flow/ondemand"
+	shttp "github.com/skydive-project/skydive/http"
+	"github.com/skydive-project/skydive/logging"
+	"github.com/skydive-project/skydive/topology/graph"
+)
+
+// captureListTimeout is the maximum time to wait for the agents to report
+// their active captures after a leadership change
+const captureListTimeout = 5 * time.Second
+
+// OnMaster takes over the captures started by the previous leader, the
+// registrations being suspended until the agents reported their active
+// captures
+func (o *OnDemandProbeClient) OnMaster() {
+	atomic.StoreInt32(&o.syncing, 1)
+	go o.syncCaptures()
+}
+
+// OnSlave stops the expiration timers, the new leader taking them over
+func (o *OnDemandProbeClient) OnSlave() {
+	o.cancelAllExpiries()
+}
+
+func (o *OnDemandProbeClient) isSyncing() bool {
+	return atomic.LoadInt32(&o.syncing) == 1
+}
+
+// syncCaptures asks each agent for its active captures, adopts them and then
+// registers the captures on the nodes not already captured
+func (o *OnDemandProbeClient) syncCaptures() {
+	clients := o.wsServer.GetClientsByType(common.AgentService)
+	replies := make(chan []ondemand.CaptureQuery, len(clients))
+
+	var uuids []string
+	o.listsLock.Lock()
+	for _, c := range clients {
+		msg := shttp.NewWSMessage(ondemand.Namespace, "CaptureList", nil)
+		o.lists[msg.UUID] = replies
+		uuids = append(uuids, msg.UUID)
+		c.SendWSMessage(msg)
+	}
+	o.listsLock.Unlock()
+
+	timeout := time.After(captureListTimeout)
+	for received := 0; received < len(clients); received++ {
+		select {
+		case queries := <-replies:
+			for _, query := range queries {
+				o.adoptCapture(query)
+			}
+		case <-timeout:
+			logging.GetLogger().Errorf("Timeout while waiting for the active captures of the agents, %d/%d replies", received, len(clients))
+			received = len(clients)
+		}
+	}
+
+	o.listsLock.Lock()
+	for _, uuid := range uuids {
+		delete(o.lists, uuid)
+	}
+	o.listsLock.Unlock()
+
+	o.RLock()
+	for _, capture := range o.captures {
+		o.scheduleExpiry(capture)
+	}
+	o.RUnlock()
+
+	atomic.StoreInt32(&o.syncing, 0)
+
+	o.graph.RLock()
+	o.onNodeEvent()
+	o.graph.RUnlock()
+}
+
+// adoptCapture records a capture reported as running by an agent, so that it
+// is not started again. Captures deleted in the meantime are stopped.
+func (o *OnDemandProbeClient) adoptCapture(query ondemand.CaptureQuery) {
+	id := graph.Identifier(query.NodeID)
+
+	o.RLock()
+	capture, ok := o.captures[query.Capture.UUID]
+	o.RUnlock()
+
+	if !ok {
+		o.graph.RLock()
+		node := o.graph.GetNode(id)
+		o.graph.RUnlock()
+
+		if node != nil {
+			logging.GetLogger().Infof("Stopping capture %s on node %s as not existing anymore", query.Capture.UUID, id)
+			o.unregisterProbe(node, &query.Capture)
+		}
+		return
+	}
+
+	o.adoptNode(id, capture)
+	o.setCaptureState(capture.UUID, id, CaptureActive)
+}
+
+// adoptNode makes the capture the owner of the node, the previous owner if
+// any being queued
+func (o *OnDemandProbeClient) adoptNode(id graph.Identifier, capture *api.Capture) {
+	o.nodesLock.Lock()
+	defer o.nodesLock.Unlock()
+
+	if owner, ok := o.owners[id]; ok && owner.UUID != capture.UUID {
+		o.enqueue(id, owner)
+	}
+	o.owners[id] = capture
+}
+
+// onCaptureList dispatches the list of the active captures of an agent to
+// the pending synchronization
+func (o *OnDemandProbeClient) onCaptureList(msg shttp.WSMessage) {
+	var queries []ondemand.CaptureQuery
+	if err := json.Unmarshal([]byte(*msg.Obj), &queries); err != nil {
+		logging.GetLogger().Errorf("Unable to decode capture list %v", msg)
+		return
+	}
+
+	o.listsLock.Lock()
+	defer o.listsLock.Unlock()
+
+	if replies, ok := o.lists[msg.UUID]; ok {
+		delete(o.lists, msg.UUID)
+		replies <- queries
+	}
+}
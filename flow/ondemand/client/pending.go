@@ -0,0 +1,179 @@
+/*
+ * Copyright (C) 2016 Red Hat, Inc.
+ *
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ *
+ */
+
+package client
+
+import (
+	"github.com/skydive-project/skydive/flow/ondemand"
+	shttp "github.com/skydive-project/skydive/http"
+	"github.com/skydive-project/skydive/logging"
+	"github.com/skydive-project/skydive/topology/graph"
+)
+
+// maxPendingMessages is the maximum number of messages queued per agent
+const maxPendingMessages = 1024
+
+// pendingMessage is a message queued for a disconnected agent
+type pendingMessage struct {
+	msgType string
+	queries []ondemand.CaptureQuery
+}
+
+// newCaptureMessage returns the message carrying the queries, several
+// queries being sent with a single batch message
+func newCaptureMessage(msgType string, queries []ondemand.CaptureQuery) *shttp.WSMessage {
+	if len(queries) == 1 {
+		return shttp.NewWSMessage(ondemand.Namespace, msgType, queries[0])
+	}
+	return shttp.NewWSMessage(ondemand.Namespace, msgType+"Batch", queries)
+}
+
+// sendToAgent sends the queries to the agent. If the agent is not connected
+// the message is queued, to be sent once the agent reconnects or by the
+// retries of the host. The messages already queued are sent first to keep
+// their order.
+func (o *OnDemandProbeClient) sendToAgent(host string, msgType string, queries ...ondemand.CaptureQuery) bool {
+	o.pendingLock.Lock()
+	defer o.pendingLock.Unlock()
+
+	if len(o.pending[host]) == 0 {
+		if o.wsServer.SendWSMessageTo(newCaptureMessage(msgType, queries), host) {
+			return true
+		}
+	}
+
+	if queries = o.unqueued(host, msgType, queries); len(queries) == 0 {
+		return false
+	}
+
+	if len(o.pending[host]) >= maxPendingMessages {
+		logging.GetLogger().Errorf("Too many pending messages for agent %s, dropping %s", host, msgType)
+		return false
+	}
+	o.pending[host] = append(o.pending[host], &pendingMessage{msgType: msgType, queries: queries})
+	o.scheduleRetry(host)
+
+	return o.sendPending(host)
+}
+
+// unqueued returns the queries that are not already the last ones queued for
+// their node. The pending lock has to be held.
+func (o *OnDemandProbeClient) unqueued(host string, msgType string, queries []ondemand.CaptureQuery) []ondemand.CaptureQuery {
+	last := make(map[string]*pendingMessage)
+	captures := make(map[string]string)
+	for _, msg := range o.pending[host] {
+		for _, query := range msg.queries {
+			last[query.NodeID] = msg
+			captures[query.NodeID] = query.Capture.UUID
+		}
+	}
+
+	var result []ondemand.CaptureQuery
+	for _, query := range queries {
+		if msg, ok := last[query.NodeID]; ok && msg.msgType == msgType && captures[query.NodeID] == query.Capture.UUID {
+			continue
+		}
+		result = append(result, query)
+	}
+	return result
+}
+
+// isCurrent returns whether the query of a queued message still reflects the
+// capture that has to run on the node. As an agent stops the capture of a
+// node whatever the capture, a stop is dropped once the capture owns the
+// node again.
+func (o *OnDemandProbeClient) isCurrent(msgType string, query ondemand.CaptureQuery) bool {
+	owner := o.isOwner(graph.Identifier(query.NodeID), &query.Capture)
+	if msgType == "CaptureStop" {
+		return !owner
+	}
+	return owner
+}
+
+// currentPending returns the messages queued for the host without the
+// queries of the captures that changed since they were queued. The pending
+// lock has to be held.
+func (o *OnDemandProbeClient) currentPending(host string) []*pendingMessage {
+	var msgs []*pendingMessage
+	for _, msg := range o.pending[host] {
+		var queries []ondemand.CaptureQuery
+		for _, query := range msg.queries {
+			if o.isCurrent(msg.msgType, query) {
+				queries = append(queries, query)
+			} else {
+				logging.GetLogger().Debugf("Dropping %s of capture %s on node %s for agent %s", msg.msgType, query.Capture.UUID, query.NodeID, host)
+			}
+		}
+
+		if len(queries) > 0 {
+			msgs = append(msgs, &pendingMessage{msgType: msg.msgType, queries: queries})
+		}
+	}
+	return msgs
+}
+
+// prunePending drops the queued queries of the captures that changed, the
+// retries of the agents without any pending message left being cancelled
+func (o *OnDemandProbeClient) prunePending() {
+	o.pendingLock.Lock()
+	defer o.pendingLock.Unlock()
+
+	for host := range o.pending {
+		if msgs := o.currentPending(host); len(msgs) > 0 {
+			o.pending[host] = msgs
+		} else {
+			delete(o.pending, host)
+			o.cancelRetry(host)
+		}
+	}
+}
+
+// flushPending sends the messages queued for the agent, dropping the ones of
+// the captures that changed in the meantime. It returns whether all the
+// messages were sent.
+func (o *OnDemandProbeClient) flushPending(host string) bool {
+	o.pendingLock.Lock()
+	defer o.pendingLock.Unlock()
+
+	return o.sendPending(host)
+}
+
+// sendPending sends the messages queued for the agent. The pending lock has
+// to be held.
+func (o *OnDemandProbeClient) sendPending(host string) bool {
+	msgs := o.currentPending(host)
+	for i, msg := range msgs {
+		if !o.wsServer.SendWSMessageTo(newCaptureMessage(msg.msgType, msg.queries), host) {
+			o.pending[host] = msgs[i:]
+			return false
+		}
+	}
+
+	delete(o.pending, host)
+	o.cancelRetry(host)
+	return true
+}
+
+// OnAgentConnected sends the messages queued for the reconnected agent
+func (o *OnDemandProbeClient) OnAgentConnected(c *shttp.WSClient) {
+	o.flushPending(c.Host)
+}
@@ -0,0 +1,119 @@
+/*
+ * Copyright (C) 2016 Red Hat, Inc.
+ *
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ *
+ */
+
+package client
+
+import (
+	"time"
+
+	"github.com/skydive-project/skydive/logging"
+)
+
+const (
+	retryMinDelay = time.Second
+	retryMaxDelay = 60 * time.Second
+)
+
+// agentRetry is the next attempt to send the messages queued for an agent
+// that was not reachable
+type agentRetry struct {
+	delay time.Duration
+	next  time.Time
+}
+
+// scheduleRetry schedules an attempt to send the messages queued for the
+// agent, if none is already scheduled
+func (o *OnDemandProbeClient) scheduleRetry(host string) {
+	o.retriesLock.Lock()
+	defer o.retriesLock.Unlock()
+
+	if _, ok := o.retries[host]; ok {
+		return
+	}
+
+	logging.GetLogger().Debugf("Retrying sending to agent %s in %s", host, retryMinDelay)
+	o.retries[host] = &agentRetry{
+		delay: retryMinDelay,
+		next:  time.Now().Add(retryMinDelay),
+	}
+}
+
+// backoffRetry schedules a new attempt after a failed one, the delay between
+// two attempts doubling up to retryMaxDelay
+func (o *OnDemandProbeClient) backoffRetry(host string) {
+	o.retriesLock.Lock()
+	defer o.retriesLock.Unlock()
+
+	r, ok := o.retries[host]
+	if !ok {
+		// the messages were sent or dropped in the meantime
+		return
+	}
+
+	if r.delay *= 2; r.delay > retryMaxDelay {
+		r.delay = retryMaxDelay
+	}
+	r.next = time.Now().Add(r.delay)
+
+	logging.GetLogger().Debugf("Retrying sending to agent %s in %s", host, r.delay)
+}
+
+func (o *OnDemandProbeClient) cancelRetry(host string) {
+	o.retriesLock.Lock()
+	delete(o.retries, host)
+	o.retriesLock.Unlock()
+}
+
+func (o *OnDemandProbeClient) retryDue() {
+	var hosts []string
+	now := time.Now()
+
+	o.retriesLock.Lock()
+	for host, r := range o.retries {
+		if !now.Before(r.next) {
+			hosts = append(hosts, host)
+		}
+	}
+	o.retriesLock.Unlock()
+
+	for _, host := range hosts {
+		if !o.flushPending(host) {
+			o.backoffRetry(host)
+		}
+	}
+}
+
+func (o *OnDemandProbeClient) retryLoop() {
+	defer o.wg.Done()
+
+	ticker := time.NewTicker(retryMinDelay)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-o.quit:
+			return
+		case <-ticker.C:
+			o.retryDue()
+		}
+	}
+}
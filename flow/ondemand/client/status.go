@@ -0,0 +1,214 @@
+/*
+ * Copyright (C) 2016 Red Hat, Inc.
+ *
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ *
+ */
+
+package client
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/abbot/go-http-auth"
+
+	"github.com/skydive-project/skydive/flow/ondemand"
+	shttp "github.com/skydive-project/skydive/http"
+	"github.com/skydive-project/skydive/logging"
+	"github.com/skydive-project/skydive/topology/graph"
+)
+
+// CaptureState is the state of a capture on a node
+type CaptureState int
+
+const (
+	// CapturePending the capture was requested, or is going to be retried
+	CapturePending CaptureState = iota
+	// CaptureActive the agent started the capture
+	CaptureActive
+	// CaptureFailed the agent failed to start the capture
+	CaptureFailed
+	// CaptureCompleted the capture reached its maximum number of packets or
+	// bytes and was stopped by the agent
+	CaptureCompleted
+)
+
+func (s CaptureState) String() string {
+	switch s {
+	case CapturePending:
+		return "pending"
+	case CaptureActive:
+		return "active"
+	case CaptureFailed:
+		return "failed"
+	case CaptureCompleted:
+		return "completed"
+	}
+	return "unknown"
+}
+
+func (s CaptureState) MarshalJSON() ([]byte, error) {
+	return json.Marshal(s.String())
+}
+
+// CaptureStatus holds the state of the captures per capture UUID and node ID
+type CaptureStatus map[string]map[graph.Identifier]CaptureState
+
+// captureStatusReply is the status of a capture returned by the API, with the
+// time left before its expiration if it has a duration
+type captureStatusReply struct {
+	Nodes     map[graph.Identifier]CaptureState
+	Remaining time.Duration `json:"Remaining,omitempty"`
+}
+
+func (o *OnDemandProbeClient) setCaptureState(uuid string, id graph.Identifier, state CaptureState) {
+	o.statusLock.Lock()
+	defer o.statusLock.Unlock()
+
+	nodes, ok := o.status[uuid]
+	if !ok {
+		nodes = make(map[graph.Identifier]CaptureState)
+		o.status[uuid] = nodes
+	}
+	nodes[id] = state
+}
+
+func (o *OnDemandProbeClient) updateCaptureState(uuid string, id graph.Identifier, state CaptureState) {
+	o.statusLock.Lock()
+	defer o.statusLock.Unlock()
+
+	// the capture may have been deleted in the meantime
+	if nodes, ok := o.status[uuid]; ok {
+		nodes[id] = state
+	}
+}
+
+// isCaptureCompleted returns whether the capture completed on the node, in
+// which case it must not be started again
+func (o *OnDemandProbeClient) isCaptureCompleted(uuid string, id graph.Identifier) bool {
+	o.statusLock.RLock()
+	defer o.statusLock.RUnlock()
+
+	state, ok := o.status[uuid][id]
+	return ok && state == CaptureCompleted
+}
+
+func (o *OnDemandProbeClient) removeCaptureState(uuid string, id graph.Identifier) {
+	o.statusLock.Lock()
+	defer o.statusLock.Unlock()
+
+	if nodes, ok := o.status[uuid]; ok {
+		delete(nodes, id)
+	}
+}
+
+func (o *OnDemandProbeClient) deleteCaptureStatus(uuid string) {
+	o.statusLock.Lock()
+	delete(o.status, uuid)
+	o.statusLock.Unlock()
+}
+
+// GetCaptureStatus returns a copy of the state of the captures
+func (o *OnDemandProbeClient) GetCaptureStatus() CaptureStatus {
+	o.statusLock.RLock()
+	defer o.statusLock.RUnlock()
+
+	status := make(CaptureStatus, len(o.status))
+	for uuid, nodes := range o.status {
+		status[uuid] = make(map[graph.Identifier]CaptureState, len(nodes))
+		for id, state := range nodes {
+			status[uuid][id] = state
+		}
+	}
+	return status
+}
+
+// OnMessage updates the state of the captures according to the replies of
+// the agents
+func (o *OnDemandProbeClient) OnMessage(c *shttp.WSClient, msg shttp.WSMessage) {
+	if msg.Namespace != ondemand.Namespace {
+		return
+	}
+
+	if msg.Type == "CaptureListReply" {
+		o.onCaptureList(msg)
+		return
+	}
+
+	var query ondemand.CaptureQuery
+	if err := json.Unmarshal([]byte(*msg.Obj), &query); err != nil {
+		logging.GetLogger().Errorf("Unable to decode capture reply %v", msg)
+		return
+	}
+	id := graph.Identifier(query.NodeID)
+
+	switch msg.Type {
+	case "CaptureStartReply":
+		if msg.Status == http.StatusOK {
+			o.updateCaptureState(query.Capture.UUID, id, CaptureActive)
+		} else {
+			logging.GetLogger().Errorf("Failed to start capture %s on node %s: %d", query.Capture.UUID, id, msg.Status)
+			o.updateCaptureState(query.Capture.UUID, id, CaptureFailed)
+		}
+	case "CaptureStopReply":
+		if msg.Status == http.StatusOK {
+			o.removeCaptureState(query.Capture.UUID, id)
+		}
+	case "CaptureCompleted":
+		o.onCaptureCompleted(id, &query.Capture)
+	}
+}
+
+func (o *OnDemandProbeClient) captureStatus(w http.ResponseWriter, r *auth.AuthenticatedRequest) {
+	w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+
+	replies := make(map[string]*captureStatusReply)
+	for uuid, nodes := range o.GetCaptureStatus() {
+		replies[uuid] = &captureStatusReply{Nodes: nodes}
+	}
+
+	o.RLock()
+	for uuid := range o.captures {
+		if remaining, ok := o.remainingDuration(uuid); ok {
+			if _, ok := replies[uuid]; !ok {
+				replies[uuid] = &captureStatusReply{Nodes: make(map[graph.Identifier]CaptureState)}
+			}
+			replies[uuid].Remaining = remaining
+		}
+	}
+	o.RUnlock()
+
+	w.WriteHeader(http.StatusOK)
+	if err := json.NewEncoder(w).Encode(replies); err != nil {
+		panic(err)
+	}
+}
+
+// RegisterStatusAPI registers the endpoint returning the state of the captures
+func (o *OnDemandProbeClient) RegisterStatusAPI(s *shttp.Server) {
+	s.RegisterRoutes([]shttp.Route{
+		{
+			Name:        "CaptureStatus",
+			Method:      "GET",
+			Path:        "/api/capturestatus",
+			HandlerFunc: o.captureStatus,
+		},
+	})
+}
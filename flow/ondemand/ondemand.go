@@ -22,7 +22,10 @@
 
 package ondemand
 
-import "github.com/skydive-project/skydive/api"
+import (
+	"github.com/skydive-project/skydive/api"
+	shttp "github.com/skydive-project/skydive/http"
+)
 
 const (
 	Namespace = "OnDemand"
@@ -32,3 +35,8 @@ type CaptureQuery struct {
 	NodeID  string
 	Capture api.Capture
 }
+
+func init() {
+	// capture requests must not wait behind the graph synchronization
+	shttp.SetWSNamespacePriority(Namespace, shttp.WSControlPriority)
+}
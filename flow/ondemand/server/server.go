@@ -28,6 +28,8 @@ import (
 	"net/http"
 	"sync"
 
+	"github.com/prometheus/client_golang/prometheus"
+
 	"github.com/skydive-project/skydive/api"
 	"github.com/skydive-project/skydive/common"
 	"github.com/skydive-project/skydive/flow"
@@ -38,6 +40,11 @@ import (
 	"github.com/skydive-project/skydive/topology/graph"
 )
 
+var activeCaptures = prometheus.NewGauge(prometheus.GaugeOpts{
+	Name: "skydive_captures_active",
+	Help: "Number of captures running on the nodes of the agent",
+})
+
 type OnDemandProbeServer struct {
 	sync.RWMutex
 	graph.DefaultGraphListener
@@ -128,6 +135,10 @@ func (o *OnDemandProbeServer) registerProbe(n *graph.Node, capture *api.Capture)
 
 	ft := o.fta.Alloc(fprobe.AsyncFlowPipeline)
 	ft.SetNodeTID(tid)
+	ft.SetSamplingRate(capture.SamplingRate)
+	ft.SetCaptureLimits(capture.MaxPackets, capture.MaxBytes, func() {
+		o.onCaptureCompleted(n, capture)
+	})
 
 	if err := fprobe.RegisterProbe(n, capture, ft); err != nil {
 		logging.GetLogger().Debugf("Failed to register flow probe: %s", err.Error())
@@ -137,6 +148,7 @@ func (o *OnDemandProbeServer) registerProbe(n *graph.Node, capture *api.Capture)
 
 	o.activeProbes[n.ID] = ft
 	o.captures[n.ID] = capture
+	activeCaptures.Inc()
 
 	logging.GetLogger().Debugf("New active probe on: %v", n)
 	return true
@@ -167,15 +179,118 @@ func (o *OnDemandProbeServer) unregisterProbe(n *graph.Node) bool {
 	delete(o.activeProbes, n.ID)
 	delete(o.captures, n.ID)
 	o.Unlock()
+	activeCaptures.Dec()
 
 	return true
 }
 
+// clearCaptureMetadata removes the capture metadata of the node
+func (o *OnDemandProbeServer) clearCaptureMetadata(n *graph.Node) {
+	metadata := n.Metadata()
+	delete(metadata, "Capture/ID")
+	delete(metadata, "Capture/PacketsReceived")
+	delete(metadata, "Capture/PacketsDropped")
+	delete(metadata, "Capture/PacketsIfDropped")
+	o.Graph.SetMetadata(n, metadata)
+}
+
+// onCaptureCompleted stops the capture having reached its maximum number of
+// packets or bytes, or the end of its PCAP file, and notifies the analyzers
+func (o *OnDemandProbeServer) onCaptureCompleted(n *graph.Node, capture *api.Capture) {
+	o.Graph.Lock()
+	defer o.Graph.Unlock()
+
+	if !o.unregisterProbe(n) {
+		return
+	}
+	o.clearCaptureMetadata(n)
+
+	logging.GetLogger().Infof("Capture %s completed on node %s", capture.UUID, n.ID)
+
+	query := ondemand.CaptureQuery{
+		NodeID:  string(n.ID),
+		Capture: *capture,
+	}
+	o.WSAsyncClientPool.BroadcastWSMessage(shttp.NewWSMessage(ondemand.Namespace, "CaptureCompleted", query))
+}
+
+// activeCaptures returns the captures running on the nodes of the agent
+func (o *OnDemandProbeServer) activeCaptures() []ondemand.CaptureQuery {
+	o.RLock()
+	defer o.RUnlock()
+
+	queries := make([]ondemand.CaptureQuery, 0, len(o.captures))
+	for id, capture := range o.captures {
+		queries = append(queries, ondemand.CaptureQuery{
+			NodeID:  string(id),
+			Capture: *capture,
+		})
+	}
+	return queries
+}
+
+// startCapture starts the capture on the node of the query and returns the
+// status of the reply. The graph lock has to be held.
+func (o *OnDemandProbeServer) startCapture(query *ondemand.CaptureQuery) int {
+	n := o.Graph.GetNode(graph.Identifier(query.NodeID))
+	if n == nil {
+		logging.GetLogger().Errorf("Unknown node %s for new capture", query.NodeID)
+		return http.StatusNotFound
+	}
+
+	if id, err := n.GetFieldString("Capture/ID"); err == nil {
+		logging.GetLogger().Debugf("Capture already started on node %s", n.ID)
+		if id == query.Capture.UUID {
+			return http.StatusOK
+		}
+		return http.StatusConflict
+	}
+
+	if !o.registerProbe(n, &query.Capture) {
+		return http.StatusBadRequest
+	}
+
+	t := o.Graph.StartMetadataTransaction(n)
+	t.AddMetadata("Capture/ID", query.Capture.UUID)
+	t.Commit()
+
+	return http.StatusOK
+}
+
+// startCaptureBatch starts the captures of a batch, a CaptureStartReply being
+// sent for each of them
+func (o *OnDemandProbeServer) startCaptureBatch(c *shttp.WSAsyncClient, msg shttp.WSMessage) {
+	var queries []ondemand.CaptureQuery
+	if err := json.Unmarshal([]byte(*msg.Obj), &queries); err != nil {
+		logging.GetLogger().Errorf("Unable to decode capture batch %v", msg)
+		return
+	}
+
+	o.Graph.Lock()
+	defer o.Graph.Unlock()
+
+	for i := range queries {
+		status := o.startCapture(&queries[i])
+		c.SendWSMessage(msg.Reply(&queries[i], "CaptureStartReply", status))
+	}
+}
+
 func (o *OnDemandProbeServer) OnMessage(c *shttp.WSAsyncClient, msg shttp.WSMessage) {
 	if msg.Namespace != ondemand.Namespace {
 		return
 	}
 
+	// the analyzer leader asks for the running captures to take them over
+	if msg.Type == "CaptureList" {
+		c.SendWSMessage(msg.Reply(o.activeCaptures(), "CaptureListReply", http.StatusOK))
+		return
+	}
+
+	if msg.Type == "CaptureStartBatch" {
+		o.startCaptureBatch(c, msg)
+		return
+	}
+
 	var query ondemand.CaptureQuery
 	if err := json.Unmarshal([]byte(*msg.Obj), &query); err != nil {
 		logging.GetLogger().Errorf("Unable to decode capture %v", msg)
@@ -190,22 +305,8 @@ func (o *OnDemandProbeServer) OnMessage(c *shttp.WSAsyncClient, msg shttp.WSMess
 
 	switch msg.Type {
 	case "CaptureStart":
-		n := o.Graph.GetNode(graph.Identifier(query.NodeID))
-		if n == nil {
-			logging.GetLogger().Errorf("Unknown node %s for new capture", query.NodeID)
-			status = http.StatusNotFound
-			break
-		}
-
-		if _, err := n.GetFieldString("Capture/ID"); err == nil {
-			logging.GetLogger().Debugf("Capture already started on node %s", n.ID)
-		} else {
-			if ok = o.registerProbe(n, &query.Capture); ok {
-				t := o.Graph.StartMetadataTransaction(n)
-				t.AddMetadata("Capture/ID", query.Capture.UUID)
-				t.Commit()
-			}
-		}
+		status = o.startCapture(&query)
+		ok = status == http.StatusOK
 	case "CaptureStop":
 		n := o.Graph.GetNode(graph.Identifier(query.NodeID))
 		if n == nil {
@@ -215,18 +316,17 @@ func (o *OnDemandProbeServer) OnMessage(c *shttp.WSAsyncClient, msg shttp.WSMess
 		}
 
 		if ok = o.unregisterProbe(n); ok {
-			metadata := n.Metadata()
-			delete(metadata, "Capture/ID")
-			delete(metadata, "Capture/PacketsReceived")
-			delete(metadata, "Capture/PacketsDropped")
-			delete(metadata, "Capture/PacketsIfDropped")
-			o.Graph.SetMetadata(n, metadata)
+			o.clearCaptureMetadata(n)
 		}
 	default:
 		return
 	}
 
-	reply := msg.Reply(&ondemand.CaptureQuery{}, msg.Type+"Reply", status)
+	if ok {
+		status = http.StatusOK
+	}
+
+	reply := msg.Reply(&query, msg.Type+"Reply", status)
 	c.SendWSMessage(reply)
 }
 
@@ -259,3 +359,7 @@ func NewOnDemandProbeServer(fb *probes.FlowProbeBundle, g *graph.Graph, wspool *
 		captures:          make(map[graph.Identifier]*api.Capture),
 	}, nil
 }
+
+func init() {
+	prometheus.MustRegister(activeCaptures)
+}
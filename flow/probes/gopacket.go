@@ -25,6 +25,7 @@ package probes
 import (
 	"fmt"
 	"io"
+	"math/rand"
 	"sync"
 	"sync/atomic"
 	"time"
@@ -51,6 +52,11 @@ type GoPacketProbe struct {
 	NodeTID      string
 	flowTable    *flow.Table
 	state        int64
+	samplingRate float64
+	replay       bool
+	realTime     bool
+	lastTS       time.Time
+	lastSend     time.Time
 }
 
 type GoPacketProbesHandler struct {
@@ -84,9 +90,23 @@ func (p *GoPacketProbe) feedFlowTable(packetsChan chan *flow.FlowPackets) {
 	for atomic.LoadInt64(&p.state) == common.RunningState {
 		packet, err := p.packetSource.NextPacket()
 		if err == io.EOF {
+			if p.replay {
+				// end of the file, the capture is completed
+				packetsChan <- nil
+				return
+			}
 			time.Sleep(20 * time.Millisecond)
 		} else if err == nil {
-			if flowPackets := flow.FlowPacketsFromGoPacket(&packet, 0, -1); len(flowPackets.Packets) > 0 {
+			timestamp := int64(-1)
+			if p.replay {
+				timestamp = p.replayTimestamp(packet.Metadata().Timestamp)
+			}
+
+			if !p.sample() {
+				continue
+			}
+
+			if flowPackets := flow.FlowPacketsFromGoPacket(&packet, 0, timestamp); len(flowPackets.Packets) > 0 {
 				packetsChan <- flowPackets
 			}
 		} else {
@@ -96,6 +116,31 @@ func (p *GoPacketProbe) feedFlowTable(packetsChan chan *flow.FlowPackets) {
 	}
 }
 
+// replayTimestamp returns the timestamp of a packet read from a PCAP file.
+// In real time, the packets are sent at the pace of the capture and
+// timestamped when sent, otherwise they are sent as fast as possible with
+// their capture time.
+func (p *GoPacketProbe) replayTimestamp(ts time.Time) int64 {
+	if !p.realTime {
+		return ts.Unix()
+	}
+
+	if !p.lastSend.IsZero() {
+		if wait := ts.Sub(p.lastTS) - time.Since(p.lastSend); wait > 0 {
+			time.Sleep(wait)
+		}
+	}
+	p.lastSend, p.lastTS = time.Now(), ts
+
+	return -1
+}
+
+// sample returns whether the packet has to be kept according to the
+// sampling rate of the capture
+func (p *GoPacketProbe) sample() bool {
+	return p.samplingRate <= 0 || p.samplingRate >= 1 || rand.Float64() < p.samplingRate
+}
+
 func (p *GoPacketProbe) run(g *graph.Graph, n *graph.Node, capture *api.Capture) error {
 	var ticker *time.Ticker
 	atomic.StoreInt64(&p.state, common.RunningState)
@@ -118,8 +163,24 @@ func (p *GoPacketProbe) run(g *graph.Graph, n *graph.Node, capture *api.Capture)
 		return err
 	}
 
-	switch capture.Type {
-	case "pcap":
+	switch {
+	case capture.Type == "pcap" && capture.PCAPFilePath != "":
+		handle, err := pcap.OpenOffline(capture.PCAPFilePath)
+		if err != nil {
+			return fmt.Errorf("Error while opening PCAP file %s: %s", capture.PCAPFilePath, err.Error())
+		}
+
+		if err := handle.SetBPFFilter(capture.BPFFilter); err != nil {
+			return fmt.Errorf("BPF Filter failed: %s", err)
+		}
+
+		p.handle = handle
+		p.packetSource = gopacket.NewPacketSource(handle, handle.LinkType())
+		p.replay = true
+		p.realTime = capture.PCAPRealTime
+
+		logging.GetLogger().Infof("PCAP file %s replayed on %s", capture.PCAPFilePath, ifName)
+	case capture.Type == "pcap":
 		handle, err := pcap.OpenLive(ifName, snaplen, true, time.Second)
 		if err != nil {
 			return fmt.Errorf("Error while opening device %s: %s", ifName, err.Error())
@@ -231,9 +292,10 @@ func (p *GoPacketProbesHandler) RegisterProbe(n *graph.Node, capture *api.Captur
 	}
 
 	probe := &GoPacketProbe{
-		NodeTID:   tid,
-		state:     common.StoppedState,
-		flowTable: ft,
+		NodeTID:      tid,
+		state:        common.StoppedState,
+		flowTable:    ft,
+		samplingRate: capture.SamplingRate,
 	}
 
 	p.probesLock.Lock()
@@ -70,6 +70,7 @@ func flowToDocument(flow *flow.Flow) orient.Document {
 		"NodeTID":      flow.NodeTID,
 		"ANodeTID":     flow.ANodeTID,
 		"BNodeTID":     flow.BNodeTID,
+		"SamplingRate": flow.SamplingRate,
 		"Metric":       metricDoc,
 		"LinkLayer":    linkLayer,
 	}
@@ -243,6 +244,7 @@ func New() (*OrientDBStorage, error) {
 				{Name: "NodeTID", Type: "STRING"},
 				{Name: "ANodeTID", Type: "STRING"},
 				{Name: "BNodeTID", Type: "STRING"},
+				{Name: "SamplingRate", Type: "DOUBLE"},
 			},
 			Indexes: []orient.Index{
 				{Name: "Flow.UUID", Fields: []string{"UUID"}, Type: "UNIQUE"},
@@ -79,6 +79,13 @@ type Table struct {
 	expireHandler *FlowHandler
 	tableClock    int64
 	nodeTID       string
+	samplingRate  float64
+	maxPackets    int64
+	maxBytes      int64
+	packets       int64
+	bytes         int64
+	completed     bool
+	onCompleted   func()
 }
 
 func NewTable(updateHandler *FlowHandler, expireHandler *FlowHandler) *Table {
@@ -112,6 +119,21 @@ func (ft *Table) SetNodeTID(tid string) {
 	ft.nodeTID = tid
 }
 
+// SetSamplingRate sets the rate at which the packets of the flows of the
+// table are sampled
+func (ft *Table) SetSamplingRate(rate float64) {
+	ft.samplingRate = rate
+}
+
+// SetCaptureLimits sets the number of packets and bytes after which the
+// table stops processing packets, the handler being then called. A zero
+// value means no limit.
+func (ft *Table) SetCaptureLimits(maxPackets, maxBytes int64, onCompleted func()) {
+	ft.maxPackets = maxPackets
+	ft.maxBytes = maxBytes
+	ft.onCompleted = onCompleted
+}
+
 func (ft *Table) Update(flows []*Flow) {
 	ft.Lock()
 	for _, f := range flows {
@@ -169,6 +191,7 @@ func (ft *Table) GetOrCreateFlow(key string) (*Flow, bool) {
 		LastUpdateMetric: &FlowMetric{},
 	}
 	ft.table[key] = new
+	flowsCreated.Inc()
 
 	return new, true
 }
@@ -364,6 +387,7 @@ func (ft *Table) FlowPacketToFlow(packet *FlowPacket, parentUUID string, t int64
 	flow, new := ft.GetOrCreateFlow(key)
 	if new {
 		flow.Init(key, t, packet.gopacket, packet.length, ft.nodeTID, parentUUID, L2ID, L3ID)
+		flow.SamplingRate = ft.samplingRate
 	} else {
 		flow.Update(t, packet.gopacket, packet.length)
 	}
@@ -392,6 +416,42 @@ func (ft *Table) FlowPacketsToFlow(flowPackets *FlowPackets) {
 	}
 }
 
+// complete stops the processing of the packets and calls the completion
+// handler
+func (ft *Table) complete() {
+	ft.completed = true
+	if ft.onCompleted != nil {
+		go ft.onCompleted()
+	}
+}
+
+// processPackets updates the flows with the packets unless one of the capture
+// limits was reached. A nil value marks the end of the packet source, a
+// replayed PCAP file for instance, and completes the capture.
+func (ft *Table) processPackets(flowPackets *FlowPackets) {
+	if ft.completed {
+		return
+	}
+
+	if flowPackets == nil {
+		ft.complete()
+		return
+	}
+
+	ft.FlowPacketsToFlow(flowPackets)
+
+	if ft.maxPackets == 0 && ft.maxBytes == 0 || len(flowPackets.Packets) == 0 {
+		return
+	}
+
+	ft.packets++
+	ft.bytes += flowPackets.Packets[0].length
+
+	if ft.maxPackets > 0 && ft.packets >= ft.maxPackets || ft.maxBytes > 0 && ft.bytes >= ft.maxBytes {
+		ft.complete()
+	}
+}
+
 func (ft *Table) Run() {
 	ft.wg.Add(1)
 	defer ft.wg.Done()
@@ -425,7 +485,7 @@ func (ft *Table) Run() {
 		case now := <-nowTicker.C:
 			atomic.StoreInt64(&ft.tableClock, now.UTC().Unix())
 		case packets := <-ft.PacketsChan:
-			ft.FlowPacketsToFlow(packets)
+			ft.processPackets(packets)
 		}
 	}
 }
@@ -181,6 +181,51 @@ func TestTable_GetOrCreateFlow(t *testing.T) {
 	}
 }
 
+func TestTable_CaptureLimits(t *testing.T) {
+	ft := NewTable(nil, nil)
+
+	completed := make(chan bool, 1)
+	ft.SetCaptureLimits(3, 0, func() { completed <- true })
+
+	for i := int64(0); i < 5; i++ {
+		packet := forgeTestPacket(t, i, false, ETH, IPv4, TCP)
+		ft.processPackets(FlowPacketsFromGoPacket(packet, 0, -1))
+	}
+
+	select {
+	case <-completed:
+	case <-time.After(time.Second):
+		t.Fatal("Capture limit handler not called")
+	}
+
+	if ft.packets != 3 || len(ft.table) != 3 {
+		t.Errorf("Packets processed after the capture limit: %d packets, %d flows", ft.packets, len(ft.table))
+	}
+}
+
+func TestTable_EndOfPackets(t *testing.T) {
+	ft := NewTable(nil, nil)
+
+	completed := make(chan bool, 1)
+	ft.SetCaptureLimits(0, 0, func() { completed <- true })
+
+	packet := forgeTestPacket(t, 1, false, ETH, IPv4, TCP)
+	ft.processPackets(FlowPacketsFromGoPacket(packet, 0, -1))
+	ft.processPackets(nil)
+
+	select {
+	case <-completed:
+	case <-time.After(time.Second):
+		t.Fatal("Completion handler not called at the end of the packets")
+	}
+
+	packet = forgeTestPacket(t, 2, false, ETH, IPv4, TCP)
+	ft.processPackets(FlowPacketsFromGoPacket(packet, 0, -1))
+	if len(ft.table) != 1 {
+		t.Errorf("Packets processed after the end of the packets: %d flows", len(ft.table))
+	}
+}
+
 func TestTable_NewTableFromFlows(t *testing.T) {
 	ft := NewTestFlowTableComplex(t, nil, nil)
 	var flows []*Flow
@@ -921,8 +921,7 @@ func (s *HopsGremlinTraversalStep) Exec(last traversal.GraphTraversalStep) (trav
 }
 
 func (s *HopsGremlinTraversalStep) Reduce(next traversal.GremlinTraversalStep) traversal.GremlinTraversalStep {
-	if hasStep, ok := next.(*traversal.GremlinTraversalStepHas); ok {
-		s.context.Params = hasStep.Params
+	if s.context.ReduceHas(next) {
 		return s
 	}
 	return next
@@ -942,8 +941,7 @@ func (s *NodesGremlinTraversalStep) Exec(last traversal.GraphTraversalStep) (tra
 }
 
 func (s *NodesGremlinTraversalStep) Reduce(next traversal.GremlinTraversalStep) traversal.GremlinTraversalStep {
-	if hasStep, ok := next.(*traversal.GremlinTraversalStepHas); ok {
-		s.context.Params = hasStep.Params
+	if s.context.ReduceHas(next) {
 		return s
 	}
 	return next
@@ -964,8 +962,7 @@ func (s *CaptureNodeGremlinTraversalStep) Exec(last traversal.GraphTraversalStep
 }
 
 func (s *CaptureNodeGremlinTraversalStep) Reduce(next traversal.GremlinTraversalStep) traversal.GremlinTraversalStep {
-	if hasStep, ok := next.(*traversal.GremlinTraversalStepHas); ok {
-		s.context.Params = hasStep.Params
+	if s.context.ReduceHas(next) {
 		return s
 	}
 	return next
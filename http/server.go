@@ -37,6 +37,7 @@ import (
 	"github.com/gorilla/context"
 	"github.com/gorilla/mux"
 	"github.com/hydrogen18/stoppableListener"
+	"github.com/prometheus/client_golang/prometheus"
 
 	"github.com/skydive-project/skydive/common"
 	"github.com/skydive-project/skydive/config"
@@ -44,6 +45,9 @@ import (
 	"github.com/skydive-project/skydive/statics"
 )
 
+// metricsHandler exposes the registered metrics in the Prometheus format
+var metricsHandler = prometheus.Handler()
+
 type PathPrefix string
 
 type Route struct {
@@ -215,6 +219,10 @@ func unauthorized(w http.ResponseWriter, r *http.Request) {
 	w.Write([]byte("401 Unauthorized\n"))
 }
 
+func (s *Server) serveMetrics(w http.ResponseWriter, r *auth.AuthenticatedRequest) {
+	metricsHandler.ServeHTTP(w, &r.Request)
+}
+
 func (s *Server) HandleFunc(path string, f auth.AuthenticatedHandlerFunc) {
 	s.Router.HandleFunc(path, s.Auth.Wrap(f))
 }
@@ -235,6 +243,7 @@ func NewServer(host string, serviceType common.ServiceType, addr string, port in
 	}
 
 	router.HandleFunc("/login", server.serveLogin)
+	router.HandleFunc("/metrics", auth.Wrap(server.serveMetrics))
 	router.HandleFunc("/", auth.Wrap(server.serveIndex))
 
 	return server
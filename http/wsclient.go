@@ -23,7 +23,6 @@
 package http
 
 import (
-	"encoding/json"
 	"io"
 	"math/rand"
 	"net/http"
@@ -39,6 +38,12 @@ import (
 	"github.com/skydive-project/skydive/logging"
 )
 
+const (
+	maxPendingMessages = 500
+	reconnectMinDelay  = time.Second
+	reconnectMaxDelay  = 30 * time.Second
+)
+
 type WSClientEventHandler interface {
 	OnMessage(c *WSAsyncClient, m WSMessage)
 	OnConnected(c *WSAsyncClient)
@@ -64,6 +69,11 @@ type WSAsyncClient struct {
 	eventHandlers map[WSClientEventHandler]bool
 	connected     atomic.Value
 	running       atomic.Value
+	compression   bool
+	compressMin   int
+	pingTimeout   time.Duration
+	pendingLock   sync.Mutex
+	pending       []string
 }
 
 type WSAsyncClientPool struct {
@@ -86,12 +96,35 @@ func (d *DefaultWSClientEventHandler) OnDisconnected(c *WSAsyncClient) {
 
 func (c *WSAsyncClient) sendMessage(m string) {
 	if !c.IsConnected() {
+		// kept to be sent once reconnected
+		c.pendingLock.Lock()
+		if len(c.pending) < maxPendingMessages {
+			c.pending = append(c.pending, m)
+		} else {
+			logging.GetLogger().Warningf("Too many pending messages for %s:%d, dropping message", c.Addr, c.Port)
+		}
+		c.pendingLock.Unlock()
 		return
 	}
 
 	c.messages <- m
 }
 
+// flushPending sends the messages kept while disconnected
+func (c *WSAsyncClient) flushPending() {
+	c.pendingLock.Lock()
+	pending := c.pending
+	c.pending = nil
+	c.pendingLock.Unlock()
+
+	for _, m := range pending {
+		if err := c.send(m); err != nil {
+			logging.GetLogger().Errorf("Error while writing to the WebSocket: %s", err.Error())
+			return
+		}
+	}
+}
+
 func (c *WSAsyncClient) SendWSMessage(m *WSMessage) {
 	c.sendMessage(m.String())
 }
@@ -101,6 +134,10 @@ func (c *WSAsyncClient) IsConnected() bool {
 }
 
 func (c *WSAsyncClient) send(msg string) error {
+	if c.compression {
+		c.wsConn.EnableWriteCompression(len(msg) >= c.compressMin)
+	}
+
 	w, err := c.wsConn.NextWriter(websocket.TextMessage)
 	if err != nil {
 		return err
@@ -114,32 +151,42 @@ func (c *WSAsyncClient) send(msg string) error {
 	return w.Close()
 }
 
-func (c *WSAsyncClient) connect() {
+// connect connects to the server and handles the messages until
+// disconnected. It returns whether the connection succeeded.
+func (c *WSAsyncClient) connect() bool {
 	var err error
 	host := c.Addr + ":" + strconv.FormatInt(int64(c.Port), 10)
 	endpoint := "ws://" + host + c.Path
-	headers := http.Header{"X-Host-ID": {c.Host}, "Origin": {endpoint}, "X-Client-Type": {c.ClientType.String()}}
+	headers := http.Header{"X-Host-ID": {c.Host}, "Origin": {endpoint}, "X-Client-Type": {c.ClientType.String()}, "X-Websocket-Batch": {"true"}}
 
 	if c.AuthClient != nil {
 		if err = c.AuthClient.Authenticate(); err != nil {
 			logging.GetLogger().Errorf("Unable to create a WebSocket connection %s : %s", endpoint, err.Error())
-			return
+			return false
 		}
 		c.AuthClient.SetHeaders(headers)
 	}
 
 	d := websocket.Dialer{
-		Proxy:           http.ProxyFromEnvironment,
-		ReadBufferSize:  1024,
-		WriteBufferSize: 1024,
+		Proxy:             http.ProxyFromEnvironment,
+		ReadBufferSize:    1024,
+		WriteBufferSize:   1024,
+		EnableCompression: c.compression,
 	}
 	c.wsConn, _, err = d.Dial(endpoint, headers)
 	if err != nil {
 		logging.GetLogger().Errorf("Unable to create a WebSocket connection %s : %s", endpoint, err.Error())
-		return
+		return false
 	}
 	defer c.wsConn.Close()
-	c.wsConn.SetPingHandler(nil)
+
+	// the server pings periodically, the connection is considered as lost
+	// when no ping nor message is received within the ping timeout
+	c.resetReadDeadline()
+	c.wsConn.SetPingHandler(func(data string) error {
+		c.resetReadDeadline()
+		return c.wsConn.WriteControl(websocket.PongMessage, []byte(data), time.Now().Add(time.Second))
+	})
 
 	c.connected.Store(true)
 	defer c.connected.Store(false)
@@ -149,6 +196,9 @@ func (c *WSAsyncClient) connect() {
 	c.wg.Add(1)
 	defer c.wg.Done()
 
+	// sent before the messages of the handlers being notified
+	c.flushPending()
+
 	// notify connected
 	c.RLock()
 	for l := range c.eventHandlers {
@@ -166,6 +216,7 @@ func (c *WSAsyncClient) connect() {
 				break
 			}
 
+			c.resetReadDeadline()
 			c.read <- m
 		}
 	}()
@@ -187,31 +238,55 @@ func (c *WSAsyncClient) connect() {
 				logging.GetLogger().Errorf("Error while writing to the WebSocket: %s", err.Error())
 			}
 		case m := <-c.read:
-			var msg WSMessage
-			if err := json.Unmarshal(m, &msg); err != nil {
+			msgs, err := DecodeWSMessages(m)
+			if err != nil {
 				logging.GetLogger().Errorf("Error while decoding WSMessage %s", err.Error())
-			} else {
-				c.RLock()
+				break
+			}
+
+			c.RLock()
+			for _, msg := range msgs {
 				for l := range c.eventHandlers {
 					l.OnMessage(c, msg)
 				}
-				c.RUnlock()
 			}
+			c.RUnlock()
 		case <-c.quit:
-			return
+			return true
 		}
 	}
 }
 
+func (c *WSAsyncClient) resetReadDeadline() {
+	if c.pingTimeout > 0 {
+		c.wsConn.SetReadDeadline(time.Now().Add(c.pingTimeout))
+	}
+}
+
+// Connect connects to the server, reconnecting with an exponential backoff
+// when the connection is lost
 func (c *WSAsyncClient) Connect() {
 	go func() {
+		delay := reconnectMinDelay
 		for c.running.Load() == true {
-			c.connect()
-			time.Sleep(1 * time.Second)
+			if c.connect() {
+				delay = reconnectMinDelay
+			}
+			time.Sleep(delay)
+
+			if delay *= 2; delay > reconnectMaxDelay {
+				delay = reconnectMaxDelay
+			}
 		}
 	}()
 }
 
+// SetPingTimeout sets the time after which the connection is considered as
+// lost if neither a ping nor a message is received, 0 disabling the check
+func (c *WSAsyncClient) SetPingTimeout(timeout time.Duration) {
+	c.pingTimeout = timeout
+}
+
 func (c *WSAsyncClient) AddEventHandler(h WSClientEventHandler) {
 	c.Lock()
 	c.eventHandlers[h] = true
@@ -226,6 +301,13 @@ func (c *WSAsyncClient) Disconnect() {
 	}
 }
 
+// SetCompression enables the permessage-deflate compression of the messages
+// whose size is at least the given threshold
+func (c *WSAsyncClient) SetCompression(enabled bool, threshold int) {
+	c.compression = enabled
+	c.compressMin = threshold
+}
+
 func NewWSAsyncClient(host string, clientType common.ServiceType, addr string, port int, path string, authClient *AuthenticationClient) *WSAsyncClient {
 	c := &WSAsyncClient{
 		Host:          host,
@@ -246,7 +328,14 @@ func NewWSAsyncClient(host string, clientType common.ServiceType, addr string, p
 
 func NewWSAsyncClientFromConfig(clientType common.ServiceType, addr string, port int, path string, authClient *AuthenticationClient) *WSAsyncClient {
 	host := config.GetConfig().GetString("host_id")
-	return NewWSAsyncClient(host, clientType, addr, port, path, authClient)
+	c := NewWSAsyncClient(host, clientType, addr, port, path, authClient)
+	c.SetCompression(config.GetConfig().GetBool("ws_compression"), config.GetConfig().GetInt("ws_compression_threshold"))
+	// the server pings at each interval and waits for the pong up to the
+	// timeout, the ping being late at most by this timeout
+	pongWait, pingPeriod := wsPingConfig()
+	c.SetPingTimeout(pingPeriod + pongWait)
+
+	return c
 }
 
 func (a *WSAsyncClientPool) selectMaster() *WSAsyncClient {
@@ -23,7 +23,9 @@
 package http
 
 import (
+	"bytes"
 	"encoding/json"
+	"fmt"
 	"net/http"
 	"sync"
 	"sync/atomic"
@@ -42,6 +44,7 @@ const (
 	Namespace      = "WSServer"
 	writeWait      = 10 * time.Second
 	maxMessages    = 1024
+	maxBatchSize   = 100
 	maxMessageSize = 0
 )
 
@@ -50,22 +53,69 @@ type WSClient struct {
 	ClientType common.ServiceType
 	conn       *websocket.Conn
 	read       chan []byte
-	send       chan []byte
+	send       [WSControlPriority + 1]chan []byte
 	server     *WSServer
+	batching   bool
+	stats      *ConnectionStats
 }
 
+// WSMessagePriority is the priority of a message, the messages of higher
+// priority being sent first to a client
+type WSMessagePriority int
+
+const (
+	// WSDataPriority for the data messages, the default
+	WSDataPriority WSMessagePriority = iota
+	// WSSyncPriority for the synchronization messages, like the graph ones
+	WSSyncPriority
+	// WSControlPriority for the urgent control messages, like the capture ones
+	WSControlPriority
+)
+
+// WSDropPolicy is the policy applied when the send buffer of a client is full
+type WSDropPolicy int
+
+const (
+	// WSDisconnect disconnects the slow client
+	WSDisconnect WSDropPolicy = iota
+	// WSDropOldest drops the oldest message of the buffer
+	WSDropOldest
+	// WSDropNewest drops the message being sent
+	WSDropNewest
+)
+
+// ParseWSDropPolicy returns the drop policy of the given name, "disconnect",
+// "drop_oldest" or "drop_newest"
+func ParseWSDropPolicy(policy string) (WSDropPolicy, error) {
+	switch policy {
+	case "disconnect":
+		return WSDisconnect, nil
+	case "drop_oldest":
+		return WSDropOldest, nil
+	case "drop_newest":
+		return WSDropNewest, nil
+	}
+	return WSDisconnect, fmt.Errorf("Unknown WebSocket drop policy: %s", policy)
+}
+
+// wsNamespacePriorities holds the priority of the messages per namespace,
+// only set at initialization
+var wsNamespacePriorities = make(map[string]WSMessagePriority)
+
 type WSMessage struct {
 	Namespace string
 	Type      string
 	UUID      string `json:",omitempty"`
 	Obj       *json.RawMessage
 	Status    int
+	Priority  WSMessagePriority `json:"-"`
 }
 
 type WSServerEventHandler interface {
 	OnMessage(c *WSClient, m WSMessage)
 	OnRegisterClient(c *WSClient)
 	OnUnregisterClient(c *WSClient)
+	OnAgentConnected(c *WSClient)
 }
 
 type DefaultWSServerEventHandler struct {
@@ -79,16 +129,42 @@ type WSServer struct {
 	ServiceType   common.ServiceType
 	eventHandlers []WSServerEventHandler
 	clients       map[*WSClient]bool
-	broadcast     chan string
+	broadcast     chan *WSMessage
 	quit          chan bool
 	register      chan *WSClient
 	unregister    chan *WSClient
 	pongWait      time.Duration
 	pingPeriod    time.Duration
+	compression   bool
+	compressMin   int
+	sendSize      int
+	dropPolicy    WSDropPolicy
+	batchWindow   time.Duration
+	batchSize     int
+	statsLock     sync.RWMutex
+	stats         map[string]*ConnectionStats
 	wg            sync.WaitGroup
 	listening     atomic.Value
 }
 
+// DecodeWSMessages decodes a frame holding either a single message or a batch
+// of messages as a JSON array
+func DecodeWSMessages(b []byte) ([]WSMessage, error) {
+	if b = bytes.TrimSpace(b); len(b) > 0 && b[0] == '[' {
+		var msgs []WSMessage
+		if err := json.Unmarshal(b, &msgs); err != nil {
+			return nil, err
+		}
+		return msgs, nil
+	}
+
+	var msg WSMessage
+	if err := json.Unmarshal(b, &msg); err != nil {
+		return nil, err
+	}
+	return []WSMessage{msg}, nil
+}
+
 func (g WSMessage) Marshal() []byte {
 	j, _ := json.Marshal(g)
 	return j
@@ -108,9 +184,16 @@ func (g *WSMessage) Reply(v interface{}, kind string, status int) *WSMessage {
 		Obj:       &raw,
 		Type:      kind,
 		Status:    status,
+		Priority:  g.Priority,
 	}
 }
 
+// SetWSNamespacePriority sets the priority of the messages of a namespace. It
+// has to be called at initialization.
+func SetWSNamespacePriority(ns string, priority WSMessagePriority) {
+	wsNamespacePriorities[ns] = priority
+}
+
 func NewWSMessage(ns string, tp string, v interface{}, uuids ...string) *WSMessage {
 	var u string
 	if len(uuids) != 0 {
@@ -129,6 +212,7 @@ func NewWSMessage(ns string, tp string, v interface{}, uuids ...string) *WSMessa
 		UUID:      u,
 		Obj:       &raw,
 		Status:    http.StatusOK,
+		Priority:  wsNamespacePriorities[ns],
 	}
 }
 
@@ -141,20 +225,53 @@ func (d *DefaultWSServerEventHandler) OnRegisterClient(c *WSClient) {
 func (d *DefaultWSServerEventHandler) OnUnregisterClient(c *WSClient) {
 }
 
+func (d *DefaultWSServerEventHandler) OnAgentConnected(c *WSClient) {
+}
+
 func (c *WSClient) SendWSMessage(msg *WSMessage) {
-	c.send <- []byte(msg.String())
+	c.enqueue(msg.Priority, []byte(msg.String()))
+}
+
+// enqueue adds the message to the send buffer, applying the drop policy of
+// the server if the buffer is full
+func (c *WSClient) enqueue(priority WSMessagePriority, m []byte) {
+	select {
+	case c.send[priority] <- m:
+		return
+	default:
+	}
+
+	switch c.server.dropPolicy {
+	case WSDropNewest:
+		logging.GetLogger().Warningf("Send buffer of %s (%s, %s) full, dropping newest message", c.Host, c.ClientType, c.conn.RemoteAddr().String())
+	case WSDropOldest:
+		logging.GetLogger().Warningf("Send buffer of %s (%s, %s) full, dropping oldest message", c.Host, c.ClientType, c.conn.RemoteAddr().String())
+		select {
+		case <-c.send[priority]:
+		default:
+		}
+		select {
+		case c.send[priority] <- m:
+		default:
+		}
+	default:
+		logging.GetLogger().Warningf("Send buffer of %s (%s, %s) full, disconnecting", c.Host, c.ClientType, c.conn.RemoteAddr().String())
+		c.conn.Close()
+	}
 }
 
 func (c *WSClient) processMessage(m []byte) {
-	var msg WSMessage
-	if err := json.Unmarshal(m, &msg); err != nil {
-		logging.GetLogger().Errorf("WSServer: Unable to parse the event %s: %s", msg, err.Error())
+	msgs, err := DecodeWSMessages(m)
+	if err != nil {
+		logging.GetLogger().Errorf("WSServer: Unable to parse the event %s: %s", string(m), err.Error())
 		return
 	}
 
-	if msg.Namespace != Namespace {
-		for _, e := range c.server.eventHandlers {
-			e.OnMessage(c, msg)
+	for _, msg := range msgs {
+		if msg.Namespace != Namespace {
+			for _, e := range c.server.eventHandlers {
+				e.OnMessage(c, msg)
+			}
 		}
 	}
 }
@@ -192,10 +309,12 @@ func (c *WSClient) readPump() {
 		if err != nil {
 			if err != websocket.ErrCloseSent {
 				logging.GetLogger().Errorf("Error while reading websocket from %s: %s", c.Host, err.Error())
+				c.stats.addError()
 			}
 			break
 		}
 
+		c.stats.received(len(m))
 		c.read <- m
 	}
 }
@@ -215,33 +334,104 @@ func (c *WSClient) writePump(wg *sync.WaitGroup, quit chan struct{}) {
 	}
 
 	for {
-		select {
-		case message, ok := <-c.send:
-			if !ok {
-				c.write(websocket.CloseMessage, []byte{})
+		message, ok, found := c.nextMessage()
+		if !found {
+			select {
+			case message, ok = <-c.send[WSControlPriority]:
+			case message, ok = <-c.send[WSSyncPriority]:
+			case message, ok = <-c.send[WSDataPriority]:
+			case <-ticker.C:
+				if err := c.write(websocket.PingMessage, []byte{}); err != nil {
+					wg.Done()
+					return
+				}
+				continue
+			case <-quit:
 				wg.Done()
 				return
 			}
-			if err := c.write(websocket.TextMessage, message); err != nil {
-				logging.GetLogger().Warningf("Error while writing to the websocket: %s", err.Error())
-				wg.Done()
-				return
-			}
-		case <-ticker.C:
-			if err := c.write(websocket.PingMessage, []byte{}); err != nil {
-				wg.Done()
-				return
-			}
-		case <-quit:
+		}
+
+		if ok && c.batching && c.server.batchWindow > 0 {
+			message, ok = c.collectBatch(message)
+		}
+
+		if !ok {
+			c.write(websocket.CloseMessage, []byte{})
+			wg.Done()
+			return
+		}
+		if err := c.write(websocket.TextMessage, message); err != nil {
+			logging.GetLogger().Warningf("Error while writing to the websocket: %s", err.Error())
 			wg.Done()
 			return
 		}
 	}
 }
 
+// collectBatch accumulates the messages sent within the batch window after
+// the first one, up to the batch size, and returns them as a single JSON
+// array frame. ok is false if the client is being closed.
+func (c *WSClient) collectBatch(first []byte) (frame []byte, ok bool) {
+	batch := [][]byte{first}
+	window := time.NewTimer(c.server.batchWindow)
+	defer window.Stop()
+
+collect:
+	for len(batch) < c.server.batchSize {
+		message, ok, found := c.nextMessage()
+		if !found {
+			select {
+			case message, ok = <-c.send[WSControlPriority]:
+			case message, ok = <-c.send[WSSyncPriority]:
+			case message, ok = <-c.send[WSDataPriority]:
+			case <-window.C:
+				break collect
+			}
+		}
+
+		if !ok {
+			return nil, false
+		}
+		batch = append(batch, message)
+	}
+
+	if len(batch) == 1 {
+		return first, true
+	}
+
+	frame = append([]byte{'['}, bytes.Join(batch, []byte{','})...)
+	return append(frame, ']'), true
+}
+
+// nextMessage returns without blocking the next message to send, the
+// messages of higher priority first
+func (c *WSClient) nextMessage() (message []byte, ok bool, found bool) {
+	for p := WSControlPriority; p >= WSDataPriority; p-- {
+		select {
+		case message, ok = <-c.send[p]:
+			return message, ok, true
+		default:
+		}
+	}
+	return nil, false, false
+}
+
 func (c *WSClient) write(mt int, message []byte) error {
+	if c.server.compression && mt == websocket.TextMessage {
+		c.conn.EnableWriteCompression(len(message) >= c.server.compressMin)
+	}
+
 	c.conn.SetWriteDeadline(time.Now().Add(writeWait))
-	return c.conn.WriteMessage(mt, message)
+	if err := c.conn.WriteMessage(mt, message); err != nil {
+		c.stats.addError()
+		return err
+	}
+
+	if mt == websocket.TextMessage {
+		c.stats.sent(len(message))
+	}
+	return nil
 }
 
 func (s *WSServer) SendWSMessageTo(msg *WSMessage, host string) bool {
@@ -277,6 +467,11 @@ func (s *WSServer) listenAndServe() {
 			for _, e := range s.eventHandlers {
 				e.OnRegisterClient(c)
 			}
+			if c.ClientType == common.AgentService {
+				for _, e := range s.eventHandlers {
+					e.OnAgentConnected(c)
+				}
+			}
 		case c := <-s.unregister:
 			for _, e := range s.eventHandlers {
 				e.OnUnregisterClient(c)
@@ -285,18 +480,20 @@ func (s *WSServer) listenAndServe() {
 			c.conn.Close()
 			delete(s.clients, c)
 			s.Unlock()
+			s.removeStats(c)
 		case m := <-s.broadcast:
 			s.broadcastMessage(m)
 		}
 	}
 }
 
-func (s *WSServer) broadcastMessage(m string) {
+func (s *WSServer) broadcastMessage(msg *WSMessage) {
 	s.RLock()
 	defer s.RUnlock()
 
+	m := []byte(msg.String())
 	for c := range s.clients {
-		c.send <- []byte(m)
+		c.enqueue(msg.Priority, m)
 	}
 }
 
@@ -320,8 +517,9 @@ func (s *WSServer) serveMessages(w http.ResponseWriter, r *auth.AuthenticatedReq
 	}
 
 	var upgrader = websocket.Upgrader{
-		ReadBufferSize:  1024,
-		WriteBufferSize: 1024,
+		ReadBufferSize:    1024,
+		WriteBufferSize:   1024,
+		EnableCompression: s.compression,
 	}
 
 	conn, err := upgrader.Upgrade(w, &r.Request, nil)
@@ -331,14 +529,19 @@ func (s *WSServer) serveMessages(w http.ResponseWriter, r *auth.AuthenticatedReq
 
 	c := &WSClient{
 		read:       make(chan []byte, maxMessages),
-		send:       make(chan []byte, maxMessages),
 		conn:       conn,
 		server:     s,
 		Host:       host,
 		ClientType: common.ServiceType(r.Header.Get("X-Client-Type")),
+		batching:   r.Header.Get("X-Websocket-Batch") == "true",
+	}
+	for i := range c.send {
+		c.send[i] = make(chan []byte, s.sendSize)
 	}
+
 	logging.GetLogger().Infof("New WebSocket Connection from %s : URI path %s", conn.RemoteAddr().String(), r.URL.Path)
 
+	s.addStats(c)
 	s.register <- c
 
 	var wg sync.WaitGroup
@@ -355,13 +558,15 @@ func (s *WSServer) serveMessages(w http.ResponseWriter, r *auth.AuthenticatedReq
 	quit <- struct{}{}
 
 	close(c.read)
-	close(c.send)
+	for _, send := range c.send {
+		close(send)
+	}
 
 	wg.Wait()
 }
 
 func (s *WSServer) BroadcastWSMessage(msg *WSMessage) {
-	s.broadcast <- msg.String()
+	s.broadcast <- msg
 }
 
 func (s *WSServer) ListenAndServe() {
@@ -404,28 +609,97 @@ func (s *WSServer) GetClientsByType(clientType common.ServiceType) (clients []*W
 	return clients
 }
 
+// SetCompression enables the permessage-deflate compression of the messages
+// whose size is at least the given threshold
+func (s *WSServer) SetCompression(enabled bool, threshold int) {
+	s.compression = enabled
+	s.compressMin = threshold
+}
+
+// SetPingInterval sets the interval between two pings sent to the clients,
+// the clients not answering within the pong timeout being disconnected. The
+// interval has to be lower than the pong timeout.
+func (s *WSServer) SetPingInterval(interval time.Duration) {
+	if interval >= s.pongWait {
+		logging.GetLogger().Warningf("WebSocket ping interval %s not lower than the pong timeout %s, ignored", interval, s.pongWait)
+		return
+	}
+
+	if interval > 0 {
+		s.pingPeriod = interval
+	}
+}
+
+// SetBatching makes the messages sent within the window to be sent as a
+// single frame, up to size messages, to the clients supporting it. A zero
+// window disables the batching.
+func (s *WSServer) SetBatching(window time.Duration, size int) {
+	s.batchWindow = window
+	if size > 0 {
+		s.batchSize = size
+	}
+}
+
+// SetSendBuffer sets the size of the per client send buffer and the policy
+// applied when the buffer of a slow client is full
+func (s *WSServer) SetSendBuffer(size int, policy WSDropPolicy) {
+	if size > 0 {
+		s.sendSize = size
+	}
+	s.dropPolicy = policy
+}
+
 func NewWSServer(host string, serviceType common.ServiceType, server *Server, pongWait time.Duration, endpoint string) *WSServer {
 	s := &WSServer{
 		Host:        host,
 		ServiceType: serviceType,
 		Server:      server,
-		broadcast:   make(chan string, 500),
+		broadcast:   make(chan *WSMessage, 500),
 		quit:        make(chan bool, 1),
 		register:    make(chan *WSClient),
 		unregister:  make(chan *WSClient),
 		clients:     make(map[*WSClient]bool),
 		pongWait:    pongWait,
 		pingPeriod:  (pongWait * 8) / 10,
+		sendSize:    maxMessages,
+		dropPolicy:  WSDisconnect,
+		batchSize:   maxBatchSize,
+		stats:       make(map[string]*ConnectionStats),
 	}
 
 	server.HandleFunc(endpoint, s.serveMessages)
+	s.registerStatsAPI()
 
 	return s
 }
 
+// wsPingConfig returns the pong timeout and the ping interval, by default
+// 80% of the timeout
+func wsPingConfig() (time.Duration, time.Duration) {
+	pongWait := time.Duration(config.GetConfig().GetInt("ws_pong_timeout")) * time.Second
+	pingPeriod := time.Duration(config.GetConfig().GetInt("ws_ping_interval")) * time.Second
+	if pingPeriod <= 0 {
+		pingPeriod = (pongWait * 8) / 10
+	}
+	return pongWait, pingPeriod
+}
+
 func NewWSServerFromConfig(serviceType common.ServiceType, server *Server, endpoint string) *WSServer {
-	w := config.GetConfig().GetInt("ws_pong_timeout")
 	host := config.GetConfig().GetString("host_id")
 
-	return NewWSServer(host, serviceType, server, time.Duration(w)*time.Second, endpoint)
+	pongWait, pingPeriod := wsPingConfig()
+	s := NewWSServer(host, serviceType, server, pongWait, endpoint)
+	s.SetPingInterval(pingPeriod)
+	s.SetCompression(config.GetConfig().GetBool("ws_compression"), config.GetConfig().GetInt("ws_compression_threshold"))
+
+	policy, err := ParseWSDropPolicy(config.GetConfig().GetString("ws_send_drop_policy"))
+	if err != nil {
+		logging.GetLogger().Errorf("%s, using disconnect", err.Error())
+	}
+	s.SetSendBuffer(config.GetConfig().GetInt("ws_send_buffer_size"), policy)
+
+	window := time.Duration(config.GetConfig().GetInt("ws_batch_window")) * time.Millisecond
+	s.SetBatching(window, config.GetConfig().GetInt("ws_batch_max_size"))
+
+	return s
 }
@@ -0,0 +1,168 @@
+/*
+ * Copyright (C) 2016 Red Hat, Inc.
+ *
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ *
+ */
+
+package http
+
+import (
+	"encoding/json"
+	"net/http"
+	"sync/atomic"
+	"time"
+
+	"github.com/abbot/go-http-auth"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var (
+	wsMessagesSent = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "skydive_ws_messages_sent_total",
+		Help: "Number of WebSocket messages sent to the clients",
+	})
+	wsMessagesReceived = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "skydive_ws_messages_received_total",
+		Help: "Number of WebSocket messages received from the clients",
+	})
+	wsBytesSent = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "skydive_ws_bytes_sent_total",
+		Help: "Number of bytes of the WebSocket messages sent to the clients",
+	})
+	wsBytesReceived = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "skydive_ws_bytes_received_total",
+		Help: "Number of bytes of the WebSocket messages received from the clients",
+	})
+	wsErrors = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "skydive_ws_errors_total",
+		Help: "Number of errors while reading from or writing to the WebSocket clients",
+	})
+	wsConnections = prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "skydive_ws_connections",
+		Help: "Number of connected WebSocket clients",
+	})
+)
+
+// ConnectionStats holds the statistics of a WebSocket client connection
+type ConnectionStats struct {
+	MessagesSent     int64
+	MessagesReceived int64
+	BytesSent        int64
+	BytesReceived    int64
+	Errors           int64
+	Host             string
+	ClientType       string
+	ConnectedAt      time.Time
+}
+
+func (s *ConnectionStats) sent(size int) {
+	atomic.AddInt64(&s.MessagesSent, 1)
+	atomic.AddInt64(&s.BytesSent, int64(size))
+	wsMessagesSent.Inc()
+	wsBytesSent.Add(float64(size))
+}
+
+func (s *ConnectionStats) received(size int) {
+	atomic.AddInt64(&s.MessagesReceived, 1)
+	atomic.AddInt64(&s.BytesReceived, int64(size))
+	wsMessagesReceived.Inc()
+	wsBytesReceived.Add(float64(size))
+}
+
+func (s *ConnectionStats) addError() {
+	atomic.AddInt64(&s.Errors, 1)
+	wsErrors.Inc()
+}
+
+func (s *ConnectionStats) snapshot() ConnectionStats {
+	return ConnectionStats{
+		MessagesSent:     atomic.LoadInt64(&s.MessagesSent),
+		MessagesReceived: atomic.LoadInt64(&s.MessagesReceived),
+		BytesSent:        atomic.LoadInt64(&s.BytesSent),
+		BytesReceived:    atomic.LoadInt64(&s.BytesReceived),
+		Errors:           atomic.LoadInt64(&s.Errors),
+		Host:             s.Host,
+		ClientType:       s.ClientType,
+		ConnectedAt:      s.ConnectedAt,
+	}
+}
+
+func (s *WSServer) addStats(c *WSClient) {
+	c.stats = &ConnectionStats{
+		Host:        c.Host,
+		ClientType:  c.ClientType.String(),
+		ConnectedAt: time.Now().UTC(),
+	}
+
+	s.statsLock.Lock()
+	s.stats[c.conn.RemoteAddr().String()] = c.stats
+	s.statsLock.Unlock()
+
+	wsConnections.Inc()
+}
+
+func (s *WSServer) removeStats(c *WSClient) {
+	s.statsLock.Lock()
+	delete(s.stats, c.conn.RemoteAddr().String())
+	s.statsLock.Unlock()
+
+	wsConnections.Dec()
+}
+
+// GetConnectionStats returns the statistics of the connected clients per
+// remote address
+func (s *WSServer) GetConnectionStats() map[string]ConnectionStats {
+	s.statsLock.RLock()
+	defer s.statsLock.RUnlock()
+
+	stats := make(map[string]ConnectionStats, len(s.stats))
+	for addr, st := range s.stats {
+		stats[addr] = st.snapshot()
+	}
+	return stats
+}
+
+func (s *WSServer) connectionStats(w http.ResponseWriter, r *auth.AuthenticatedRequest) {
+	w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+
+	w.WriteHeader(http.StatusOK)
+	if err := json.NewEncoder(w).Encode(s.GetConnectionStats()); err != nil {
+		panic(err)
+	}
+}
+
+func (s *WSServer) registerStatsAPI() {
+	s.Server.RegisterRoutes([]Route{
+		{
+			Name:        "WSConnections",
+			Method:      "GET",
+			Path:        "/api/wsconnections",
+			HandlerFunc: s.connectionStats,
+		},
+	})
+}
+
+func init() {
+	prometheus.MustRegister(wsMessagesSent)
+	prometheus.MustRegister(wsMessagesReceived)
+	prometheus.MustRegister(wsBytesSent)
+	prometheus.MustRegister(wsBytesReceived)
+	prometheus.MustRegister(wsErrors)
+	prometheus.MustRegister(wsConnections)
+}
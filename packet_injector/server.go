@@ -110,3 +110,7 @@ func NewServer(wspool *shttp.WSAsyncClientPool, graph *graph.Graph) *PacketInjec
 
 	return s
 }
+
+func init() {
+	shttp.SetWSNamespacePriority(Namespace, shttp.WSControlPriority)
+}
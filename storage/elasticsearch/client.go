@@ -128,6 +128,14 @@ func (c *ElasticSearchClient) start(mappings []map[string][]byte) error {
 	return nil
 }
 
+var wildcardEscaper = strings.NewReplacer(`\`, `\\`, `*`, `\*`, `?`, `\?`)
+
+// escapeWildcard escapes the wildcards of a value to be matched literally by
+// a wildcard query
+func escapeWildcard(value string) string {
+	return wildcardEscaper.Replace(value)
+}
+
 func (c *ElasticSearchClient) FormatFilter(filter *filters.Filter, prefix string) map[string]interface{} {
 	if filter == nil {
 		return map[string]interface{}{
@@ -179,6 +187,73 @@ func (c *ElasticSearchClient) FormatFilter(filter *filters.Filter, prefix string
 		}
 	}
 
+	if f := filter.ContainsFilter; f != nil {
+		return map[string]interface{}{
+			"wildcard": map[string]string{
+				prefix + f.Key: "*" + escapeWildcard(f.Value) + "*",
+			},
+		}
+	}
+
+	if f := filter.PrefixFilter; f != nil {
+		return map[string]interface{}{
+			"prefix": map[string]string{
+				prefix + f.Key: f.Value,
+			},
+		}
+	}
+
+	if f := filter.SuffixFilter; f != nil {
+		return map[string]interface{}{
+			"wildcard": map[string]string{
+				prefix + f.Key: "*" + escapeWildcard(f.Value),
+			},
+		}
+	}
+
+	if f := filter.IPFilter; f != nil {
+		if regex, ok := f.Regex(); ok {
+			return map[string]interface{}{
+				"regexp": map[string]string{
+					prefix + f.Key: regex,
+				},
+			}
+		}
+
+		logging.GetLogger().Warningf("IP filter on network %s not supported, no match", f.Cidr)
+		return map[string]interface{}{
+			"bool": map[string]interface{}{
+				"must_not": []interface{}{
+					map[string]interface{}{
+						"match_all": map[string]interface{}{},
+					},
+				},
+			},
+		}
+	}
+
+	if f := filter.ArrayContainsFilter; f != nil {
+		return map[string]interface{}{
+			"term": map[string]string{
+				prefix + f.Key: f.Value,
+			},
+		}
+	}
+
+	if f := filter.NullFilter; f != nil {
+		return map[string]interface{}{
+			"bool": map[string]interface{}{
+				"must_not": []interface{}{
+					map[string]interface{}{
+						"exists": map[string]string{
+							"field": prefix + f.Key,
+						},
+					},
+				},
+			},
+		}
+	}
+
 	if f := filter.GtInt64Filter; f != nil {
 		return map[string]interface{}{
 			"range": map[string]interface{}{
@@ -223,6 +298,50 @@ func (c *ElasticSearchClient) FormatFilter(filter *filters.Filter, prefix string
 			},
 		}
 	}
+	if f := filter.GtFloat64Filter; f != nil {
+		return map[string]interface{}{
+			"range": map[string]interface{}{
+				prefix + f.Key: &struct {
+					Gt interface{} `json:"gt,omitempty"`
+				}{
+					Gt: f.Value,
+				},
+			},
+		}
+	}
+	if f := filter.LtFloat64Filter; f != nil {
+		return map[string]interface{}{
+			"range": map[string]interface{}{
+				prefix + f.Key: &struct {
+					Lt interface{} `json:"lt,omitempty"`
+				}{
+					Lt: f.Value,
+				},
+			},
+		}
+	}
+	if f := filter.GteFloat64Filter; f != nil {
+		return map[string]interface{}{
+			"range": map[string]interface{}{
+				prefix + f.Key: &struct {
+					Gte interface{} `json:"gte,omitempty"`
+				}{
+					Gte: f.Value,
+				},
+			},
+		}
+	}
+	if f := filter.LteFloat64Filter; f != nil {
+		return map[string]interface{}{
+			"range": map[string]interface{}{
+				prefix + f.Key: &struct {
+					Lte interface{} `json:"lte,omitempty"`
+				}{
+					Lte: f.Value,
+				},
+			},
+		}
+	}
 	return nil
 }
 
@@ -0,0 +1,59 @@
+/*
+ * Copyright (C) 2016 Red Hat, Inc.
+ *
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ *
+ */
+
+package elasticsearch
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/skydive-project/skydive/filters"
+)
+
+func TestFormatFilterEscaping(t *testing.T) {
+	c := &ElasticSearchClient{}
+
+	tests := []struct {
+		filter   *filters.Filter
+		expected map[string]interface{}
+	}{
+		{
+			filters.NewContainsFilter("Name", `a*b?c\d%_`),
+			map[string]interface{}{"wildcard": map[string]string{"Name": `*a\*b\?c\\d%_*`}},
+		},
+		{
+			filters.NewSuffixFilter("Name", `*eth?`),
+			map[string]interface{}{"wildcard": map[string]string{"Name": `*\*eth\?`}},
+		},
+		{
+			// no wildcard in a prefix query
+			filters.NewPrefixFilter("Name", `eth*`),
+			map[string]interface{}{"prefix": map[string]string{"Name": `eth*`}},
+		},
+	}
+
+	for _, test := range tests {
+		if query := c.FormatFilter(test.filter, ""); !reflect.DeepEqual(query, test.expected) {
+			t.Errorf("Expected %v, got %v", test.expected, query)
+		}
+	}
+}
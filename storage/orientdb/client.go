@@ -31,10 +31,12 @@ import (
 	"io"
 	"io/ioutil"
 	"net/http"
+	"regexp"
 	"strings"
 
 	"github.com/skydive-project/skydive/common"
 	"github.com/skydive-project/skydive/filters"
+	"github.com/skydive-project/skydive/logging"
 )
 
 type Document map[string]interface{}
@@ -162,6 +164,20 @@ func replaceSlashes(key string) string {
 	return strings.Replace(key, "/", ".", -1)
 }
 
+var stringEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`)
+
+// quoteString returns the value as a string literal of an expression
+func quoteString(value string) string {
+	return `"` + stringEscaper.Replace(value) + `"`
+}
+
+// quoteMatch returns the regular expression matching the value literally
+// between the before and after expressions, as a string literal. The value is
+// not given to LIKE as its wildcards can't be escaped.
+func quoteMatch(before, value, after string) string {
+	return quoteString(before + regexp.QuoteMeta(value) + after)
+}
+
 func FilterToExpression(f *filters.Filter, prefix string) string {
 	if f.BoolFilter != nil {
 		keyword := ""
@@ -183,7 +199,7 @@ func FilterToExpression(f *filters.Filter, prefix string) string {
 	}
 
 	if f.TermStringFilter != nil {
-		return fmt.Sprintf(`%s = "%s"`, prefix+replaceSlashes(f.TermStringFilter.Key), f.TermStringFilter.Value)
+		return fmt.Sprintf(`%s = %s`, prefix+replaceSlashes(f.TermStringFilter.Key), quoteString(f.TermStringFilter.Value))
 	}
 
 	if f.TermInt64Filter != nil {
@@ -206,8 +222,54 @@ func FilterToExpression(f *filters.Filter, prefix string) string {
 		return fmt.Sprintf("%v <= %v", prefix+replaceSlashes(f.LteInt64Filter.Key), f.LteInt64Filter.Value)
 	}
 
+	if f.GtFloat64Filter != nil {
+		return fmt.Sprintf("%v > %v", prefix+replaceSlashes(f.GtFloat64Filter.Key), f.GtFloat64Filter.Value)
+	}
+
+	if f.LtFloat64Filter != nil {
+		return fmt.Sprintf("%v < %v", prefix+replaceSlashes(f.LtFloat64Filter.Key), f.LtFloat64Filter.Value)
+	}
+
+	if f.GteFloat64Filter != nil {
+		return fmt.Sprintf("%v >= %v", prefix+replaceSlashes(f.GteFloat64Filter.Key), f.GteFloat64Filter.Value)
+	}
+
+	if f.LteFloat64Filter != nil {
+		return fmt.Sprintf("%v <= %v", prefix+replaceSlashes(f.LteFloat64Filter.Key), f.LteFloat64Filter.Value)
+	}
+
+	if f.ContainsFilter != nil {
+		return fmt.Sprintf(`%s MATCHES %s`, prefix+replaceSlashes(f.ContainsFilter.Key), quoteMatch(".*", f.ContainsFilter.Value, ".*"))
+	}
+
+	if f.PrefixFilter != nil {
+		return fmt.Sprintf(`%s MATCHES %s`, prefix+replaceSlashes(f.PrefixFilter.Key), quoteMatch("", f.PrefixFilter.Value, ".*"))
+	}
+
+	if f.SuffixFilter != nil {
+		return fmt.Sprintf(`%s MATCHES %s`, prefix+replaceSlashes(f.SuffixFilter.Key), quoteMatch(".*", f.SuffixFilter.Value, ""))
+	}
+
 	if f.RegexFilter != nil {
-		return fmt.Sprintf(`%s MATCHES "%s"`, prefix+replaceSlashes(f.RegexFilter.Key), f.RegexFilter.Value)
+		return fmt.Sprintf(`%s MATCHES %s`, prefix+replaceSlashes(f.RegexFilter.Key), quoteString(f.RegexFilter.Value))
+	}
+
+	if f.IPFilter != nil {
+		key := prefix + replaceSlashes(f.IPFilter.Key)
+		if regex, ok := f.IPFilter.Regex(); ok {
+			return fmt.Sprintf(`%s MATCHES %s`, key, quoteString(regex))
+		}
+
+		logging.GetLogger().Warningf("IP filter on network %s not supported, no match", f.IPFilter.Cidr)
+		return fmt.Sprintf(`%s IS NULL AND %s IS NOT NULL`, key, key)
+	}
+
+	if f.NullFilter != nil {
+		return fmt.Sprintf(`%s IS NULL`, prefix+replaceSlashes(f.NullFilter.Key))
+	}
+
+	if f.ArrayContainsFilter != nil {
+		return fmt.Sprintf(`%s CONTAINS %s`, prefix+replaceSlashes(f.ArrayContainsFilter.Key), quoteString(f.ArrayContainsFilter.Value))
 	}
 
 	return ""
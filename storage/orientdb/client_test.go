@@ -0,0 +1,63 @@
+/*
+ * Copyright (C) 2016 Red Hat, Inc.
+ *
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ *
+ */
+
+package orientdb
+
+import (
+	"testing"
+
+	"github.com/skydive-project/skydive/filters"
+)
+
+func TestFilterToExpressionEscaping(t *testing.T) {
+	tests := []struct {
+		filter   *filters.Filter
+		expected string
+	}{
+		{
+			filters.NewTermStringFilter("Name", `eth"0`),
+			`Name = "eth\"0"`,
+		},
+		{
+			filters.NewContainsFilter("Name", `a%b_c`),
+			`Name MATCHES ".*a%b_c.*"`,
+		},
+		{
+			filters.NewPrefixFilter("Name", `*.x`),
+			`Name MATCHES "\\*\\.x.*"`,
+		},
+		{
+			filters.NewSuffixFilter("Name", `?"`),
+			`Name MATCHES ".*\\?\""`,
+		},
+		{
+			filters.NewArrayContainsFilter("IPV4", `1" OR 1=1`),
+			`IPV4 CONTAINS "1\" OR 1=1"`,
+		},
+	}
+
+	for _, test := range tests {
+		if expr := FilterToExpression(test.filter, ""); expr != test.expected {
+			t.Errorf("Expected %s, got %s", test.expected, expr)
+		}
+	}
+}
@@ -0,0 +1,89 @@
+/*
+ * Copyright (C) 2016 Red Hat, Inc.
+ *
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ *
+ */
+
+package graph
+
+import (
+	"fmt"
+)
+
+// AddNodes adds all the nodes at once, holding the graph lock, then notifies
+// the listeners. If one of the nodes can't be added, none of them is.
+// The graph must not be locked by the caller.
+func (g *Graph) AddNodes(nodes []*Node) error {
+	g.Lock()
+	defer g.Unlock()
+
+	ids := make(map[Identifier]bool)
+	for _, n := range nodes {
+		if ids[n.ID] || g.GetNode(n.ID) != nil {
+			return fmt.Errorf("Node %s already exists", n.ID)
+		}
+		ids[n.ID] = true
+	}
+
+	for i, n := range nodes {
+		if !g.addNode(n) {
+			for _, added := range nodes[:i] {
+				g.delNode(added)
+			}
+			return fmt.Errorf("Unable to add node %s", n.ID)
+		}
+	}
+
+	for _, n := range nodes {
+		g.notifyEvent(graphEvent{element: n, kind: nodeAdded})
+	}
+
+	return nil
+}
+
+// AddEdges adds all the edges at once, holding the graph lock, then notifies
+// the listeners. If one of the edges can't be added, none of them is.
+// The graph must not be locked by the caller.
+func (g *Graph) AddEdges(edges []*Edge) error {
+	g.Lock()
+	defer g.Unlock()
+
+	ids := make(map[Identifier]bool)
+	for _, e := range edges {
+		if ids[e.ID] || g.GetEdge(e.ID) != nil {
+			return fmt.Errorf("Edge %s already exists", e.ID)
+		}
+		ids[e.ID] = true
+	}
+
+	for i, e := range edges {
+		if !g.addEdge(e) {
+			for _, added := range edges[:i] {
+				g.delEdge(added)
+			}
+			return fmt.Errorf("Unable to add edge %s", e.ID)
+		}
+	}
+
+	for _, e := range edges {
+		g.notifyEvent(graphEvent{element: e, kind: edgeAdded})
+	}
+
+	return nil
+}
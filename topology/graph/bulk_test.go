@@ -0,0 +1,96 @@
+/*
+ * Copyright (C) 2016 Red Hat, Inc.
+ *
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ *
+ */
+
+package graph
+
+import (
+	"testing"
+	"time"
+)
+
+func newTestNode(id string, m Metadata) *Node {
+	return &Node{
+		graphElement: graphElement{
+			ID:        Identifier(id),
+			metadata:  m,
+			createdAt: time.Now().UTC(),
+		},
+	}
+}
+
+func newTestEdge(id string, parent, child Identifier) *Edge {
+	return &Edge{
+		parent: parent,
+		child:  child,
+		graphElement: graphElement{
+			ID:        Identifier(id),
+			metadata:  Metadata{},
+			createdAt: time.Now().UTC(),
+		},
+	}
+}
+
+func TestBulkAdd(t *testing.T) {
+	g := newGraph(t)
+
+	listener := &FakeListener{}
+	g.AddEventListener(listener)
+
+	nodes := []*Node{
+		newTestNode("n1", Metadata{"Value": 1}),
+		newTestNode("n2", Metadata{"Value": 2}),
+		newTestNode("n3", Metadata{"Value": 3}),
+	}
+	if err := g.AddNodes(nodes); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(g.GetNodes(Metadata{})) != 3 || listener.lastNodeAdded != nodes[2] {
+		t.Error("Nodes not added")
+	}
+
+	if err := g.AddNodes([]*Node{newTestNode("n4", nil), newTestNode("n1", nil)}); err == nil {
+		t.Error("Should return an error for a duplicate node")
+	}
+	if g.GetNode(Identifier("n4")) != nil {
+		t.Error("Nodes should be rolled back")
+	}
+
+	edges := []*Edge{
+		newTestEdge("e1", "n1", "n2"),
+		newTestEdge("e2", "n2", "n3"),
+	}
+	if err := g.AddEdges(edges); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(g.GetEdges(Metadata{})) != 2 || listener.lastEdgeAdded != edges[1] {
+		t.Error("Edges not added")
+	}
+
+	if err := g.AddEdges([]*Edge{newTestEdge("e3", "n1", "n3"), newTestEdge("e4", "n1", "n5")}); err == nil {
+		t.Error("Should return an error for an unknown node")
+	}
+	if g.GetEdge(Identifier("e3")) != nil {
+		t.Error("Edges should be rolled back")
+	}
+}
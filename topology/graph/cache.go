@@ -0,0 +1,137 @@
+/*
+ * Copyright (C) 2016 Red Hat, Inc.
+ *
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ *
+ */
+
+package graph
+
+import (
+	"container/list"
+	"fmt"
+	"sync"
+
+	"github.com/mitchellh/hashstructure"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var (
+	shortestPathCacheHits = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "skydive_graph_shortest_path_cache_hits_total",
+		Help: "Number of shortest path lookups served by the cache",
+	})
+	shortestPathCacheMisses = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "skydive_graph_shortest_path_cache_misses_total",
+		Help: "Number of shortest path lookups not served by the cache",
+	})
+)
+
+type shortestPathCacheEntry struct {
+	key  string
+	path []*Node
+}
+
+// shortestPathCache is a LRU cache of shortest path lookups. The whole cache
+// is flushed on any change of the graph as an update of any node or edge may
+// make it match the filters of a cached lookup, and then change its result.
+type shortestPathCache struct {
+	sync.Mutex
+	size    int
+	ll      *list.List
+	entries map[string]*list.Element
+}
+
+func newShortestPathCache(size int) *shortestPathCache {
+	return &shortestPathCache{
+		size:    size,
+		ll:      list.New(),
+		entries: make(map[string]*list.Element),
+	}
+}
+
+func shortestPathCacheKey(n *Node, m Metadata, em Metadata) (string, error) {
+	mh, err := hashstructure.Hash(m, nil)
+	if err != nil {
+		return "", err
+	}
+
+	emh, err := hashstructure.Hash(em, nil)
+	if err != nil {
+		return "", err
+	}
+
+	return fmt.Sprintf("%s/%d/%d", n.ID, mh, emh), nil
+}
+
+func (c *shortestPathCache) get(key string) ([]*Node, bool) {
+	c.Lock()
+	defer c.Unlock()
+
+	if el, ok := c.entries[key]; ok {
+		c.ll.MoveToFront(el)
+		shortestPathCacheHits.Inc()
+		return el.Value.(*shortestPathCacheEntry).path, true
+	}
+
+	shortestPathCacheMisses.Inc()
+	return nil, false
+}
+
+func (c *shortestPathCache) add(key string, path []*Node) {
+	c.Lock()
+	defer c.Unlock()
+
+	if _, ok := c.entries[key]; ok {
+		return
+	}
+
+	c.entries[key] = c.ll.PushFront(&shortestPathCacheEntry{key: key, path: path})
+
+	if c.ll.Len() > c.size {
+		entry := c.ll.Remove(c.ll.Back()).(*shortestPathCacheEntry)
+		delete(c.entries, entry.key)
+	}
+}
+
+func (c *shortestPathCache) flush() {
+	c.Lock()
+	defer c.Unlock()
+
+	c.ll.Init()
+	c.entries = make(map[string]*list.Element)
+}
+
+func (c *shortestPathCache) onEvent(ge graphEvent) {
+	c.flush()
+}
+
+// SetShortestPathCacheSize sets the number of shortest path lookups kept in
+// cache, 0 disabling the cache
+func (g *Graph) SetShortestPathCacheSize(size int) {
+	if size <= 0 {
+		g.pathCache = nil
+		return
+	}
+	g.pathCache = newShortestPathCache(size)
+}
+
+func init() {
+	prometheus.MustRegister(shortestPathCacheHits)
+	prometheus.MustRegister(shortestPathCacheMisses)
+}
@@ -190,6 +190,19 @@ func (c *CachedBackend) SetMetadata(i interface{}, metadata Metadata) bool {
 	return r
 }
 
+func (c *CachedBackend) WalkNodes(t *common.TimeSlice, m Metadata, fn func(n *Node) bool) {
+	if t == nil && c.cacheMode.Load() != PERSISTENT_ONLY_MODE {
+		c.memory.WalkNodes(t, m, fn)
+		return
+	}
+
+	for _, n := range c.GetNodes(t, m) {
+		if !fn(n) {
+			return
+		}
+	}
+}
+
 func (c *CachedBackend) GetNodes(t *common.TimeSlice, m Metadata) []*Node {
 	mode := c.cacheMode.Load()
 
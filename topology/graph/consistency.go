@@ -0,0 +1,185 @@
+/*
+ * Copyright (C) 2016 Red Hat, Inc.
+ *
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ *
+ */
+
+package graph
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/skydive-project/skydive/logging"
+)
+
+// Kinds of consistency errors
+const (
+	DanglingEdge       = "DanglingEdge"
+	DuplicateNodeID    = "DuplicateNodeID"
+	DuplicateEdgeID    = "DuplicateEdgeID"
+	UnserializableData = "UnserializableData"
+)
+
+var graphConsistencyErrors = prometheus.NewGauge(prometheus.GaugeOpts{
+	Name: "skydive_graph_consistency_errors",
+	Help: "Number of consistency errors found by the last graph check",
+})
+
+// ConsistencyError describes an inconsistency of the graph found by Validate
+type ConsistencyError struct {
+	Kind    string
+	ID      Identifier
+	Message string
+}
+
+func (e ConsistencyError) Error() string {
+	return e.Message
+}
+
+func (g *Graph) validateMetadata(id Identifier, m Metadata) []ConsistencyError {
+	var errs []ConsistencyError
+	for k, v := range m {
+		if _, err := json.Marshal(v); err != nil {
+			errs = append(errs, ConsistencyError{
+				Kind:    UnserializableData,
+				ID:      id,
+				Message: fmt.Sprintf("Metadata %s of %s can't be serialized: %s", k, id, err.Error()),
+			})
+		}
+	}
+	return errs
+}
+
+// Validate checks that the edges link existing nodes, that the node and edge
+// identifiers are unique and that the metadata can be serialized to JSON.
+// An error is returned if the graph can't be checked, a graph history for
+// instance holding several revisions of the same elements.
+func (g *Graph) Validate() ([]ConsistencyError, error) {
+	g.RLock()
+	defer g.RUnlock()
+
+	if g.context.GetTimeSlice() != nil {
+		return nil, errors.New("Consistency check not supported on a graph history")
+	}
+
+	var errs []ConsistencyError
+
+	nodes := make(map[Identifier]bool)
+	for _, n := range g.GetNodes(Metadata{}) {
+		if nodes[n.ID] {
+			errs = append(errs, ConsistencyError{
+				Kind:    DuplicateNodeID,
+				ID:      n.ID,
+				Message: fmt.Sprintf("Several nodes with the ID %s", n.ID),
+			})
+			continue
+		}
+		nodes[n.ID] = true
+
+		errs = append(errs, g.validateMetadata(n.ID, n.metadata)...)
+	}
+
+	edges := make(map[Identifier]bool)
+	for _, e := range g.GetEdges(Metadata{}) {
+		if edges[e.ID] {
+			errs = append(errs, ConsistencyError{
+				Kind:    DuplicateEdgeID,
+				ID:      e.ID,
+				Message: fmt.Sprintf("Several edges with the ID %s", e.ID),
+			})
+			continue
+		}
+		edges[e.ID] = true
+
+		for _, id := range []Identifier{e.parent, e.child} {
+			if !nodes[id] {
+				errs = append(errs, ConsistencyError{
+					Kind:    DanglingEdge,
+					ID:      e.ID,
+					Message: fmt.Sprintf("Edge %s references the unknown node %s", e.ID, id),
+				})
+			}
+		}
+
+		errs = append(errs, g.validateMetadata(e.ID, e.metadata)...)
+	}
+
+	return errs, nil
+}
+
+// ConsistencyChecker validates periodically a graph, logging the consistency
+// errors found
+type ConsistencyChecker struct {
+	graph    *Graph
+	interval time.Duration
+	quit     chan bool
+}
+
+func (c *ConsistencyChecker) check() {
+	errs, err := c.graph.Validate()
+	if err != nil {
+		logging.GetLogger().Errorf("Unable to check the graph consistency: %s", err.Error())
+		return
+	}
+
+	for _, e := range errs {
+		logging.GetLogger().Warningf("Graph consistency error: %s", e.Message)
+	}
+	graphConsistencyErrors.Set(float64(len(errs)))
+}
+
+func (c *ConsistencyChecker) run() {
+	ticker := time.NewTicker(c.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			c.check()
+		case <-c.quit:
+			return
+		}
+	}
+}
+
+func (c *ConsistencyChecker) Start() {
+	go c.run()
+}
+
+func (c *ConsistencyChecker) Stop() {
+	c.quit <- true
+}
+
+// NewConsistencyChecker returns a checker validating the graph every interval
+func NewConsistencyChecker(g *Graph, interval time.Duration) *ConsistencyChecker {
+	return &ConsistencyChecker{
+		graph:    g,
+		interval: interval,
+		quit:     make(chan bool),
+	}
+}
+
+func init() {
+	prometheus.MustRegister(graphConsistencyErrors)
+}
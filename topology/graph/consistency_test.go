@@ -0,0 +1,61 @@
+/*
+ * Copyright (C) 2016 Red Hat, Inc.
+ *
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ *
+ */
+
+package graph
+
+import (
+	"math"
+	"testing"
+)
+
+func TestValidate(t *testing.T) {
+	g := newGraph(t)
+
+	n1 := g.NewNode(GenID(), Metadata{"Type": "intf"})
+	n2 := g.NewNode(GenID(), Metadata{"Type": "intf"})
+	g.Link(n1, n2, Metadata{})
+
+	errs, err := g.Validate()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(errs) != 0 {
+		t.Fatalf("Unexpected consistency errors: %v", errs)
+	}
+
+	// remove the node from the backend only, leaving its edge behind
+	g.backend.DelNode(n2)
+	g.AddMetadata(n1, "Ratio", math.NaN())
+
+	errs, err = g.Validate()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	kinds := make(map[string]int)
+	for _, e := range errs {
+		kinds[e.Kind]++
+	}
+	if len(errs) != 2 || kinds[DanglingEdge] != 1 || kinds[UnserializableData] != 1 {
+		t.Errorf("Wrong consistency errors: %v", errs)
+	}
+}
@@ -0,0 +1,102 @@
+/*
+ * Copyright (C) 2016 Red Hat, Inc.
+ *
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ *
+ */
+
+package graph
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+)
+
+type dotOptions struct {
+	attributes []string
+}
+
+// DOTOption allows to customize the DOT export
+type DOTOption func(*dotOptions)
+
+// DOTNodeAttributes adds the given metadata keys as attributes of the nodes
+func DOTNodeAttributes(keys ...string) DOTOption {
+	return func(o *dotOptions) {
+		o.attributes = append(o.attributes, keys...)
+	}
+}
+
+type nodesByID []*Node
+
+func (n nodesByID) Len() int           { return len(n) }
+func (n nodesByID) Swap(i, j int)      { n[i], n[j] = n[j], n[i] }
+func (n nodesByID) Less(i, j int) bool { return n[i].ID < n[j].ID }
+
+type edgesByID []*Edge
+
+func (e edgesByID) Len() int           { return len(e) }
+func (e edgesByID) Swap(i, j int)      { e[i], e[j] = e[j], e[i] }
+func (e edgesByID) Less(i, j int) bool { return e[i].ID < e[j].ID }
+
+func dotNodeLabel(n *Node) string {
+	name, _ := n.GetFieldString("Name")
+	if t, err := n.GetFieldString("Type"); err == nil {
+		return fmt.Sprintf("%s[%s]", name, t)
+	}
+	return name
+}
+
+// ExportDOT writes the graph in the Graphviz DOT format. Nodes are labeled
+// with "Name[Type]" and edges with their "RelationType".
+func (g *Graph) ExportDOT(w io.Writer, opts ...DOTOption) error {
+	options := &dotOptions{}
+	for _, opt := range opts {
+		opt(options)
+	}
+
+	nodes := nodesByID(g.GetNodes(Metadata{}))
+	sort.Sort(nodes)
+
+	edges := edgesByID(g.GetEdges(Metadata{}))
+	sort.Sort(edges)
+
+	bw := bufio.NewWriter(w)
+	fmt.Fprintln(bw, "digraph skydive {")
+
+	for _, n := range nodes {
+		fmt.Fprintf(bw, "\t%s [label=%s", strconv.Quote(string(n.ID)), strconv.Quote(dotNodeLabel(n)))
+		for _, key := range options.attributes {
+			if v, ok := n.GetField(key); ok {
+				fmt.Fprintf(bw, ", %s=%s", strconv.Quote(key), strconv.Quote(fmt.Sprintf("%v", v)))
+			}
+		}
+		fmt.Fprintln(bw, "];")
+	}
+
+	for _, e := range edges {
+		relationType, _ := e.GetFieldString("RelationType")
+		fmt.Fprintf(bw, "\t%s -> %s [label=%s];\n", strconv.Quote(string(e.parent)), strconv.Quote(string(e.child)), strconv.Quote(relationType))
+	}
+
+	fmt.Fprintln(bw, "}")
+
+	return bw.Flush()
+}
@@ -0,0 +1,51 @@
+/*
+ * Copyright (C) 2016 Red Hat, Inc.
+ *
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ *
+ */
+
+package graph
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestExportDOT(t *testing.T) {
+	g := newGraph(t)
+
+	n1 := g.NewNode(Identifier("n1"), Metadata{"Name": "eth0", "Type": "device", "MTU": 1500})
+	n2 := g.NewNode(Identifier("n2"), Metadata{"Name": "br0", "Type": "bridge"})
+	g.NewEdge(Identifier("e1"), n2, n1, Metadata{"RelationType": "ownership"})
+
+	var buf bytes.Buffer
+	if err := g.ExportDOT(&buf, DOTNodeAttributes("MTU")); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := `digraph skydive {
+	"n1" [label="eth0[device]", "MTU"="1500"];
+	"n2" [label="br0[bridge]"];
+	"n2" -> "n1" [label="ownership"];
+}
+`
+	if buf.String() != expected {
+		t.Errorf("Expected:\n%s\ngot:\n%s", expected, buf.String())
+	}
+}
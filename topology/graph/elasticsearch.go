@@ -494,11 +494,18 @@ func (b *ElasticSearchBackend) GetNodeEdges(n *Node, t *common.TimeSlice, m Meta
 }
 
 func (b *ElasticSearchBackend) WithContext(graph *Graph, context GraphContext) (*Graph, error) {
-	return &Graph{
+	g := &Graph{
 		backend: graph.backend,
 		context: context,
 		host:    graph.host,
-	}, nil
+	}
+
+	// the counts of a time slice are retrieved from the backend
+	if context.TimeSlice == nil {
+		g.seedCounts()
+	}
+
+	return g, nil
 }
 
 func NewElasticSearchBackend(addr string, port string, maxConns int, retrySeconds int, bulkMaxDocs int) (*ElasticSearchBackend, error) {
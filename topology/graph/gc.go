@@ -0,0 +1,79 @@
+/*
+ * Copyright (C) 2016 Red Hat, Inc.
+ *
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ *
+ */
+
+package graph
+
+import (
+	"sort"
+)
+
+type gcOptions struct {
+	edgeFilter Metadata
+	dryRun     bool
+}
+
+// GCOption allows to customize the garbage collection of the graph
+type GCOption func(*gcOptions)
+
+// GCOwnershipOnly makes the garbage collection remove the nodes without any
+// ownership edge, whatever their other edges
+func GCOwnershipOnly() GCOption {
+	return func(o *gcOptions) {
+		o.edgeFilter = Metadata{"RelationType": "ownership"}
+	}
+}
+
+// GCDryRun makes the garbage collection only report the nodes that would be
+// removed
+func GCDryRun() GCOption {
+	return func(o *gcOptions) {
+		o.dryRun = true
+	}
+}
+
+// GarbageCollect removes the orphaned nodes, that is to say the nodes without
+// any edge, or without ownership edge when GCOwnershipOnly is given. It
+// returns the sorted IDs of the removed nodes.
+func (g *Graph) GarbageCollect(opts ...GCOption) []Identifier {
+	options := &gcOptions{edgeFilter: Metadata{}}
+	for _, opt := range opts {
+		opt(options)
+	}
+
+	var orphans nodesByID
+	for _, n := range g.GetNodes(Metadata{}) {
+		if len(g.GetNodeEdges(n, options.edgeFilter)) == 0 {
+			orphans = append(orphans, n)
+		}
+	}
+	sort.Sort(orphans)
+
+	ids := make([]Identifier, len(orphans))
+	for i, n := range orphans {
+		ids[i] = n.ID
+		if !options.dryRun {
+			g.DelNode(n)
+		}
+	}
+
+	return ids
+}
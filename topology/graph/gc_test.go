@@ -0,0 +1,69 @@
+/*
+ * Copyright (C) 2016 Red Hat, Inc.
+ *
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ *
+ */
+
+package graph
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestGarbageCollect(t *testing.T) {
+	g := newGraph(t)
+
+	host := g.NewNode(Identifier("host"), Metadata{"Type": "host"})
+	n1 := g.NewNode(Identifier("n1"), Metadata{"Type": "intf"})
+	n2 := g.NewNode(Identifier("n2"), Metadata{"Type": "intf"})
+	g.NewNode(Identifier("n3"), Metadata{"Type": "intf"})
+	g.Link(host, n1, Metadata{"RelationType": "ownership"})
+	g.Link(n1, n2, Metadata{"RelationType": "layer2"})
+
+	listener := &FakeListener{}
+	g.AddEventListener(listener)
+
+	ids := g.GarbageCollect(GCOwnershipOnly(), GCDryRun())
+	if !reflect.DeepEqual(ids, []Identifier{"n2", "n3"}) {
+		t.Errorf("Wrong nodes to collect: %v", ids)
+	}
+	if len(g.GetNodes(Metadata{})) != 4 || listener.lastNodeDeleted != nil {
+		t.Error("Dry run should not remove nodes")
+	}
+
+	ids = g.GarbageCollect()
+	if !reflect.DeepEqual(ids, []Identifier{"n3"}) {
+		t.Errorf("Wrong collected nodes: %v", ids)
+	}
+	if g.GetNode(Identifier("n3")) != nil {
+		t.Error("Orphaned node should be removed")
+	}
+	if listener.lastNodeDeleted == nil || listener.lastNodeDeleted.ID != "n3" {
+		t.Error("Node deleted event not received")
+	}
+
+	ids = g.GarbageCollect(GCOwnershipOnly())
+	if !reflect.DeepEqual(ids, []Identifier{"n2"}) {
+		t.Errorf("Wrong collected nodes: %v", ids)
+	}
+	if len(g.GetNodes(Metadata{})) != 2 {
+		t.Errorf("Wrong remaining nodes: %s", g.String())
+	}
+}
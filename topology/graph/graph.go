@@ -23,12 +23,15 @@
 package graph
 
 import (
+	"container/heap"
 	"encoding/json"
 	"errors"
 	"fmt"
+	"math"
 	"reflect"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/nu7hatch/gouuid"
@@ -36,6 +39,7 @@ import (
 	"github.com/skydive-project/skydive/common"
 	"github.com/skydive-project/skydive/config"
 	"github.com/skydive-project/skydive/filters"
+	"github.com/skydive-project/skydive/logging"
 )
 
 const (
@@ -116,11 +120,20 @@ type GraphBackend interface {
 	WithContext(graph *Graph, context GraphContext) (*Graph, error)
 }
 
+// nodeWalker is implemented by the backends able to walk through their nodes
+// without building the list of all of them
+type nodeWalker interface {
+	WalkNodes(t *common.TimeSlice, m Metadata, fn func(n *Node) bool)
+}
+
 type GraphContext struct {
 	TimeSlice *common.TimeSlice
 }
 
 type Graph struct {
+	// accessed atomically, kept first to be 64-bit aligned on 32-bit platforms
+	nodeCount int64
+	edgeCount int64
 	sync.RWMutex
 	backend              GraphBackend
 	context              GraphContext
@@ -129,6 +142,12 @@ type Graph struct {
 	eventChan            chan graphEvent
 	eventConsumed        bool
 	currentEventListener GraphEventListener
+	expirations          map[Identifier]time.Time
+	expirationRunning    bool
+	indexes              map[string]*int64Index
+	stringIndexes        map[string]*stringIndex
+	pathCache            *shortestPathCache
+	transaction          *GraphTransaction
 }
 
 type HostNodeTIDMap map[string][]string
@@ -177,6 +196,44 @@ func (m *Metadata) String() string {
 	return string(j)
 }
 
+// clone returns a shallow copy of the metadata
+func (m Metadata) clone() Metadata {
+	c := make(Metadata, len(m))
+	for k, v := range m {
+		c[k] = v
+	}
+	return c
+}
+
+// GetField returns the value of the given key. If the key is not found and
+// contains slashes, it is considered as a path in nested maps, "A/B" being
+// the key "B" of the map stored under "A".
+func (m Metadata) GetField(name string) (interface{}, bool) {
+	if v, ok := m[name]; ok {
+		return v, true
+	}
+
+	if !strings.Contains(name, "/") {
+		return nil, false
+	}
+
+	var current interface{} = map[string]interface{}(m)
+	for _, key := range strings.Split(name, "/") {
+		var ok bool
+		switch c := current.(type) {
+		case map[string]interface{}:
+			current, ok = c[key]
+		case Metadata:
+			current, ok = c[key]
+		}
+		if !ok {
+			return nil, false
+		}
+	}
+
+	return current, true
+}
+
 func (e *graphElement) Host() string {
 	return e.host
 }
@@ -189,6 +246,14 @@ func (e *graphElement) GetFieldInt64(field string) (_ int64, err error) {
 	return common.ToInt64(f)
 }
 
+func (e *graphElement) GetFieldFloat64(field string) (_ float64, err error) {
+	f, found := e.GetField(field)
+	if !found {
+		return 0, common.ErrFieldNotFound
+	}
+	return common.ToFloat64(f)
+}
+
 func (e *graphElement) GetFieldString(field string) (_ string, err error) {
 	f, found := e.GetField(field)
 	if !found {
@@ -215,20 +280,14 @@ func (e *graphElement) GetField(name string) (interface{}, bool) {
 		if strings.HasPrefix(name, "Metadata/") {
 			name = name[9:]
 		}
-		v, ok := e.Metadata()[name]
-		return v, ok
+		return e.metadata.GetField(name)
 	}
 }
 
 // Metadata returns a copy in order to avoid direct modification of metadata leading in
 // loosing notification.
 func (e *graphElement) Metadata() Metadata {
-	m := Metadata{}
-
-	for k, v := range e.metadata {
-		m[k] = v
-	}
-	return m
+	return e.metadata.clone()
 }
 
 func (e *graphElement) MatchMetadata(f Metadata) bool {
@@ -239,7 +298,7 @@ func (e *graphElement) MatchMetadata(f Metadata) bool {
 				return false
 			}
 		default:
-			nv, ok := e.metadata[k]
+			nv, ok := e.metadata.GetField(k)
 			if !ok || !common.CrossTypeEqual(nv, v) {
 				return false
 			}
@@ -472,6 +531,10 @@ func (g *Graph) SetMetadata(i interface{}, m Metadata) bool {
 	}
 
 	e.metadata = m
+	g.refreshExpiration(e)
+	if n, ok := i.(*Node); ok {
+		g.updateIndexes(n)
+	}
 
 	g.notifyEvent(ge)
 	return true
@@ -514,6 +577,10 @@ func (g *Graph) AddMetadata(i interface{}, k string, v interface{}) bool {
 	}
 
 	e.metadata[k] = v
+	g.refreshExpiration(e)
+	if n, ok := i.(*Node); ok {
+		g.updateIndexes(n)
+	}
 
 	g.notifyEvent(ge)
 	return true
@@ -624,7 +691,473 @@ func (g *Graph) lookupShortestPath(n *Node, m Metadata, path []*Node, v map[Iden
 }
 
 func (g *Graph) LookupShortestPath(n *Node, m Metadata, em Metadata) []*Node {
-	return g.lookupShortestPath(n, m, []*Node{}, make(map[Identifier]bool), em)
+	if g.pathCache == nil || g.context.GetTimeSlice() != nil {
+		return g.lookupShortestPath(n, m, []*Node{}, make(map[Identifier]bool), em)
+	}
+
+	key, err := shortestPathCacheKey(n, m, em)
+	if err != nil {
+		return g.lookupShortestPath(n, m, []*Node{}, make(map[Identifier]bool), em)
+	}
+
+	if path, ok := g.pathCache.get(key); ok {
+		return path
+	}
+
+	path := g.lookupShortestPath(n, m, []*Node{}, make(map[Identifier]bool), em)
+	g.pathCache.add(key, path)
+
+	return path
+}
+
+func (g *Graph) lookupAllPaths(n *Node, m Metadata, path []*Node, v map[Identifier]bool, em Metadata, max int, paths [][]*Node) [][]*Node {
+	if max > 0 && len(paths) >= max {
+		return paths
+	}
+
+	newPath := make([]*Node, len(path)+1)
+	copy(newPath, path)
+	newPath[len(path)] = n
+
+	if n.MatchMetadata(m) {
+		return append(paths, newPath)
+	}
+
+	v[n.ID] = true
+	defer delete(v, n.ID)
+
+	t := g.context.GetTimeSlice()
+	for _, e := range g.backend.GetNodeEdges(n, t, em) {
+		parents, children := g.backend.GetEdgeNodes(e, t, nil, nil)
+		if len(parents) == 0 || len(children) == 0 {
+			continue
+		}
+
+		neighbor := parents[0]
+		if neighbor.ID == n.ID {
+			neighbor = children[0]
+		}
+
+		if !v[neighbor.ID] {
+			paths = g.lookupAllPaths(neighbor, m, newPath, v, em, max, paths)
+		}
+	}
+
+	return paths
+}
+
+// LookupAllPaths returns all the simple paths from the given node to the nodes
+// matching the metadata, following only edges matching em. max limits the
+// number of returned paths, 0 meaning no limit.
+func (g *Graph) LookupAllPaths(n *Node, m Metadata, em Metadata, max int) [][]*Node {
+	return g.lookupAllPaths(n, m, []*Node{}, make(map[Identifier]bool), em, max, [][]*Node{})
+}
+
+type weightedNode struct {
+	node     *Node
+	distance float64
+	index    int
+}
+
+type weightedQueue []*weightedNode
+
+func (q weightedQueue) Len() int           { return len(q) }
+func (q weightedQueue) Less(i, j int) bool { return q[i].distance < q[j].distance }
+
+func (q weightedQueue) Swap(i, j int) {
+	q[i], q[j] = q[j], q[i]
+	q[i].index, q[j].index = i, j
+}
+
+func (q *weightedQueue) Push(x interface{}) {
+	wn := x.(*weightedNode)
+	wn.index = len(*q)
+	*q = append(*q, wn)
+}
+
+func (q *weightedQueue) Pop() interface{} {
+	old := *q
+	wn := old[len(old)-1]
+	*q = old[:len(old)-1]
+	return wn
+}
+
+// LookupWeightedShortestPath returns the path of lowest cost from the given
+// node to a node matching the metadata, following only edges matching em.
+// The cost of an edge is the numeric value of its weightKey field, 1.0 if
+// missing. The edges with a negative or non-numeric cost are skipped.
+func (g *Graph) LookupWeightedShortestPath(n *Node, m Metadata, em Metadata, weightKey string) []*Node {
+	t := g.context.GetTimeSlice()
+
+	distances := map[Identifier]*weightedNode{n.ID: {node: n}}
+	previous := make(map[Identifier]*Node)
+	visited := make(map[Identifier]bool)
+
+	queue := &weightedQueue{distances[n.ID]}
+	for queue.Len() > 0 {
+		current := heap.Pop(queue).(*weightedNode)
+		if visited[current.node.ID] {
+			continue
+		}
+		visited[current.node.ID] = true
+
+		if current.node.MatchMetadata(m) {
+			var path []*Node
+			for node := current.node; node != nil; node = previous[node.ID] {
+				path = append([]*Node{node}, path...)
+			}
+			return path
+		}
+
+		for _, e := range g.backend.GetNodeEdges(current.node, t, em) {
+			parents, children := g.backend.GetEdgeNodes(e, t, nil, nil)
+			if len(parents) == 0 || len(children) == 0 {
+				continue
+			}
+
+			neighbor := parents[0]
+			if neighbor.ID == current.node.ID {
+				neighbor = children[0]
+			}
+
+			if visited[neighbor.ID] {
+				continue
+			}
+
+			weight, err := e.GetFieldFloat64(weightKey)
+			if err == common.ErrFieldNotFound {
+				weight = 1.0
+			} else if err != nil || weight < 0 || math.IsNaN(weight) {
+				logging.GetLogger().Warningf("Skipping edge %s of invalid %s weight: %v", e.ID, weightKey, e.metadata[weightKey])
+				continue
+			}
+
+			distance := current.distance + weight
+			if wn, ok := distances[neighbor.ID]; !ok || distance < wn.distance {
+				wn = &weightedNode{node: neighbor, distance: distance}
+				distances[neighbor.ID] = wn
+				previous[neighbor.ID] = current.node
+				heap.Push(queue, wn)
+			}
+		}
+	}
+
+	return []*Node{}
+}
+
+func buildShortestPaths(n *Node, predecessors map[Identifier][]*Node, path []*Node, paths [][]*Node) [][]*Node {
+	path = append([]*Node{n}, path...)
+
+	preds, ok := predecessors[n.ID]
+	if !ok || len(preds) == 0 {
+		return append(paths, path)
+	}
+
+	for _, pred := range preds {
+		paths = buildShortestPaths(pred, predecessors, path, paths)
+	}
+	return paths
+}
+
+// LookupAllShortestPaths returns all the paths of minimal length from the
+// given node to the nodes matching the metadata, following only edges
+// matching em.
+func (g *Graph) LookupAllShortestPaths(n *Node, m Metadata, em Metadata) [][]*Node {
+	t := g.context.GetTimeSlice()
+
+	depths := map[Identifier]int{n.ID: 0}
+	predecessors := make(map[Identifier][]*Node)
+
+	var targets []*Node
+	level := []*Node{n}
+	for depth := 0; len(level) > 0; depth++ {
+		for _, node := range level {
+			if node.MatchMetadata(m) {
+				targets = append(targets, node)
+			}
+		}
+		if len(targets) > 0 {
+			break
+		}
+
+		var next []*Node
+		for _, node := range level {
+			for _, e := range g.backend.GetNodeEdges(node, t, em) {
+				parents, children := g.backend.GetEdgeNodes(e, t, nil, nil)
+				if len(parents) == 0 || len(children) == 0 {
+					continue
+				}
+
+				neighbor := parents[0]
+				if neighbor.ID == node.ID {
+					neighbor = children[0]
+				}
+
+				d, ok := depths[neighbor.ID]
+				if !ok {
+					depths[neighbor.ID] = depth + 1
+					next = append(next, neighbor)
+				} else if d != depth+1 {
+					continue
+				}
+
+				known := false
+				for _, pred := range predecessors[neighbor.ID] {
+					if pred.ID == node.ID {
+						known = true
+						break
+					}
+				}
+				if !known {
+					predecessors[neighbor.ID] = append(predecessors[neighbor.ID], node)
+				}
+			}
+		}
+		level = next
+	}
+
+	paths := [][]*Node{}
+	for _, target := range targets {
+		paths = buildShortestPaths(target, predecessors, nil, paths)
+	}
+	return paths
+}
+
+// ConnectedComponents returns the weakly connected components of the graph.
+// As for the other lookups, the caller has to hold the graph lock.
+func (g *Graph) ConnectedComponents() [][]*Node {
+	t := g.context.GetTimeSlice()
+
+	var components [][]*Node
+	visited := make(map[Identifier]bool)
+	for _, n := range g.GetNodes(Metadata{}) {
+		if visited[n.ID] {
+			continue
+		}
+		visited[n.ID] = true
+
+		component := []*Node{n}
+		for i := 0; i < len(component); i++ {
+			node := component[i]
+			for _, e := range g.backend.GetNodeEdges(node, t, nil) {
+				parents, children := g.backend.GetEdgeNodes(e, t, nil, nil)
+				for _, neighbor := range append(parents, children...) {
+					if !visited[neighbor.ID] {
+						visited[neighbor.ID] = true
+						component = append(component, neighbor)
+					}
+				}
+			}
+		}
+		components = append(components, component)
+	}
+
+	return components
+}
+
+// lookupNodesWithoutEdges returns the nodes having no edge matching em for
+// which the given predicate returns true
+func (g *Graph) lookupNodesWithoutEdges(em Metadata, predicate func(n *Node, e *Edge) bool) (nodes []*Node) {
+	t := g.context.GetTimeSlice()
+
+nodeLoop:
+	for _, n := range g.GetNodes(Metadata{}) {
+		for _, e := range g.backend.GetNodeEdges(n, t, em) {
+			if predicate(n, e) {
+				continue nodeLoop
+			}
+		}
+		nodes = append(nodes, n)
+	}
+
+	return nodes
+}
+
+// GetLeafNodes returns the nodes which are not the parent of any edge
+// matching em
+func (g *Graph) GetLeafNodes(em Metadata) []*Node {
+	return g.lookupNodesWithoutEdges(em, func(n *Node, e *Edge) bool { return e.parent == n.ID })
+}
+
+// GetRootNodes returns the nodes which are not the child of any edge
+// matching em
+func (g *Graph) GetRootNodes(em Metadata) []*Node {
+	return g.lookupNodesWithoutEdges(em, func(n *Node, e *Edge) bool { return e.child == n.ID })
+}
+
+// GetIsolatedNodes returns the nodes without any edge
+func (g *Graph) GetIsolatedNodes() []*Node {
+	return g.lookupNodesWithoutEdges(nil, func(n *Node, e *Edge) bool { return true })
+}
+
+// TopologicalSort returns the nodes ordered so that parents come before their
+// children, following only edges matching em. An error listing the nodes
+// involved in cycles is returned if the graph is not acyclic.
+func (g *Graph) TopologicalSort(em Metadata) ([]*Node, error) {
+	nodes := g.GetNodes(Metadata{})
+
+	inDegrees := make(map[Identifier]int)
+	children := make(map[Identifier][]Identifier)
+	for _, e := range g.GetEdges(em) {
+		inDegrees[e.child]++
+		children[e.parent] = append(children[e.parent], e.child)
+	}
+
+	byID := make(map[Identifier]*Node)
+	var queue []Identifier
+	for _, n := range nodes {
+		byID[n.ID] = n
+		if inDegrees[n.ID] == 0 {
+			queue = append(queue, n.ID)
+		}
+	}
+
+	var sorted []*Node
+	for len(queue) > 0 {
+		id := queue[0]
+		queue = queue[1:]
+
+		if n, ok := byID[id]; ok {
+			sorted = append(sorted, n)
+		}
+
+		for _, child := range children[id] {
+			inDegrees[child]--
+			if inDegrees[child] == 0 {
+				queue = append(queue, child)
+			}
+		}
+	}
+
+	if len(sorted) != len(nodes) {
+		var cycle []string
+		for _, n := range nodes {
+			if inDegrees[n.ID] > 0 {
+				cycle = append(cycle, string(n.ID))
+			}
+		}
+		return nil, fmt.Errorf("Cycle detected between nodes: %s", strings.Join(cycle, ", "))
+	}
+
+	return sorted, nil
+}
+
+// findCycles does a depth first walk of the graph following the edges
+// matching em from parents to children. onCycle is called with the nodes of
+// the cycle for each back edge found, the walk stopping if it returns false.
+func (g *Graph) findCycles(em Metadata, onCycle func(cycle []*Node) bool) {
+	const (
+		white = iota
+		gray
+		black
+	)
+
+	nodes := g.GetNodes(Metadata{})
+
+	byID := make(map[Identifier]*Node)
+	for _, n := range nodes {
+		byID[n.ID] = n
+	}
+
+	children := make(map[Identifier][]Identifier)
+	for _, e := range g.GetEdges(em) {
+		children[e.parent] = append(children[e.parent], e.child)
+	}
+
+	colors := make(map[Identifier]int)
+	var stack []*Node
+
+	var visit func(n *Node) bool
+	visit = func(n *Node) bool {
+		colors[n.ID] = gray
+		stack = append(stack, n)
+
+		for _, id := range children[n.ID] {
+			child, ok := byID[id]
+			if !ok {
+				continue
+			}
+
+			switch colors[id] {
+			case gray:
+				i := len(stack) - 1
+				for stack[i].ID != id {
+					i--
+				}
+				cycle := make([]*Node, len(stack)-i)
+				copy(cycle, stack[i:])
+				if !onCycle(cycle) {
+					return false
+				}
+			case white:
+				if !visit(child) {
+					return false
+				}
+			}
+		}
+
+		stack = stack[:len(stack)-1]
+		colors[n.ID] = black
+		return true
+	}
+
+	for _, n := range nodes {
+		if colors[n.ID] == white && !visit(n) {
+			return
+		}
+	}
+}
+
+// HasCycle returns whether the graph contains a cycle following the edges
+// matching em from parents to children
+func (g *Graph) HasCycle(em Metadata) bool {
+	found := false
+	g.findCycles(em, func(cycle []*Node) bool {
+		found = true
+		return false
+	})
+	return found
+}
+
+// FindCycles returns the cycles closed by the back edges found while walking
+// the graph following the edges matching em from parents to children
+func (g *Graph) FindCycles(em Metadata) [][]*Node {
+	var cycles [][]*Node
+	g.findCycles(em, func(cycle []*Node) bool {
+		cycles = append(cycles, cycle)
+		return true
+	})
+	return cycles
+}
+
+func (g *Graph) lineage(n *Node, em Metadata, lookup func(n *Node, f Metadata, em Metadata) []*Node) (nodes []*Node) {
+	visited := map[Identifier]bool{n.ID: true}
+	queue := []*Node{n}
+	for len(queue) > 0 {
+		node := queue[0]
+		queue = queue[1:]
+
+		for _, next := range lookup(node, Metadata{}, em) {
+			if !visited[next.ID] {
+				visited[next.ID] = true
+				nodes = append(nodes, next)
+				queue = append(queue, next)
+			}
+		}
+	}
+	return
+}
+
+// Ancestors returns the transitive parents of the node following the edges
+// matching em, the nearest first
+func (g *Graph) Ancestors(n *Node, em Metadata) []*Node {
+	return g.lineage(n, em, g.LookupParents)
+}
+
+// Descendants returns the transitive children of the node following the edges
+// matching em, the nearest first
+func (g *Graph) Descendants(n *Node, em Metadata) []*Node {
+	return g.lineage(n, em, g.LookupChildren)
 }
 
 func (g *Graph) LookupParents(n *Node, f Metadata, em Metadata) (nodes []*Node) {
@@ -681,6 +1214,27 @@ func (g *Graph) AreLinked(n1 *Node, n2 *Node, m Metadata) bool {
 	return false
 }
 
+// GetEdgesBetween returns the edges matching the metadata linking the two
+// nodes, whatever their direction
+func (g *Graph) GetEdgesBetween(n1 *Node, n2 *Node, m Metadata) []*Edge {
+	var edges []*Edge
+
+	t := g.context.GetTimeSlice()
+	for _, e := range g.backend.GetNodeEdges(n1, t, m) {
+		parents, children := g.backend.GetEdgeNodes(e, t, Metadata{}, Metadata{})
+		if len(parents) == 0 || len(children) == 0 {
+			continue
+		}
+
+		parent, child := parents[0], children[0]
+		if (parent.ID == n1.ID && child.ID == n2.ID) || (parent.ID == n2.ID && child.ID == n1.ID) {
+			edges = append(edges, e)
+		}
+	}
+
+	return edges
+}
+
 func (g *Graph) Link(n1 *Node, n2 *Node, m Metadata) *Edge {
 	if len(m) > 0 {
 		return g.NewEdge(GenID(), n1, n2, m)
@@ -735,10 +1289,56 @@ func (g *Graph) LookupFirstNode(m Metadata) *Node {
 	return nil
 }
 
-func (g *Graph) AddEdge(e *Edge) bool {
+// addEdge adds the edge to the backend, keeps the edge count and arms its
+// TTL, without notifying the listeners
+func (g *Graph) addEdge(e *Edge) bool {
 	if !g.backend.AddEdge(e) {
 		return false
 	}
+	atomic.AddInt64(&g.edgeCount, 1)
+	g.refreshExpiration(&e.graphElement)
+	return true
+}
+
+// delEdge removes the edge from the backend, keeps the edge count and
+// forgets its TTL, without notifying the listeners
+func (g *Graph) delEdge(e *Edge) bool {
+	if !g.backend.DelEdge(e) {
+		return false
+	}
+	atomic.AddInt64(&g.edgeCount, -1)
+	g.clearExpiration(e.ID)
+	return true
+}
+
+// addNode adds the node to the backend, keeps the node count and the
+// indexes and arms its TTL, without notifying the listeners
+func (g *Graph) addNode(n *Node) bool {
+	if !g.backend.AddNode(n) {
+		return false
+	}
+	atomic.AddInt64(&g.nodeCount, 1)
+	g.updateIndexes(n)
+	g.refreshExpiration(&n.graphElement)
+	return true
+}
+
+// delNode removes the node from the backend, keeps the node count and the
+// indexes and forgets its TTL, without notifying the listeners
+func (g *Graph) delNode(n *Node) bool {
+	if !g.backend.DelNode(n) {
+		return false
+	}
+	atomic.AddInt64(&g.nodeCount, -1)
+	g.removeFromIndexes(n)
+	g.clearExpiration(n.ID)
+	return true
+}
+
+func (g *Graph) AddEdge(e *Edge) bool {
+	if !g.addEdge(e) {
+		return false
+	}
 	g.notifyEvent(graphEvent{element: e, kind: edgeAdded})
 
 	return true
@@ -752,7 +1352,7 @@ func (g *Graph) GetEdge(i Identifier) *Edge {
 }
 
 func (g *Graph) AddNode(n *Node) bool {
-	if !g.backend.AddNode(n) {
+	if !g.addNode(n) {
 		return false
 	}
 	g.notifyEvent(graphEvent{element: n, kind: nodeAdded})
@@ -818,7 +1418,7 @@ func (g *Graph) NewEdge(i Identifier, p *Node, c *Node, m Metadata) *Edge {
 }
 
 func (g *Graph) DelEdge(e *Edge) {
-	if g.backend.DelEdge(e) {
+	if g.delEdge(e) {
 		e.deletedAt = time.Now().UTC()
 		g.notifyEvent(graphEvent{element: e, kind: edgeDeleted})
 	}
@@ -829,7 +1429,7 @@ func (g *Graph) DelNode(n *Node) {
 		g.DelEdge(e)
 	}
 
-	if g.backend.DelNode(n) {
+	if g.delNode(n) {
 		n.deletedAt = time.Now().UTC()
 		g.notifyEvent(graphEvent{element: n, kind: nodeDeleted})
 	}
@@ -844,13 +1444,65 @@ func (g *Graph) DelHostGraph(host string) {
 }
 
 func (g *Graph) GetNodes(m Metadata) []*Node {
+	if nodes, ok := g.lookupIndexes(m); ok {
+		return nodes
+	}
 	return g.backend.GetNodes(g.context.GetTimeSlice(), m)
 }
 
+// WalkNodes calls fn for each node matching the metadata, until fn returns
+// false. The indexes are used when possible and the nodes are walked
+// without retrieving all of them first if the backend supports it. The graph
+// has to stay locked until WalkNodes returns.
+func (g *Graph) WalkNodes(m Metadata, fn func(n *Node) bool) {
+	nodes, ok := g.lookupIndexes(m)
+	if !ok {
+		if walker, ok := g.backend.(nodeWalker); ok {
+			walker.WalkNodes(g.context.GetTimeSlice(), m, fn)
+			return
+		}
+		nodes = g.backend.GetNodes(g.context.GetTimeSlice(), m)
+	}
+
+	for _, n := range nodes {
+		if !fn(n) {
+			return
+		}
+	}
+}
+
 func (g *Graph) GetEdges(m Metadata) []*Edge {
 	return g.backend.GetEdges(g.context.GetTimeSlice(), m)
 }
 
+// NodeCount returns the number of nodes of the graph. The live nodes are
+// counted without having to retrieve them, the nodes of a time slice being
+// retrieved from the backend.
+func (g *Graph) NodeCount() int {
+	if t := g.context.GetTimeSlice(); t != nil {
+		return len(g.backend.GetNodes(t, Metadata{}))
+	}
+	return int(atomic.LoadInt64(&g.nodeCount))
+}
+
+// EdgeCount returns the number of edges of the graph. The live edges are
+// counted without having to retrieve them, the edges of a time slice being
+// retrieved from the backend.
+func (g *Graph) EdgeCount() int {
+	if t := g.context.GetTimeSlice(); t != nil {
+		return len(g.backend.GetEdges(t, Metadata{}))
+	}
+	return int(atomic.LoadInt64(&g.edgeCount))
+}
+
+// seedCounts sets the node and edge counters to the number of live nodes and
+// edges the backend already holds, a persistent backend keeping the ones of
+// the previous runs
+func (g *Graph) seedCounts() {
+	atomic.StoreInt64(&g.nodeCount, int64(len(g.backend.GetNodes(nil, Metadata{}))))
+	atomic.StoreInt64(&g.edgeCount, int64(len(g.backend.GetEdges(nil, Metadata{}))))
+}
+
 func (g *Graph) GetEdgeNodes(e *Edge, parentMetadata, childMetadata Metadata) ([]*Node, []*Node) {
 	return g.backend.GetEdgeNodes(e, g.context.GetTimeSlice(), parentMetadata, childMetadata)
 }
@@ -859,6 +1511,185 @@ func (g *Graph) GetNodeEdges(n *Node, m Metadata) []*Edge {
 	return g.backend.GetNodeEdges(n, g.context.GetTimeSlice(), m)
 }
 
+// Diff computes the changes needed to go from the graph to the other one.
+// Nodes sharing an ID but with different metadata are returned as updated,
+// using their version from the other graph.
+func (g *Graph) Diff(other *Graph) (addedNodes, removedNodes []*Node, addedEdges, removedEdges []*Edge, updatedNodes []*Node) {
+	nodes := make(map[Identifier]*Node)
+	for _, n := range g.GetNodes(Metadata{}) {
+		nodes[n.ID] = n
+	}
+
+	for _, n := range other.GetNodes(Metadata{}) {
+		if old, ok := nodes[n.ID]; !ok {
+			addedNodes = append(addedNodes, n)
+		} else {
+			if !reflect.DeepEqual(old.metadata, n.metadata) {
+				updatedNodes = append(updatedNodes, n)
+			}
+			delete(nodes, n.ID)
+		}
+	}
+
+	for _, n := range nodes {
+		removedNodes = append(removedNodes, n)
+	}
+
+	edges := make(map[Identifier]*Edge)
+	for _, e := range g.GetEdges(Metadata{}) {
+		edges[e.ID] = e
+	}
+
+	for _, e := range other.GetEdges(Metadata{}) {
+		if _, ok := edges[e.ID]; !ok {
+			addedEdges = append(addedEdges, e)
+		} else {
+			delete(edges, e.ID)
+		}
+	}
+
+	for _, e := range edges {
+		removedEdges = append(removedEdges, e)
+	}
+
+	return
+}
+
+// Merge adds to the graph the nodes and edges of the other graph that are not
+// already present. Metadata of nodes sharing an ID are merged, a key having
+// different values in both graphs being reported as a conflict and left
+// untouched.
+func (g *Graph) Merge(other *Graph) error {
+	var conflicts []string
+
+	for _, n := range other.GetNodes(Metadata{}) {
+		node := g.GetNode(n.ID)
+		if node == nil {
+			g.AddNode(&Node{
+				graphElement: graphElement{
+					ID:        n.ID,
+					metadata:  n.metadata.clone(),
+					host:      n.host,
+					createdAt: n.createdAt,
+				},
+			})
+			continue
+		}
+
+		metadata := node.metadata.clone()
+		updated := false
+		for k, v := range n.metadata {
+			if o, ok := metadata[k]; !ok {
+				metadata[k] = v
+				updated = true
+			} else if !reflect.DeepEqual(o, v) {
+				conflicts = append(conflicts, fmt.Sprintf("%s/%s", n.ID, k))
+			}
+		}
+
+		if updated {
+			g.SetMetadata(node, metadata)
+		}
+	}
+
+	for _, e := range other.GetEdges(Metadata{}) {
+		if g.GetEdge(e.ID) != nil {
+			continue
+		}
+
+		g.AddEdge(&Edge{
+			parent: e.parent,
+			child:  e.child,
+			graphElement: graphElement{
+				ID:        e.ID,
+				metadata:  e.metadata.clone(),
+				host:      e.host,
+				createdAt: e.createdAt,
+			},
+		})
+	}
+
+	if len(conflicts) > 0 {
+		return fmt.Errorf("Metadata conflicts while merging: %s", strings.Join(conflicts, ", "))
+	}
+
+	return nil
+}
+
+// deepCopy returns a copy of the value, nested maps and slices being copied
+// as well
+func deepCopy(v interface{}) interface{} {
+	switch v := v.(type) {
+	case Metadata:
+		c := make(Metadata, len(v))
+		for k, i := range v {
+			c[k] = deepCopy(i)
+		}
+		return c
+	case map[string]interface{}:
+		c := make(map[string]interface{}, len(v))
+		for k, i := range v {
+			c[k] = deepCopy(i)
+		}
+		return c
+	case []interface{}:
+		c := make([]interface{}, len(v))
+		for j, i := range v {
+			c[j] = deepCopy(i)
+		}
+		return c
+	case []string:
+		return append([]string(nil), v...)
+	}
+	return v
+}
+
+// Clone returns a deep copy of the graph, backed by a new in-memory backend.
+// Nodes, edges and metadata are copied so that the clone can be modified
+// without affecting the original graph. Event listeners are not copied.
+func (g *Graph) Clone() *Graph {
+	backend, _ := NewMemoryBackend()
+	c := NewGraph(g.host, backend)
+
+	for field := range g.indexes {
+		c.RegisterIndex(field)
+	}
+	for field := range g.stringIndexes {
+		c.RegisterStringIndex(field)
+	}
+	if g.pathCache != nil {
+		c.SetShortestPathCacheSize(g.pathCache.size)
+	}
+
+	for _, n := range g.GetNodes(Metadata{}) {
+		c.AddNode(&Node{
+			graphElement: graphElement{
+				ID:        n.ID,
+				metadata:  deepCopy(n.metadata).(Metadata),
+				host:      n.host,
+				createdAt: n.createdAt,
+				deletedAt: n.deletedAt,
+			},
+		})
+	}
+
+	for _, e := range g.GetEdges(Metadata{}) {
+		c.AddEdge(&Edge{
+			parent: e.parent,
+			child:  e.child,
+			graphElement: graphElement{
+				ID:        e.ID,
+				metadata:  deepCopy(e.metadata).(Metadata),
+				host:      e.host,
+				createdAt: e.createdAt,
+				deletedAt: e.deletedAt,
+			},
+		})
+	}
+
+	return c
+}
+
 func (g *Graph) String() string {
 	j, _ := json.Marshal(g)
 	return string(j)
@@ -879,6 +1710,17 @@ func (g *Graph) notifyEvent(ge graphEvent) {
 	// right order. Assiociate the event with the current event listener so
 	// we can avoid loop by not triggering event for the current listener.
 	ge.listener = g.currentEventListener
+
+	if g.pathCache != nil {
+		g.pathCache.onEvent(ge)
+	}
+
+	// events are delivered once the transaction is committed
+	if g.transaction != nil {
+		g.transaction.events = append(g.transaction.events, ge)
+		return
+	}
+
 	g.eventChan <- ge
 
 	// already a consumer no need to run another consumer
@@ -950,17 +1792,22 @@ func (g *Graph) GetHost() string {
 }
 
 func NewGraph(host string, backend GraphBackend) *Graph {
-	return &Graph{
+	g := &Graph{
 		backend:   backend,
 		host:      host,
 		context:   GraphContext{},
 		eventChan: make(chan graphEvent, maxEvents),
 	}
+	g.seedCounts()
+
+	return g
 }
 
 func NewGraphFromConfig(backend GraphBackend) *Graph {
 	host := config.GetConfig().GetString("host_id")
-	return NewGraph(host, backend)
+	g := NewGraph(host, backend)
+	g.SetShortestPathCacheSize(config.GetConfig().GetInt("graph.shortest_path_cache_size"))
+	return g
 }
 
 func NewGraphWithContext(hostID string, backend GraphBackend, context GraphContext) (*Graph, error) {
@@ -23,6 +23,7 @@
 package graph
 
 import (
+	"errors"
 	"strconv"
 	"strings"
 	"testing"
@@ -84,6 +85,84 @@ func TestAreLinkedWithMetadata(t *testing.T) {
 	}
 }
 
+func TestGetEdgesBetween(t *testing.T) {
+	g := newGraph(t)
+
+	n1 := g.NewNode(GenID(), Metadata{"Value": 1})
+	n2 := g.NewNode(GenID(), Metadata{"Value": 2})
+	n3 := g.NewNode(GenID(), Metadata{"Value": 3})
+
+	g.Link(n1, n2, Metadata{"Type": "aaa"})
+	g.Link(n2, n1, Metadata{"Type": "bbb"})
+	g.Link(n1, n3, Metadata{"Type": "aaa"})
+
+	if edges := g.GetEdgesBetween(n1, n2, Metadata{}); len(edges) != 2 {
+		t.Errorf("Expected 2 edges, got: %v", edges)
+	}
+
+	if edges := g.GetEdgesBetween(n2, n1, Metadata{"Type": "bbb"}); len(edges) != 1 {
+		t.Errorf("Expected 1 edge, got: %v", edges)
+	}
+
+	if edges := g.GetEdgesBetween(n2, n3, Metadata{}); len(edges) != 0 {
+		t.Errorf("Expected no edge, got: %v", edges)
+	}
+}
+
+func TestCounts(t *testing.T) {
+	g := newGraph(t)
+
+	n1 := g.NewNode(GenID(), Metadata{"Value": 1})
+	n2 := g.NewNode(GenID(), Metadata{"Value": 2})
+	n3 := g.NewNode(GenID(), Metadata{"Value": 3})
+	g.Link(n1, n2, nil)
+	g.Link(n2, n3, nil)
+
+	if g.NodeCount() != 3 || g.EdgeCount() != 2 {
+		t.Errorf("Expected 3 nodes and 2 edges, got: %d nodes and %d edges", g.NodeCount(), g.EdgeCount())
+	}
+
+	g.DelNode(n2)
+	if g.NodeCount() != 2 || g.EdgeCount() != 0 {
+		t.Errorf("Expected 2 nodes and no edge, got: %d nodes and %d edges", g.NodeCount(), g.EdgeCount())
+	}
+
+	g.DelNode(n2)
+	if g.NodeCount() != 2 {
+		t.Errorf("Deleting a node twice shouldn't change the count, got: %d nodes", g.NodeCount())
+	}
+
+	err := g.AddNodes([]*Node{newTestNode("b1", Metadata{}), newTestNode("b2", Metadata{})})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = g.AddEdges([]*Edge{newTestEdge("be1", "b1", "b2")}); err != nil {
+		t.Fatal(err)
+	}
+	if g.NodeCount() != 4 || g.EdgeCount() != 1 {
+		t.Errorf("Expected 4 nodes and 1 edge, got: %d nodes and %d edges", g.NodeCount(), g.EdgeCount())
+	}
+
+	err = g.Transaction(func(tx *GraphTransaction) error {
+		n5 := tx.NewNode(GenID(), Metadata{"Value": 5})
+		tx.Link(n1, n5, nil)
+		tx.DelNode(g.GetNode("b1"))
+		return errors.New("abort")
+	})
+	if err == nil {
+		t.Fatal("Should return the error of the transaction")
+	}
+	if g.NodeCount() != 4 || g.EdgeCount() != 1 {
+		t.Errorf("Expected 4 nodes and 1 edge after rollback, got: %d nodes and %d edges", g.NodeCount(), g.EdgeCount())
+	}
+
+	// a graph created on a backend already holding elements counts them
+	reopened := NewGraph("host", g.backend)
+	if reopened.NodeCount() != 4 || reopened.EdgeCount() != 1 {
+		t.Errorf("Expected 4 nodes and 1 edge in the backend, got: %d nodes and %d edges", reopened.NodeCount(), reopened.EdgeCount())
+	}
+}
+
 func TestBasicLookup(t *testing.T) {
 	g := newGraph(t)
 
@@ -187,19 +266,19 @@ func TestHierarchyLookup(t *testing.T) {
 	}
 }
 
-func TestPath(t *testing.T) {
-	g := newGraph(t)
+func validatePath(nodes []*Node, expected string) bool {
+	var values []string
 
-	validatePath := func(nodes []*Node, expected string) bool {
-		var values []string
+	for _, n := range nodes {
+		value, _ := n.GetFieldInt64("Value")
+		values = append(values, strconv.FormatInt(value, 10))
+	}
 
-		for _, n := range nodes {
-			value, _ := n.GetFieldInt64("Value")
-			values = append(values, strconv.FormatInt(value, 10))
-		}
+	return expected == strings.Join(values, "/")
+}
 
-		return expected == strings.Join(values, "/")
-	}
+func TestPath(t *testing.T) {
+	g := newGraph(t)
 
 	n1 := g.NewNode(GenID(), Metadata{"Value": 1, "Type": "intf"})
 	n2 := g.NewNode(GenID(), Metadata{"Value": 2, "Type": "intf"})
@@ -275,6 +354,227 @@ func TestPath(t *testing.T) {
 	}
 }
 
+func TestWeightedPath(t *testing.T) {
+	g := newGraph(t)
+
+	n1 := g.NewNode(Identifier("1"), Metadata{"Value": 1})
+	n2 := g.NewNode(Identifier("2"), Metadata{"Value": 2})
+	n3 := g.NewNode(Identifier("3"), Metadata{"Value": 3})
+	n4 := g.NewNode(Identifier("4"), Metadata{"Value": 4})
+
+	g.Link(n1, n4, Metadata{"Latency": 10})
+	g.Link(n1, n2, Metadata{"Latency": 2})
+	g.Link(n2, n3, Metadata{"Latency": 2})
+	g.Link(n3, n4, Metadata{"Latency": 2})
+
+	r := g.LookupWeightedShortestPath(n1, Metadata{"Value": 4}, nil, "Latency")
+	if len(r) == 0 || !validatePath(r, "1/2/3/4") {
+		t.Errorf("Wrong nodes returned: %v", r)
+	}
+
+	r = g.LookupWeightedShortestPath(n1, Metadata{"Value": 4}, nil, "Cost")
+	if len(r) == 0 || !validatePath(r, "1/4") {
+		t.Errorf("Wrong nodes returned: %v", r)
+	}
+
+	r = g.LookupWeightedShortestPath(n1, Metadata{"Value": 5}, nil, "Latency")
+	if len(r) != 0 {
+		t.Errorf("Should return an empty path: %v", r)
+	}
+
+	// edges with a negative or non-numeric weight are skipped
+	g.Link(n1, n3, Metadata{"Latency": -10})
+	g.Link(n2, n4, Metadata{"Latency": "fast"})
+	r = g.LookupWeightedShortestPath(n1, Metadata{"Value": 4}, nil, "Latency")
+	if len(r) == 0 || !validatePath(r, "1/2/3/4") {
+		t.Errorf("Wrong nodes returned: %v", r)
+	}
+}
+
+func TestAllShortestPaths(t *testing.T) {
+	g := newGraph(t)
+
+	n1 := g.NewNode(Identifier("1"), Metadata{"Value": 1})
+	n2 := g.NewNode(Identifier("2"), Metadata{"Value": 2})
+	n3 := g.NewNode(Identifier("3"), Metadata{"Value": 3})
+	n4 := g.NewNode(Identifier("4"), Metadata{"Value": 4})
+	n5 := g.NewNode(Identifier("5"), Metadata{"Value": 5})
+
+	g.Link(n1, n2, nil)
+	g.Link(n1, n3, nil)
+	g.Link(n2, n4, nil)
+	g.Link(n3, n4, nil)
+	g.Link(n1, n5, nil)
+	g.Link(n5, n2, nil)
+
+	paths := g.LookupAllShortestPaths(n1, Metadata{"Value": 4}, nil)
+	if len(paths) != 2 {
+		t.Fatalf("Should return 2 paths, returned: %v", paths)
+	}
+
+	for _, path := range paths {
+		if !validatePath(path, "1/2/4") && !validatePath(path, "1/3/4") {
+			t.Errorf("Wrong path returned: %v", path)
+		}
+	}
+
+	paths = g.LookupAllShortestPaths(n1, Metadata{"Value": 6}, nil)
+	if len(paths) != 0 {
+		t.Errorf("Should return no path, returned: %v", paths)
+	}
+}
+
+func TestConnectedComponents(t *testing.T) {
+	g := newGraph(t)
+
+	n1 := g.NewNode(GenID(), Metadata{"Value": 1})
+	n2 := g.NewNode(GenID(), Metadata{"Value": 2})
+	n3 := g.NewNode(GenID(), Metadata{"Value": 3})
+	n4 := g.NewNode(GenID(), Metadata{"Value": 4})
+	g.NewNode(GenID(), Metadata{"Value": 5})
+
+	g.Link(n1, n2, nil)
+	g.Link(n3, n2, nil)
+	g.Link(n4, n4, nil)
+
+	components := g.ConnectedComponents()
+	if len(components) != 3 {
+		t.Fatalf("Should return 3 components, returned: %v", components)
+	}
+
+	sizes := make(map[int]int)
+	for _, component := range components {
+		sizes[len(component)]++
+	}
+
+	if sizes[3] != 1 || sizes[1] != 2 {
+		t.Errorf("Wrong components returned: %v", components)
+	}
+}
+
+func TestStructuralLookups(t *testing.T) {
+	g := newGraph(t)
+
+	n1 := g.NewNode(GenID(), Metadata{"Value": 1})
+	n2 := g.NewNode(GenID(), Metadata{"Value": 2})
+	n3 := g.NewNode(GenID(), Metadata{"Value": 3})
+	n4 := g.NewNode(GenID(), Metadata{"Value": 4})
+
+	g.Link(n1, n2, Metadata{"Type": "ownership"})
+	g.Link(n2, n3, Metadata{"Type": "layer2"})
+
+	ids := func(nodes []*Node) map[Identifier]bool {
+		m := make(map[Identifier]bool)
+		for _, n := range nodes {
+			m[n.ID] = true
+		}
+		return m
+	}
+
+	leaves := ids(g.GetLeafNodes(Metadata{}))
+	if len(leaves) != 2 || !leaves[n3.ID] || !leaves[n4.ID] {
+		t.Errorf("Wrong leaf nodes returned: %v", leaves)
+	}
+
+	leaves = ids(g.GetLeafNodes(Metadata{"Type": "ownership"}))
+	if len(leaves) != 3 || leaves[n1.ID] {
+		t.Errorf("Wrong leaf nodes returned: %v", leaves)
+	}
+
+	roots := ids(g.GetRootNodes(Metadata{}))
+	if len(roots) != 2 || !roots[n1.ID] || !roots[n4.ID] {
+		t.Errorf("Wrong root nodes returned: %v", roots)
+	}
+
+	isolated := g.GetIsolatedNodes()
+	if len(isolated) != 1 || isolated[0].ID != n4.ID {
+		t.Errorf("Wrong isolated nodes returned: %v", isolated)
+	}
+}
+
+func TestTopologicalSort(t *testing.T) {
+	g := newGraph(t)
+
+	n1 := g.NewNode(Identifier("1"), Metadata{"Value": 1})
+	n2 := g.NewNode(Identifier("2"), Metadata{"Value": 2})
+	n3 := g.NewNode(Identifier("3"), Metadata{"Value": 3})
+	n4 := g.NewNode(Identifier("4"), Metadata{"Value": 4})
+
+	g.Link(n3, n4, Metadata{"RelationType": "ownership"})
+	g.Link(n1, n3, Metadata{"RelationType": "ownership"})
+	g.Link(n1, n2, Metadata{"RelationType": "ownership"})
+	g.Link(n4, n1, Metadata{"RelationType": "layer2"})
+
+	sorted, err := g.TopologicalSort(Metadata{"RelationType": "ownership"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	positions := make(map[Identifier]int)
+	for i, n := range sorted {
+		positions[n.ID] = i
+	}
+
+	if len(sorted) != 4 || positions["1"] > positions["3"] || positions["3"] > positions["4"] || positions["1"] > positions["2"] {
+		t.Errorf("Wrong order returned: %v", sorted)
+	}
+
+	if _, err := g.TopologicalSort(nil); err == nil {
+		t.Error("Should return an error as the graph contains a cycle")
+	}
+}
+
+func TestCycles(t *testing.T) {
+	g := newGraph(t)
+
+	n1 := g.NewNode(Identifier("1"), Metadata{"Value": 1})
+	n2 := g.NewNode(Identifier("2"), Metadata{"Value": 2})
+	n3 := g.NewNode(Identifier("3"), Metadata{"Value": 3})
+	n4 := g.NewNode(Identifier("4"), Metadata{"Value": 4})
+
+	g.Link(n1, n2, Metadata{"RelationType": "layer2"})
+	g.Link(n2, n3, Metadata{"RelationType": "layer2"})
+	g.Link(n3, n4, Metadata{"RelationType": "ownership"})
+	g.Link(n4, n1, Metadata{"RelationType": "layer2"})
+
+	if g.HasCycle(Metadata{"RelationType": "layer2"}) {
+		t.Error("Should not find any cycle among layer2 edges")
+	}
+
+	if !g.HasCycle(nil) {
+		t.Error("Should find a cycle")
+	}
+
+	cycles := g.FindCycles(nil)
+	if len(cycles) != 1 || len(cycles[0]) != 4 {
+		t.Errorf("Should return a cycle of 4 nodes, returned: %v", cycles)
+	}
+}
+
+func TestLineage(t *testing.T) {
+	g := newGraph(t)
+
+	n1 := g.NewNode(Identifier("1"), Metadata{"Value": 1})
+	n2 := g.NewNode(Identifier("2"), Metadata{"Value": 2})
+	n3 := g.NewNode(Identifier("3"), Metadata{"Value": 3})
+	n4 := g.NewNode(Identifier("4"), Metadata{"Value": 4})
+
+	g.Link(n1, n2, Metadata{"RelationType": "ownership"})
+	g.Link(n2, n3, Metadata{"RelationType": "ownership"})
+	g.Link(n3, n4, Metadata{"RelationType": "layer2"})
+	g.Link(n3, n1, Metadata{"RelationType": "ownership"})
+
+	ancestors := g.Ancestors(n3, Metadata{"RelationType": "ownership"})
+	if !validatePath(ancestors, "2/1") {
+		t.Errorf("Wrong ancestors returned: %v", ancestors)
+	}
+
+	descendants := g.Descendants(n1, nil)
+	if !validatePath(descendants, "2/3/4") {
+		t.Errorf("Wrong descendants returned: %v", descendants)
+	}
+}
+
 func TestMetadata(t *testing.T) {
 	g := newGraph(t)
 
@@ -303,6 +603,126 @@ func TestMetadata(t *testing.T) {
 	}
 }
 
+func TestDiff(t *testing.T) {
+	g1 := newGraph(t)
+	g2 := newGraph(t)
+
+	n1 := g1.NewNode(Identifier("n1"), Metadata{"Value": 1})
+	n2 := g1.NewNode(Identifier("n2"), Metadata{"Value": 2})
+	n3 := g1.NewNode(Identifier("n3"), Metadata{"Value": 3})
+	g1.NewEdge(Identifier("e1"), n1, n2, nil)
+	g1.NewEdge(Identifier("e2"), n2, n3, nil)
+
+	m1 := g2.NewNode(Identifier("n1"), Metadata{"Value": 1})
+	m2 := g2.NewNode(Identifier("n2"), Metadata{"Value": 22})
+	m4 := g2.NewNode(Identifier("n4"), Metadata{"Value": 4})
+	g2.NewEdge(Identifier("e1"), m1, m2, nil)
+	g2.NewEdge(Identifier("e3"), m2, m4, nil)
+
+	addedNodes, removedNodes, addedEdges, removedEdges, updatedNodes := g1.Diff(g2)
+	if len(addedNodes) != 1 || addedNodes[0].ID != "n4" {
+		t.Errorf("Wrong added nodes: %v", addedNodes)
+	}
+	if len(removedNodes) != 1 || removedNodes[0].ID != "n3" {
+		t.Errorf("Wrong removed nodes: %v", removedNodes)
+	}
+	if len(addedEdges) != 1 || addedEdges[0].ID != "e3" {
+		t.Errorf("Wrong added edges: %v", addedEdges)
+	}
+	if len(removedEdges) != 1 || removedEdges[0].ID != "e2" {
+		t.Errorf("Wrong removed edges: %v", removedEdges)
+	}
+	if len(updatedNodes) != 1 || updatedNodes[0].ID != "n2" {
+		t.Errorf("Wrong updated nodes: %v", updatedNodes)
+	}
+}
+
+func TestMerge(t *testing.T) {
+	g1 := newGraph(t)
+	g2 := newGraph(t)
+
+	n1 := g1.NewNode(Identifier("n1"), Metadata{"Value": 1})
+	n2 := g1.NewNode(Identifier("n2"), Metadata{"Value": 2})
+	g1.NewEdge(Identifier("e1"), n1, n2, nil)
+
+	m2 := g2.NewNode(Identifier("n2"), Metadata{"Value": 2, "Name": "Node2"})
+	m3 := g2.NewNode(Identifier("n3"), Metadata{"Value": 3})
+	g2.NewEdge(Identifier("e2"), m2, m3, nil)
+
+	listener := &FakeListener{}
+	g1.AddEventListener(listener)
+
+	if err := g1.Merge(g2); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(g1.GetNodes(Metadata{})) != 3 || len(g1.GetEdges(Metadata{})) != 2 {
+		t.Errorf("Wrong merged graph: %s", g1.String())
+	}
+
+	if name, _ := n2.GetFieldString("Name"); name != "Node2" {
+		t.Error("Metadata of existing node should be merged")
+	}
+
+	if listener.lastNodeAdded == nil || listener.lastNodeAdded.ID != "n3" {
+		t.Error("Node added event not received")
+	}
+
+	if listener.lastEdgeAdded == nil || listener.lastEdgeAdded.ID != "e2" {
+		t.Error("Edge added event not received")
+	}
+
+	g3 := newGraph(t)
+	g3.NewNode(Identifier("n1"), Metadata{"Value": 11})
+
+	if err := g1.Merge(g3); err == nil {
+		t.Error("Conflicting metadata should return an error")
+	}
+
+	if v, _ := n1.GetFieldInt64("Value"); v != 1 {
+		t.Error("Conflicting metadata should not be overwritten")
+	}
+}
+
+func TestClone(t *testing.T) {
+	g := newGraph(t)
+
+	n1 := g.NewNode(Identifier("n1"), Metadata{"Value": 1, "Nested": map[string]interface{}{"A": "a"}})
+	n2 := g.NewNode(Identifier("n2"), Metadata{"Value": 2})
+	g.NewEdge(Identifier("e1"), n1, n2, Metadata{"RelationType": "ownership"})
+
+	listener := &FakeListener{}
+	g.AddEventListener(listener)
+
+	c := g.Clone()
+	if len(c.GetNodes(Metadata{})) != 2 || len(c.GetEdges(Metadata{})) != 1 {
+		t.Fatalf("Wrong cloned graph: %s", c.String())
+	}
+
+	cn1 := c.GetNode(Identifier("n1"))
+	if cn1 == n1 {
+		t.Error("Cloned node should not be the original one")
+	}
+
+	c.AddMetadata(cn1, "Value", 11)
+	cn1.metadata["Nested"].(map[string]interface{})["A"] = "b"
+	if v, _ := n1.GetFieldInt64("Value"); v != 1 {
+		t.Error("Original metadata should not be modified")
+	}
+	if v, _ := n1.GetFieldString("Nested/A"); v != "a" {
+		t.Error("Original nested metadata should not be modified")
+	}
+
+	c.DelNode(c.GetNode(Identifier("n2")))
+	if g.GetNode(Identifier("n2")) == nil || g.GetEdge(Identifier("e1")) == nil {
+		t.Error("Original graph should not be modified")
+	}
+
+	if listener.lastNodeUpdated != nil || listener.lastNodeDeleted != nil {
+		t.Error("Listeners of the original graph should not be notified")
+	}
+}
+
 type FakeListener struct {
 	lastNodeUpdated *Node
 	lastNodeAdded   *Node
@@ -410,3 +830,62 @@ func TestRecursiveEvents(t *testing.T) {
 		t.Error("Events are not in the right order")
 	}
 }
+
+func TestShortestPathCache(t *testing.T) {
+	g := newGraph(t)
+	g.SetShortestPathCacheSize(10)
+
+	n1 := g.NewNode(GenID(), Metadata{"Value": 1})
+	n2 := g.NewNode(GenID(), Metadata{"Value": 2})
+	n3 := g.NewNode(GenID(), Metadata{"Value": 3})
+
+	g.Link(n1, n2, nil)
+	g.Link(n2, n3, nil)
+
+	r := g.LookupShortestPath(n1, Metadata{"Value": 3}, nil)
+	if !validatePath(r, "1/2/3") {
+		t.Errorf("Wrong nodes returned: %v", r)
+	}
+
+	// a shorter path has to flush the cache
+	g.Link(n1, n3, nil)
+	r = g.LookupShortestPath(n1, Metadata{"Value": 3}, nil)
+	if !validatePath(r, "1/3") {
+		t.Errorf("Wrong nodes returned: %v", r)
+	}
+
+	// updating a node of the path has to invalidate the entry
+	g.AddMetadata(n3, "Value", 4)
+	r = g.LookupShortestPath(n1, Metadata{"Value": 3}, nil)
+	if len(r) != 0 {
+		t.Errorf("Should return an empty path: %v", r)
+	}
+
+	// updating a node off the path so that it matches has to be seen too
+	n4 := g.NewNode(GenID(), Metadata{"Value": 5})
+	g.Link(n1, n4, nil)
+	r = g.LookupShortestPath(n1, Metadata{"Value": 3}, nil)
+	if len(r) != 0 {
+		t.Errorf("Should return an empty path: %v", r)
+	}
+
+	g.AddMetadata(n4, "Value", 3)
+	r = g.LookupShortestPath(n1, Metadata{"Value": 3}, nil)
+	if !validatePath(r, "1/3") {
+		t.Errorf("Wrong nodes returned: %v", r)
+	}
+
+	// an edge starting to match the edge filter has to be seen too
+	r = g.LookupShortestPath(n1, Metadata{"Value": 2}, Metadata{"Type": "layer2"})
+	if len(r) != 0 {
+		t.Errorf("Should return an empty path: %v", r)
+	}
+
+	for _, e := range g.GetEdgesBetween(n1, n2, nil) {
+		g.AddMetadata(e, "Type", "layer2")
+	}
+	r = g.LookupShortestPath(n1, Metadata{"Value": 2}, Metadata{"Type": "layer2"})
+	if !validatePath(r, "1/2") {
+		t.Errorf("Wrong nodes returned: %v", r)
+	}
+}
@@ -0,0 +1,204 @@
+/*
+ * Copyright (C) 2016 Red Hat, Inc.
+ *
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ *
+ */
+
+package graph
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"sort"
+)
+
+const graphMLNamespace = "http://graphml.graphdrawing.org/xmlns"
+
+// metadata keys exported as GraphML data, Host being the host of the element
+var graphMLKeys = []string{"Name", "Type", "TID", "RelationType", "Host"}
+
+type graphMLKey struct {
+	ID       string `xml:"id,attr"`
+	For      string `xml:"for,attr"`
+	AttrName string `xml:"attr.name,attr"`
+	AttrType string `xml:"attr.type,attr"`
+}
+
+type graphMLData struct {
+	Key   string `xml:"key,attr"`
+	Value string `xml:",chardata"`
+}
+
+type graphMLNode struct {
+	ID   string        `xml:"id,attr"`
+	Data []graphMLData `xml:"data"`
+}
+
+type graphMLEdge struct {
+	ID     string        `xml:"id,attr"`
+	Source string        `xml:"source,attr"`
+	Target string        `xml:"target,attr"`
+	Data   []graphMLData `xml:"data"`
+}
+
+type graphMLGraph struct {
+	ID          string        `xml:"id,attr"`
+	EdgeDefault string        `xml:"edgedefault,attr"`
+	Nodes       []graphMLNode `xml:"node"`
+	Edges       []graphMLEdge `xml:"edge"`
+}
+
+type graphMLDocument struct {
+	XMLName xml.Name     `xml:"graphml"`
+	XMLNS   string       `xml:"xmlns,attr"`
+	Keys    []graphMLKey `xml:"key"`
+	Graph   graphMLGraph `xml:"graph"`
+}
+
+func graphMLElementData(e *graphElement) (data []graphMLData) {
+	for _, key := range graphMLKeys {
+		if key == "Host" {
+			if e.host != "" {
+				data = append(data, graphMLData{Key: key, Value: e.host})
+			}
+		} else if v, ok := e.metadata[key]; ok {
+			data = append(data, graphMLData{Key: key, Value: fmt.Sprintf("%v", v)})
+		}
+	}
+	return
+}
+
+// ExportGraphML writes the graph in the GraphML format
+func (g *Graph) ExportGraphML(w io.Writer) error {
+	doc := graphMLDocument{
+		XMLNS: graphMLNamespace,
+		Graph: graphMLGraph{ID: "G", EdgeDefault: "directed"},
+	}
+
+	for _, key := range graphMLKeys {
+		doc.Keys = append(doc.Keys, graphMLKey{ID: key, For: "all", AttrName: key, AttrType: "string"})
+	}
+
+	nodes := nodesByID(g.GetNodes(Metadata{}))
+	sort.Sort(nodes)
+	for _, n := range nodes {
+		doc.Graph.Nodes = append(doc.Graph.Nodes, graphMLNode{
+			ID:   string(n.ID),
+			Data: graphMLElementData(&n.graphElement),
+		})
+	}
+
+	edges := edgesByID(g.GetEdges(Metadata{}))
+	sort.Sort(edges)
+	for _, e := range edges {
+		doc.Graph.Edges = append(doc.Graph.Edges, graphMLEdge{
+			ID:     string(e.ID),
+			Source: string(e.parent),
+			Target: string(e.child),
+			Data:   graphMLElementData(&e.graphElement),
+		})
+	}
+
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+
+	encoder := xml.NewEncoder(w)
+	encoder.Indent("", "  ")
+	return encoder.Encode(doc)
+}
+
+// graphMLDataToMetadata converts the data to metadata, the data keys being
+// resolved to the attribute names of the key definitions
+func graphMLDataToMetadata(data []graphMLData, names map[string]string) (m Metadata, host string) {
+	m = make(Metadata)
+	for _, d := range data {
+		name := d.Key
+		if n, ok := names[d.Key]; ok {
+			name = n
+		}
+
+		if name == "Host" {
+			host = d.Value
+		} else {
+			m[name] = d.Value
+		}
+	}
+	return
+}
+
+// ImportGraphML creates a new in-memory graph from a GraphML document. Data
+// values are imported as string metadata.
+func ImportGraphML(r io.Reader) (*Graph, error) {
+	var doc graphMLDocument
+	if err := xml.NewDecoder(r).Decode(&doc); err != nil {
+		return nil, err
+	}
+
+	backend, err := NewMemoryBackend()
+	if err != nil {
+		return nil, err
+	}
+	g := NewGraphFromConfig(backend)
+
+	names := make(map[string]string)
+	for _, key := range doc.Keys {
+		if key.AttrName != "" {
+			names[key.ID] = key.AttrName
+		}
+	}
+
+	nodes := make(map[string]*Node)
+	for _, node := range doc.Graph.Nodes {
+		m, host := graphMLDataToMetadata(node.Data, names)
+		n := g.NewNode(Identifier(node.ID), m, host)
+		if n == nil {
+			return nil, fmt.Errorf("Unable to add node %s", node.ID)
+		}
+		nodes[node.ID] = n
+	}
+
+	for i, edge := range doc.Graph.Edges {
+		parent, ok := nodes[edge.Source]
+		if !ok {
+			return nil, fmt.Errorf("Unknown source node %s", edge.Source)
+		}
+		child, ok := nodes[edge.Target]
+		if !ok {
+			return nil, fmt.Errorf("Unknown target node %s", edge.Target)
+		}
+
+		id := Identifier(edge.ID)
+		if id == "" {
+			id = Identifier(fmt.Sprintf("e%d", i))
+		}
+
+		m, host := graphMLDataToMetadata(edge.Data, names)
+		e := g.NewEdge(id, parent, child, m)
+		if e == nil {
+			return nil, fmt.Errorf("Unable to add edge %s", id)
+		}
+		if host != "" {
+			e.host = host
+		}
+	}
+
+	return g, nil
+}
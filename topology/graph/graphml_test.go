@@ -0,0 +1,96 @@
+/*
+ * Copyright (C) 2016 Red Hat, Inc.
+ *
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ *
+ */
+
+package graph
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestGraphML(t *testing.T) {
+	g := newGraph(t)
+
+	n1 := g.NewNode(Identifier("n1"), Metadata{"Name": "eth0", "Type": "device", "TID": "123"}, "host1")
+	n2 := g.NewNode(Identifier("n2"), Metadata{"Name": "br0", "Type": "bridge"}, "host1")
+	g.NewEdge(Identifier("e1"), n2, n1, Metadata{"Name": "link", "RelationType": "ownership"})
+
+	var buf bytes.Buffer
+	if err := g.ExportGraphML(&buf); err != nil {
+		t.Fatal(err)
+	}
+
+	ig, err := ImportGraphML(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	n := ig.GetNode(Identifier("n1"))
+	if n == nil {
+		t.Fatal("Node n1 not imported")
+	}
+
+	if tid, _ := n.GetFieldString("TID"); tid != "123" {
+		t.Errorf("Wrong TID imported: %s", tid)
+	}
+
+	if n.Host() != "host1" {
+		t.Errorf("Wrong host imported: %s", n.Host())
+	}
+
+	if !ig.AreLinked(ig.GetNode(Identifier("n2")), n, Metadata{"Name": "link", "RelationType": "ownership"}) {
+		t.Error("Edge not imported")
+	}
+}
+
+func TestGraphMLKeyNames(t *testing.T) {
+	doc := `<?xml version="1.0" encoding="UTF-8"?>
+<graphml xmlns="http://graphml.graphdrawing.org/xmlns">
+  <key id="d0" for="node" attr.name="Name" attr.type="string"/>
+  <key id="d1" for="edge" attr.name="RelationType" attr.type="string"/>
+  <graph id="G" edgedefault="directed">
+    <node id="n0"><data key="d0">eth0</data></node>
+    <node id="n1"><data key="d0">br0</data><data key="Type">bridge</data></node>
+    <edge source="n1" target="n0"><data key="d1">ownership</data></edge>
+  </graph>
+</graphml>`
+
+	g, err := ImportGraphML(strings.NewReader(doc))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	n0 := g.GetNode(Identifier("n0"))
+	if name, _ := n0.GetFieldString("Name"); name != "eth0" {
+		t.Errorf("Data key not resolved to its attribute name: %v", n0.Metadata())
+	}
+
+	n1 := g.GetNode(Identifier("n1"))
+	if tp, _ := n1.GetFieldString("Type"); tp != "bridge" {
+		t.Errorf("Undeclared data key should be used as is: %v", n1.Metadata())
+	}
+
+	if !g.AreLinked(n1, n0, Metadata{"RelationType": "ownership"}) {
+		t.Error("Edge not imported")
+	}
+}
@@ -0,0 +1,335 @@
+/*
+ * Copyright (C) 2016 Red Hat, Inc.
+ *
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ *
+ */
+
+package graph
+
+import (
+	"math"
+
+	"github.com/google/btree"
+
+	"github.com/skydive-project/skydive/common"
+	"github.com/skydive-project/skydive/filters"
+)
+
+const indexDegree = 32
+
+type int64IndexItem struct {
+	value int64
+	node  *Node
+}
+
+func (i int64IndexItem) Less(than btree.Item) bool {
+	t := than.(int64IndexItem)
+	if i.value != t.value {
+		return i.value < t.value
+	}
+	// a nil node is used as pivot and sorts first
+	if i.node == nil || t.node == nil {
+		return t.node != nil
+	}
+	return i.node.ID < t.node.ID
+}
+
+// int64Index is a BTree index of the nodes by the value of an int64 field
+type int64Index struct {
+	field  string
+	tree   *btree.BTree
+	values map[Identifier]int64
+}
+
+func newInt64Index(field string) *int64Index {
+	return &int64Index{
+		field:  field,
+		tree:   btree.New(indexDegree),
+		values: make(map[Identifier]int64),
+	}
+}
+
+func (idx *int64Index) remove(n *Node) {
+	if v, ok := idx.values[n.ID]; ok {
+		idx.tree.Delete(int64IndexItem{value: v, node: n})
+		delete(idx.values, n.ID)
+	}
+}
+
+func (idx *int64Index) update(n *Node) {
+	idx.remove(n)
+
+	f, ok := n.GetField(idx.field)
+	if !ok {
+		return
+	}
+
+	v, err := common.ToInt64(f)
+	if err != nil {
+		return
+	}
+
+	idx.tree.ReplaceOrInsert(int64IndexItem{value: v, node: n})
+	idx.values[n.ID] = v
+}
+
+// lookup returns the nodes having a value between min and max included
+func (idx *int64Index) lookup(min, max int64) (nodes []*Node) {
+	idx.tree.AscendGreaterOrEqual(int64IndexItem{value: min}, func(i btree.Item) bool {
+		item := i.(int64IndexItem)
+		if item.value > max {
+			return false
+		}
+		nodes = append(nodes, item.node)
+		return true
+	})
+	return
+}
+
+// int64FilterRange returns the range of values matched by a filter made of
+// int64 comparisons, ok being false if the filter can't be expressed as a
+// range.
+func int64FilterRange(f *filters.Filter) (min, max int64, ok bool) {
+	switch {
+	case f.TermInt64Filter != nil:
+		return f.TermInt64Filter.Value, f.TermInt64Filter.Value, true
+	case f.GtInt64Filter != nil:
+		if f.GtInt64Filter.Value == math.MaxInt64 {
+			return 1, 0, true
+		}
+		return f.GtInt64Filter.Value + 1, math.MaxInt64, true
+	case f.GteInt64Filter != nil:
+		return f.GteInt64Filter.Value, math.MaxInt64, true
+	case f.LtInt64Filter != nil:
+		if f.LtInt64Filter.Value == math.MinInt64 {
+			return 1, 0, true
+		}
+		return math.MinInt64, f.LtInt64Filter.Value - 1, true
+	case f.LteInt64Filter != nil:
+		return math.MinInt64, f.LteInt64Filter.Value, true
+	case f.BoolFilter != nil && f.BoolFilter.Op == filters.BoolFilterOp_AND:
+		min, max = math.MinInt64, math.MaxInt64
+		for _, sub := range f.BoolFilter.Filters {
+			if smin, smax, sok := int64FilterRange(sub); sok {
+				if smin > min {
+					min = smin
+				}
+				if smax < max {
+					max = smax
+				}
+				ok = true
+			}
+		}
+		return
+	}
+
+	return 0, 0, false
+}
+
+// stringFilterValue returns the value matched by a string equality filter,
+// or by one of the terms of an AND filter, ok being false if there is none
+func stringFilterValue(f *filters.Filter) (value string, ok bool) {
+	switch {
+	case f.TermStringFilter != nil:
+		return f.TermStringFilter.Value, true
+	case f.BoolFilter != nil && f.BoolFilter.Op == filters.BoolFilterOp_AND:
+		for _, sub := range f.BoolFilter.Filters {
+			if value, ok = stringFilterValue(sub); ok {
+				return
+			}
+		}
+	}
+
+	return "", false
+}
+
+// RegisterIndex creates a BTree index on the given int64 field, used to
+// speed up the lookups of nodes by range of values of this field
+func (g *Graph) RegisterIndex(field string) {
+	if g.indexes == nil {
+		g.indexes = make(map[string]*int64Index)
+	}
+
+	if _, ok := g.indexes[field]; ok {
+		return
+	}
+
+	idx := newInt64Index(field)
+	for _, n := range g.backend.GetNodes(nil, Metadata{}) {
+		idx.update(n)
+	}
+	g.indexes[field] = idx
+}
+
+// IsIndexed returns whether a BTree index exists for the field
+func (g *Graph) IsIndexed(field string) bool {
+	_, ok := g.indexes[field]
+	return ok && g.context.GetTimeSlice() == nil
+}
+
+func (g *Graph) updateIndexes(n *Node) {
+	for _, idx := range g.indexes {
+		idx.update(n)
+	}
+	for _, idx := range g.stringIndexes {
+		idx.update(n)
+	}
+}
+
+func (g *Graph) removeFromIndexes(n *Node) {
+	for _, idx := range g.indexes {
+		idx.remove(n)
+	}
+	for _, idx := range g.stringIndexes {
+		idx.remove(n)
+	}
+}
+
+// lookupIndexes returns the nodes matching the metadata using an index, ok
+// being false if none of the indexes can be used
+func (g *Graph) lookupIndexes(m Metadata) (nodes []*Node, ok bool) {
+	if g.context.GetTimeSlice() != nil {
+		return nil, false
+	}
+
+	for k, v := range m {
+		value, isString := v.(string)
+		if f, isFilter := v.(*filters.Filter); isFilter {
+			value, isString = stringFilterValue(f)
+		}
+
+		if isString && g.IsStringIndexed(k) {
+			for _, n := range g.stringIndexes[k].lookup(value) {
+				if n.MatchMetadata(m) {
+					nodes = append(nodes, n)
+				}
+			}
+			return nodes, true
+		}
+
+		idx, found := g.indexes[k]
+		if !found {
+			continue
+		}
+
+		var min, max int64
+		switch v := v.(type) {
+		case *filters.Filter:
+			if min, max, ok = int64FilterRange(v); !ok {
+				continue
+			}
+		default:
+			i, err := common.ToInt64(v)
+			if err != nil {
+				continue
+			}
+			min, max, ok = i, i, true
+		}
+
+		for _, n := range idx.lookup(min, max) {
+			if n.MatchMetadata(m) {
+				nodes = append(nodes, n)
+			}
+		}
+		return nodes, true
+	}
+
+	return nil, false
+}
+
+// stringIndex is an inverted index of the nodes by the value of a string field
+type stringIndex struct {
+	field  string
+	nodes  map[string]map[Identifier]*Node
+	values map[Identifier]string
+}
+
+func newStringIndex(field string) *stringIndex {
+	return &stringIndex{
+		field:  field,
+		nodes:  make(map[string]map[Identifier]*Node),
+		values: make(map[Identifier]string),
+	}
+}
+
+func (idx *stringIndex) remove(n *Node) {
+	if v, ok := idx.values[n.ID]; ok {
+		delete(idx.nodes[v], n.ID)
+		if len(idx.nodes[v]) == 0 {
+			delete(idx.nodes, v)
+		}
+		delete(idx.values, n.ID)
+	}
+}
+
+func (idx *stringIndex) update(n *Node) {
+	idx.remove(n)
+
+	v, err := n.GetFieldString(idx.field)
+	if err != nil {
+		return
+	}
+
+	if _, ok := idx.nodes[v]; !ok {
+		idx.nodes[v] = make(map[Identifier]*Node)
+	}
+	idx.nodes[v][n.ID] = n
+	idx.values[n.ID] = v
+}
+
+func (idx *stringIndex) lookup(value string) []*Node {
+	nodes := make([]*Node, 0, len(idx.nodes[value]))
+	for _, n := range idx.nodes[value] {
+		nodes = append(nodes, n)
+	}
+	return nodes
+}
+
+// RegisterStringIndex creates an inverted index on the given string field,
+// used to speed up the lookups of nodes by value of this field
+func (g *Graph) RegisterStringIndex(field string) {
+	if g.stringIndexes == nil {
+		g.stringIndexes = make(map[string]*stringIndex)
+	}
+
+	if _, ok := g.stringIndexes[field]; ok {
+		return
+	}
+
+	idx := newStringIndex(field)
+	for _, n := range g.backend.GetNodes(nil, Metadata{}) {
+		idx.update(n)
+	}
+	g.stringIndexes[field] = idx
+}
+
+// IsStringIndexed returns whether an inverted index exists for the field
+func (g *Graph) IsStringIndexed(field string) bool {
+	_, ok := g.stringIndexes[field]
+	return ok && g.context.GetTimeSlice() == nil
+}
+
+// GetNodesByField returns the nodes having the given value for the field,
+// using the inverted index of the field if registered
+func (g *Graph) GetNodesByField(field, value string) []*Node {
+	if g.IsStringIndexed(field) {
+		return g.stringIndexes[field].lookup(value)
+	}
+	return g.backend.GetNodes(g.context.GetTimeSlice(), Metadata{field: value})
+}
@@ -0,0 +1,92 @@
+/*
+ * Copyright (C) 2016 Red Hat, Inc.
+ *
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ *
+ */
+
+package graph
+
+import (
+	"testing"
+
+	"github.com/skydive-project/skydive/filters"
+)
+
+func TestInt64Index(t *testing.T) {
+	g := newGraph(t)
+
+	n1 := g.NewNode(GenID(), Metadata{"Value": 1, "Type": "intf"})
+	g.NewNode(GenID(), Metadata{"Value": 2, "Type": "intf"})
+	g.RegisterIndex("Value")
+	n3 := g.NewNode(GenID(), Metadata{"Value": 3})
+	g.NewNode(GenID(), Metadata{"Name": "Node4"})
+
+	if nodes := g.GetNodes(Metadata{"Value": filters.NewGteInt64Filter("Value", 2)}); len(nodes) != 2 {
+		t.Errorf("Should return 2 nodes, returned: %v", nodes)
+	}
+
+	m := Metadata{
+		"Value": filters.NewAndFilter(filters.NewGtInt64Filter("Value", 1), filters.NewLtInt64Filter("Value", 3)),
+		"Type":  "intf",
+	}
+	if nodes := g.GetNodes(m); len(nodes) != 1 {
+		t.Errorf("Should return 1 node, returned: %v", nodes)
+	}
+
+	g.AddMetadata(n1, "Value", 10)
+	g.DelNode(n3)
+
+	nodes := g.GetNodes(Metadata{"Value": filters.NewGtInt64Filter("Value", 2)})
+	if len(nodes) != 1 || nodes[0].ID != n1.ID {
+		t.Errorf("Index not updated, returned: %v", nodes)
+	}
+
+	if nodes := g.GetNodes(Metadata{"Value": 2}); len(nodes) != 1 {
+		t.Errorf("Should return 1 node, returned: %v", nodes)
+	}
+}
+
+func TestStringIndex(t *testing.T) {
+	g := newGraph(t)
+
+	n1 := g.NewNode(GenID(), Metadata{"Value": 1, "Type": "intf"})
+	g.NewNode(GenID(), Metadata{"Value": 2, "Type": "intf"})
+	g.RegisterStringIndex("Type")
+	n3 := g.NewNode(GenID(), Metadata{"Value": 3, "Type": "host"})
+
+	if nodes := g.GetNodesByField("Type", "intf"); len(nodes) != 2 {
+		t.Errorf("Should return 2 nodes, returned: %v", nodes)
+	}
+
+	if nodes := g.GetNodes(Metadata{"Type": "intf", "Value": 2}); len(nodes) != 1 {
+		t.Errorf("Should return 1 node, returned: %v", nodes)
+	}
+
+	g.SetMetadata(n1, Metadata{"Value": 1, "Type": "host"})
+	g.DelNode(n3)
+
+	nodes := g.GetNodesByField("Type", "host")
+	if len(nodes) != 1 || nodes[0].ID != n1.ID {
+		t.Errorf("Index not updated, returned: %v", nodes)
+	}
+
+	if nodes := g.GetNodesByField("Name", "Node1"); len(nodes) != 0 {
+		t.Errorf("Should return no node, returned: %v", nodes)
+	}
+}
@@ -150,6 +150,10 @@ func (m *MemoryBackend) DelEdge(e *Edge) bool {
 }
 
 func (m *MemoryBackend) DelNode(n *Node) bool {
+	if _, ok := m.nodes[n.ID]; !ok {
+		return false
+	}
+
 	delete(m.nodes, n.ID)
 
 	return true
@@ -164,6 +168,16 @@ func (m MemoryBackend) GetNodes(t *common.TimeSlice, metadata Metadata) (nodes [
 	return
 }
 
+// WalkNodes calls fn for each node matching the metadata, until fn returns
+// false, without building the list of the nodes
+func (m MemoryBackend) WalkNodes(t *common.TimeSlice, metadata Metadata, fn func(n *Node) bool) {
+	for _, n := range m.nodes {
+		if n.MatchMetadata(metadata) && !fn(n.Node) {
+			return
+		}
+	}
+}
+
 func (m MemoryBackend) GetEdges(t *common.TimeSlice, metadata Metadata) (edges []*Edge) {
 	for _, e := range m.edges {
 		if e.MatchMetadata(metadata) {
@@ -0,0 +1,94 @@
+/*
+ * Copyright (C) 2016 Red Hat, Inc.
+ *
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ *
+ */
+
+package graph
+
+import (
+	"sync"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var (
+	graphNodesDesc = prometheus.NewDesc(
+		"skydive_graph_nodes",
+		"Number of nodes of the graph by type",
+		[]string{"service", "type"}, nil,
+	)
+	graphEdgesDesc = prometheus.NewDesc(
+		"skydive_graph_edges",
+		"Number of edges of the graph",
+		[]string{"service"}, nil,
+	)
+)
+
+// metricsCollector exports the number of nodes and edges of the graphs of
+// the services, counted when the metrics are collected
+type metricsCollector struct {
+	sync.RWMutex
+	graphs map[string]*Graph
+}
+
+var graphMetrics = &metricsCollector{graphs: make(map[string]*Graph)}
+
+func (c *metricsCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- graphNodesDesc
+	ch <- graphEdgesDesc
+}
+
+func (c *metricsCollector) collectGraph(ch chan<- prometheus.Metric, service string, g *Graph) {
+	g.RLock()
+	nodes := g.GetNodes(Metadata{})
+
+	types := make(map[string]int)
+	for _, n := range nodes {
+		tp, _ := n.GetFieldString("Type")
+		types[tp]++
+	}
+	g.RUnlock()
+
+	for tp, count := range types {
+		ch <- prometheus.MustNewConstMetric(graphNodesDesc, prometheus.GaugeValue, float64(count), service, tp)
+	}
+	ch <- prometheus.MustNewConstMetric(graphEdgesDesc, prometheus.GaugeValue, float64(g.EdgeCount()), service)
+}
+
+func (c *metricsCollector) Collect(ch chan<- prometheus.Metric) {
+	c.RLock()
+	defer c.RUnlock()
+
+	for service, g := range c.graphs {
+		c.collectGraph(ch, service, g)
+	}
+}
+
+// RegisterMetrics exports the node and edge counts of the graph of the
+// service, replacing the graph previously registered for this service
+func RegisterMetrics(g *Graph, service string) {
+	graphMetrics.Lock()
+	graphMetrics.graphs[service] = g
+	graphMetrics.Unlock()
+}
+
+func init() {
+	prometheus.MustRegister(graphMetrics)
+}
@@ -0,0 +1,66 @@
+/*
+ * Copyright (C) 2016 Red Hat, Inc.
+ *
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ *
+ */
+
+package graph
+
+import (
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+)
+
+func TestMetricsCollector(t *testing.T) {
+	g := newGraph(t)
+
+	n1 := g.NewNode(GenID(), Metadata{"Type": "intf"})
+	n2 := g.NewNode(GenID(), Metadata{"Type": "intf"})
+	n3 := g.NewNode(GenID(), Metadata{"Type": "host"})
+	g.Link(n3, n1, Metadata{})
+	g.Link(n3, n2, Metadata{})
+
+	ch := make(chan prometheus.Metric, 10)
+	c := &metricsCollector{graphs: map[string]*Graph{"agent": g}}
+	c.Collect(ch)
+	close(ch)
+
+	nodes := make(map[string]float64)
+	var edges float64
+	for m := range ch {
+		var metric dto.Metric
+		m.Write(&metric)
+
+		if m.Desc() == graphEdgesDesc {
+			edges = metric.GetGauge().GetValue()
+			continue
+		}
+		// labels are sorted by name, service then type
+		nodes[metric.GetLabel()[1].GetValue()] = metric.GetGauge().GetValue()
+	}
+
+	if nodes["intf"] != 2 || nodes["host"] != 1 {
+		t.Errorf("Wrong node counts: %v", nodes)
+	}
+	if edges != 2 {
+		t.Errorf("Wrong edge count: %v", edges)
+	}
+}
@@ -0,0 +1,96 @@
+/*
+ * Copyright (C) 2016 Red Hat, Inc.
+ *
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ *
+ */
+
+package graph
+
+import (
+	"fmt"
+
+	"github.com/skydive-project/skydive/config"
+)
+
+type mockEdge struct {
+	parent   Identifier
+	child    Identifier
+	metadata Metadata
+}
+
+type mockNode struct {
+	id       Identifier
+	metadata Metadata
+}
+
+// MockBuilder builds an in memory graph from a description of its nodes and
+// edges, mostly to write tests:
+//
+//	g := NewMockBuilder().
+//		AddNode("n1", Metadata{"Type": "host"}).
+//		AddNode("n2", Metadata{"Type": "netns"}).
+//		AddEdge("n1", "n2", Metadata{"RelationType": "ownership"}).
+//		Build()
+type MockBuilder struct {
+	nodes []mockNode
+	edges []mockEdge
+}
+
+// AddNode adds a node with the given identifier and metadata
+func (b *MockBuilder) AddNode(id string, m Metadata) *MockBuilder {
+	b.nodes = append(b.nodes, mockNode{id: Identifier(id), metadata: m})
+	return b
+}
+
+// AddEdge adds an edge between the nodes of the given identifiers
+func (b *MockBuilder) AddEdge(parentID, childID string, m Metadata) *MockBuilder {
+	b.edges = append(b.edges, mockEdge{parent: Identifier(parentID), child: Identifier(childID), metadata: m})
+	return b
+}
+
+// Build returns the graph, panicking if an edge references an unknown node
+func (b *MockBuilder) Build() *Graph {
+	backend, err := NewMemoryBackend()
+	if err != nil {
+		panic(err)
+	}
+	// no shortest path cache, the mock graphs being used to test the lookups
+	g := NewGraph(config.GetConfig().GetString("host_id"), backend)
+
+	nodes := make(map[Identifier]*Node)
+	for _, n := range b.nodes {
+		nodes[n.id] = g.NewNode(n.id, n.metadata.clone())
+	}
+
+	for _, e := range b.edges {
+		parent, child := nodes[e.parent], nodes[e.child]
+		if parent == nil || child == nil {
+			panic(fmt.Sprintf("Edge between unknown nodes %s and %s", e.parent, e.child))
+		}
+
+		g.Link(parent, child, e.metadata.clone())
+	}
+
+	return g
+}
+
+// NewMockBuilder returns a builder of an empty graph
+func NewMockBuilder() *MockBuilder {
+	return &MockBuilder{}
+}
@@ -360,11 +360,18 @@ func (o *OrientDBBackend) GetEdges(t *common.TimeSlice, m Metadata) (edges []*Ed
 }
 
 func (o *OrientDBBackend) WithContext(graph *Graph, context GraphContext) (*Graph, error) {
-	return &Graph{
+	g := &Graph{
 		backend: graph.backend,
 		context: context,
 		host:    graph.host,
-	}, nil
+	}
+
+	// the counts of a time slice are retrieved from the backend
+	if context.TimeSlice == nil {
+		g.seedCounts()
+	}
+
+	return g, nil
 }
 
 func NewOrientDBBackend(addr string, database string, username string, password string) (*OrientDBBackend, error) {
@@ -0,0 +1,161 @@
+/*
+ * Copyright (C) 2016 Red Hat, Inc.
+ *
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ *
+ */
+
+package graph
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// JSONPatchOp describes a change of the metadata of a node or an edge, in
+// the JSON Patch style. The path has the form /nodes/<id>/metadata/<key> or
+// /edges/<id>/metadata/<key>, the operation being add, remove or replace.
+type JSONPatchOp struct {
+	Op    string      `json:"op"`
+	Path  string      `json:"path"`
+	Value interface{} `json:"value,omitempty"`
+}
+
+// patchValue returns the value of the operation as stored in the metadata,
+// JSON numbers being converted to int64 or float64 as when decoding nodes
+func patchValue(v interface{}) (interface{}, error) {
+	switch v := v.(type) {
+	case json.Number:
+		if i, err := v.Int64(); err == nil {
+			return i, nil
+		}
+		return v.Float64()
+	case string, bool, int, int32, int64, uint, uint32, uint64, float32, float64:
+		return v, nil
+	}
+	return nil, fmt.Errorf("Unsupported value type %T", v)
+}
+
+// valueKind returns the kind of a metadata value, numbers of all types being
+// of the same kind
+func valueKind(v interface{}) string {
+	switch v.(type) {
+	case string:
+		return "string"
+	case bool:
+		return "bool"
+	case int, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64, float32, float64, json.Number:
+		return "number"
+	}
+	return fmt.Sprintf("%T", v)
+}
+
+// unescapePatchKey decodes the ~1 and ~0 sequences of a JSON pointer token
+func unescapePatchKey(s string) string {
+	return strings.Replace(strings.Replace(s, "~1", "/", -1), "~0", "~", -1)
+}
+
+// lookupPatchElement returns the node or the edge targeted by the path along
+// with the metadata key
+func (g *Graph) lookupPatchElement(path string) (interface{}, *graphElement, string, error) {
+	tokens := strings.Split(path, "/")
+	if len(tokens) != 5 || tokens[0] != "" || tokens[3] != "metadata" || tokens[4] == "" {
+		return nil, nil, "", fmt.Errorf("Invalid path %s, expected /nodes/<id>/metadata/<key> or /edges/<id>/metadata/<key>", path)
+	}
+
+	id, key := Identifier(unescapePatchKey(tokens[2])), unescapePatchKey(tokens[4])
+
+	switch tokens[1] {
+	case "nodes":
+		if n := g.GetNode(id); n != nil {
+			return n, &n.graphElement, key, nil
+		}
+		return nil, nil, "", fmt.Errorf("Node %s not found", id)
+	case "edges":
+		if e := g.GetEdge(id); e != nil {
+			return e, &e.graphElement, key, nil
+		}
+		return nil, nil, "", fmt.Errorf("Edge %s not found", id)
+	}
+
+	return nil, nil, "", fmt.Errorf("Invalid path %s, unknown element type %s", path, tokens[1])
+}
+
+// ApplyPatch applies the operations to the metadata of the nodes and edges of
+// the graph under the write lock. The graph is left untouched if any of the
+// operations is invalid.
+func (g *Graph) ApplyPatch(patch []JSONPatchOp) error {
+	g.Lock()
+	defer g.Unlock()
+
+	if g.context.GetTimeSlice() != nil {
+		return errors.New("Patch not supported on a graph history")
+	}
+
+	var elements []interface{}
+	metadata := make(map[interface{}]Metadata)
+
+	for i, op := range patch {
+		element, ge, key, err := g.lookupPatchElement(op.Path)
+		if err != nil {
+			return fmt.Errorf("Operation %d: %s", i, err.Error())
+		}
+
+		m, ok := metadata[element]
+		if !ok {
+			m = ge.metadata.clone()
+			metadata[element] = m
+			elements = append(elements, element)
+		}
+
+		old, exists := m[key]
+
+		switch op.Op {
+		case "add", "replace":
+			if op.Op == "replace" && !exists {
+				return fmt.Errorf("Operation %d: no metadata %s to replace in %s", i, key, ge.ID)
+			}
+
+			value, err := patchValue(op.Value)
+			if err != nil {
+				return fmt.Errorf("Operation %d: %s", i, err.Error())
+			}
+
+			if exists && valueKind(old) != valueKind(value) {
+				return fmt.Errorf("Operation %d: can't replace the %s metadata %s of %s with a %s", i, valueKind(old), key, ge.ID, valueKind(value))
+			}
+
+			m[key] = value
+		case "remove":
+			if !exists {
+				return fmt.Errorf("Operation %d: no metadata %s to remove in %s", i, key, ge.ID)
+			}
+			delete(m, key)
+		default:
+			return fmt.Errorf("Operation %d: unsupported operation %s", i, op.Op)
+		}
+	}
+
+	for _, element := range elements {
+		g.SetMetadata(element, metadata[element])
+	}
+
+	return nil
+}
@@ -0,0 +1,92 @@
+/*
+ * Copyright (C) 2016 Red Hat, Inc.
+ *
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ *
+ */
+
+package graph
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestApplyPatch(t *testing.T) {
+	g := newGraph(t)
+
+	n1 := g.NewNode(Identifier("n1"), Metadata{"Name": "eth0", "MTU": int64(1500), "State": "UP"})
+	n2 := g.NewNode(Identifier("n2"), Metadata{"Name": "eth1"})
+	e := g.NewEdge(Identifier("e1"), n1, n2, Metadata{"RelationType": "layer2"})
+
+	err := g.ApplyPatch([]JSONPatchOp{
+		{Op: "replace", Path: "/nodes/n1/metadata/MTU", Value: json.Number("9000")},
+		{Op: "remove", Path: "/nodes/n1/metadata/State"},
+		{Op: "add", Path: "/nodes/n2/metadata/Driver~1Name", Value: "veth"},
+		{Op: "add", Path: "/edges/e1/metadata/Weight", Value: 1.5},
+	})
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	if mtu, _ := n1.GetFieldInt64("MTU"); mtu != 9000 {
+		t.Errorf("Expected MTU 9000, got: %v", n1.Metadata())
+	}
+
+	if _, ok := n1.Metadata()["State"]; ok {
+		t.Errorf("State should have been removed, got: %v", n1.Metadata())
+	}
+
+	if driver, _ := n2.GetFieldString("Driver/Name"); driver != "veth" {
+		t.Errorf("Expected Driver/Name veth, got: %v", n2.Metadata())
+	}
+
+	if weight, _ := e.GetFieldFloat64("Weight"); weight != 1.5 {
+		t.Errorf("Expected Weight 1.5, got: %v", e.Metadata())
+	}
+}
+
+func TestApplyPatchErrors(t *testing.T) {
+	g := newGraph(t)
+
+	n1 := g.NewNode(Identifier("n1"), Metadata{"Name": "eth0", "MTU": int64(1500)})
+
+	patches := [][]JSONPatchOp{
+		{{Op: "add", Path: "/nodes/n1/Name", Value: "eth1"}},
+		{{Op: "add", Path: "/links/n1/metadata/Name", Value: "eth1"}},
+		{{Op: "add", Path: "/nodes/n2/metadata/Name", Value: "eth1"}},
+		{{Op: "move", Path: "/nodes/n1/metadata/Name"}},
+		{{Op: "replace", Path: "/nodes/n1/metadata/State", Value: "UP"}},
+		{{Op: "remove", Path: "/nodes/n1/metadata/State"}},
+		{{Op: "add", Path: "/nodes/n1/metadata/Addresses", Value: []interface{}{"10.0.0.1"}}},
+		{
+			{Op: "replace", Path: "/nodes/n1/metadata/Name", Value: "eth1"},
+			{Op: "replace", Path: "/nodes/n1/metadata/MTU", Value: "9000"},
+		},
+	}
+
+	for _, patch := range patches {
+		if err := g.ApplyPatch(patch); err == nil {
+			t.Errorf("Patch should fail: %+v", patch)
+		}
+	}
+
+	if name, _ := n1.GetFieldString("Name"); name != "eth0" {
+		t.Errorf("Node shouldn't be modified by a failed patch, got: %v", n1.Metadata())
+	}
+}
@@ -100,3 +100,7 @@ func NewServer(g *Graph, server *shttp.WSServer) *GraphServer {
 
 	return s
 }
+
+func init() {
+	shttp.SetWSNamespacePriority(Namespace, shttp.WSSyncPriority)
+}
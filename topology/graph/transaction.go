@@ -0,0 +1,194 @@
+/*
+ * Copyright (C) 2016 Red Hat, Inc.
+ *
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ *
+ */
+
+package graph
+
+import (
+	"time"
+)
+
+// GraphTransaction records the mutations done on the graph during a
+// transaction so that they can be rolled back
+type GraphTransaction struct {
+	graph  *Graph
+	events []graphEvent
+	undo   []func()
+}
+
+func copyElementMetadata(i interface{}) (*graphElement, Metadata) {
+	var e *graphElement
+	switch i := i.(type) {
+	case *Node:
+		e = &i.graphElement
+	case *Edge:
+		e = &i.graphElement
+	}
+	return e, e.metadata.clone()
+}
+
+func (t *GraphTransaction) restoreMetadata(i interface{}, e *graphElement, m Metadata) {
+	t.undo = append(t.undo, func() {
+		t.graph.backend.SetMetadata(i, m)
+		e.metadata = m
+		t.graph.refreshExpiration(e)
+		if n, ok := i.(*Node); ok {
+			t.graph.updateIndexes(n)
+		}
+	})
+}
+
+func (t *GraphTransaction) AddNode(n *Node) bool {
+	if !t.graph.AddNode(n) {
+		return false
+	}
+	t.undo = append(t.undo, func() {
+		t.graph.delNode(n)
+	})
+	return true
+}
+
+func (t *GraphTransaction) AddEdge(e *Edge) bool {
+	if !t.graph.AddEdge(e) {
+		return false
+	}
+	t.undo = append(t.undo, func() {
+		t.graph.delEdge(e)
+	})
+	return true
+}
+
+func (t *GraphTransaction) NewNode(i Identifier, m Metadata, h ...string) *Node {
+	n := t.graph.NewNode(i, m, h...)
+	if n != nil {
+		t.undo = append(t.undo, func() {
+			t.graph.delNode(n)
+		})
+	}
+	return n
+}
+
+func (t *GraphTransaction) NewEdge(i Identifier, p *Node, c *Node, m Metadata) *Edge {
+	e := t.graph.NewEdge(i, p, c, m)
+	if e != nil {
+		t.undo = append(t.undo, func() {
+			t.graph.delEdge(e)
+		})
+	}
+	return e
+}
+
+func (t *GraphTransaction) Link(n1 *Node, n2 *Node, m Metadata) *Edge {
+	return t.NewEdge(GenID(), n1, n2, m)
+}
+
+func (t *GraphTransaction) AddMetadata(i interface{}, k string, v interface{}) bool {
+	e, m := copyElementMetadata(i)
+	if !t.graph.AddMetadata(i, k, v) {
+		return false
+	}
+	t.restoreMetadata(i, e, m)
+	return true
+}
+
+func (t *GraphTransaction) SetMetadata(i interface{}, m Metadata) bool {
+	e, old := copyElementMetadata(i)
+	if !t.graph.SetMetadata(i, m) {
+		return false
+	}
+	t.restoreMetadata(i, e, old)
+	return true
+}
+
+func (t *GraphTransaction) DelEdge(e *Edge) {
+	if t.graph.GetEdge(e.ID) == nil {
+		return
+	}
+
+	t.graph.DelEdge(e)
+	t.undo = append(t.undo, func() {
+		t.graph.addEdge(e)
+		e.deletedAt = time.Time{}
+	})
+}
+
+func (t *GraphTransaction) DelNode(n *Node) {
+	if t.graph.GetNode(n.ID) == nil {
+		return
+	}
+
+	for _, e := range t.graph.GetNodeEdges(n, Metadata{}) {
+		t.DelEdge(e)
+	}
+
+	t.graph.DelNode(n)
+	t.undo = append(t.undo, func() {
+		t.graph.addNode(n)
+		n.deletedAt = time.Time{}
+	})
+}
+
+func (t *GraphTransaction) rollback() {
+	for i := len(t.undo) - 1; i >= 0; i-- {
+		t.undo[i]()
+	}
+
+	if t.graph.pathCache != nil {
+		t.graph.pathCache.flush()
+	}
+}
+
+// run calls fn with the transaction set on the graph, the mutations being
+// rolled back if fn panics
+func (t *GraphTransaction) run(fn func(tx *GraphTransaction) error) error {
+	t.graph.transaction = t
+	defer func() {
+		t.graph.transaction = nil
+		if r := recover(); r != nil {
+			t.rollback()
+			panic(r)
+		}
+	}()
+
+	return fn(t)
+}
+
+// Transaction calls fn holding the graph lock. The events generated by the
+// mutations done through the transaction are delivered once fn returned. If
+// fn returns an error, all the mutations are rolled back and no event is
+// delivered. If fn panics, the mutations are rolled back as well before the
+// panic is propagated. The graph must not be locked by the caller.
+func (g *Graph) Transaction(fn func(tx *GraphTransaction) error) error {
+	g.Lock()
+	defer g.Unlock()
+
+	tx := &GraphTransaction{graph: g}
+	if err := tx.run(fn); err != nil {
+		tx.rollback()
+		return err
+	}
+
+	for _, ge := range tx.events {
+		g.notifyEvent(ge)
+	}
+
+	return nil
+}
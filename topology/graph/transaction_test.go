@@ -0,0 +1,120 @@
+/*
+ * Copyright (C) 2016 Red Hat, Inc.
+ *
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ *
+ */
+
+package graph
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestTransaction(t *testing.T) {
+	g := newGraph(t)
+
+	n1 := g.NewNode(Identifier("n1"), Metadata{"Value": 1})
+
+	listener := &FakeListener{}
+	g.AddEventListener(listener)
+
+	err := g.Transaction(func(tx *GraphTransaction) error {
+		n2 := tx.NewNode(Identifier("n2"), Metadata{"Value": 2})
+		tx.Link(n1, n2, nil)
+
+		if listener.lastNodeAdded != nil {
+			t.Error("Events should not be delivered before the end of the transaction")
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if listener.lastNodeAdded == nil || listener.lastNodeAdded.ID != "n2" || listener.lastEdgeAdded == nil {
+		t.Error("Events not delivered")
+	}
+
+	listener.lastNodeAdded, listener.lastNodeDeleted = nil, nil
+	n2 := g.GetNode(Identifier("n2"))
+
+	err = g.Transaction(func(tx *GraphTransaction) error {
+		tx.NewNode(Identifier("n3"), Metadata{"Value": 3})
+		tx.AddMetadata(n1, "Value", 10)
+		tx.SetMetadata(n2, Metadata{"Name": "Node2"})
+		tx.DelNode(n2)
+		return errors.New("abort")
+	})
+	if err == nil {
+		t.Fatal("Should return the error of the transaction")
+	}
+
+	if g.GetNode(Identifier("n3")) != nil {
+		t.Error("Node creation not rolled back")
+	}
+
+	if g.GetNode(Identifier("n2")) == nil || !g.AreLinked(n1, n2, nil) {
+		t.Error("Node deletion not rolled back")
+	}
+
+	if v, _ := n1.GetFieldInt64("Value"); v != 1 {
+		t.Error("Metadata update not rolled back")
+	}
+
+	if v, _ := n2.GetFieldInt64("Value"); v != 2 {
+		t.Error("Metadata update not rolled back")
+	}
+
+	if listener.lastNodeAdded != nil || listener.lastNodeDeleted != nil {
+		t.Error("Events should not be delivered when the transaction is rolled back")
+	}
+}
+
+func TestTransactionPanic(t *testing.T) {
+	g := newGraph(t)
+
+	listener := &FakeListener{}
+	g.AddEventListener(listener)
+
+	func() {
+		defer func() {
+			if r := recover(); r == nil {
+				t.Error("The panic should be propagated")
+			}
+		}()
+
+		g.Transaction(func(tx *GraphTransaction) error {
+			tx.NewNode(Identifier("n1"), Metadata{"Value": 1})
+			panic("abort")
+		})
+	}()
+
+	if g.GetNode(Identifier("n1")) != nil {
+		t.Error("Node creation not rolled back")
+	}
+
+	g.Lock()
+	g.NewNode(Identifier("n2"), Metadata{"Value": 2})
+	g.Unlock()
+
+	if listener.lastNodeAdded == nil || listener.lastNodeAdded.ID != "n2" {
+		t.Error("Events should be delivered after a panicking transaction")
+	}
+}
@@ -0,0 +1,136 @@
+/*
+ * Copyright (C) 2016 Red Hat, Inc.
+ *
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ *
+ */
+
+package traversal
+
+import (
+	"bytes"
+	"fmt"
+	"reflect"
+	"strings"
+
+	"github.com/skydive-project/skydive/topology/graph"
+)
+
+// GremlinTraversalStepExplain makes the sequence return its execution plan
+// instead of executing it
+type GremlinTraversalStepExplain struct {
+	GremlinTraversalContext
+}
+
+func (s *GremlinTraversalStepExplain) Exec(last GraphTraversalStep) (GraphTraversalStep, error) {
+	return nil, ExecutionError
+}
+
+func (s *GremlinTraversalStepExplain) Reduce(next GremlinTraversalStep) GremlinTraversalStep {
+	return next
+}
+
+func stepName(step GremlinTraversalStep) string {
+	return strings.TrimPrefix(reflect.TypeOf(step).Elem().Name(), "GremlinTraversalStep")
+}
+
+// indexedKeys returns the keys of the parameters having an index
+func indexedKeys(g *graph.Graph, params []interface{}) (keys []string) {
+	for i := 0; i+1 < len(params); i += 2 {
+		if k, ok := params[i].(string); ok && (g.IsIndexed(k) || g.IsStringIndexed(k)) {
+			keys = append(keys, k)
+		}
+	}
+	return
+}
+
+// Explain returns the execution plan of the sequence, with for each step,
+// once reduced, an estimation of the number of input and output elements
+// based on the number of nodes and edges of the graph.
+func (s *GremlinTraversalSequence) Explain() *GraphTraversalValue {
+	g := s.GraphTraversal.Graph
+
+	nodes := len(g.GetNodes(graph.Metadata{}))
+	edges := len(g.GetEdges(graph.Metadata{}))
+	degree := 0
+	if nodes > 0 {
+		degree = (2*edges + nodes - 1) / nodes
+	}
+
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "graph: %d nodes, %d edges\n", nodes, edges)
+
+	estimate := 0
+	for i := 0; i < len(s.steps); {
+		step := s.steps[i]
+		for i = i + 1; i < len(s.steps); i = i + 1 {
+			if next := step.Reduce(s.steps[i]); next != step {
+				break
+			}
+		}
+
+		if _, ok := step.(*GremlinTraversalStepExplain); ok {
+			continue
+		}
+
+		context := step.Context()
+		input := estimate
+
+		var index []string
+		switch step.(type) {
+		case *GremlinTraversalStepV:
+			estimate = nodes
+			if len(context.Params) == 1 {
+				estimate = 1
+			}
+			index = indexedKeys(g, context.Params)
+		case *GremlinTraversalStepHas:
+			index = indexedKeys(g, context.Params)
+		case *GremlinTraversalStepOut, *GremlinTraversalStepIn, *GremlinTraversalStepBoth,
+			*GremlinTraversalStepOutE, *GremlinTraversalStepInE, *GremlinTraversalStepBothE:
+			estimate = input * degree
+		case *GremlinTraversalStepOutV, *GremlinTraversalStepInV:
+		case *GremlinTraversalStepCount, *GremlinTraversalStepSum, *GremlinTraversalStepAvg,
+			*GremlinTraversalStepMin, *GremlinTraversalStepMax, *GremlinTraversalStepFirst,
+			*GremlinTraversalStepLast:
+			if estimate > 1 {
+				estimate = 1
+			}
+		}
+
+		if r := context.StepContext.PaginationRange; r != nil && int64(estimate) > r[1]-r[0] {
+			estimate = int(r[1] - r[0])
+		}
+
+		var params []string
+		for _, param := range context.Params {
+			params = append(params, fmt.Sprintf("%v", param))
+		}
+
+		fmt.Fprintf(&buf, "%s(%s) input: %d, output: ~%d", stepName(step), strings.Join(params, ", "), input, estimate)
+		if r := context.StepContext.PaginationRange; r != nil {
+			fmt.Fprintf(&buf, ", range: [%d, %d]", r[0], r[1])
+		}
+		if len(index) > 0 {
+			fmt.Fprintf(&buf, ", index: %s", strings.Join(index, ", "))
+		}
+		buf.WriteString("\n")
+	}
+
+	return &GraphTraversalValue{GraphTraversal: s.GraphTraversal, value: buf.String()}
+}
@@ -0,0 +1,75 @@
+/*
+ * Copyright (C) 2016 Red Hat, Inc.
+ *
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ *
+ */
+
+package traversal
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/skydive-project/skydive/topology/graph"
+)
+
+// TraversalTestCase holds a traversal and the values it is expected to
+// return, the nodes and edges being identified by their ID
+type TraversalTestCase struct {
+	Input    *GraphTraversalV
+	Expected []interface{}
+}
+
+// testValue returns the ID of the nodes and edges, the value itself otherwise
+func testValue(v interface{}) interface{} {
+	switch v := v.(type) {
+	case *graph.Node:
+		return v.ID
+	case *graph.Edge:
+		return v.ID
+	}
+	return v
+}
+
+// AssertTraversal checks that the traversal returns the expected values,
+// whatever their order
+func AssertTraversal(t *testing.T, tc TraversalTestCase) {
+	if err := tc.Input.Error(); err != nil {
+		t.Fatal(err)
+	}
+
+	values := tc.Input.Values()
+	if len(values) != len(tc.Expected) {
+		t.Fatalf("Should return %d values, returned: %v", len(tc.Expected), values)
+	}
+
+	matched := make([]bool, len(values))
+	for _, expected := range tc.Expected {
+		found := false
+		for i, v := range values {
+			if !matched[i] && reflect.DeepEqual(testValue(v), testValue(expected)) {
+				matched[i], found = true, true
+				break
+			}
+		}
+		if !found {
+			t.Fatalf("Should return %v, returned: %v", expected, values)
+		}
+	}
+}
@@ -0,0 +1,90 @@
+/*
+ * Copyright (C) 2016 Red Hat, Inc.
+ *
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ *
+ */
+
+package traversal
+
+import (
+	"time"
+)
+
+// StepProfile holds the execution of a step of a profiled traversal
+type StepProfile struct {
+	Name        string
+	InputCount  int
+	OutputCount int
+	Duration    time.Duration
+}
+
+// ProfileReport holds the execution of the steps of a profiled traversal
+type ProfileReport struct {
+	Steps    []StepProfile
+	Duration time.Duration
+}
+
+// GremlinTraversalStepProfile makes the sequence return the execution time
+// of its steps along with its result
+type GremlinTraversalStepProfile struct {
+	GremlinTraversalContext
+}
+
+func (s *GremlinTraversalStepProfile) Exec(last GraphTraversalStep) (GraphTraversalStep, error) {
+	return last, nil
+}
+
+func (s *GremlinTraversalStepProfile) Reduce(next GremlinTraversalStep) GremlinTraversalStep {
+	return next
+}
+
+// SetProfile enables or disables the recording of the execution time of the
+// steps of the traversal
+func (t *GraphTraversal) SetProfile(enabled bool) {
+	t.profile = enabled
+}
+
+// Profile returns whether the execution time of the steps is recorded
+func (t *GraphTraversal) Profile() bool {
+	return t.profile
+}
+
+// cardinality returns the number of elements returned by a step
+func cardinality(step GraphTraversalStep) int {
+	switch s := step.(type) {
+	case *GraphTraversal:
+		return 0
+	case *GraphTraversalV:
+		return len(s.nodes)
+	case *GraphTraversalE:
+		return len(s.edges)
+	}
+	return len(step.Values())
+}
+
+// isProfiled returns whether the sequence has to be profiled, either by a
+// Profile step or by the option of its traversal
+func (s *GremlinTraversalSequence) isProfiled() bool {
+	for _, step := range s.steps {
+		if _, ok := step.(*GremlinTraversalStepProfile); ok {
+			return true
+		}
+	}
+	return s.GraphTraversal.Profile()
+}
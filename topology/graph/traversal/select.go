@@ -0,0 +1,141 @@
+/*
+ * Copyright (C) 2016 Red Hat, Inc.
+ *
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ *
+ */
+
+package traversal
+
+import (
+	"fmt"
+)
+
+// GremlinTraversalStepAs labels the elements of the previous step
+type GremlinTraversalStepAs struct {
+	GremlinTraversalContext
+}
+
+// GremlinTraversalStepSelect returns the elements of the labeled steps
+type GremlinTraversalStepSelect struct {
+	GremlinTraversalContext
+}
+
+// bind keeps the values under the label, replacing the ones previously
+// labeled the same way
+func (t *GraphTraversal) bind(label string, values []interface{}) {
+	if t.labels == nil {
+		t.labels = make(map[string][]interface{})
+	}
+	t.labels[label] = values
+}
+
+// selectLabels returns the values of the labels, all of them if none given
+func (t *GraphTraversal) selectLabels(labels ...string) *GraphTraversalValue {
+	if len(labels) == 0 {
+		for label := range t.labels {
+			labels = append(labels, label)
+		}
+	}
+
+	selected := make(map[string][]interface{})
+	for _, label := range labels {
+		values, ok := t.labels[label]
+		if !ok {
+			return &GraphTraversalValue{error: fmt.Errorf("Unknown label '%s'", label)}
+		}
+		selected[label] = values
+	}
+
+	return &GraphTraversalValue{GraphTraversal: t, value: selected}
+}
+
+// As labels the current nodes so that they can be returned by Select
+func (tv *GraphTraversalV) As(label string) *GraphTraversalV {
+	if tv.error != nil {
+		return tv
+	}
+
+	tv.GraphTraversal.bind(label, tv.Values())
+	return tv
+}
+
+// Select returns a map of the nodes or edges of the given labels
+func (tv *GraphTraversalV) Select(labels ...string) *GraphTraversalValue {
+	if tv.error != nil {
+		return &GraphTraversalValue{error: tv.error}
+	}
+
+	return tv.GraphTraversal.selectLabels(labels...)
+}
+
+// As labels the current edges so that they can be returned by Select
+func (te *GraphTraversalE) As(label string) *GraphTraversalE {
+	if te.error != nil {
+		return te
+	}
+
+	te.GraphTraversal.bind(label, te.Values())
+	return te
+}
+
+// Select returns a map of the nodes or edges of the given labels
+func (te *GraphTraversalE) Select(labels ...string) *GraphTraversalValue {
+	if te.error != nil {
+		return &GraphTraversalValue{error: te.error}
+	}
+
+	return te.GraphTraversal.selectLabels(labels...)
+}
+
+func (s *GremlinTraversalStepAs) Exec(last GraphTraversalStep) (GraphTraversalStep, error) {
+	label := s.Params[0].(string)
+
+	switch last.(type) {
+	case *GraphTraversalV:
+		return last.(*GraphTraversalV).As(label), nil
+	case *GraphTraversalE:
+		return last.(*GraphTraversalE).As(label), nil
+	}
+
+	return invokeStepFnc(last, "As", s)
+}
+
+func (s *GremlinTraversalStepAs) Reduce(next GremlinTraversalStep) GremlinTraversalStep {
+	return next
+}
+
+func (s *GremlinTraversalStepSelect) Exec(last GraphTraversalStep) (GraphTraversalStep, error) {
+	var labels []string
+	for _, param := range s.Params {
+		labels = append(labels, param.(string))
+	}
+
+	switch last.(type) {
+	case *GraphTraversalV:
+		return last.(*GraphTraversalV).Select(labels...), nil
+	case *GraphTraversalE:
+		return last.(*GraphTraversalE).Select(labels...), nil
+	}
+
+	return invokeStepFnc(last, "Select", s)
+}
+
+func (s *GremlinTraversalStepSelect) Reduce(next GremlinTraversalStep) GremlinTraversalStep {
+	return next
+}
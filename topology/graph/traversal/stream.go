@@ -0,0 +1,120 @@
+/*
+ * Copyright (C) 2016 Red Hat, Inc.
+ *
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ *
+ */
+
+package traversal
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"golang.org/x/net/context"
+
+	"github.com/skydive-project/skydive/topology/graph"
+)
+
+// GraphTraversalVStream is a lazily evaluated stream of nodes. The nodes are
+// walked by a goroutine as the consumer reads them, so the graph has to be
+// locked, at least for reading, from the call to VStream until the stream
+// is closed or its context cancelled and the channel drained.
+type GraphTraversalVStream struct {
+	GraphTraversal *GraphTraversal
+	nodes          chan *graph.Node
+	error          error
+}
+
+// VStream returns the nodes matching the same parameters as V, one at a time.
+// Nodes are matched as the consumer reads them and the stream is closed when
+// the context is cancelled.
+func (t *GraphTraversal) VStream(ctx context.Context, s ...interface{}) *GraphTraversalVStream {
+	if t.error != nil {
+		return &GraphTraversalVStream{error: t.error}
+	}
+
+	var node *graph.Node
+	var metadata graph.Metadata
+	var err error
+
+	switch len(s) {
+	case 1:
+		id, ok := s[0].(string)
+		if !ok {
+			return &GraphTraversalVStream{error: fmt.Errorf("VStream accepts only a string when there is only one argument")}
+		}
+		if node = t.Graph.GetNode(graph.Identifier(id)); node == nil {
+			return &GraphTraversalVStream{error: fmt.Errorf("Node '%s' does not exist", id)}
+		}
+	default:
+		if metadata, err = SliceToMetadata(s...); err != nil {
+			return &GraphTraversalVStream{error: err}
+		}
+	}
+
+	stream := &GraphTraversalVStream{GraphTraversal: t, nodes: make(chan *graph.Node)}
+	send := func(n *graph.Node) bool {
+		select {
+		case stream.nodes <- n:
+			return true
+		case <-ctx.Done():
+			return false
+		}
+	}
+
+	go func() {
+		defer close(stream.nodes)
+
+		if node != nil {
+			send(node)
+			return
+		}
+		t.Graph.WalkNodes(metadata, send)
+	}()
+
+	return stream
+}
+
+// Nodes returns the channel the nodes are sent to, closed at the end of the
+// stream
+func (s *GraphTraversalVStream) Nodes() <-chan *graph.Node {
+	if s.nodes == nil {
+		nodes := make(chan *graph.Node)
+		close(nodes)
+		return nodes
+	}
+	return s.nodes
+}
+
+// Values consumes the whole stream
+func (s *GraphTraversalVStream) Values() []interface{} {
+	var values []interface{}
+	for n := range s.Nodes() {
+		values = append(values, n)
+	}
+	return values
+}
+
+func (s *GraphTraversalVStream) MarshalJSON() ([]byte, error) {
+	return json.Marshal(s.Values())
+}
+
+func (s *GraphTraversalVStream) Error() error {
+	return s.error
+}
@@ -23,10 +23,18 @@
 package traversal
 
 import (
+	"bytes"
 	"encoding/json"
 	"errors"
 	"fmt"
+	"math"
+	"net"
+	"reflect"
 	"regexp"
+	"runtime"
+	"sort"
+	"strings"
+	"sync"
 	"time"
 
 	"github.com/mitchellh/hashstructure"
@@ -54,15 +62,24 @@ func (r *GraphTraversalRange) Iterator() *common.Iterator {
 
 type GraphTraversalRange [2]int64
 
+// DefaultMaxRepeat is the maximum number of iterations of a Repeat step
+// when MaxRepeat is not set on the traversal.
+const DefaultMaxRepeat = 100
+
 type GraphTraversal struct {
 	Graph              *graph.Graph
+	MaxRepeat          int
 	error              error
 	currentStepContext GraphStepContext
+	profile            bool
+	labels             map[string][]interface{}
 }
 
 type GraphTraversalV struct {
 	GraphTraversal *GraphTraversal
 	nodes          []*graph.Node
+	repeat         func(*GraphTraversalV) *GraphTraversalV
+	workers        int
 	error          error
 }
 
@@ -88,12 +105,40 @@ type WithinMetadataMatcher struct {
 	List []interface{}
 }
 
+func isFloat(v interface{}) bool {
+	switch v.(type) {
+	case float32, float64:
+		return true
+	}
+	return false
+}
+
 func ParamToFilter(k string, v interface{}) (*filters.Filter, error) {
 	switch v := v.(type) {
 	case *RegexMetadataMatcher:
 		return &filters.Filter{
 			RegexFilter: &filters.RegexFilter{Key: k, Value: v.pattern},
 		}, nil
+	case *ContainsMetadataMatcher:
+		return filters.NewContainsFilter(k, v.value), nil
+	case *StartsWithMetadataMatcher:
+		return filters.NewPrefixFilter(k, v.value), nil
+	case *EndsWithMetadataMatcher:
+		return filters.NewSuffixFilter(k, v.value), nil
+	case *IPNetMetadataMatcher:
+		return filters.NewIPFilter(k, v.ipnet.String())
+	case *ArrayContainsMetadataMatcher:
+		return filters.NewArrayContainsFilter(k, v.value), nil
+	case *IsNullMetadataMatcher:
+		return filters.NewNullFilter(k), nil
+	case *IsNotNullMetadataMatcher:
+		return filters.NewNotNullFilter(k), nil
+	case *NotMetadataMatcher:
+		filter, err := ParamToFilter(k, v.value)
+		if err != nil {
+			return nil, err
+		}
+		return filters.NewNotFilter(filter), nil
 	case *NEMetadataMatcher:
 		switch t := v.value.(type) {
 		case string:
@@ -106,18 +151,39 @@ func ParamToFilter(k string, v interface{}) (*filters.Filter, error) {
 			return filters.NewNotFilter(filters.NewTermInt64Filter(k, i)), nil
 		}
 	case *LTMetadataMatcher:
+		if isFloat(v.value) {
+			f, err := common.ToFloat64(v.value)
+			if err != nil {
+				return nil, errors.New("LT values should be of float64 type")
+			}
+			return filters.NewLtFloat64Filter(k, f), nil
+		}
 		i, err := common.ToInt64(v.value)
 		if err != nil {
 			return nil, errors.New("LT values should be of int64 type")
 		}
 		return filters.NewLtInt64Filter(k, i), nil
 	case *GTMetadataMatcher:
+		if isFloat(v.value) {
+			f, err := common.ToFloat64(v.value)
+			if err != nil {
+				return nil, errors.New("GT values should be of float64 type")
+			}
+			return filters.NewGtFloat64Filter(k, f), nil
+		}
 		i, err := common.ToInt64(v.value)
 		if err != nil {
 			return nil, errors.New("GT values should be of int64 type")
 		}
 		return filters.NewGtInt64Filter(k, i), nil
 	case *GTEMetadataMatcher:
+		if isFloat(v.value) {
+			f, err := common.ToFloat64(v.value)
+			if err != nil {
+				return nil, errors.New("GTE values should be of float64 type")
+			}
+			return filters.NewGteFloat64Filter(k, f), nil
+		}
 		i, err := common.ToInt64(v.value)
 		if err != nil {
 			return nil, errors.New("GTE values should be of int64 type")
@@ -126,6 +192,13 @@ func ParamToFilter(k string, v interface{}) (*filters.Filter, error) {
 			GteInt64Filter: &filters.GteInt64Filter{Key: k, Value: i},
 		}, nil
 	case *LTEMetadataMatcher:
+		if isFloat(v.value) {
+			f, err := common.ToFloat64(v.value)
+			if err != nil {
+				return nil, errors.New("LTE values should be of float64 type")
+			}
+			return filters.NewLteFloat64Filter(k, f), nil
+		}
 		i, err := common.ToInt64(v.value)
 		if err != nil {
 			return nil, errors.New("LTE values should be of int64 type")
@@ -134,6 +207,17 @@ func ParamToFilter(k string, v interface{}) (*filters.Filter, error) {
 			LteInt64Filter: &filters.LteInt64Filter{Key: k, Value: i},
 		}, nil
 	case *InsideMetadataMatcher:
+		if isFloat(v.from) || isFloat(v.to) {
+			f64, fok := common.ToFloat64(v.from)
+			t64, tok := common.ToFloat64(v.to)
+
+			if fok != nil || tok != nil {
+				return nil, errors.New("Inside values should be of numeric type")
+			}
+
+			return filters.NewAndFilter(filters.NewGtFloat64Filter(k, f64), filters.NewLtFloat64Filter(k, t64)), nil
+		}
+
 		f64, fok := common.ToInt64(v.from)
 		t64, tok := common.ToInt64(v.to)
 
@@ -143,6 +227,17 @@ func ParamToFilter(k string, v interface{}) (*filters.Filter, error) {
 
 		return filters.NewAndFilter(filters.NewGtInt64Filter(k, f64), filters.NewLtInt64Filter(k, t64)), nil
 	case *OutsideMetadataMatcher:
+		if isFloat(v.from) || isFloat(v.to) {
+			f64, fok := common.ToFloat64(v.from)
+			t64, tok := common.ToFloat64(v.to)
+
+			if fok != nil || tok != nil {
+				return nil, errors.New("Outside values should be of numeric type")
+			}
+
+			return filters.NewOrFilter(filters.NewLtFloat64Filter(k, f64), filters.NewGtFloat64Filter(k, t64)), nil
+		}
+
 		f64, fok := common.ToInt64(v.from)
 		t64, tok := common.ToInt64(v.to)
 
@@ -150,8 +245,19 @@ func ParamToFilter(k string, v interface{}) (*filters.Filter, error) {
 			return nil, errors.New("Outside values should be of int64 type")
 		}
 
-		return filters.NewAndFilter(filters.NewLtInt64Filter(k, f64), filters.NewGtInt64Filter(k, t64)), nil
+		return filters.NewOrFilter(filters.NewLtInt64Filter(k, f64), filters.NewGtInt64Filter(k, t64)), nil
 	case *BetweenMetadataMatcher:
+		if isFloat(v.from) || isFloat(v.to) {
+			f64, fok := common.ToFloat64(v.from)
+			t64, tok := common.ToFloat64(v.to)
+
+			if fok != nil || tok != nil {
+				return nil, errors.New("Between values should be of numeric type")
+			}
+
+			return filters.NewAndFilter(filters.NewGteFloat64Filter(k, f64), filters.NewLtFloat64Filter(k, t64)), nil
+		}
+
 		f64, fok := common.ToInt64(v.from)
 		t64, tok := common.ToInt64(v.to)
 
@@ -190,12 +296,12 @@ func ParamToFilter(k string, v interface{}) (*filters.Filter, error) {
 	}
 }
 
-func ParamsToFilter(params ...interface{}) (*filters.Filter, error) {
+func paramsToFilters(params ...interface{}) ([]*filters.Filter, error) {
 	if len(params)%2 != 0 {
 		return nil, fmt.Errorf("Slice must be defined by pair k,v: %v", params)
 	}
 
-	var andFilters []*filters.Filter
+	var pairFilters []*filters.Filter
 	for i := 0; i < len(params); i += 2 {
 		k, ok := params[i].(string)
 		if !ok {
@@ -206,12 +312,48 @@ func ParamsToFilter(params ...interface{}) (*filters.Filter, error) {
 		if err != nil {
 			return nil, err
 		}
-		andFilters = append(andFilters, filter)
+		pairFilters = append(pairFilters, filter)
+	}
+
+	return pairFilters, nil
+}
+
+func ParamsToFilter(params ...interface{}) (*filters.Filter, error) {
+	andFilters, err := paramsToFilters(params...)
+	if err != nil {
+		return nil, err
 	}
 
 	return filters.NewAndFilter(andFilters...), nil
 }
 
+// ParamsToOrFilter returns a filter matching any of the k,v pairs
+func ParamsToOrFilter(params ...interface{}) (*filters.Filter, error) {
+	orFilters, err := paramsToFilters(params...)
+	if err != nil {
+		return nil, err
+	}
+
+	return filters.NewOrFilter(orFilters...), nil
+}
+
+func paramsToKeys(step string, params ...interface{}) ([]string, error) {
+	if len(params) == 0 {
+		return nil, fmt.Errorf("At least one parameter must be provided to '%s'", step)
+	}
+
+	keys := make([]string, len(params))
+	for i, param := range params {
+		k, ok := param.(string)
+		if !ok {
+			return nil, fmt.Errorf("%s parameters have to be string keys", step)
+		}
+		keys[i] = k
+	}
+
+	return keys, nil
+}
+
 func Within(s ...interface{}) *WithinMetadataMatcher {
 	return &WithinMetadataMatcher{List: s}
 }
@@ -232,6 +374,76 @@ func Ne(s interface{}) *NEMetadataMatcher {
 	return &NEMetadataMatcher{value: s}
 }
 
+type ContainsMetadataMatcher struct {
+	value string
+}
+
+func Contains(s string) *ContainsMetadataMatcher {
+	return &ContainsMetadataMatcher{value: s}
+}
+
+type StartsWithMetadataMatcher struct {
+	value string
+}
+
+func StartsWith(s string) *StartsWithMetadataMatcher {
+	return &StartsWithMetadataMatcher{value: s}
+}
+
+type EndsWithMetadataMatcher struct {
+	value string
+}
+
+func EndsWith(s string) *EndsWithMetadataMatcher {
+	return &EndsWithMetadataMatcher{value: s}
+}
+
+type IPNetMetadataMatcher struct {
+	ipnet *net.IPNet
+}
+
+// IPNet returns a matcher for the IPs belonging to the given CIDR
+func IPNet(cidr string) (*IPNetMetadataMatcher, error) {
+	_, ipnet, err := net.ParseCIDR(cidr)
+	if err != nil {
+		return nil, err
+	}
+	return &IPNetMetadataMatcher{ipnet: ipnet}, nil
+}
+
+type ArrayContainsMetadataMatcher struct {
+	value string
+}
+
+// ArrayContains returns a matcher for the slices containing the given value
+func ArrayContains(s string) *ArrayContainsMetadataMatcher {
+	return &ArrayContainsMetadataMatcher{value: s}
+}
+
+type IsNullMetadataMatcher struct {
+}
+
+// IsNull returns a matcher for the absent or nil keys
+func IsNull() *IsNullMetadataMatcher {
+	return &IsNullMetadataMatcher{}
+}
+
+type IsNotNullMetadataMatcher struct {
+}
+
+// IsNotNull returns a matcher for the present and not nil keys
+func IsNotNull() *IsNotNullMetadataMatcher {
+	return &IsNotNullMetadataMatcher{}
+}
+
+type NotMetadataMatcher struct {
+	value interface{}
+}
+
+func Not(s interface{}) *NotMetadataMatcher {
+	return &NotMetadataMatcher{value: s}
+}
+
 type LTMetadataMatcher struct {
 	value interface{}
 }
@@ -391,7 +603,7 @@ func (t *GraphTraversal) Context(s ...interface{}) *GraphTraversal {
 		return &GraphTraversal{error: err}
 	}
 
-	return &GraphTraversal{Graph: g}
+	return &GraphTraversal{Graph: g, MaxRepeat: t.MaxRepeat}
 }
 
 func (t *GraphTraversal) V(s ...interface{}) *GraphTraversalV {
@@ -481,7 +693,7 @@ func (tv *GraphTraversalV) PropertyValues(keys ...interface{}) *GraphTraversalVa
 
 	var s []interface{}
 	for _, n := range tv.nodes {
-		if value, ok := n.Metadata()[key]; ok {
+		if value, ok := n.Metadata().GetField(key); ok {
 			s = append(s, value)
 		}
 	}
@@ -529,6 +741,260 @@ func (tv *GraphTraversalV) Sum(keys ...interface{}) *GraphTraversalValue {
 	return &GraphTraversalValue{GraphTraversal: tv.GraphTraversal, value: s}
 }
 
+func (tv *GraphTraversalV) Avg(keys ...interface{}) *GraphTraversalValue {
+	if tv.error != nil {
+		return &GraphTraversalValue{error: tv.error}
+	}
+
+	if len(keys) != 1 {
+		return &GraphTraversalValue{error: fmt.Errorf("Avg requires 1 parameter")}
+	}
+	key, ok := keys[0].(string)
+	if !ok {
+		return &GraphTraversalValue{error: fmt.Errorf("Avg parameter has to be a string key")}
+	}
+
+	var s float64
+	var count int
+	for _, n := range tv.nodes {
+		if value, err := n.GetFieldInt64(key); err == nil {
+			if v, err := common.ToFloat64(value); err == nil {
+				s += v
+				count++
+			} else {
+				return &GraphTraversalValue{error: err}
+			}
+		}
+	}
+
+	if count == 0 {
+		return &GraphTraversalValue{GraphTraversal: tv.GraphTraversal, value: float64(0)}
+	}
+	return &GraphTraversalValue{GraphTraversal: tv.GraphTraversal, value: s / float64(count)}
+}
+
+func (tv *GraphTraversalV) extremum(name string, keys []interface{}, less func(a, b float64) bool) *GraphTraversalValue {
+	if tv.error != nil {
+		return &GraphTraversalValue{error: tv.error}
+	}
+
+	if len(keys) != 1 {
+		return &GraphTraversalValue{error: fmt.Errorf("%s requires 1 parameter", name)}
+	}
+	key, ok := keys[0].(string)
+	if !ok {
+		return &GraphTraversalValue{error: fmt.Errorf("%s parameter has to be a string key", name)}
+	}
+
+	var result float64
+	found := false
+	for _, n := range tv.nodes {
+		value, err := n.GetFieldInt64(key)
+		if err == common.ErrFieldNotFound {
+			continue
+		} else if err != nil {
+			return &GraphTraversalValue{error: fmt.Errorf("%s: %s is not a numeric value: %s", name, key, err.Error())}
+		}
+
+		v, err := common.ToFloat64(value)
+		if err != nil {
+			return &GraphTraversalValue{error: err}
+		}
+
+		if !found || less(v, result) {
+			result = v
+			found = true
+		}
+	}
+
+	if !found {
+		return &GraphTraversalValue{error: fmt.Errorf("%s: no node with key '%s' found", name, key)}
+	}
+
+	return &GraphTraversalValue{GraphTraversal: tv.GraphTraversal, value: result}
+}
+
+// Min returns the lowest numeric value of the given key across the nodes
+func (tv *GraphTraversalV) Min(keys ...interface{}) *GraphTraversalValue {
+	return tv.extremum("Min", keys, func(a, b float64) bool { return a < b })
+}
+
+// Max returns the highest numeric value of the given key across the nodes
+func (tv *GraphTraversalV) Max(keys ...interface{}) *GraphTraversalValue {
+	return tv.extremum("Max", keys, func(a, b float64) bool { return a > b })
+}
+
+// GroupBy buckets the nodes according to the value of the given key. Nodes
+// without the key are grouped under the empty string.
+func (tv *GraphTraversalV) GroupBy(keys ...interface{}) *GraphTraversalValue {
+	if tv.error != nil {
+		return &GraphTraversalValue{error: tv.error}
+	}
+
+	if len(keys) != 1 {
+		return &GraphTraversalValue{error: fmt.Errorf("GroupBy requires 1 parameter")}
+	}
+	key, ok := keys[0].(string)
+	if !ok {
+		return &GraphTraversalValue{error: fmt.Errorf("GroupBy parameter has to be a string key")}
+	}
+
+	groups := make(map[string][]*graph.Node)
+	for _, n := range tv.nodes {
+		var group string
+		if v, ok := n.GetField(key); ok {
+			group = fmt.Sprintf("%v", v)
+		}
+		groups[group] = append(groups[group], n)
+	}
+
+	return &GraphTraversalValue{GraphTraversal: tv.GraphTraversal, value: groups}
+}
+
+// Histogram counts the number of nodes per bucket, a bucket being the value
+// of the given key rounded down to a multiple of the bucket size
+type Histogram map[float64]int
+
+type histogramBucket struct {
+	Bucket float64 `json:"bucket"`
+	Count  int     `json:"count"`
+}
+
+type histogramBuckets []histogramBucket
+
+func (b histogramBuckets) Len() int           { return len(b) }
+func (b histogramBuckets) Swap(i, j int)      { b[i], b[j] = b[j], b[i] }
+func (b histogramBuckets) Less(i, j int) bool { return b[i].Bucket < b[j].Bucket }
+
+// MarshalJSON serializes the histogram as an array of buckets sorted by value
+func (h Histogram) MarshalJSON() ([]byte, error) {
+	buckets := make(histogramBuckets, 0, len(h))
+	for bucket, count := range h {
+		buckets = append(buckets, histogramBucket{Bucket: bucket, Count: count})
+	}
+	sort.Sort(buckets)
+
+	return json.Marshal(buckets)
+}
+
+// Histogram groups the nodes by floor(value/bucketSize)*bucketSize of the
+// given key. Nodes without the key are not counted.
+func (tv *GraphTraversalV) Histogram(s ...interface{}) *GraphTraversalValue {
+	if tv.error != nil {
+		return &GraphTraversalValue{error: tv.error}
+	}
+
+	if len(s) != 2 {
+		return &GraphTraversalValue{error: fmt.Errorf("Histogram requires 2 parameters")}
+	}
+	key, ok := s[0].(string)
+	if !ok {
+		return &GraphTraversalValue{error: fmt.Errorf("Histogram first parameter has to be a string key")}
+	}
+	size, err := common.ToFloat64(s[1])
+	if err != nil || size <= 0 {
+		return &GraphTraversalValue{error: fmt.Errorf("Histogram bucket size has to be a positive number")}
+	}
+
+	histogram := make(Histogram)
+	for _, n := range tv.nodes {
+		value, err := n.GetFieldFloat64(key)
+		if err == common.ErrFieldNotFound {
+			continue
+		} else if err != nil {
+			return &GraphTraversalValue{error: fmt.Errorf("Histogram: %s is not a numeric value: %s", key, err.Error())}
+		}
+
+		histogram[math.Floor(value/size)*size]++
+	}
+
+	return &GraphTraversalValue{GraphTraversal: tv.GraphTraversal, value: histogram}
+}
+
+// Union returns the current nodes followed by the nodes of the given
+// traversals, keeping only the first occurrence of each node
+func (tv *GraphTraversalV) Union(traversals ...*GraphTraversalV) *GraphTraversalV {
+	if tv.error != nil {
+		return tv
+	}
+
+	ntv := &GraphTraversalV{GraphTraversal: tv.GraphTraversal, nodes: []*graph.Node{}}
+	visited := make(map[graph.Identifier]bool)
+
+	for _, t := range append([]*GraphTraversalV{tv}, traversals...) {
+		if t.error != nil {
+			return &GraphTraversalV{error: t.error}
+		}
+
+		for _, n := range t.nodes {
+			if !visited[n.ID] {
+				ntv.nodes = append(ntv.nodes, n)
+				visited[n.ID] = true
+			}
+		}
+	}
+
+	return ntv
+}
+
+// Intersect returns the current nodes which are also returned by all the
+// given traversals
+func (tv *GraphTraversalV) Intersect(traversals ...*GraphTraversalV) *GraphTraversalV {
+	if tv.error != nil {
+		return tv
+	}
+
+	counts := make(map[graph.Identifier]int)
+	for _, t := range traversals {
+		if t.error != nil {
+			return &GraphTraversalV{error: t.error}
+		}
+
+		ids := make(map[graph.Identifier]bool)
+		for _, n := range t.nodes {
+			if !ids[n.ID] {
+				counts[n.ID]++
+				ids[n.ID] = true
+			}
+		}
+	}
+
+	ntv := &GraphTraversalV{GraphTraversal: tv.GraphTraversal, nodes: []*graph.Node{}}
+	for _, n := range tv.nodes {
+		if counts[n.ID] == len(traversals) {
+			ntv.nodes = append(ntv.nodes, n)
+		}
+	}
+
+	return ntv
+}
+
+// Difference returns the current nodes which are not returned by the given
+// traversal
+func (tv *GraphTraversalV) Difference(traversal *GraphTraversalV) *GraphTraversalV {
+	if tv.error != nil {
+		return tv
+	}
+
+	if traversal.error != nil {
+		return &GraphTraversalV{error: traversal.error}
+	}
+
+	excluded := make(map[graph.Identifier]bool)
+	for _, n := range traversal.nodes {
+		excluded[n.ID] = true
+	}
+
+	ntv := &GraphTraversalV{GraphTraversal: tv.GraphTraversal, nodes: []*graph.Node{}}
+	for _, n := range tv.nodes {
+		if !excluded[n.ID] {
+			ntv.nodes = append(ntv.nodes, n)
+		}
+	}
+
+	return ntv
+}
+
 func (tv *GraphTraversalV) Dedup(s ...interface{}) *GraphTraversalV {
 	if tv.error != nil {
 		return tv
@@ -625,6 +1091,32 @@ func (tv *GraphTraversalV) ShortestPathTo(m graph.Metadata, e graph.Metadata) *G
 	return sp
 }
 
+// AllPaths returns every simple path from the nodes to the nodes matching m,
+// following the edges matching e. An optional maximum number of paths can be
+// given.
+func (tv *GraphTraversalV) AllPaths(m graph.Metadata, e graph.Metadata, maxPaths ...int64) *GraphTraversalShortestPath {
+	if tv.error != nil {
+		return &GraphTraversalShortestPath{error: tv.error}
+	}
+	sp := &GraphTraversalShortestPath{GraphTraversal: tv.GraphTraversal, paths: [][]*graph.Node{}}
+
+	var max int
+	if len(maxPaths) > 0 {
+		max = int(maxPaths[0])
+	}
+
+	for _, n := range tv.nodes {
+		left := 0
+		if max > 0 {
+			if left = max - len(sp.paths); left <= 0 {
+				break
+			}
+		}
+		sp.paths = append(sp.paths, tv.GraphTraversal.Graph.LookupAllPaths(n, m, e, left)...)
+	}
+	return sp
+}
+
 func (tv *GraphTraversalV) hasKey(k string) *GraphTraversalV {
 	if tv.error != nil {
 		return tv
@@ -632,7 +1124,7 @@ func (tv *GraphTraversalV) hasKey(k string) *GraphTraversalV {
 
 	ntv := &GraphTraversalV{GraphTraversal: tv.GraphTraversal, nodes: []*graph.Node{}}
 	for _, n := range tv.nodes {
-		if _, ok := n.Metadata()[k]; ok {
+		if _, ok := n.Metadata().GetField(k); ok {
 			ntv.nodes = append(ntv.nodes, n)
 		}
 	}
@@ -664,12 +1156,145 @@ func (tv *GraphTraversalV) Has(s ...interface{}) *GraphTraversalV {
 		return &GraphTraversalV{error: err}
 	}
 
-	for _, n := range tv.nodes {
-		if it.Done() {
-			break
-		}
-		if (filter == nil || filter.Eval(n)) && it.Next() {
-			ntv.nodes = append(ntv.nodes, n)
+	match := func(n *graph.Node) bool {
+		return filter == nil || filter.Eval(n)
+	}
+
+	if tv.workers > 1 {
+		ntv.workers = tv.workers
+
+		matches := tv.evalParallel(match)
+		for i, n := range tv.nodes {
+			if it.Done() {
+				break
+			}
+			if matches[i] && it.Next() {
+				ntv.nodes = append(ntv.nodes, n)
+			}
+		}
+		return ntv
+	}
+
+	for _, n := range tv.nodes {
+		if it.Done() {
+			break
+		}
+		if match(n) && it.Next() {
+			ntv.nodes = append(ntv.nodes, n)
+		}
+	}
+
+	return ntv
+}
+
+// evalParallel evaluates match against the nodes, splitting them among the
+// workers, and returns the results in the order of the nodes
+func (tv *GraphTraversalV) evalParallel(match func(n *graph.Node) bool) []bool {
+	matches := make([]bool, len(tv.nodes))
+
+	chunk := (len(tv.nodes) + tv.workers - 1) / tv.workers
+
+	var wg sync.WaitGroup
+	for from := 0; from < len(tv.nodes); from += chunk {
+		to := from + chunk
+		if to > len(tv.nodes) {
+			to = len(tv.nodes)
+		}
+
+		wg.Add(1)
+		go func(from, to int) {
+			defer wg.Done()
+			for i := from; i < to; i++ {
+				matches[i] = match(tv.nodes[i])
+			}
+		}(from, to)
+	}
+	wg.Wait()
+
+	return matches
+}
+
+// Parallel makes the following Has steps evaluate their predicates using
+// the given number of goroutines, runtime.NumCPU() by default
+func (tv *GraphTraversalV) Parallel(s ...interface{}) *GraphTraversalV {
+	if tv.error != nil {
+		return tv
+	}
+
+	workers := runtime.NumCPU()
+	switch len(s) {
+	case 0:
+	case 1:
+		n, ok := s[0].(int64)
+		if !ok || n < 1 {
+			return &GraphTraversalV{error: fmt.Errorf("Parallel parameter has to be a positive integer")}
+		}
+		workers = int(n)
+	default:
+		return &GraphTraversalV{error: fmt.Errorf("Parallel accepts at most 1 parameter")}
+	}
+
+	return &GraphTraversalV{GraphTraversal: tv.GraphTraversal, nodes: tv.nodes, workers: workers}
+}
+
+// HasNot keeps only the nodes having none of the given keys
+func (tv *GraphTraversalV) HasNot(s ...interface{}) *GraphTraversalV {
+	if tv.error != nil {
+		return tv
+	}
+
+	keys, err := paramsToKeys("HasNot", s...)
+	if err != nil {
+		return &GraphTraversalV{error: err}
+	}
+
+	ntv := &GraphTraversalV{GraphTraversal: tv.GraphTraversal, nodes: []*graph.Node{}}
+	it := tv.GraphTraversal.currentStepContext.PaginationRange.Iterator()
+
+nodeLoop:
+	for _, n := range tv.nodes {
+		if it.Done() {
+			break
+		}
+
+		for _, k := range keys {
+			if _, ok := n.GetField(k); ok {
+				continue nodeLoop
+			}
+		}
+
+		if it.Next() {
+			ntv.nodes = append(ntv.nodes, n)
+		}
+	}
+
+	return ntv
+}
+
+// Match keeps the nodes matching at least one of the k,v pairs
+func (tv *GraphTraversalV) Match(s ...interface{}) *GraphTraversalV {
+	if tv.error != nil {
+		return tv
+	}
+
+	if len(s) == 0 {
+		return &GraphTraversalV{error: errors.New("At least one pair of parameters must be provided")}
+	}
+
+	filter, err := ParamsToOrFilter(s...)
+	if err != nil {
+		return &GraphTraversalV{error: err}
+	}
+
+	ntv := &GraphTraversalV{GraphTraversal: tv.GraphTraversal, nodes: []*graph.Node{}}
+	it := tv.GraphTraversal.currentStepContext.PaginationRange.Iterator()
+
+	for _, n := range tv.nodes {
+		if it.Done() {
+			break
+		}
+		if filter.Eval(n) && it.Next() {
+			ntv.nodes = append(ntv.nodes, n)
 		}
 	}
 
@@ -712,6 +1337,106 @@ nodeloop:
 	return ntv
 }
 
+// Repeat registers a step to be applied repeatedly by a following Until step
+func (tv *GraphTraversalV) Repeat(step func(*GraphTraversalV) *GraphTraversalV) *GraphTraversalV {
+	if tv.error != nil {
+		return tv
+	}
+
+	return &GraphTraversalV{GraphTraversal: tv.GraphTraversal, nodes: tv.nodes, repeat: step}
+}
+
+// Until applies the step given to Repeat until reaching nodes matching the
+// given metadata. Walking stops when no new node is found or after MaxRepeat
+// iterations.
+func (tv *GraphTraversalV) Until(m graph.Metadata) *GraphTraversalV {
+	if tv.error != nil {
+		return tv
+	}
+
+	if tv.repeat == nil {
+		return &GraphTraversalV{error: errors.New("Until has to be preceded by a Repeat step")}
+	}
+
+	maxRepeat := tv.GraphTraversal.MaxRepeat
+	if maxRepeat <= 0 {
+		maxRepeat = DefaultMaxRepeat
+	}
+
+	ntv := &GraphTraversalV{GraphTraversal: tv.GraphTraversal, nodes: []*graph.Node{}}
+
+	visited := make(map[graph.Identifier]bool)
+	for _, n := range tv.nodes {
+		visited[n.ID] = true
+	}
+
+	frontier := tv.nodes
+	for i := 0; i < maxRepeat && len(frontier) > 0; i++ {
+		rtv := tv.repeat(&GraphTraversalV{GraphTraversal: tv.GraphTraversal, nodes: frontier})
+		if rtv.error != nil {
+			return &GraphTraversalV{error: rtv.error}
+		}
+
+		var next []*graph.Node
+		for _, n := range rtv.nodes {
+			if visited[n.ID] {
+				continue
+			}
+			visited[n.ID] = true
+
+			if n.MatchMetadata(m) {
+				ntv.nodes = append(ntv.nodes, n)
+			} else {
+				next = append(next, n)
+			}
+		}
+		frontier = next
+	}
+
+	return ntv
+}
+
+// Coalesce applies the branches in order to the current nodes and returns the
+// result of the first one returning nodes, an empty result if none does. The
+// branches following the first non-empty one are not evaluated.
+func (tv *GraphTraversalV) Coalesce(branches ...func(*GraphTraversalV) *GraphTraversalV) *GraphTraversalV {
+	if tv.error != nil {
+		return tv
+	}
+
+	for _, branch := range branches {
+		btv := branch(&GraphTraversalV{GraphTraversal: tv.GraphTraversal, nodes: tv.nodes})
+		if btv.error != nil {
+			return &GraphTraversalV{error: btv.error}
+		}
+
+		if len(btv.nodes) > 0 {
+			return btv
+		}
+	}
+
+	return &GraphTraversalV{GraphTraversal: tv.GraphTraversal, nodes: []*graph.Node{}}
+}
+
+// Optional applies the step to the current nodes and returns its result, or
+// the current nodes unchanged if the step doesn't return any node.
+func (tv *GraphTraversalV) Optional(step func(*GraphTraversalV) *GraphTraversalV) *GraphTraversalV {
+	if tv.error != nil {
+		return tv
+	}
+
+	stv := step(&GraphTraversalV{GraphTraversal: tv.GraphTraversal, nodes: tv.nodes})
+	if stv.error != nil {
+		return &GraphTraversalV{error: stv.error}
+	}
+
+	if len(stv.nodes) == 0 {
+		return tv
+	}
+
+	return stv
+}
+
 func (tv *GraphTraversalV) Count(s ...interface{}) *GraphTraversalValue {
 	if tv.error != nil {
 		return &GraphTraversalValue{error: tv.error}
@@ -720,6 +1445,81 @@ func (tv *GraphTraversalV) Count(s ...interface{}) *GraphTraversalValue {
 	return &GraphTraversalValue{GraphTraversal: tv.GraphTraversal, value: len(tv.nodes)}
 }
 
+type nodeSorter struct {
+	nodes []*graph.Node
+	key   string
+	desc  bool
+}
+
+func (s nodeSorter) Len() int {
+	return len(s.nodes)
+}
+
+func (s nodeSorter) Swap(i, j int) {
+	s.nodes[i], s.nodes[j] = s.nodes[j], s.nodes[i]
+}
+
+func (s nodeSorter) Less(i, j int) bool {
+	vi, iok := s.nodes[i].GetField(s.key)
+	vj, jok := s.nodes[j].GetField(s.key)
+
+	// nodes without the key always sort last
+	if !iok || !jok {
+		return iok && !jok
+	}
+
+	var cmp int
+	si, iok := vi.(string)
+	sj, jok := vj.(string)
+	if iok && jok {
+		cmp = strings.Compare(si, sj)
+	} else if c, err := common.CrossTypeCompare(vi, vj); err == nil {
+		cmp = c
+	}
+
+	if s.desc {
+		return cmp > 0
+	}
+	return cmp < 0
+}
+
+func (tv *GraphTraversalV) Sort(s ...interface{}) *GraphTraversalV {
+	if tv.error != nil {
+		return tv
+	}
+
+	if len(s) == 0 || len(s) > 2 {
+		return &GraphTraversalV{error: errors.New("Sort requires a key and an optional order")}
+	}
+
+	key, ok := s[0].(string)
+	if !ok {
+		return &GraphTraversalV{error: errors.New("Sort parameter has to be a string key")}
+	}
+
+	desc := false
+	if len(s) == 2 {
+		order, ok := s[1].(string)
+		if !ok {
+			return &GraphTraversalV{error: errors.New("Sort order has to be a string")}
+		}
+
+		switch strings.ToUpper(order) {
+		case "ASC":
+		case "DESC":
+			desc = true
+		default:
+			return &GraphTraversalV{error: fmt.Errorf("Sort order must be either ASC or DESC, got: %s", order)}
+		}
+	}
+
+	nodes := make([]*graph.Node, len(tv.nodes))
+	copy(nodes, tv.nodes)
+	sort.Stable(nodeSorter{nodes: nodes, key: key, desc: desc})
+
+	return &GraphTraversalV{GraphTraversal: tv.GraphTraversal, nodes: nodes}
+}
+
 func (tv *GraphTraversalV) Range(s ...interface{}) *GraphTraversalV {
 	if tv.error != nil {
 		return &GraphTraversalV{error: tv.error}
@@ -748,16 +1548,100 @@ func (tv *GraphTraversalV) Limit(s ...interface{}) *GraphTraversalV {
 	return tv.Range(int64(0), s[0])
 }
 
+// First returns the first node of the current list, if any
+func (tv *GraphTraversalV) First(s ...interface{}) *GraphTraversalV {
+	if tv.error != nil {
+		return tv
+	}
+
+	return tv.Range(int64(0), int64(1))
+}
+
+// Last returns the last node of the current list, if any
+func (tv *GraphTraversalV) Last(s ...interface{}) *GraphTraversalV {
+	if tv.error != nil {
+		return tv
+	}
+
+	l := int64(len(tv.nodes))
+	if l == 0 {
+		return &GraphTraversalV{GraphTraversal: tv.GraphTraversal}
+	}
+	return tv.Range(l-1, l)
+}
+
+// splitDepth extracts the optional depth given as first parameter of
+// the Out and In steps, the remaining parameters being metadata pairs.
+func splitDepth(s []interface{}) (int64, []interface{}) {
+	if len(s)%2 == 1 {
+		switch depth := s[0].(type) {
+		case int:
+			return int64(depth), s[1:]
+		case int64:
+			return depth, s[1:]
+		}
+	}
+	return 1, s
+}
+
+// walk does a breadth first walk of at most depth hops, using lookup to get
+// the neighbors of a node, and returns all the visited nodes matching the
+// given metadata.
+func (tv *GraphTraversalV) walk(depth int64, metadata graph.Metadata, lookup func(n *graph.Node) []*graph.Node) *GraphTraversalV {
+	ntv := &GraphTraversalV{GraphTraversal: tv.GraphTraversal, nodes: []*graph.Node{}}
+	it := tv.GraphTraversal.currentStepContext.PaginationRange.Iterator()
+
+	visited := make(map[graph.Identifier]bool)
+	for _, n := range tv.nodes {
+		visited[n.ID] = true
+	}
+
+	frontier := tv.nodes
+	for hop := int64(0); hop < depth && len(frontier) > 0; hop++ {
+		var next []*graph.Node
+		for _, n := range frontier {
+			for _, neighbor := range lookup(n) {
+				if visited[neighbor.ID] {
+					continue
+				}
+				visited[neighbor.ID] = true
+				next = append(next, neighbor)
+
+				if !neighbor.MatchMetadata(metadata) {
+					continue
+				}
+
+				if it.Done() {
+					return ntv
+				} else if it.Next() {
+					ntv.nodes = append(ntv.nodes, neighbor)
+				}
+			}
+		}
+		frontier = next
+	}
+
+	return ntv
+}
+
 func (tv *GraphTraversalV) Out(s ...interface{}) *GraphTraversalV {
 	if tv.error != nil {
 		return tv
 	}
 
+	depth, s := splitDepth(s)
+
 	metadata, err := SliceToMetadata(s...)
 	if err != nil {
 		return &GraphTraversalV{error: err}
 	}
 
+	if depth > 1 {
+		return tv.walk(depth, metadata, func(n *graph.Node) []*graph.Node {
+			return tv.GraphTraversal.Graph.LookupChildren(n, nil, nil)
+		})
+	}
+
 	ntv := &GraphTraversalV{GraphTraversal: tv.GraphTraversal, nodes: []*graph.Node{}}
 	it := tv.GraphTraversal.currentStepContext.PaginationRange.Iterator()
 
@@ -809,11 +1693,19 @@ func (tv *GraphTraversalV) In(s ...interface{}) *GraphTraversalV {
 		return tv
 	}
 
+	depth, s := splitDepth(s)
+
 	metadata, err := SliceToMetadata(s...)
 	if err != nil {
 		return &GraphTraversalV{error: err}
 	}
 
+	if depth > 1 {
+		return tv.walk(depth, metadata, func(n *graph.Node) []*graph.Node {
+			return tv.GraphTraversal.Graph.LookupParents(n, nil, nil)
+		})
+	}
+
 	ntv := &GraphTraversalV{GraphTraversal: tv.GraphTraversal, nodes: []*graph.Node{}}
 	it := tv.GraphTraversal.currentStepContext.PaginationRange.Iterator()
 
@@ -860,6 +1752,138 @@ nodeloop:
 	return nte
 }
 
+// Subgraph returns a traversal over a new in-memory graph made of the current
+// nodes and of the edges linking them together
+func (tv *GraphTraversalV) Subgraph(s ...interface{}) *GraphTraversal {
+	if tv.error != nil {
+		return &GraphTraversal{error: tv.error}
+	}
+
+	backend, err := graph.NewMemoryBackend()
+	if err != nil {
+		return &GraphTraversal{error: err}
+	}
+	g := graph.NewGraph(tv.GraphTraversal.Graph.GetHost(), backend)
+
+	nodes := make(map[graph.Identifier]*graph.Node)
+	for _, n := range tv.nodes {
+		if _, ok := nodes[n.ID]; !ok {
+			nodes[n.ID] = g.NewNode(n.ID, n.Metadata(), n.Host())
+		}
+	}
+
+	for _, n := range tv.nodes {
+		for _, e := range tv.GraphTraversal.Graph.GetNodeEdges(n, nil) {
+			if e.GetParent() != n.ID {
+				continue
+			}
+			if child, ok := nodes[e.GetChild()]; ok {
+				g.NewEdge(e.ID, nodes[n.ID], child, e.Metadata())
+			}
+		}
+	}
+
+	return &GraphTraversal{Graph: g, MaxRepeat: tv.GraphTraversal.MaxRepeat}
+}
+
+// ToDOT returns the DOT representation of the current nodes and of the edges
+// linking them together
+func (tv *GraphTraversalV) ToDOT(s ...interface{}) *GraphTraversalValue {
+	if tv.error != nil {
+		return &GraphTraversalValue{error: tv.error}
+	}
+
+	var opts []graph.DOTOption
+	for _, param := range s {
+		key, ok := param.(string)
+		if !ok {
+			return &GraphTraversalValue{error: fmt.Errorf("ToDOT parameters have to be string keys")}
+		}
+		opts = append(opts, graph.DOTNodeAttributes(key))
+	}
+
+	sg := tv.Subgraph()
+	if sg.error != nil {
+		return &GraphTraversalValue{error: sg.error}
+	}
+
+	var buf bytes.Buffer
+	if err := sg.Graph.ExportDOT(&buf, opts...); err != nil {
+		return &GraphTraversalValue{error: err}
+	}
+
+	return &GraphTraversalValue{GraphTraversal: tv.GraphTraversal, value: buf.String()}
+}
+
+func (tv *GraphTraversalV) degree(match func(n *graph.Node, e *graph.Edge) bool) *GraphTraversalValue {
+	if tv.error != nil {
+		return &GraphTraversalValue{error: tv.error}
+	}
+
+	degrees := make(map[graph.Identifier]int64, len(tv.nodes))
+	for _, n := range tv.nodes {
+		degrees[n.ID] = 0
+		for _, e := range tv.GraphTraversal.Graph.GetNodeEdges(n, nil) {
+			if match(n, e) {
+				degrees[n.ID]++
+			}
+		}
+	}
+
+	return &GraphTraversalValue{GraphTraversal: tv.GraphTraversal, value: degrees}
+}
+
+// InDegree returns the number of incoming edges of each node, by node ID
+func (tv *GraphTraversalV) InDegree(s ...interface{}) *GraphTraversalValue {
+	return tv.degree(func(n *graph.Node, e *graph.Edge) bool { return e.GetChild() == n.ID })
+}
+
+// OutDegree returns the number of outgoing edges of each node, by node ID
+func (tv *GraphTraversalV) OutDegree(s ...interface{}) *GraphTraversalValue {
+	return tv.degree(func(n *graph.Node, e *graph.Edge) bool { return e.GetParent() == n.ID })
+}
+
+// Degree returns the number of edges of each node, by node ID
+func (tv *GraphTraversalV) Degree(s ...interface{}) *GraphTraversalValue {
+	return tv.degree(func(n *graph.Node, e *graph.Edge) bool { return true })
+}
+
+// BothE returns the incoming and outgoing edges of the nodes, each edge being
+// returned only once
+func (tv *GraphTraversalV) BothE(s ...interface{}) *GraphTraversalE {
+	if tv.error != nil {
+		return &GraphTraversalE{error: tv.error}
+	}
+
+	metadata, err := SliceToMetadata(s...)
+	if err != nil {
+		return &GraphTraversalE{GraphTraversal: tv.GraphTraversal, error: err}
+	}
+
+	nte := &GraphTraversalE{GraphTraversal: tv.GraphTraversal, edges: []*graph.Edge{}}
+	it := tv.GraphTraversal.currentStepContext.PaginationRange.Iterator()
+
+	visited := make(map[graph.Identifier]bool)
+
+nodeloop:
+	for _, n := range tv.nodes {
+		for _, e := range tv.GraphTraversal.Graph.GetNodeEdges(n, metadata) {
+			if _, ok := visited[e.ID]; ok {
+				continue
+			}
+			visited[e.ID] = true
+
+			if it.Done() {
+				break nodeloop
+			} else if it.Next() {
+				nte.edges = append(nte.edges, e)
+			}
+		}
+	}
+
+	return nte
+}
+
 func (te *GraphTraversalE) Error() error {
 	return te.error
 }
@@ -918,6 +1942,54 @@ func (te *GraphTraversalE) Limit(s ...interface{}) *GraphTraversalE {
 	return te.Range(int64(0), s[0])
 }
 
+// First returns the first edge of the current list, if any
+func (te *GraphTraversalE) First(s ...interface{}) *GraphTraversalE {
+	if te.error != nil {
+		return te
+	}
+
+	return te.Range(int64(0), int64(1))
+}
+
+// Last returns the last edge of the current list, if any
+func (te *GraphTraversalE) Last(s ...interface{}) *GraphTraversalE {
+	if te.error != nil {
+		return te
+	}
+
+	l := int64(len(te.edges))
+	if l == 0 {
+		return &GraphTraversalE{GraphTraversal: te.GraphTraversal}
+	}
+	return te.Range(l-1, l)
+}
+
+// Union returns the current edges followed by the edges of the given
+// traversals, keeping only the first occurrence of each edge
+func (te *GraphTraversalE) Union(traversals ...*GraphTraversalE) *GraphTraversalE {
+	if te.error != nil {
+		return te
+	}
+
+	nte := &GraphTraversalE{GraphTraversal: te.GraphTraversal, edges: []*graph.Edge{}}
+	visited := make(map[graph.Identifier]bool)
+
+	for _, t := range append([]*GraphTraversalE{te}, traversals...) {
+		if t.error != nil {
+			return &GraphTraversalE{error: t.error}
+		}
+
+		for _, e := range t.edges {
+			if !visited[e.ID] {
+				nte.edges = append(nte.edges, e)
+				visited[e.ID] = true
+			}
+		}
+	}
+
+	return nte
+}
+
 func (te *GraphTraversalE) Dedup(keys ...interface{}) *GraphTraversalE {
 	if te.error != nil {
 		return te
@@ -965,7 +2037,7 @@ func (te *GraphTraversalE) hasKey(k string) *GraphTraversalE {
 	for _, e := range te.edges {
 		if it.Done() {
 			break
-		} else if _, ok := e.Metadata()[k]; ok && it.Next() {
+		} else if _, ok := e.Metadata().GetField(k); ok && it.Next() {
 			nte.edges = append(nte.edges, e)
 		}
 	}
@@ -1007,6 +2079,70 @@ func (te *GraphTraversalE) Has(s ...interface{}) *GraphTraversalE {
 	return nte
 }
 
+// HasNot keeps only the edges having none of the given keys
+func (te *GraphTraversalE) HasNot(s ...interface{}) *GraphTraversalE {
+	if te.error != nil {
+		return te
+	}
+
+	keys, err := paramsToKeys("HasNot", s...)
+	if err != nil {
+		return &GraphTraversalE{error: err}
+	}
+
+	nte := &GraphTraversalE{GraphTraversal: te.GraphTraversal, edges: []*graph.Edge{}}
+	it := te.GraphTraversal.currentStepContext.PaginationRange.Iterator()
+
+edgeLoop:
+	for _, e := range te.edges {
+		if it.Done() {
+			break
+		}
+
+		for _, k := range keys {
+			if _, ok := e.GetField(k); ok {
+				continue edgeLoop
+			}
+		}
+
+		if it.Next() {
+			nte.edges = append(nte.edges, e)
+		}
+	}
+
+	return nte
+}
+
+// Match keeps the edges matching at least one of the k,v pairs
+func (te *GraphTraversalE) Match(s ...interface{}) *GraphTraversalE {
+	if te.error != nil {
+		return te
+	}
+
+	if len(s) == 0 {
+		return &GraphTraversalE{error: errors.New("At least one pair of parameters must be provided")}
+	}
+
+	filter, err := ParamsToOrFilter(s...)
+	if err != nil {
+		return &GraphTraversalE{error: err}
+	}
+
+	nte := &GraphTraversalE{GraphTraversal: te.GraphTraversal, edges: []*graph.Edge{}}
+	it := te.GraphTraversal.currentStepContext.PaginationRange.Iterator()
+
+	for _, e := range te.edges {
+		if it.Done() {
+			break
+		}
+		if filter.Eval(e) && it.Next() {
+			nte.edges = append(nte.edges, e)
+		}
+	}
+
+	return nte
+}
+
 func (te *GraphTraversalE) InV(s ...interface{}) *GraphTraversalV {
 	if te.error != nil {
 		return &GraphTraversalV{error: te.error}
@@ -1099,10 +2235,53 @@ func (t *GraphTraversalValue) Dedup(keys ...interface{}) *GraphTraversalValue {
 	ntv := &GraphTraversalValue{GraphTraversal: t.GraphTraversal, value: nv}
 	visited := make(map[interface{}]bool)
 	for _, v := range t.Values() {
-		if _, ok := visited[v]; !ok {
-			visited[v] = true
+		key, err := dedupKey(v)
+		if err != nil {
+			return &GraphTraversalValue{GraphTraversal: t.GraphTraversal, error: err}
+		}
+
+		if _, ok := visited[key]; !ok {
+			visited[key] = true
 			ntv.value = append(ntv.value.([]interface{}), v)
 		}
 	}
 	return ntv
 }
+
+// jsonKey identifies a value by its JSON serialization
+type jsonKey string
+
+// dedupKey returns the key of a value for Dedup, the values that can't be
+// hashed, like the maps returned by GroupBy or Histogram, being keyed by
+// their JSON serialization
+func dedupKey(v interface{}) (interface{}, error) {
+	if v == nil || reflect.TypeOf(v).Comparable() {
+		return v, nil
+	}
+
+	b, err := json.Marshal(v)
+	if err != nil {
+		return nil, fmt.Errorf("Dedup: unable to compare %v: %s", v, err.Error())
+	}
+	return jsonKey(b), nil
+}
+
+// Map applies fn to each value. If fn returns an error, the traversal stops
+// and the error is returned as the error of the step.
+func (t *GraphTraversalValue) Map(fn func(interface{}) interface{}) *GraphTraversalValue {
+	if t.error != nil {
+		return t
+	}
+
+	values := t.Values()
+	nv := make([]interface{}, len(values))
+	for i, v := range values {
+		r := fn(v)
+		if err, ok := r.(error); ok {
+			return &GraphTraversalValue{GraphTraversal: t.GraphTraversal, error: err}
+		}
+		nv[i] = r
+	}
+
+	return &GraphTraversalValue{GraphTraversal: t.GraphTraversal, value: nv}
+}
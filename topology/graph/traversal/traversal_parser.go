@@ -30,9 +30,16 @@ import (
 	"strconv"
 	"time"
 
+	"github.com/prometheus/client_golang/prometheus"
+
 	"github.com/skydive-project/skydive/topology/graph"
 )
 
+var gremlinQueryDuration = prometheus.NewHistogram(prometheus.HistogramOpts{
+	Name: "skydive_gremlin_query_duration_seconds",
+	Help: "Execution time of the Gremlin queries",
+})
+
 type (
 	GremlinTraversalSequence struct {
 		GraphTraversal *GraphTraversal
@@ -82,15 +89,45 @@ type (
 	GremlinTraversalStepInE struct {
 		GremlinTraversalContext
 	}
+	GremlinTraversalStepBothE struct {
+		GremlinTraversalContext
+	}
+	GremlinTraversalStepInDegree struct {
+		GremlinTraversalContext
+	}
+	GremlinTraversalStepOutDegree struct {
+		GremlinTraversalContext
+	}
+	GremlinTraversalStepDegree struct {
+		GremlinTraversalContext
+	}
+	GremlinTraversalStepSubgraph struct {
+		GremlinTraversalContext
+	}
+	GremlinTraversalStepToDOT struct {
+		GremlinTraversalContext
+	}
+	GremlinTraversalStepParallel struct {
+		GremlinTraversalContext
+	}
 	GremlinTraversalStepDedup struct {
 		GremlinTraversalContext
 	}
 	GremlinTraversalStepHas struct {
 		GremlinTraversalContext
 	}
+	GremlinTraversalStepHasNot struct {
+		GremlinTraversalContext
+	}
+	GremlinTraversalStepMatch struct {
+		GremlinTraversalContext
+	}
 	GremlinTraversalStepShortestPathTo struct {
 		GremlinTraversalContext
 	}
+	GremlinTraversalStepAllPaths struct {
+		GremlinTraversalContext
+	}
 	GremlinTraversalStepBoth struct {
 		GremlinTraversalContext
 	}
@@ -115,6 +152,27 @@ type (
 	GremlinTraversalStepSum struct {
 		GremlinTraversalContext
 	}
+	GremlinTraversalStepMin struct {
+		GremlinTraversalContext
+	}
+	GremlinTraversalStepMax struct {
+		GremlinTraversalContext
+	}
+	GremlinTraversalStepGroupBy struct {
+		GremlinTraversalContext
+	}
+	GremlinTraversalStepAvg struct {
+		GremlinTraversalContext
+	}
+	GremlinTraversalStepHistogram struct {
+		GremlinTraversalContext
+	}
+	GremlinTraversalStepFirst struct {
+		GremlinTraversalContext
+	}
+	GremlinTraversalStepLast struct {
+		GremlinTraversalContext
+	}
 )
 
 var (
@@ -162,6 +220,42 @@ func (p *GremlinTraversalContext) ReduceRange(next GremlinTraversalStep) bool {
 	return p.StepContext.PaginationRange != nil
 }
 
+// ReduceHas merges the key/value filters of a following Has step into the
+// parameters of the step, the elements being then filtered while looked up
+// instead of once the whole result built. The merge is refused when it could
+// change the result: a step already paginated, as the pagination applies to
+// the filtered elements, a Has step on a key only or a key filtered twice.
+func (p *GremlinTraversalContext) ReduceHas(next GremlinTraversalStep) bool {
+	hasStep, ok := next.(*GremlinTraversalStepHas)
+	if !ok || p.StepContext.PaginationRange != nil {
+		return false
+	}
+
+	// an odd number of parameters means a depth or a key only filter
+	if len(p.Params)%2 != 0 || len(hasStep.Params) == 0 || len(hasStep.Params)%2 != 0 {
+		return false
+	}
+
+	keys := make(map[string]bool)
+	for i := 0; i < len(p.Params); i += 2 {
+		if k, ok := p.Params[i].(string); ok {
+			keys[k] = true
+		}
+	}
+	for i := 0; i < len(hasStep.Params); i += 2 {
+		k, ok := hasStep.Params[i].(string)
+		if !ok || keys[k] {
+			return false
+		}
+		keys[k] = true
+	}
+
+	params := make([]interface{}, 0, len(p.Params)+len(hasStep.Params))
+	p.Params = append(append(params, p.Params...), hasStep.Params...)
+
+	return true
+}
+
 func (p *GremlinTraversalContext) Context() *GremlinTraversalContext {
 	return p
 }
@@ -190,8 +284,7 @@ func (s *GremlinTraversalStepV) Reduce(next GremlinTraversalStep) GremlinTravers
 		return s
 	}
 
-	if hasStep, ok := next.(*GremlinTraversalStepHas); ok && len(s.Params) == 0 && len(hasStep.Params) >= 2 {
-		s.Params = hasStep.Params
+	if s.ReduceHas(next) {
 		return s
 	}
 
@@ -260,6 +353,44 @@ func (s *GremlinTraversalStepHas) Reduce(next GremlinTraversalStep) GremlinTrave
 	return next
 }
 
+func (s *GremlinTraversalStepHasNot) Exec(last GraphTraversalStep) (GraphTraversalStep, error) {
+	switch last.(type) {
+	case *GraphTraversalV:
+		return last.(*GraphTraversalV).HasNot(s.Params...), nil
+	case *GraphTraversalE:
+		return last.(*GraphTraversalE).HasNot(s.Params...), nil
+	}
+
+	return invokeStepFnc(last, "HasNot", s)
+}
+
+func (s *GremlinTraversalStepHasNot) Reduce(next GremlinTraversalStep) GremlinTraversalStep {
+	if s.ReduceRange(next) {
+		return s
+	}
+
+	return next
+}
+
+func (s *GremlinTraversalStepMatch) Exec(last GraphTraversalStep) (GraphTraversalStep, error) {
+	switch last.(type) {
+	case *GraphTraversalV:
+		return last.(*GraphTraversalV).Match(s.Params...), nil
+	case *GraphTraversalE:
+		return last.(*GraphTraversalE).Match(s.Params...), nil
+	}
+
+	return invokeStepFnc(last, "Match", s)
+}
+
+func (s *GremlinTraversalStepMatch) Reduce(next GremlinTraversalStep) GremlinTraversalStep {
+	if s.ReduceRange(next) {
+		return s
+	}
+
+	return next
+}
+
 func (s *GremlinTraversalStepDedup) Exec(last GraphTraversalStep) (GraphTraversalStep, error) {
 	switch g := last.(type) {
 	case *GraphTraversalV:
@@ -293,8 +424,7 @@ func (s *GremlinTraversalStepOut) Exec(last GraphTraversalStep) (GraphTraversalS
 }
 
 func (s *GremlinTraversalStepOut) Reduce(next GremlinTraversalStep) GremlinTraversalStep {
-	if hasStep, ok := next.(*GremlinTraversalStepHas); ok && len(s.Params) == 0 {
-		s.Params = hasStep.Params
+	if s.ReduceHas(next) {
 		return s
 	}
 
@@ -315,8 +445,7 @@ func (s *GremlinTraversalStepIn) Exec(last GraphTraversalStep) (GraphTraversalSt
 }
 
 func (s *GremlinTraversalStepIn) Reduce(next GremlinTraversalStep) GremlinTraversalStep {
-	if hasStep, ok := next.(*GremlinTraversalStepHas); ok && len(s.Params) == 0 {
-		s.Params = hasStep.Params
+	if s.ReduceHas(next) {
 		return s
 	}
 
@@ -337,8 +466,7 @@ func (s *GremlinTraversalStepOutV) Exec(last GraphTraversalStep) (GraphTraversal
 }
 
 func (s *GremlinTraversalStepOutV) Reduce(next GremlinTraversalStep) GremlinTraversalStep {
-	if hasStep, ok := next.(*GremlinTraversalStepHas); ok && len(s.Params) == 0 {
-		s.Params = hasStep.Params
+	if s.ReduceHas(next) {
 		return s
 	}
 
@@ -359,8 +487,7 @@ func (s *GremlinTraversalStepInV) Exec(last GraphTraversalStep) (GraphTraversalS
 }
 
 func (s *GremlinTraversalStepInV) Reduce(next GremlinTraversalStep) GremlinTraversalStep {
-	if hasStep, ok := next.(*GremlinTraversalStepHas); ok && len(s.Params) == 0 {
-		s.Params = hasStep.Params
+	if s.ReduceHas(next) {
 		return s
 	}
 
@@ -381,8 +508,7 @@ func (s *GremlinTraversalStepOutE) Exec(last GraphTraversalStep) (GraphTraversal
 }
 
 func (s *GremlinTraversalStepOutE) Reduce(next GremlinTraversalStep) GremlinTraversalStep {
-	if hasStep, ok := next.(*GremlinTraversalStepHas); ok && len(s.Params) == 0 {
-		s.Params = hasStep.Params
+	if s.ReduceHas(next) {
 		return s
 	}
 
@@ -403,8 +529,28 @@ func (s *GremlinTraversalStepInE) Exec(last GraphTraversalStep) (GraphTraversalS
 }
 
 func (s *GremlinTraversalStepInE) Reduce(next GremlinTraversalStep) GremlinTraversalStep {
-	if hasStep, ok := next.(*GremlinTraversalStepHas); ok && len(s.Params) == 0 {
-		s.Params = hasStep.Params
+	if s.ReduceHas(next) {
+		return s
+	}
+
+	if s.ReduceRange(next) {
+		return s
+	}
+
+	return next
+}
+
+func (s *GremlinTraversalStepBothE) Exec(last GraphTraversalStep) (GraphTraversalStep, error) {
+	switch last.(type) {
+	case *GraphTraversalV:
+		return last.(*GraphTraversalV).BothE(s.Params...), nil
+	}
+
+	return nil, ExecutionError
+}
+
+func (s *GremlinTraversalStepBothE) Reduce(next GremlinTraversalStep) GremlinTraversalStep {
+	if s.ReduceHas(next) {
 		return s
 	}
 
@@ -437,6 +583,38 @@ func (s *GremlinTraversalStepShortestPathTo) Reduce(next GremlinTraversalStep) G
 	return next
 }
 
+func (s *GremlinTraversalStepAllPaths) Exec(last GraphTraversalStep) (GraphTraversalStep, error) {
+	switch last.(type) {
+	case *GraphTraversalV:
+		m, ok := s.Params[0].(graph.Metadata)
+		if !ok {
+			return nil, ExecutionError
+		}
+
+		var e graph.Metadata
+		if len(s.Params) > 1 {
+			if e, ok = s.Params[1].(graph.Metadata); !ok {
+				return nil, ExecutionError
+			}
+		}
+
+		if len(s.Params) > 2 {
+			max, ok := s.Params[2].(int64)
+			if !ok {
+				return nil, ExecutionError
+			}
+			return last.(*GraphTraversalV).AllPaths(m, e, max), nil
+		}
+		return last.(*GraphTraversalV).AllPaths(m, e), nil
+	}
+
+	return nil, ExecutionError
+}
+
+func (s *GremlinTraversalStepAllPaths) Reduce(next GremlinTraversalStep) GremlinTraversalStep {
+	return next
+}
+
 func (s *GremlinTraversalStepBoth) Exec(last GraphTraversalStep) (GraphTraversalStep, error) {
 	switch last.(type) {
 	case *GraphTraversalV:
@@ -447,8 +625,7 @@ func (s *GremlinTraversalStepBoth) Exec(last GraphTraversalStep) (GraphTraversal
 }
 
 func (s *GremlinTraversalStepBoth) Reduce(next GremlinTraversalStep) GremlinTraversalStep {
-	if hasStep, ok := next.(*GremlinTraversalStepHas); ok && len(s.Params) == 0 {
-		s.Params = hasStep.Params
+	if s.ReduceHas(next) {
 		return s
 	}
 
@@ -505,6 +682,11 @@ func (s *GremlinTraversalStepLimit) Reduce(next GremlinTraversalStep) GremlinTra
 }
 
 func (s *GremlinTraversalStepSort) Exec(last GraphTraversalStep) (GraphTraversalStep, error) {
+	switch last.(type) {
+	case *GraphTraversalV:
+		return last.(*GraphTraversalV).Sort(s.Params...), nil
+	}
+
 	return invokeStepFnc(last, "Sort", s)
 }
 
@@ -536,11 +718,130 @@ func (s *GremlinTraversalStepSum) Reduce(next GremlinTraversalStep) GremlinTrave
 	return next
 }
 
+func (s *GremlinTraversalStepAvg) Exec(last GraphTraversalStep) (GraphTraversalStep, error) {
+	return invokeStepFnc(last, "Avg", s)
+}
+
+func (s *GremlinTraversalStepAvg) Reduce(next GremlinTraversalStep) GremlinTraversalStep {
+	return next
+}
+
+func (s *GremlinTraversalStepMin) Exec(last GraphTraversalStep) (GraphTraversalStep, error) {
+	return invokeStepFnc(last, "Min", s)
+}
+
+func (s *GremlinTraversalStepMin) Reduce(next GremlinTraversalStep) GremlinTraversalStep {
+	return next
+}
+
+func (s *GremlinTraversalStepMax) Exec(last GraphTraversalStep) (GraphTraversalStep, error) {
+	return invokeStepFnc(last, "Max", s)
+}
+
+func (s *GremlinTraversalStepMax) Reduce(next GremlinTraversalStep) GremlinTraversalStep {
+	return next
+}
+
+func (s *GremlinTraversalStepInDegree) Exec(last GraphTraversalStep) (GraphTraversalStep, error) {
+	return invokeStepFnc(last, "InDegree", s)
+}
+
+func (s *GremlinTraversalStepInDegree) Reduce(next GremlinTraversalStep) GremlinTraversalStep {
+	return next
+}
+
+func (s *GremlinTraversalStepOutDegree) Exec(last GraphTraversalStep) (GraphTraversalStep, error) {
+	return invokeStepFnc(last, "OutDegree", s)
+}
+
+func (s *GremlinTraversalStepOutDegree) Reduce(next GremlinTraversalStep) GremlinTraversalStep {
+	return next
+}
+
+func (s *GremlinTraversalStepDegree) Exec(last GraphTraversalStep) (GraphTraversalStep, error) {
+	return invokeStepFnc(last, "Degree", s)
+}
+
+func (s *GremlinTraversalStepDegree) Reduce(next GremlinTraversalStep) GremlinTraversalStep {
+	return next
+}
+
+func (s *GremlinTraversalStepSubgraph) Exec(last GraphTraversalStep) (GraphTraversalStep, error) {
+	return invokeStepFnc(last, "Subgraph", s)
+}
+
+func (s *GremlinTraversalStepSubgraph) Reduce(next GremlinTraversalStep) GremlinTraversalStep {
+	return next
+}
+
+func (s *GremlinTraversalStepToDOT) Exec(last GraphTraversalStep) (GraphTraversalStep, error) {
+	return invokeStepFnc(last, "ToDOT", s)
+}
+
+func (s *GremlinTraversalStepToDOT) Reduce(next GremlinTraversalStep) GremlinTraversalStep {
+	return next
+}
+
+func (s *GremlinTraversalStepParallel) Exec(last GraphTraversalStep) (GraphTraversalStep, error) {
+	return invokeStepFnc(last, "Parallel", s)
+}
+
+func (s *GremlinTraversalStepParallel) Reduce(next GremlinTraversalStep) GremlinTraversalStep {
+	return next
+}
+
+func (s *GremlinTraversalStepFirst) Exec(last GraphTraversalStep) (GraphTraversalStep, error) {
+	return invokeStepFnc(last, "First", s)
+}
+
+func (s *GremlinTraversalStepFirst) Reduce(next GremlinTraversalStep) GremlinTraversalStep {
+	return next
+}
+
+func (s *GremlinTraversalStepLast) Exec(last GraphTraversalStep) (GraphTraversalStep, error) {
+	return invokeStepFnc(last, "Last", s)
+}
+
+func (s *GremlinTraversalStepLast) Reduce(next GremlinTraversalStep) GremlinTraversalStep {
+	return next
+}
+
+func (s *GremlinTraversalStepHistogram) Exec(last GraphTraversalStep) (GraphTraversalStep, error) {
+	return invokeStepFnc(last, "Histogram", s)
+}
+
+func (s *GremlinTraversalStepHistogram) Reduce(next GremlinTraversalStep) GremlinTraversalStep {
+	return next
+}
+
+func (s *GremlinTraversalStepGroupBy) Exec(last GraphTraversalStep) (GraphTraversalStep, error) {
+	return invokeStepFnc(last, "GroupBy", s)
+}
+
+func (s *GremlinTraversalStepGroupBy) Reduce(next GremlinTraversalStep) GremlinTraversalStep {
+	return next
+}
+
 func (s *GremlinTraversalSequence) Exec() (GraphTraversalStep, error) {
+	defer func(start time.Time) {
+		gremlinQueryDuration.Observe(time.Since(start).Seconds())
+	}(time.Now())
+
 	var step GremlinTraversalStep
 	var last GraphTraversalStep
 	var err error
 
+	if len(s.steps) > 0 {
+		if _, ok := s.steps[len(s.steps)-1].(*GremlinTraversalStepExplain); ok {
+			return s.Explain(), nil
+		}
+	}
+
+	var report *ProfileReport
+	if s.isProfiled() {
+		report = &ProfileReport{}
+	}
+
 	last = s.GraphTraversal
 	for i := 0; i < len(s.steps); {
 		step = s.steps[i]
@@ -551,6 +852,13 @@ func (s *GremlinTraversalSequence) Exec() (GraphTraversalStep, error) {
 			}
 		}
 
+		var profile StepProfile
+		var start time.Time
+		if report != nil {
+			profile = StepProfile{Name: stepName(step), InputCount: cardinality(last)}
+			start = time.Now()
+		}
+
 		if last, err = step.Exec(last); err != nil {
 			return nil, err
 		}
@@ -558,6 +866,13 @@ func (s *GremlinTraversalSequence) Exec() (GraphTraversalStep, error) {
 		if err := last.Error(); err != nil {
 			return nil, err
 		}
+
+		if _, ok := step.(*GremlinTraversalStepProfile); report != nil && !ok {
+			profile.Duration = time.Since(start)
+			profile.OutputCount = cardinality(last)
+			report.Steps = append(report.Steps, profile)
+			report.Duration += profile.Duration
+		}
 	}
 
 	res, ok := last.(GraphTraversalStep)
@@ -565,6 +880,14 @@ func (s *GremlinTraversalSequence) Exec() (GraphTraversalStep, error) {
 		return nil, ExecutionError
 	}
 
+	// the report is returned along with the values of the result
+	if report != nil {
+		return NewGraphTraversalValue(s.GraphTraversal, map[string]interface{}{
+			"Values":  res.Values(),
+			"Profile": report,
+		}), nil
+	}
+
 	return res, nil
 }
 
@@ -696,6 +1019,102 @@ func (p *GremlinTraversalParser) parseStepParams() ([]interface{}, error) {
 				return nil, fmt.Errorf("One parameter expected with NE: %v", neParams)
 			}
 			params = append(params, Ne(neParams[0]))
+		case NOT:
+			notParams, err := p.parseStepParams()
+			if err != nil {
+				return nil, err
+			}
+			if len(notParams) != 1 {
+				return nil, fmt.Errorf("One parameter expected with NOT: %v", notParams)
+			}
+			params = append(params, Not(notParams[0]))
+		case CONTAINS:
+			containsParams, err := p.parseStepParams()
+			if err != nil {
+				return nil, err
+			}
+			if len(containsParams) != 1 {
+				return nil, fmt.Errorf("One parameter expected with CONTAINS: %v", containsParams)
+			}
+			param, ok := containsParams[0].(string)
+			if !ok {
+				return nil, fmt.Errorf("CONTAINS predicate expects a string as parameter, got: %s", lit)
+			}
+			params = append(params, Contains(param))
+		case STARTSWITH:
+			startsWithParams, err := p.parseStepParams()
+			if err != nil {
+				return nil, err
+			}
+			if len(startsWithParams) != 1 {
+				return nil, fmt.Errorf("One parameter expected with STARTSWITH: %v", startsWithParams)
+			}
+			param, ok := startsWithParams[0].(string)
+			if !ok {
+				return nil, fmt.Errorf("STARTSWITH predicate expects a string as parameter, got: %s", lit)
+			}
+			params = append(params, StartsWith(param))
+		case ENDSWITH:
+			endsWithParams, err := p.parseStepParams()
+			if err != nil {
+				return nil, err
+			}
+			if len(endsWithParams) != 1 {
+				return nil, fmt.Errorf("One parameter expected with ENDSWITH: %v", endsWithParams)
+			}
+			param, ok := endsWithParams[0].(string)
+			if !ok {
+				return nil, fmt.Errorf("ENDSWITH predicate expects a string as parameter, got: %s", lit)
+			}
+			params = append(params, EndsWith(param))
+		case IPNET:
+			ipnetParams, err := p.parseStepParams()
+			if err != nil {
+				return nil, err
+			}
+			if len(ipnetParams) != 1 {
+				return nil, fmt.Errorf("One parameter expected with IPNET: %v", ipnetParams)
+			}
+			param, ok := ipnetParams[0].(string)
+			if !ok {
+				return nil, fmt.Errorf("IPNET predicate expects a string as parameter, got: %s", lit)
+			}
+			matcher, err := IPNet(param)
+			if err != nil {
+				return nil, err
+			}
+			params = append(params, matcher)
+		case ARRAYCONTAINS:
+			arrayContainsParams, err := p.parseStepParams()
+			if err != nil {
+				return nil, err
+			}
+			if len(arrayContainsParams) != 1 {
+				return nil, fmt.Errorf("One parameter expected with ARRAYCONTAINS: %v", arrayContainsParams)
+			}
+			param, ok := arrayContainsParams[0].(string)
+			if !ok {
+				return nil, fmt.Errorf("ARRAYCONTAINS predicate expects a string as parameter, got: %s", lit)
+			}
+			params = append(params, ArrayContains(param))
+		case ISNULL:
+			isNullParams, err := p.parseStepParams()
+			if err != nil {
+				return nil, err
+			}
+			if len(isNullParams) != 0 {
+				return nil, fmt.Errorf("No parameter expected with ISNULL: %v", isNullParams)
+			}
+			params = append(params, IsNull())
+		case ISNOTNULL:
+			isNotNullParams, err := p.parseStepParams()
+			if err != nil {
+				return nil, err
+			}
+			if len(isNotNullParams) != 0 {
+				return nil, fmt.Errorf("No parameter expected with ISNOTNULL: %v", isNotNullParams)
+			}
+			params = append(params, IsNotNull())
 		case REGEX:
 			regexParams, err := p.parseStepParams()
 			if err != nil {
@@ -774,6 +1193,59 @@ func (p *GremlinTraversalParser) parserStep() (GremlinTraversalStep, error) {
 		return &GremlinTraversalStepOutE{gremlinStepContext}, nil
 	case INE:
 		return &GremlinTraversalStepInE{gremlinStepContext}, nil
+	case BOTHE:
+		return &GremlinTraversalStepBothE{gremlinStepContext}, nil
+	case INDEGREE:
+		return &GremlinTraversalStepInDegree{gremlinStepContext}, nil
+	case OUTDEGREE:
+		return &GremlinTraversalStepOutDegree{gremlinStepContext}, nil
+	case DEGREE:
+		return &GremlinTraversalStepDegree{gremlinStepContext}, nil
+	case SUBGRAPH:
+		return &GremlinTraversalStepSubgraph{gremlinStepContext}, nil
+	case TODOT:
+		for _, param := range params {
+			if _, ok := param.(string); !ok {
+				return nil, fmt.Errorf("ToDOT parameters have to be string keys")
+			}
+		}
+		return &GremlinTraversalStepToDOT{gremlinStepContext}, nil
+	case EXPLAIN:
+		if len(params) != 0 {
+			return nil, fmt.Errorf("Explain doesn't accept any parameter")
+		}
+		return &GremlinTraversalStepExplain{gremlinStepContext}, nil
+	case AS:
+		if len(params) != 1 {
+			return nil, fmt.Errorf("As requires a label")
+		}
+		if _, ok := params[0].(string); !ok {
+			return nil, fmt.Errorf("As label has to be a string")
+		}
+		return &GremlinTraversalStepAs{gremlinStepContext}, nil
+	case SELECT:
+		for _, param := range params {
+			if _, ok := param.(string); !ok {
+				return nil, fmt.Errorf("Select parameters have to be string labels")
+			}
+		}
+		return &GremlinTraversalStepSelect{gremlinStepContext}, nil
+	case PROFILE:
+		if len(params) != 0 {
+			return nil, fmt.Errorf("Profile doesn't accept any parameter")
+		}
+		return &GremlinTraversalStepProfile{gremlinStepContext}, nil
+	case PARALLEL:
+		switch len(params) {
+		case 0:
+		case 1:
+			if _, ok := params[0].(int64); !ok {
+				return nil, fmt.Errorf("Parallel parameter has to be an integer")
+			}
+		default:
+			return nil, fmt.Errorf("Parallel accepts at most 1 parameter")
+		}
+		return &GremlinTraversalStepParallel{gremlinStepContext}, nil
 	case DEDUP:
 		for _, param := range params {
 			if _, ok := param.(string); !ok {
@@ -783,11 +1255,31 @@ func (p *GremlinTraversalParser) parserStep() (GremlinTraversalStep, error) {
 		return &GremlinTraversalStepDedup{gremlinStepContext}, nil
 	case HAS:
 		return &GremlinTraversalStepHas{gremlinStepContext}, nil
+	case MATCH:
+		if len(params) == 0 || len(params)%2 != 0 {
+			return nil, fmt.Errorf("Match requires pairs of parameters")
+		}
+		return &GremlinTraversalStepMatch{gremlinStepContext}, nil
+	case HASNOT:
+		if len(params) == 0 {
+			return nil, fmt.Errorf("HasNot requires at least 1 parameter")
+		}
+		for _, param := range params {
+			if _, ok := param.(string); !ok {
+				return nil, fmt.Errorf("HasNot parameters have to be string keys")
+			}
+		}
+		return &GremlinTraversalStepHasNot{gremlinStepContext}, nil
 	case SHORTESTPATHTO:
 		if len(params) == 0 || len(params) > 2 {
 			return nil, fmt.Errorf("ShortestPathTo predicate accepts only 1 or 2 parameters")
 		}
 		return &GremlinTraversalStepShortestPathTo{gremlinStepContext}, nil
+	case ALLPATHS:
+		if len(params) == 0 || len(params) > 3 {
+			return nil, fmt.Errorf("AllPaths predicate accepts only 1 to 3 parameters")
+		}
+		return &GremlinTraversalStepAllPaths{gremlinStepContext}, nil
 	case BOTH:
 		return &GremlinTraversalStepBoth{gremlinStepContext}, nil
 	case CONTEXT:
@@ -801,13 +1293,15 @@ func (p *GremlinTraversalParser) parserStep() (GremlinTraversalStep, error) {
 		switch len(params) {
 		case 0:
 			return &GremlinTraversalStepSort{gremlinStepContext}, nil
-		case 1:
-			if _, ok := params[0].(string); !ok {
-				return nil, fmt.Errorf("Sort parameter has to be a string key")
+		case 1, 2:
+			for _, param := range params {
+				if _, ok := param.(string); !ok {
+					return nil, fmt.Errorf("Sort parameters have to be strings")
+				}
 			}
 			return &GremlinTraversalStepSort{gremlinStepContext}, nil
 		default:
-			return nil, fmt.Errorf("Sort accepts at most 1 string parameter")
+			return nil, fmt.Errorf("Sort accepts at most 2 string parameters")
 		}
 	case RANGE:
 		if len(params) != 2 {
@@ -831,6 +1325,38 @@ func (p *GremlinTraversalParser) parserStep() (GremlinTraversalStep, error) {
 		return &GremlinTraversalStepKeys{gremlinStepContext}, nil
 	case SUM:
 		return &GremlinTraversalStepSum{gremlinStepContext}, nil
+	case AVG:
+		return &GremlinTraversalStepAvg{gremlinStepContext}, nil
+	case MIN:
+		return &GremlinTraversalStepMin{gremlinStepContext}, nil
+	case MAX:
+		return &GremlinTraversalStepMax{gremlinStepContext}, nil
+	case GROUPBY:
+		if len(params) != 1 {
+			return nil, fmt.Errorf("GroupBy requires 1 parameter")
+		}
+		if _, ok := params[0].(string); !ok {
+			return nil, fmt.Errorf("GroupBy parameter has to be a string key")
+		}
+		return &GremlinTraversalStepGroupBy{gremlinStepContext}, nil
+	case HISTOGRAM:
+		if len(params) != 2 {
+			return nil, fmt.Errorf("Histogram requires 2 parameters")
+		}
+		if _, ok := params[0].(string); !ok {
+			return nil, fmt.Errorf("Histogram first parameter has to be a string key")
+		}
+		return &GremlinTraversalStepHistogram{gremlinStepContext}, nil
+	case FIRST:
+		if len(params) != 0 {
+			return nil, fmt.Errorf("First doesn't accept any parameter")
+		}
+		return &GremlinTraversalStepFirst{gremlinStepContext}, nil
+	case LAST:
+		if len(params) != 0 {
+			return nil, fmt.Errorf("Last doesn't accept any parameter")
+		}
+		return &GremlinTraversalStepLast{gremlinStepContext}, nil
 	}
 
 	// extensions
@@ -901,3 +1427,7 @@ func (p *GremlinTraversalParser) scanIgnoreWhitespace() (Token, string) {
 func (p *GremlinTraversalParser) unscan() {
 	p.buf.n = 1
 }
+
+func init() {
+	prometheus.MustRegister(gremlinQueryDuration)
+}
@@ -83,6 +83,35 @@ const (
 	VALUES
 	KEYS
 	SUM
+	MIN
+	MAX
+	GROUPBY
+	AVG
+	HASNOT
+	NOT
+	ALLPATHS
+	CONTAINS
+	STARTSWITH
+	ENDSWITH
+	IPNET
+	MATCH
+	HISTOGRAM
+	FIRST
+	LAST
+	BOTHE
+	INDEGREE
+	OUTDEGREE
+	DEGREE
+	SUBGRAPH
+	TODOT
+	PARALLEL
+	EXPLAIN
+	PROFILE
+	AS
+	SELECT
+	ISNULL
+	ISNOTNULL
+	ARRAYCONTAINS
 
 	// extensions token have to start after 1000
 )
@@ -261,6 +290,64 @@ func (s *GremlinTraversalScanner) scanIdent() (tok Token, lit string) {
 		return KEYS, buf.String()
 	case "SUM":
 		return SUM, buf.String()
+	case "MIN":
+		return MIN, buf.String()
+	case "MAX":
+		return MAX, buf.String()
+	case "GROUPBY":
+		return GROUPBY, buf.String()
+	case "AVG":
+		return AVG, buf.String()
+	case "HASNOT":
+		return HASNOT, buf.String()
+	case "NOT":
+		return NOT, buf.String()
+	case "ALLPATHS":
+		return ALLPATHS, buf.String()
+	case "CONTAINS":
+		return CONTAINS, buf.String()
+	case "STARTSWITH":
+		return STARTSWITH, buf.String()
+	case "ENDSWITH":
+		return ENDSWITH, buf.String()
+	case "IPNET":
+		return IPNET, buf.String()
+	case "MATCH":
+		return MATCH, buf.String()
+	case "HISTOGRAM":
+		return HISTOGRAM, buf.String()
+	case "FIRST":
+		return FIRST, buf.String()
+	case "LAST":
+		return LAST, buf.String()
+	case "BOTHE":
+		return BOTHE, buf.String()
+	case "INDEGREE":
+		return INDEGREE, buf.String()
+	case "OUTDEGREE":
+		return OUTDEGREE, buf.String()
+	case "DEGREE":
+		return DEGREE, buf.String()
+	case "SUBGRAPH":
+		return SUBGRAPH, buf.String()
+	case "TODOT":
+		return TODOT, buf.String()
+	case "PARALLEL":
+		return PARALLEL, buf.String()
+	case "EXPLAIN":
+		return EXPLAIN, buf.String()
+	case "PROFILE":
+		return PROFILE, buf.String()
+	case "AS":
+		return AS, buf.String()
+	case "SELECT":
+		return SELECT, buf.String()
+	case "ISNULL":
+		return ISNULL, buf.String()
+	case "ISNOTNULL":
+		return ISNOTNULL, buf.String()
+	case "ARRAYCONTAINS":
+		return ARRAYCONTAINS, buf.String()
 	}
 
 	for _, e := range s.extensions {
@@ -23,9 +23,15 @@
 package traversal
 
 import (
+	"encoding/json"
+	"errors"
+	"reflect"
 	"strings"
 	"testing"
 
+	"golang.org/x/net/context"
+
+	"github.com/skydive-project/skydive/common"
 	"github.com/skydive-project/skydive/topology/graph"
 )
 
@@ -38,25 +44,22 @@ func newGraph(t *testing.T) *graph.Graph {
 	return graph.NewGraphFromConfig(b)
 }
 
-func newTransversalGraph(t *testing.T) *graph.Graph {
-	g := newGraph(t)
-
-	n1 := g.NewNode(graph.GenID(), graph.Metadata{"Value": 1, "Type": "intf", "Bytes": 1024})
-	n2 := g.NewNode(graph.GenID(), graph.Metadata{"Value": 2, "Type": "intf", "Bytes": 2024})
-	n3 := g.NewNode(graph.GenID(), graph.Metadata{"Value": 3})
-	n4 := g.NewNode(graph.GenID(), graph.Metadata{"Value": 4, "Name": "Node4", "Bytes": 4024})
-
-	g.Link(n1, n2, graph.Metadata{"Direction": "Left", "Name": "e1"})
-	g.Link(n2, n3, graph.Metadata{"Direction": "Left", "Name": "e2"})
-	g.Link(n3, n4, graph.Metadata{"Name": "e3"})
-	g.Link(n1, n4, graph.Metadata{"Name": "e4"})
-	g.Link(n1, n3, graph.Metadata{"Mode": "Direct", "Name": "e5"})
-
-	return g
+func newTransversalGraph() *graph.Graph {
+	return graph.NewMockBuilder().
+		AddNode("n1", graph.Metadata{"Value": 1, "Type": "intf", "Bytes": 1024}).
+		AddNode("n2", graph.Metadata{"Value": 2, "Type": "intf", "Bytes": 2024}).
+		AddNode("n3", graph.Metadata{"Value": 3}).
+		AddNode("n4", graph.Metadata{"Value": 4, "Name": "Node4", "Bytes": 4024}).
+		AddEdge("n1", "n2", graph.Metadata{"Direction": "Left", "Name": "e1"}).
+		AddEdge("n2", "n3", graph.Metadata{"Direction": "Left", "Name": "e2"}).
+		AddEdge("n3", "n4", graph.Metadata{"Name": "e3"}).
+		AddEdge("n1", "n4", graph.Metadata{"Name": "e4"}).
+		AddEdge("n1", "n3", graph.Metadata{"Mode": "Direct", "Name": "e5"}).
+		Build()
 }
 
 func TestBasicTraversal(t *testing.T) {
-	g := newTransversalGraph(t)
+	g := newTransversalGraph()
 
 	tr := NewGraphTraversal(g)
 
@@ -155,87 +158,134 @@ func TestBasicTraversal(t *testing.T) {
 		t.Logf("Error in Sum() step: %s", sum.Error())
 	}
 
-}
-
-func TestTraversalWithin(t *testing.T) {
-	g := newTransversalGraph(t)
+	avg := tr.V().Avg("Bytes")
+	if avg.Error() != nil {
+		t.Fatal(avg.Error())
+	}
 
-	tr := NewGraphTraversal(g)
+	if avg.Values()[0] != float64(7072)/3 {
+		t.Fatalf("Should return 2357.33, instead got %v", avg.Values())
+	}
 
-	tv := tr.V().Has("Value", Within(1, 2, 4))
-	if len(tv.Values()) != 3 {
-		t.Fatalf("Should return 3 nodes, returned: %v", tv.Values())
+	avg = tr.V().Avg("Unknown")
+	if avg.Error() != nil || avg.Values()[0] != float64(0) {
+		t.Fatalf("Should return 0, instead got %v", avg.Values())
 	}
+
 }
 
-func TestTraversalLt(t *testing.T) {
-	g := newTransversalGraph(t)
+func TestTraversalMinMax(t *testing.T) {
+	g := newTransversalGraph()
 
 	tr := NewGraphTraversal(g)
 
-	tv := tr.V().Has("Value", Lt(3))
-	if len(tv.Values()) != 2 {
-		t.Fatalf("Should return 2 nodes, returned: %v", tv.Values())
+	min := tr.V().Min("Bytes")
+	if min.Error() != nil {
+		t.Fatal(min.Error())
 	}
-}
 
-func TestTraversalGt(t *testing.T) {
-	g := newTransversalGraph(t)
+	if min.Values()[0] != float64(1024) {
+		t.Fatalf("Should return 1024, returned: %v", min.Values())
+	}
 
-	tr := NewGraphTraversal(g)
+	max := tr.V().Max("Bytes")
+	if max.Error() != nil {
+		t.Fatal(max.Error())
+	}
 
-	tv := tr.V().Has("Value", Gt(3))
-	if len(tv.Values()) != 1 {
-		t.Fatalf("Should return 1 nodes, returned: %v", tv.Values())
+	if max.Values()[0] != float64(4024) {
+		t.Fatalf("Should return 4024, returned: %v", max.Values())
+	}
+
+	// next test
+	if max = tr.V().Max("Unknown"); max.Error() == nil {
+		t.Fatalf("Should return an error for an unknown key, returned: %v", max.Values())
+	}
+
+	// next test
+	if max = tr.V().Max("Type"); max.Error() == nil {
+		t.Fatalf("Should return an error for a non numeric key, returned: %v", max.Values())
 	}
 }
 
-func TestTraversalLte(t *testing.T) {
-	g := newTransversalGraph(t)
+func TestTraversalComparisons(t *testing.T) {
+	g := newTransversalGraph()
 
 	tr := NewGraphTraversal(g)
 
-	tv := tr.V().Has("Value", Lte(3))
-	if len(tv.Values()) != 3 {
-		t.Fatalf("Should return 3 nodes, returned: %v", tv.Values())
+	tcs := []TraversalTestCase{
+		{tr.V().Has("Value", Within(1, 2, 4)), []interface{}{graph.Identifier("n1"), graph.Identifier("n2"), graph.Identifier("n4")}},
+		{tr.V().Has("Value", Lt(3)), []interface{}{graph.Identifier("n1"), graph.Identifier("n2")}},
+		{tr.V().Has("Value", Gt(3)), []interface{}{graph.Identifier("n4")}},
+		{tr.V().Has("Value", Lte(3)), []interface{}{graph.Identifier("n1"), graph.Identifier("n2"), graph.Identifier("n3")}},
+		{tr.V().Has("Value", Gte(3)), []interface{}{graph.Identifier("n3"), graph.Identifier("n4")}},
+		{tr.V().Has("Value", Inside(1, 4)), []interface{}{graph.Identifier("n2"), graph.Identifier("n3")}},
+		{tr.V().Has("Value", Between(1, 4)), []interface{}{graph.Identifier("n1"), graph.Identifier("n2"), graph.Identifier("n3")}},
+	}
+
+	for _, tc := range tcs {
+		AssertTraversal(t, tc)
 	}
 }
 
-func TestTraversalGte(t *testing.T) {
-	g := newTransversalGraph(t)
+func TestTraversalFloat(t *testing.T) {
+	g := newTransversalGraph()
 
 	tr := NewGraphTraversal(g)
 
-	tv := tr.V().Has("Value", Gte(3))
+	tv := tr.V().Has("Value", Lt(2.5))
 	if len(tv.Values()) != 2 {
 		t.Fatalf("Should return 2 nodes, returned: %v", tv.Values())
 	}
-}
 
-func TestTraversalInside(t *testing.T) {
-	g := newTransversalGraph(t)
+	// next test
+	tv = tr.V().Has("Value", Gte(3.5))
+	if len(tv.Values()) != 1 {
+		t.Fatalf("Should return 1 node, returned: %v", tv.Values())
+	}
 
-	tr := NewGraphTraversal(g)
+	// next test
+	tv = tr.V().Has("Value", Between(1.5, 4))
+	if len(tv.Values()) != 2 {
+		t.Fatalf("Should return 2 nodes, returned: %v", tv.Values())
+	}
 
-	tv := tr.V().Has("Value", Inside(1, 4))
+	// next test
+	tv = tr.V().Has("Value", Outside(1.5, 3.5))
 	if len(tv.Values()) != 2 {
 		t.Fatalf("Should return 2 nodes, returned: %v", tv.Values())
 	}
 }
 
-func TestTraversalBetween(t *testing.T) {
-	g := newTransversalGraph(t)
+func TestTraversalOutside(t *testing.T) {
+	g := newTransversalGraph()
 
 	tr := NewGraphTraversal(g)
 
-	tv := tr.V().Has("Value", Between(1, 4))
-	if len(tv.Values()) != 3 {
-		t.Fatalf("Should return 3 nodes, returned: %v", tv.Values())
+	// the int and the float bounds select the same nodes
+	ints := tr.V().Has("Value", Outside(2, 3))
+	if len(ints.Values()) != 2 {
+		t.Fatalf("Should return 2 nodes, returned: %v", ints.Values())
+	}
+
+	floats := tr.V().Has("Value", Outside(2.0, 3.0))
+	if len(floats.Values()) != 2 {
+		t.Fatalf("Should return 2 nodes, returned: %v", floats.Values())
+	}
+
+	ids := make(map[graph.Identifier]bool)
+	for _, node := range ints.Values() {
+		ids[node.(*graph.Node).ID] = true
+	}
+	for _, node := range floats.Values() {
+		if !ids[node.(*graph.Node).ID] {
+			t.Fatalf("Should return the same nodes, returned: %v and %v", ints.Values(), floats.Values())
+		}
 	}
 }
 
 func TestTraversalNe(t *testing.T) {
-	g := newTransversalGraph(t)
+	g := newTransversalGraph()
 
 	tr := NewGraphTraversal(g)
 
@@ -252,8 +302,26 @@ func TestTraversalNe(t *testing.T) {
 	}
 }
 
+func TestTraversalNot(t *testing.T) {
+	g := newTransversalGraph()
+
+	tr := NewGraphTraversal(g)
+
+	// next test
+	tv := tr.V().Has("Value", Not(Within(1, 2)))
+	if len(tv.Values()) != 2 {
+		t.Fatalf("Should return 2 nodes, returned: %v", tv.Values())
+	}
+
+	// next test
+	tv = tr.V().Has("Type", Not("intf"))
+	if len(tv.Values()) != 2 {
+		t.Fatalf("Should return 2 nodes, returned: %v", tv.Values())
+	}
+}
+
 func TestTraversalRegex(t *testing.T) {
-	g := newTransversalGraph(t)
+	g := newTransversalGraph()
 
 	tr := NewGraphTraversal(g)
 
@@ -270,206 +338,1213 @@ func TestTraversalRegex(t *testing.T) {
 	}
 }
 
-func TestTraversalBoth(t *testing.T) {
-	g := newTransversalGraph(t)
+func TestTraversalHasNot(t *testing.T) {
+	g := newTransversalGraph()
 
 	tr := NewGraphTraversal(g)
 
-	// next test
-	tv := tr.V().Has("Value", 2).Both()
+	tv := tr.V().HasNot("Type")
 	if len(tv.Values()) != 2 {
 		t.Fatalf("Should return 2 nodes, returned: %v", tv.Values())
 	}
+
+	// next test
+	tv = tr.V().HasNot("Type", "Name")
+	if len(tv.Values()) != 1 {
+		t.Fatalf("Should return 1 node, returned: %v", tv.Values())
+	}
+
+	// next test
+	te := tr.V().Has("Value", 1).OutE().HasNot("Direction")
+	if len(te.Values()) != 2 {
+		t.Fatalf("Should return 2 edges, returned: %v", te.Values())
+	}
 }
 
-func TestTraversalCount(t *testing.T) {
-	g := newTransversalGraph(t)
+func TestTraversalContains(t *testing.T) {
+	g := newTransversalGraph()
 
 	tr := NewGraphTraversal(g)
 
 	// next test
-	tv := tr.V().Count()
-	if tv.Values()[0] != 4 {
-		t.Fatalf("Should return 4 nodes, returned: %v", tv.Values())
+	tv := tr.V().Has("Name", Contains("ode"))
+	if len(tv.Values()) != 1 {
+		t.Fatalf("Should return 1 node, returned: %v", tv.Values())
+	}
+
+	// next test
+	tv = tr.V().Has("Name", Contains("ODE"))
+	if len(tv.Values()) != 0 {
+		t.Fatalf("Shouldn't return node, returned: %v", tv.Values())
 	}
 }
 
-func TestTraversalShortestPathTo(t *testing.T) {
-	g := newTransversalGraph(t)
+func TestTraversalStartsEndsWith(t *testing.T) {
+	g := newTransversalGraph()
 
 	tr := NewGraphTraversal(g)
 
-	tv := tr.V().Has("Value", 1).ShortestPathTo(graph.Metadata{"Value": 3}, nil)
+	// next test
+	tv := tr.V().Has("Name", StartsWith("Node"))
 	if len(tv.Values()) != 1 {
-		t.Fatalf("Should return 1 path, returned: %v", tv.Values())
+		t.Fatalf("Should return 1 node, returned: %v", tv.Values())
 	}
 
-	path := tv.Values()[0].([]*graph.Node)
-	if len(path) != 2 {
-		t.Fatalf("Should return a path len of 2, returned: %v", len(path))
+	// next test
+	tv = tr.V().Has("Name", StartsWith("ode"))
+	if len(tv.Values()) != 0 {
+		t.Fatalf("Shouldn't return node, returned: %v", tv.Values())
 	}
 
 	// next test
-	tv = tr.V().Has("Value", Within(1, 2)).ShortestPathTo(graph.Metadata{"Value": 3}, nil)
+	tv = tr.V().Has("Type", EndsWith("tf"))
 	if len(tv.Values()) != 2 {
-		t.Fatalf("Should return 2 paths, returned: %v", tv.Values())
+		t.Fatalf("Should return 2 nodes, returned: %v", tv.Values())
 	}
+}
 
-	path = tv.Values()[0].([]*graph.Node)
-	if len(path) != 2 {
-		t.Fatalf("Should return a path len of 2, returned: %v", len(path))
-	}
+func TestTraversalIPNet(t *testing.T) {
+	g := newGraph(t)
 
-	// next test
-	tv = tr.V().Has("Value", 1).ShortestPathTo(graph.Metadata{"Value": 3}, graph.Metadata{"Direction": "Left"})
-	if len(tv.Values()) != 1 {
-		t.Fatalf("Should return 1 path, returned: %v", tv.Values())
-	}
+	g.NewNode(graph.GenID(), graph.Metadata{"Name": "intf1", "IPV4": "10.0.0.1"})
+	g.NewNode(graph.GenID(), graph.Metadata{"Name": "intf2", "IPV4": "192.168.0.1,10.0.1.1"})
+	g.NewNode(graph.GenID(), graph.Metadata{"Name": "intf3", "IPV4": "192.168.0.2"})
+	g.NewNode(graph.GenID(), graph.Metadata{"Name": "intf4", "IPV4": "10.1.0.1/16"})
 
-	path = tv.Values()[0].([]*graph.Node)
-	if len(path) != 3 {
-		t.Fatalf("Should return a path len of 3, returned: %v", len(path))
-	}
-}
+	tr := NewGraphTraversal(g)
 
-func execTraversalQuery(t *testing.T, g *graph.Graph, query string) GraphTraversalStep {
-	ts, err := NewGremlinTraversalParser(g).Parse(strings.NewReader(query))
+	ipnet, err := IPNet("10.0.0.0/8")
 	if err != nil {
-		t.Fatalf("%s: %s", query, err.Error())
+		t.Fatal(err)
 	}
 
-	res, err := ts.Exec()
-	if err != nil {
-		t.Fatalf("%s: %s", query, err.Error())
+	tv := tr.V().Has("IPV4", ipnet)
+	if len(tv.Values()) != 3 {
+		t.Fatalf("Should return 3 nodes, returned: %v", tv.Values())
 	}
 
-	return res
-}
-
-func TestTraversalParser(t *testing.T) {
-	g := newTransversalGraph(t)
+	// next test
+	if _, err = IPNet("10.0.0.0/33"); err == nil {
+		t.Fatal("Should return an error for an invalid CIDR")
+	}
 
-	// next traversal test
-	query := `G.V().Has("Type", "intf")`
+	// next test
+	query := `G.V().Has("IPV4", IPNet("192.168.0.0/16"))`
 	res := execTraversalQuery(t, g, query)
 	if len(res.Values()) != 2 {
 		t.Fatalf("Should return 2 nodes, returned: %v", res.Values())
 	}
+}
 
-	// next traversal test
-	query = `G.V().Count()`
-	res = execTraversalQuery(t, g, query)
-	if res.Values()[0] != 4 {
-		t.Fatalf("Should return 4, returned: %v", res.Values())
-	}
+func TestTraversalArrayContains(t *testing.T) {
+	g := newGraph(t)
 
-	// next traversal test
-	query = `G.V().Has("Value", 1).Out().Has("Name", "Node4")`
-	res = execTraversalQuery(t, g, query)
-	if len(res.Values()) != 1 {
-		t.Fatalf("Should return 1 nodes, returned: %v", res.Values())
-	}
+	g.NewNode(graph.GenID(), graph.Metadata{"Name": "br1", "BridgePorts": []string{"eth0", "eth1"}})
+	g.NewNode(graph.GenID(), graph.Metadata{"Name": "br2", "BridgePorts": []interface{}{"eth1", "eth2"}})
+	g.NewNode(graph.GenID(), graph.Metadata{"Name": "br3", "BridgePorts": "eth1"})
 
-	// next traversal test
-	query = `G.V().Has("Value", 1).Out().Has("Value", 2).OutE().Has("Direction", "Left").OutV().Out()`
-	res = execTraversalQuery(t, g, query)
-	if len(res.Values()) != 1 {
-		t.Fatalf("should return 1 node, returned: %d, %v", len(res.Values()), res.Values())
-	}
+	tr := NewGraphTraversal(g)
 
-	node := res.Values()[0].(*graph.Node)
-	if name, _ := node.GetFieldString("Name"); name != "Node4" {
-		t.Fatalf("Should return Node4, returned: %v", res.Values())
+	tv := tr.V().Has("BridgePorts", ArrayContains("eth1"))
+	if len(tv.Values()) != 2 {
+		t.Fatalf("Should return 2 nodes, returned: %v", tv.Values())
 	}
 
-	// next traversal test
-	query = `G.V().Has("Type")`
-	res = execTraversalQuery(t, g, query)
-	if len(res.Values()) != 2 {
-		t.Fatalf("Should return 2 nodes, returned: %v", res.Values())
+	// next test
+	query := `G.V().Has("BridgePorts", ArrayContains("eth2"))`
+	res := execTraversalQuery(t, g, query)
+	if len(res.Values()) != 1 {
+		t.Fatalf("Should return 1 node, returned: %v", res.Values())
 	}
+}
 
-	// next traversal test
-	query = `G.V().Out().Has("Value", 4)`
-	res = execTraversalQuery(t, g, query)
-	if len(res.Values()) != 2 {
-		t.Fatalf("Should return 2 nodes, returned: %v", res.Values())
-	}
+func TestTraversalIsNull(t *testing.T) {
+	g := newTransversalGraph()
 
-	// next traversal test
-	query = `G.V().Out().Has("Value", 4).Dedup()`
-	res = execTraversalQuery(t, g, query)
-	if len(res.Values()) != 1 {
-		t.Fatalf("Should return 1 nodes, returned: %v", res.Values())
-	}
+	tr := NewGraphTraversal(g)
 
-	// next traversal test
-	query = `G.V().Dedup("Type")`
-	res = execTraversalQuery(t, g, query)
-	if len(res.Values()) != 1 {
-		t.Fatalf("Should return 1 nodes, returned: %v", res.Values())
+	tv := tr.V().Has("Type", IsNull())
+	if len(tv.Values()) != 2 {
+		t.Fatalf("Should return 2 nodes, returned: %v", tv.Values())
 	}
 
-	// next traversal test
-	query = `G.V().Has("Value", Within(1, 2, 4))`
-	res = execTraversalQuery(t, g, query)
-	if len(res.Values()) != 3 {
-		t.Fatalf("Should return 3 nodes, returned: %v", res.Values())
+	// next test
+	tv = tr.V().Has("Type", IsNotNull())
+	if len(tv.Values()) != 2 {
+		t.Fatalf("Should return 2 nodes, returned: %v", tv.Values())
 	}
 
-	// next traversal test
-	query = `G.V().Has("Value", Within(1.0, 2, 4))`
-	res = execTraversalQuery(t, g, query)
-	if len(res.Values()) != 3 {
-		t.Fatalf("Should return 3 nodes, returned: %v", res.Values())
+	// next test
+	n1 := tr.V().Has("Value", 1).Values()[0].(*graph.Node)
+	g.Lock()
+	g.AddMetadata(n1, "Type", nil)
+	g.Unlock()
+
+	tv = tr.V().Has("Type", IsNull())
+	if len(tv.Values()) != 3 {
+		t.Fatalf("Should return 3 nodes, returned: %v", tv.Values())
 	}
 
-	// next traversal test
-	query = `G.V().Has("Value", 1)`
-	res = execTraversalQuery(t, g, query)
+	// next test
+	query := `G.V().Has("Name", IsNotNull())`
+	res := execTraversalQuery(t, g, query)
 	if len(res.Values()) != 1 {
-		t.Fatalf("should return 1 node, returned: %d, %v", len(res.Values()), res.Values())
+		t.Fatalf("Should return 1 node, returned: %v", res.Values())
 	}
-	node = res.Values()[0].(*graph.Node)
+}
 
-	query = `G.V("` + string(node.ID) + `")`
-	res = execTraversalQuery(t, g, query)
-	if len(res.Values()) != 1 || res.Values()[0].(*graph.Node).ID != node.ID {
-		t.Fatalf("Should return 1 nodes, returned: %v, expected %s", res.Values(), node.ID)
-	}
+func TestTraversalMatch(t *testing.T) {
+	g := newTransversalGraph()
 
-	// next traversal test
-	query = `G.V().Has("Value", 1).ShortestPathTo(Metadata("Value", 3), Metadata("Direction", "Left"))`
-	res = execTraversalQuery(t, g, query)
-	if len(res.Values()) != 1 {
-		t.Fatalf("Should return 1 path, returned: %v", res.Values())
+	tr := NewGraphTraversal(g)
+
+	// next test
+	tv := tr.V().Match("Type", "intf", "Name", "Node4")
+	if len(tv.Values()) != 3 {
+		t.Fatalf("Should return 3 nodes, returned: %v", tv.Values())
 	}
 
-	// next traversal test
-	query = `G.V().Has("Type", Ne("intf"))`
-	res = execTraversalQuery(t, g, query)
-	if len(res.Values()) != 2 {
-		t.Fatalf("Should return 2 nodes, returned: %v", res.Values())
+	// next test
+	te := tr.V().Has("Value", 1).OutE().Match("Mode", "Direct", "Name", "e4")
+	if len(te.Values()) != 2 {
+		t.Fatalf("Should return 2 edges, returned: %v", te.Values())
 	}
 
-	// next traversal test
-	query = `G.V().Has("Value", 2).Both()`
-	res = execTraversalQuery(t, g, query)
+	// next test
+	query := `G.V().Match("Value", 1, "Value", 3)`
+	res := execTraversalQuery(t, g, query)
 	if len(res.Values()) != 2 {
 		t.Fatalf("Should return 2 nodes, returned: %v", res.Values())
 	}
+}
 
-	// next traversal test
-	query = `G.V().Has("Name", Regex("ode"))`
-	res = execTraversalQuery(t, g, query)
-	if len(res.Values()) != 1 {
-		t.Fatalf("Should return 1 node, returned: %v", res.Values())
+func TestTraversalNestedKey(t *testing.T) {
+	g := newGraph(t)
+
+	g.NewNode(graph.GenID(), graph.Metadata{"Name": "intf1", "Capture": map[string]interface{}{"ID": "abc", "PacketsCount": 10}})
+	g.NewNode(graph.GenID(), graph.Metadata{"Name": "intf2", "Capture": graph.Metadata{"ID": "def", "PacketsCount": 20}})
+	g.NewNode(graph.GenID(), graph.Metadata{"Name": "intf3", "Capture/ID": "ghi"})
+
+	tr := NewGraphTraversal(g)
+
+	tv := tr.V().Has("Capture/ID")
+	if len(tv.Values()) != 3 {
+		t.Fatalf("Should return 3 nodes, returned: %v", tv.Values())
 	}
 
-	// next traversal test
-	query = `G.V().Values("Type")`
+	// next test
+	tv = tr.V().Has("Capture/ID", "abc")
+	if len(tv.Values()) != 1 {
+		t.Fatalf("Should return 1 node, returned: %v", tv.Values())
+	}
+
+	// next test
+	tv = tr.V().Has("Capture/PacketsCount", Gt(15))
+	if len(tv.Values()) != 1 {
+		t.Fatalf("Should return 1 node, returned: %v", tv.Values())
+	}
+
+	// next test
+	values := tr.V().PropertyValues("Capture/PacketsCount")
+	if len(values.Values()) != 2 {
+		t.Fatalf("Should return 2 values, returned: %v", values.Values())
+	}
+
+	// next test
+	tv = tr.V().HasNot("Capture/PacketsCount")
+	if len(tv.Values()) != 1 {
+		t.Fatalf("Should return 1 node, returned: %v", tv.Values())
+	}
+}
+
+func TestTraversalBoth(t *testing.T) {
+	g := newTransversalGraph()
+
+	tr := NewGraphTraversal(g)
+
+	// next test
+	tv := tr.V().Has("Value", 2).Both()
+	if len(tv.Values()) != 2 {
+		t.Fatalf("Should return 2 nodes, returned: %v", tv.Values())
+	}
+}
+
+func TestTraversalDepth(t *testing.T) {
+	g := newTransversalGraph()
+
+	tr := NewGraphTraversal(g)
+
+	// next test
+	tv := tr.V().Has("Value", 2).Out(2)
+	if len(tv.Values()) != 2 {
+		t.Fatalf("Should return 2 nodes, returned: %v", tv.Values())
+	}
+
+	// next test
+	tv = tr.V().Has("Value", 2).Out(2, "Name", "Node4")
+	if len(tv.Values()) != 1 {
+		t.Fatalf("Should return 1 node, returned: %v", tv.Values())
+	}
+
+	// next test
+	tv = tr.V().Has("Value", 4).In(1)
+	if len(tv.Values()) != 2 {
+		t.Fatalf("Should return 2 nodes, returned: %v", tv.Values())
+	}
+
+	// next test
+	tv = tr.V().Has("Value", 4).In(3)
+	if len(tv.Values()) != 3 {
+		t.Fatalf("Should return 3 nodes, returned: %v", tv.Values())
+	}
+}
+
+func TestTraversalRepeatUntil(t *testing.T) {
+	g := newTransversalGraph()
+
+	tr := NewGraphTraversal(g)
+
+	out := func(tv *GraphTraversalV) *GraphTraversalV {
+		return tv.Out()
+	}
+
+	// next test
+	tv := tr.V().Has("Value", 2).Repeat(out).Until(graph.Metadata{"Name": "Node4"})
+	if tv.Error() != nil {
+		t.Fatal(tv.Error())
+	}
+
+	if len(tv.Values()) != 1 {
+		t.Fatalf("Should return 1 node, returned: %v", tv.Values())
+	}
+
+	// next test
+	tv = tr.V().Has("Value", 4).Repeat(out).Until(graph.Metadata{"Value": 1})
+	if len(tv.Values()) != 0 {
+		t.Fatalf("Should return 0 node, returned: %v", tv.Values())
+	}
+
+	// next test
+	tr.MaxRepeat = 1
+	tv = tr.V().Has("Value", 2).Repeat(out).Until(graph.Metadata{"Name": "Node4"})
+	if len(tv.Values()) != 0 {
+		t.Fatalf("Should return 0 node, returned: %v", tv.Values())
+	}
+
+	// next test
+	if tv = tr.V().Until(graph.Metadata{"Value": 1}); tv.Error() == nil {
+		t.Fatal("Should return an error without Repeat")
+	}
+}
+
+func TestTraversalCoalesce(t *testing.T) {
+	g := newTransversalGraph()
+
+	tr := NewGraphTraversal(g)
+
+	host := func(tv *GraphTraversalV) *GraphTraversalV { return tv.Out("Type", "host") }
+	intf := func(tv *GraphTraversalV) *GraphTraversalV { return tv.Out("Type", "intf") }
+	evaluated := false
+	other := func(tv *GraphTraversalV) *GraphTraversalV {
+		evaluated = true
+		return tv.Out()
+	}
+
+	AssertTraversal(t, TraversalTestCase{tr.V("n1").Coalesce(host, intf, other), []interface{}{graph.Identifier("n2")}})
+	if evaluated {
+		t.Error("Branches following the first non-empty one shouldn't be evaluated")
+	}
+
+	AssertTraversal(t, TraversalTestCase{tr.V("n4").Coalesce(host, intf), []interface{}{}})
+}
+
+func TestTraversalOptional(t *testing.T) {
+	g := newTransversalGraph()
+
+	tr := NewGraphTraversal(g)
+
+	out := func(tv *GraphTraversalV) *GraphTraversalV { return tv.Out() }
+
+	AssertTraversal(t, TraversalTestCase{tr.V("n4").Optional(out), []interface{}{graph.Identifier("n4")}})
+	AssertTraversal(t, TraversalTestCase{tr.V("n1").Optional(out).Has("Type", "intf"), []interface{}{graph.Identifier("n2")}})
+}
+
+func TestTraversalUnion(t *testing.T) {
+	g := newTransversalGraph()
+
+	tr := NewGraphTraversal(g)
+
+	tv := tr.V("n2").Union(tr.V("n4"), tr.V("n2"), tr.V("n1"))
+	expected := []graph.Identifier{"n2", "n4", "n1"}
+	if len(tv.Values()) != len(expected) {
+		t.Fatalf("Should return %d nodes, returned: %v", len(expected), tv.Values())
+	}
+	for i, v := range tv.Values() {
+		if v.(*graph.Node).ID != expected[i] {
+			t.Errorf("Expected %v at position %d, got: %v", expected[i], i, v)
+		}
+	}
+
+	AssertTraversal(t, TraversalTestCase{tr.V("n2").Union(tr.V("n1").Out()), []interface{}{graph.Identifier("n2"), graph.Identifier("n3"), graph.Identifier("n4")}})
+
+	te := tr.V("n1").OutE().Union(tr.V("n2").BothE())
+	if len(te.Values()) != 4 {
+		t.Fatalf("Should return 4 edges, returned: %v", te.Values())
+	}
+}
+
+func TestTraversalIntersect(t *testing.T) {
+	g := newTransversalGraph()
+
+	tr := NewGraphTraversal(g)
+
+	AssertTraversal(t, TraversalTestCase{tr.V().Intersect(tr.V("n1").Out(), tr.V().Has("Bytes")), []interface{}{graph.Identifier("n2"), graph.Identifier("n4")}})
+	AssertTraversal(t, TraversalTestCase{tr.V().Intersect(tr.V("n1").Out(), tr.V("n1")), []interface{}{}})
+	AssertTraversal(t, TraversalTestCase{tr.V("n1").Intersect(), []interface{}{graph.Identifier("n1")}})
+}
+
+func TestTraversalDifference(t *testing.T) {
+	g := newTransversalGraph()
+
+	tr := NewGraphTraversal(g)
+
+	AssertTraversal(t, TraversalTestCase{tr.V().Difference(tr.V("n1").Out()), []interface{}{graph.Identifier("n1")}})
+	AssertTraversal(t, TraversalTestCase{tr.V().Has("Bytes").Difference(tr.V("n3")), []interface{}{graph.Identifier("n1"), graph.Identifier("n2"), graph.Identifier("n4")}})
+	AssertTraversal(t, TraversalTestCase{tr.V().Difference(tr.V()), []interface{}{}})
+}
+
+func TestTraversalCount(t *testing.T) {
+	g := newTransversalGraph()
+
+	tr := NewGraphTraversal(g)
+
+	// next test
+	tv := tr.V().Count()
+	if tv.Values()[0] != 4 {
+		t.Fatalf("Should return 4 nodes, returned: %v", tv.Values())
+	}
+}
+
+func TestTraversalSort(t *testing.T) {
+	g := newTransversalGraph()
+
+	tr := NewGraphTraversal(g)
+
+	tv := tr.V().Sort("Bytes")
+	if tv.Error() != nil {
+		t.Fatal(tv.Error())
+	}
+
+	var values []interface{}
+	for _, n := range tv.GetNodes() {
+		value, _ := n.GetField("Value")
+		values = append(values, value)
+	}
+
+	// node 3 has no Bytes field, it has to be the last one
+	expected := []interface{}{1, 2, 4, 3}
+	if !reflect.DeepEqual(values, expected) {
+		t.Fatalf("Should return %v, returned: %v", expected, values)
+	}
+
+	// next test
+	tv = tr.V().Sort("Bytes", "DESC")
+	if tv.Error() != nil {
+		t.Fatal(tv.Error())
+	}
+
+	values = values[:0]
+	for _, n := range tv.GetNodes() {
+		value, _ := n.GetField("Value")
+		values = append(values, value)
+	}
+
+	expected = []interface{}{4, 2, 1, 3}
+	if !reflect.DeepEqual(values, expected) {
+		t.Fatalf("Should return %v, returned: %v", expected, values)
+	}
+
+	// next test
+	if tv = tr.V().Sort("Bytes", "UP"); tv.Error() == nil {
+		t.Fatal("Should return an error for an invalid order")
+	}
+}
+
+func TestTraversalGroupBy(t *testing.T) {
+	g := newTransversalGraph()
+
+	tr := NewGraphTraversal(g)
+
+	tv := tr.V().GroupBy("Type")
+	if tv.Error() != nil {
+		t.Fatal(tv.Error())
+	}
+
+	groups := tv.Values()[0].(map[string][]*graph.Node)
+	if len(groups) != 2 {
+		t.Fatalf("Should return 2 groups, returned: %v", groups)
+	}
+
+	if len(groups["intf"]) != 2 || len(groups[""]) != 2 {
+		t.Fatalf("Should return 2 nodes per group, returned: %v", groups)
+	}
+
+	if _, err := tv.MarshalJSON(); err != nil {
+		t.Fatal(err)
+	}
+
+	// next test
+	tv = tr.V().GroupBy("Type").Dedup()
+	if tv.Error() != nil || len(tv.Values()) != 1 {
+		t.Fatalf("Should return the groups once, returned: %v, %v", tv.Values(), tv.Error())
+	}
+}
+
+func TestTraversalMap(t *testing.T) {
+	g := newTransversalGraph()
+
+	tr := NewGraphTraversal(g)
+
+	tv := tr.V().PropertyValues("Type").Map(func(v interface{}) interface{} {
+		return strings.ToUpper(v.(string))
+	})
+	if tv.Error() != nil {
+		t.Fatal(tv.Error())
+	}
+
+	if !reflect.DeepEqual(tv.Values(), []interface{}{"INTF", "INTF"}) {
+		t.Fatalf("Should return upper case values, returned: %v", tv.Values())
+	}
+
+	// next test
+	tv = tr.V().PropertyValues("Type").Map(func(v interface{}) interface{} {
+		return errors.New("map error")
+	})
+	if tv.Error() == nil {
+		t.Fatal("Should return an error")
+	}
+}
+
+func TestTraversalHistogram(t *testing.T) {
+	g := newTransversalGraph()
+
+	tr := NewGraphTraversal(g)
+
+	tv := tr.V().Histogram("Bytes", 3000)
+	if tv.Error() != nil {
+		t.Fatal(tv.Error())
+	}
+
+	expected := Histogram{0: 2, 3000: 1}
+	if !reflect.DeepEqual(tv.Values()[0], expected) {
+		t.Fatalf("Should return %v, returned: %v", expected, tv.Values())
+	}
+
+	data, err := json.Marshal(tv)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != `[{"bucket":0,"count":2},{"bucket":3000,"count":1}]` {
+		t.Fatalf("Unexpected JSON output: %s", string(data))
+	}
+
+	// next test
+	tv = tr.V().Histogram("Bytes", 0)
+	if tv.Error() == nil {
+		t.Fatal("Should return an error")
+	}
+
+	// next test
+	tv = tr.V().Histogram("Bytes", 3000).Dedup()
+	if tv.Error() != nil || len(tv.Values()) != 1 {
+		t.Fatalf("Should return the histogram once, returned: %v, %v", tv.Values(), tv.Error())
+	}
+}
+
+func TestTraversalFirstLast(t *testing.T) {
+	g := newTransversalGraph()
+
+	tr := NewGraphTraversal(g)
+
+	tv := tr.V().Sort("Value").First()
+	if len(tv.Values()) != 1 || tv.Values()[0].(*graph.Node).Metadata()["Value"] != 1 {
+		t.Fatalf("Should return the node with value 1, returned: %v", tv.Values())
+	}
+
+	// next test
+	tv = tr.V().Sort("Value").Last()
+	if len(tv.Values()) != 1 || tv.Values()[0].(*graph.Node).Metadata()["Value"] != 4 {
+		t.Fatalf("Should return the node with value 4, returned: %v", tv.Values())
+	}
+
+	// next test
+	tv = tr.V().Has("Value", 5).Last()
+	if tv.Error() != nil || len(tv.Values()) != 0 {
+		t.Fatalf("Should return an empty result, returned: %v", tv.Values())
+	}
+
+	// next test
+	te := tr.V().Has("Value", 1).OutE().Last()
+	if len(te.Values()) != 1 {
+		t.Fatalf("Should return 1 edge, returned: %v", te.Values())
+	}
+
+	// next test
+	te = tr.V().Has("Value", 4).OutE().First()
+	if te.Error() != nil || len(te.Values()) != 0 {
+		t.Fatalf("Should return an empty result, returned: %v", te.Values())
+	}
+}
+
+func TestTraversalBothE(t *testing.T) {
+	g := newTransversalGraph()
+
+	tr := NewGraphTraversal(g)
+
+	te := tr.V().Has("Value", 3).BothE()
+	if len(te.Values()) != 3 {
+		t.Fatalf("Should return 3 edges, returned: %v", te.Values())
+	}
+
+	// next test
+	te = tr.V().Has("Value", Within(1, 2)).BothE()
+	if len(te.Values()) != 4 {
+		t.Fatalf("Should return 4 edges without duplicates, returned: %v", te.Values())
+	}
+
+	// next test
+	te = tr.V().Has("Value", 3).BothE("Direction", "Left")
+	if len(te.Values()) != 1 {
+		t.Fatalf("Should return 1 edge, returned: %v", te.Values())
+	}
+}
+
+func TestTraversalDegree(t *testing.T) {
+	g := newTransversalGraph()
+
+	tr := NewGraphTraversal(g)
+
+	tv := tr.V().InDegree()
+	expected := map[graph.Identifier]int64{"n1": 0, "n2": 1, "n3": 2, "n4": 2}
+	if !reflect.DeepEqual(tv.Values()[0], expected) {
+		t.Fatalf("Should return %v, returned: %v", expected, tv.Values())
+	}
+
+	// next test
+	tv = tr.V().OutDegree()
+	expected = map[graph.Identifier]int64{"n1": 3, "n2": 1, "n3": 1, "n4": 0}
+	if !reflect.DeepEqual(tv.Values()[0], expected) {
+		t.Fatalf("Should return %v, returned: %v", expected, tv.Values())
+	}
+
+	// next test
+	tv = tr.V().Degree()
+	expected = map[graph.Identifier]int64{"n1": 3, "n2": 2, "n3": 3, "n4": 2}
+	if !reflect.DeepEqual(tv.Values()[0], expected) {
+		t.Fatalf("Should return %v, returned: %v", expected, tv.Values())
+	}
+
+	// next test
+	tv = tr.V().Degree().Dedup()
+	if tv.Error() != nil || len(tv.Values()) != 1 {
+		t.Fatalf("Should return the degrees once, returned: %v, %v", tv.Values(), tv.Error())
+	}
+}
+
+func TestTraversalSubgraph(t *testing.T) {
+	g := newTransversalGraph()
+
+	tr := NewGraphTraversal(g)
+
+	sg := tr.V().Has("Value", Within(1, 2, 3)).Subgraph()
+	if sg.Error() != nil {
+		t.Fatal(sg.Error())
+	}
+
+	if len(sg.V().Values()) != 3 {
+		t.Fatalf("Should return 3 nodes, returned: %v", sg.V().Values())
+	}
+
+	tv := sg.V().Has("Value", 1).Out()
+	if len(tv.Values()) != 2 {
+		t.Fatalf("Should return 2 nodes, returned: %v", tv.Values())
+	}
+
+	if len(sg.V().Has("Value", 3).Out().Values()) != 0 {
+		t.Fatal("Edges leaving the subgraph should not be copied")
+	}
+
+	if len(tr.V().Has("Value", 3).Out().Values()) != 1 {
+		t.Fatal("Original graph should not be modified")
+	}
+}
+
+func TestTraversalToDOT(t *testing.T) {
+	g := newTransversalGraph()
+
+	tr := NewGraphTraversal(g)
+
+	tv := tr.V().Has("Type", "intf").ToDOT("Value")
+	if tv.Error() != nil {
+		t.Fatal(tv.Error())
+	}
+
+	dot := tv.Values()[0].(string)
+	if !strings.HasPrefix(dot, "digraph") || strings.Count(dot, "->") != 1 || strings.Count(dot, `"Value"=`) != 2 {
+		t.Fatalf("Wrong DOT output: %s", dot)
+	}
+}
+
+func TestTraversalStringIndex(t *testing.T) {
+	g := newTransversalGraph()
+	g.RegisterStringIndex("Type")
+
+	tr := NewGraphTraversal(g)
+
+	tv := tr.V().Has("Type", "intf", "Value", 2)
+	if len(tv.Values()) != 1 {
+		t.Fatalf("Should return 1 node, returned: %v", tv.Values())
+	}
+
+	tv = tr.V().Has("Value", 1).Out().Has("Type", "intf")
+	if len(tv.Values()) != 1 {
+		t.Fatalf("Should return 1 node, returned: %v", tv.Values())
+	}
+}
+
+// scanCountingBackend counts the lookups of nodes done by scanning the backend
+type scanCountingBackend struct {
+	*graph.MemoryBackend
+	scans int
+}
+
+func (b *scanCountingBackend) GetNodes(t *common.TimeSlice, m graph.Metadata) []*graph.Node {
+	b.scans++
+	return b.MemoryBackend.GetNodes(t, m)
+}
+
+func TestTraversalStringIndexParser(t *testing.T) {
+	memory, err := graph.NewMemoryBackend()
+	if err != nil {
+		t.Fatal(err)
+	}
+	b := &scanCountingBackend{MemoryBackend: memory}
+
+	g := graph.NewGraph("host", b)
+	g.NewNode(graph.GenID(), graph.Metadata{"Type": "host", "Name": "h1"})
+	g.NewNode(graph.GenID(), graph.Metadata{"Type": "intf", "Name": "eth0"})
+	g.NewNode(graph.GenID(), graph.Metadata{"Type": "intf", "Name": "eth1"})
+	g.RegisterStringIndex("Type")
+
+	b.scans = 0
+	ts, err := NewGremlinTraversalParser(g).Parse(strings.NewReader(`G.V().Has("Type", "intf", "Name", "eth1")`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	res, err := ts.Exec()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(res.Values()) != 1 {
+		t.Fatalf("Should return 1 node, returned: %v", res.Values())
+	}
+
+	if b.scans != 0 {
+		t.Errorf("The lookup should use the index instead of scanning the nodes, %d scans", b.scans)
+	}
+}
+
+func TestTraversalVStream(t *testing.T) {
+	g := newTransversalGraph()
+
+	tr := NewGraphTraversal(g)
+
+	stream := tr.VStream(context.Background(), "Type", "intf")
+	if stream.Error() != nil {
+		t.Fatal(stream.Error())
+	}
+
+	count := 0
+	for range stream.Nodes() {
+		count++
+	}
+	if count != 2 {
+		t.Fatalf("Should return 2 nodes, returned: %d", count)
+	}
+
+	// next test
+	ctx, cancel := context.WithCancel(context.Background())
+	stream = tr.VStream(ctx)
+	<-stream.Nodes()
+	cancel()
+
+	for range stream.Nodes() {
+	}
+}
+
+func TestTraversalVStreamLocked(t *testing.T) {
+	memory, err := graph.NewMemoryBackend()
+	if err != nil {
+		t.Fatal(err)
+	}
+	b := &scanCountingBackend{MemoryBackend: memory}
+
+	g := graph.NewGraph("host", b)
+	for i := 0; i < 10; i++ {
+		g.NewNode(graph.GenID(), graph.Metadata{"Type": "intf"})
+	}
+
+	b.scans = 0
+	g.RLock()
+
+	stream := NewGraphTraversal(g).VStream(context.Background(), "Type", "intf")
+
+	// a writer has to wait for the stream to be consumed
+	written := make(chan bool)
+	go func() {
+		g.Lock()
+		g.NewNode(graph.GenID(), graph.Metadata{"Type": "intf"})
+		g.Unlock()
+		written <- true
+	}()
+
+	count := 0
+	for range stream.Nodes() {
+		count++
+	}
+	g.RUnlock()
+	<-written
+
+	if count != 10 {
+		t.Errorf("Should return 10 nodes, returned: %d", count)
+	}
+
+	if b.scans != 0 {
+		t.Errorf("The nodes should be walked without retrieving all of them, %d scans", b.scans)
+	}
+}
+
+func TestTraversalParallel(t *testing.T) {
+	g := newTransversalGraph()
+
+	tr := NewGraphTraversal(g)
+
+	expected := tr.V().Sort("Value").Has("Value", Gt(1)).Values()
+
+	for _, workers := range []int64{1, 2, 3, 8} {
+		tv := tr.V().Sort("Value").Parallel(workers).Has("Value", Gt(1))
+		if !reflect.DeepEqual(tv.Values(), expected) {
+			t.Fatalf("Should return %v with %d workers, returned: %v", expected, workers, tv.Values())
+		}
+	}
+
+	// next test
+	tv := tr.V().Parallel().Has("Type", "intf").Has("Value", 2)
+	if len(tv.Values()) != 1 {
+		t.Fatalf("Should return 1 node, returned: %v", tv.Values())
+	}
+
+	// next test
+	tv = tr.V().Parallel(int64(0))
+	if tv.Error() == nil {
+		t.Fatal("Should return an error")
+	}
+}
+
+func TestTraversalShortestPathTo(t *testing.T) {
+	g := newTransversalGraph()
+
+	tr := NewGraphTraversal(g)
+
+	tv := tr.V().Has("Value", 1).ShortestPathTo(graph.Metadata{"Value": 3}, nil)
+	if len(tv.Values()) != 1 {
+		t.Fatalf("Should return 1 path, returned: %v", tv.Values())
+	}
+
+	path := tv.Values()[0].([]*graph.Node)
+	if len(path) != 2 {
+		t.Fatalf("Should return a path len of 2, returned: %v", len(path))
+	}
+
+	// next test
+	tv = tr.V().Has("Value", Within(1, 2)).ShortestPathTo(graph.Metadata{"Value": 3}, nil)
+	if len(tv.Values()) != 2 {
+		t.Fatalf("Should return 2 paths, returned: %v", tv.Values())
+	}
+
+	path = tv.Values()[0].([]*graph.Node)
+	if len(path) != 2 {
+		t.Fatalf("Should return a path len of 2, returned: %v", len(path))
+	}
+
+	// next test
+	tv = tr.V().Has("Value", 1).ShortestPathTo(graph.Metadata{"Value": 3}, graph.Metadata{"Direction": "Left"})
+	if len(tv.Values()) != 1 {
+		t.Fatalf("Should return 1 path, returned: %v", tv.Values())
+	}
+
+	path = tv.Values()[0].([]*graph.Node)
+	if len(path) != 3 {
+		t.Fatalf("Should return a path len of 3, returned: %v", len(path))
+	}
+}
+
+func TestTraversalAllPaths(t *testing.T) {
+	g := newTransversalGraph()
+
+	tr := NewGraphTraversal(g)
+
+	tv := tr.V().Has("Value", 1).AllPaths(graph.Metadata{"Value": 3}, nil)
+	if len(tv.Values()) != 3 {
+		t.Fatalf("Should return 3 paths, returned: %v", tv.Values())
+	}
+
+	// next test
+	tv = tr.V().Has("Value", 1).AllPaths(graph.Metadata{"Value": 3}, graph.Metadata{"Direction": "Left"})
+	if len(tv.Values()) != 1 {
+		t.Fatalf("Should return 1 path, returned: %v", tv.Values())
+	}
+
+	// next test
+	tv = tr.V().Has("Value", 1).AllPaths(graph.Metadata{"Value": 3}, nil, 2)
+	if len(tv.Values()) != 2 {
+		t.Fatalf("Should return 2 paths, returned: %v", tv.Values())
+	}
+}
+
+func execTraversalQuery(t *testing.T, g *graph.Graph, query string) GraphTraversalStep {
+	ts, err := NewGremlinTraversalParser(g).Parse(strings.NewReader(query))
+	if err != nil {
+		t.Fatalf("%s: %s", query, err.Error())
+	}
+
+	res, err := ts.Exec()
+	if err != nil {
+		t.Fatalf("%s: %s", query, err.Error())
+	}
+
+	return res
+}
+
+func TestTraversalAsSelect(t *testing.T) {
+	g := newTransversalGraph()
+
+	tr := NewGraphTraversal(g)
+
+	tv := tr.V("n1").As("origin").Out().Has("Type", "intf").As("intfs")
+	selected := tv.Select("origin", "intfs").Values()[0].(map[string][]interface{})
+	if len(selected["origin"]) != 1 || selected["origin"][0].(*graph.Node).ID != "n1" {
+		t.Fatalf("Wrong origin selected: %v", selected["origin"])
+	}
+	if len(selected["intfs"]) != 1 || selected["intfs"][0].(*graph.Node).ID != "n2" {
+		t.Fatalf("Wrong intfs selected: %v", selected["intfs"])
+	}
+
+	if err := tv.Select("unknown").Error(); err == nil {
+		t.Fatal("Selecting an unknown label should fail")
+	}
+
+	// next traversal test
+	res := execTraversalQuery(t, g, `G.V("n1").As("origin").OutE().As("links").InV().Select()`)
+	selected = res.Values()[0].(map[string][]interface{})
+	if len(selected) != 2 || len(selected["origin"]) != 1 || len(selected["links"]) != 3 {
+		t.Fatalf("Wrong labels selected: %v", selected)
+	}
+}
+
+func TestTraversalReduceHas(t *testing.T) {
+	g := newTransversalGraph()
+
+	// the Has filters are merged into the Out lookup
+	ts, err := NewGremlinTraversalParser(g).Parse(strings.NewReader(`G.V("n1").Out().Has("Type", "intf").Has("Value", 2)`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	plan := ts.Explain().Values()[0].(string)
+	if !strings.Contains(plan, "Out(Type, intf, Value, 2)") {
+		t.Fatalf("Has steps should be merged into Out:\n%s", plan)
+	}
+
+	res, err := ts.Exec()
+	if err != nil {
+		t.Fatal(err)
+	}
+	AssertTraversal(t, TraversalTestCase{res.(*GraphTraversalV), []interface{}{graph.Identifier("n2")}})
+
+	// the pagination applies to the filtered elements
+	res = execTraversalQuery(t, g, `G.V("n1").Out().Has("Value", 3).Limit(1)`)
+	AssertTraversal(t, TraversalTestCase{res.(*GraphTraversalV), []interface{}{graph.Identifier("n3")}})
+
+	// a key only filter is not merged
+	res = execTraversalQuery(t, g, `G.V("n1").Out().Has("Type")`)
+	AssertTraversal(t, TraversalTestCase{res.(*GraphTraversalV), []interface{}{graph.Identifier("n2")}})
+
+	// a key filtered twice matches nothing
+	res = execTraversalQuery(t, g, `G.V("n1").Out().Has("Value", 2).Has("Value", 3)`)
+	AssertTraversal(t, TraversalTestCase{res.(*GraphTraversalV), []interface{}{}})
+}
+
+func TestTraversalParser(t *testing.T) {
+	g := newTransversalGraph()
+
+	// next traversal test
+	query := `G.V().Has("Type", "intf")`
+	res := execTraversalQuery(t, g, query)
+	if len(res.Values()) != 2 {
+		t.Fatalf("Should return 2 nodes, returned: %v", res.Values())
+	}
+
+	// next traversal test
+	query = `G.V().Count()`
+	res = execTraversalQuery(t, g, query)
+	if res.Values()[0] != 4 {
+		t.Fatalf("Should return 4, returned: %v", res.Values())
+	}
+
+	// next traversal test
+	query = `G.V().Has("Value", 1).Out().Has("Name", "Node4")`
+	res = execTraversalQuery(t, g, query)
+	if len(res.Values()) != 1 {
+		t.Fatalf("Should return 1 nodes, returned: %v", res.Values())
+	}
+
+	// next traversal test
+	query = `G.V().Has("Value", 1).Out().Has("Value", 2).OutE().Has("Direction", "Left").OutV().Out()`
+	res = execTraversalQuery(t, g, query)
+	if len(res.Values()) != 1 {
+		t.Fatalf("should return 1 node, returned: %d, %v", len(res.Values()), res.Values())
+	}
+
+	node := res.Values()[0].(*graph.Node)
+	if name, _ := node.GetFieldString("Name"); name != "Node4" {
+		t.Fatalf("Should return Node4, returned: %v", res.Values())
+	}
+
+	// next traversal test
+	query = `G.V().Has("Type")`
+	res = execTraversalQuery(t, g, query)
+	if len(res.Values()) != 2 {
+		t.Fatalf("Should return 2 nodes, returned: %v", res.Values())
+	}
+
+	// next traversal test
+	query = `G.V().Out().Has("Value", 4)`
+	res = execTraversalQuery(t, g, query)
+	if len(res.Values()) != 2 {
+		t.Fatalf("Should return 2 nodes, returned: %v", res.Values())
+	}
+
+	// next traversal test
+	query = `G.V().Out().Has("Value", 4).Dedup()`
+	res = execTraversalQuery(t, g, query)
+	if len(res.Values()) != 1 {
+		t.Fatalf("Should return 1 nodes, returned: %v", res.Values())
+	}
+
+	// next traversal test
+	query = `G.V().Dedup("Type")`
+	res = execTraversalQuery(t, g, query)
+	if len(res.Values()) != 1 {
+		t.Fatalf("Should return 1 nodes, returned: %v", res.Values())
+	}
+
+	// next traversal test
+	query = `G.V().Has("Value", Within(1, 2, 4))`
+	res = execTraversalQuery(t, g, query)
+	if len(res.Values()) != 3 {
+		t.Fatalf("Should return 3 nodes, returned: %v", res.Values())
+	}
+
+	// next traversal test
+	query = `G.V().Has("Value", Within(1.0, 2, 4))`
+	res = execTraversalQuery(t, g, query)
+	if len(res.Values()) != 3 {
+		t.Fatalf("Should return 3 nodes, returned: %v", res.Values())
+	}
+
+	// next traversal test
+	query = `G.V().Has("Value", 1)`
+	res = execTraversalQuery(t, g, query)
+	if len(res.Values()) != 1 {
+		t.Fatalf("should return 1 node, returned: %d, %v", len(res.Values()), res.Values())
+	}
+	node = res.Values()[0].(*graph.Node)
+
+	query = `G.V("` + string(node.ID) + `")`
+	res = execTraversalQuery(t, g, query)
+	if len(res.Values()) != 1 || res.Values()[0].(*graph.Node).ID != node.ID {
+		t.Fatalf("Should return 1 nodes, returned: %v, expected %s", res.Values(), node.ID)
+	}
+
+	// next traversal test
+	query = `G.V().Has("Value", 1).ShortestPathTo(Metadata("Value", 3), Metadata("Direction", "Left"))`
+	res = execTraversalQuery(t, g, query)
+	if len(res.Values()) != 1 {
+		t.Fatalf("Should return 1 path, returned: %v", res.Values())
+	}
+
+	// next traversal test
+	query = `G.V().Has("Type", Ne("intf"))`
+	res = execTraversalQuery(t, g, query)
+	if len(res.Values()) != 2 {
+		t.Fatalf("Should return 2 nodes, returned: %v", res.Values())
+	}
+
+	// next traversal test
+	query = `G.V().Has("Value", 2).Both()`
+	res = execTraversalQuery(t, g, query)
+	if len(res.Values()) != 2 {
+		t.Fatalf("Should return 2 nodes, returned: %v", res.Values())
+	}
+
+	// next traversal test
+	query = `G.V().Has("Name", Regex("ode"))`
+	res = execTraversalQuery(t, g, query)
+	if len(res.Values()) != 1 {
+		t.Fatalf("Should return 1 node, returned: %v", res.Values())
+	}
+
+	// next traversal test
+	query = `G.V().Values("Type")`
 	res = execTraversalQuery(t, g, query)
 	if len(res.Values()) != 2 {
 		t.Fatalf("Should return 2 node, returned: %v", res.Values())
 	}
+
+	// next traversal test
+	query = `G.V().HasNot("Type").Has("Value", 3)`
+	res = execTraversalQuery(t, g, query)
+	if len(res.Values()) != 1 {
+		t.Fatalf("Should return 1 node, returned: %v", res.Values())
+	}
+
+	// next traversal test
+	query = `G.V().Has("Value", Not(Within(1, 2, 3)))`
+	res = execTraversalQuery(t, g, query)
+	if len(res.Values()) != 1 {
+		t.Fatalf("Should return 1 node, returned: %v", res.Values())
+	}
+
+	// next traversal test
+	query = `G.V().Has("Value", 1).Out(3, "Value", 4)`
+	res = execTraversalQuery(t, g, query)
+	if len(res.Values()) != 1 {
+		t.Fatalf("Should return 1 node, returned: %v", res.Values())
+	}
+
+	// next traversal test
+	query = `G.V().Has("Value", 1).AllPaths(Metadata("Value", 3), Metadata(), 1)`
+	res = execTraversalQuery(t, g, query)
+	if len(res.Values()) != 1 {
+		t.Fatalf("Should return 1 path, returned: %v", res.Values())
+	}
+
+	// next traversal test
+	query = `G.V().Has("Type", Contains("nt"))`
+	res = execTraversalQuery(t, g, query)
+	if len(res.Values()) != 2 {
+		t.Fatalf("Should return 2 nodes, returned: %v", res.Values())
+	}
+
+	// next traversal test
+	query = `G.V().Has("Name", StartsWith("No"), "Name", EndsWith("4"))`
+	res = execTraversalQuery(t, g, query)
+	if len(res.Values()) != 1 {
+		t.Fatalf("Should return 1 node, returned: %v", res.Values())
+	}
+
+	// next traversal test
+	query = `G.V().Histogram("Bytes", 3000)`
+	res = execTraversalQuery(t, g, query)
+	if !reflect.DeepEqual(res.Values()[0], Histogram{0: 2, 3000: 1}) {
+		t.Fatalf("Should return a histogram of 2 buckets, returned: %v", res.Values())
+	}
+
+	// next traversal test
+	query = `G.V().Sort("Value", "DESC").First()`
+	res = execTraversalQuery(t, g, query)
+	if len(res.Values()) != 1 || res.Values()[0].(*graph.Node).Metadata()["Value"] != 4 {
+		t.Fatalf("Should return the node with value 4, returned: %v", res.Values())
+	}
+
+	// next traversal test
+	query = `G.V().Has("Value", 1).BothE().Count()`
+	res = execTraversalQuery(t, g, query)
+	if res.Values()[0] != 3 {
+		t.Fatalf("Should return 3, returned: %v", res.Values())
+	}
+
+	// next traversal test
+	query = `G.V().Has("Value", 1).OutDegree()`
+	res = execTraversalQuery(t, g, query)
+	if !reflect.DeepEqual(res.Values()[0], map[graph.Identifier]int64{"n1": 3}) {
+		t.Fatalf("Should return map[n1:3], returned: %v", res.Values())
+	}
+
+	// next traversal test
+	query = `G.V().Has("Type", "intf").Subgraph().V().Out()`
+	res = execTraversalQuery(t, g, query)
+	if len(res.Values()) != 1 {
+		t.Fatalf("Should return 1 node, returned: %v", res.Values())
+	}
+
+	// next traversal test
+	query = `G.V().Parallel(2).Has("Type", "intf")`
+	res = execTraversalQuery(t, g, query)
+	if len(res.Values()) != 2 {
+		t.Fatalf("Should return 2 nodes, returned: %v", res.Values())
+	}
+
+	// next traversal test
+	g.RegisterStringIndex("Type")
+	query = `G.V().Has("Type", "intf").Out().Limit(1).Explain()`
+	res = execTraversalQuery(t, g, query)
+	plan := res.Values()[0].(string)
+	expectedPlan := `graph: 4 nodes, 5 edges
+V(Type, intf) input: 0, output: ~4, index: Type
+Out() input: 4, output: ~1, range: [0, 1]
+`
+	if plan != expectedPlan {
+		t.Fatalf("Wrong execution plan:\n%s", plan)
+	}
+
+	// next traversal test
+	query = `G.V().Has("Type", "intf").Out().Profile()`
+	res = execTraversalQuery(t, g, query)
+	profiled := res.Values()[0].(map[string]interface{})
+	if len(profiled["Values"].([]interface{})) != 4 {
+		t.Fatalf("Should return 4 nodes, returned: %v", profiled["Values"])
+	}
+	// V and Has are reduced into a single step
+	report := profiled["Profile"].(*ProfileReport)
+	if len(report.Steps) != 2 {
+		t.Fatalf("Should profile 2 steps, returned: %+v", report.Steps)
+	}
+	if s := report.Steps[0]; s.Name != "V" || s.InputCount != 0 || s.OutputCount != 2 {
+		t.Fatalf("Wrong V step profile: %+v", s)
+	}
+	if s := report.Steps[1]; s.Name != "Out" || s.InputCount != 2 || s.OutputCount != 4 {
+		t.Fatalf("Wrong Out step profile: %+v", s)
+	}
+
+	// next traversal test
+	query = `G.V().Max("Value")`
+	res = execTraversalQuery(t, g, query)
+	if res.Values()[0] != float64(4) {
+		t.Fatalf("Should return 4, returned: %v", res.Values())
+	}
+
+	// next traversal test
+	query = `G.V().Sort("Value", "DESC").Limit(1)`
+	res = execTraversalQuery(t, g, query)
+	if len(res.Values()) != 1 {
+		t.Fatalf("Should return 1 node, returned: %v", res.Values())
+	}
+
+	node = res.Values()[0].(*graph.Node)
+	if name, _ := node.GetFieldString("Name"); name != "Node4" {
+		t.Fatalf("Should return Node4, returned: %v", res.Values())
+	}
 }
@@ -0,0 +1,124 @@
+/*
+ * Copyright (C) 2016 Red Hat, Inc.
+ *
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ *
+ */
+
+package graph
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/skydive-project/skydive/common"
+)
+
+// TTLMetadataKey is the metadata key holding the time to live, in
+// milliseconds, of a node or an edge. The key is reserved to not expire the
+// elements having a TTL metadata of another meaning.
+const TTLMetadataKey = "_TTL"
+
+// ttlCheckInterval is the interval at which expired elements are looked for
+var ttlCheckInterval = time.Second
+
+// refreshExpiration resets the expiration time of the element according to
+// its TTL metadata, if any. Only the graph of the host owning the element
+// expires it, the other graphs receiving the deletion from that host.
+func (g *Graph) refreshExpiration(e *graphElement) {
+	v, ok := e.metadata[TTLMetadataKey]
+	if !ok || e.host != g.host {
+		if g.expirations != nil {
+			delete(g.expirations, e.ID)
+		}
+		return
+	}
+
+	ttl, err := common.ToInt64(v)
+	if err != nil {
+		return
+	}
+
+	if g.expirations == nil {
+		g.expirations = make(map[Identifier]time.Time)
+	}
+	g.expirations[e.ID] = time.Now().Add(time.Duration(ttl) * time.Millisecond)
+
+	if !g.expirationRunning {
+		g.expirationRunning = true
+		go g.expirationLoop(ttlCheckInterval)
+	}
+}
+
+// clearExpiration forgets the expiration time of a deleted element
+func (g *Graph) clearExpiration(id Identifier) {
+	if g.expirations != nil {
+		delete(g.expirations, id)
+	}
+}
+
+// expirationLoop deletes the elements whose TTL elapsed. It stops as soon as
+// there is no element with a TTL left.
+func (g *Graph) expirationLoop(interval time.Duration) {
+	for {
+		time.Sleep(interval)
+
+		g.Lock()
+		now := time.Now()
+		for id, expiration := range g.expirations {
+			if now.Before(expiration) {
+				continue
+			}
+
+			if n := g.GetNode(id); n != nil {
+				g.DelNode(n)
+			} else if e := g.GetEdge(id); e != nil {
+				g.DelEdge(e)
+			}
+			delete(g.expirations, id)
+		}
+
+		if len(g.expirations) == 0 {
+			g.expirationRunning = false
+			g.Unlock()
+			return
+		}
+		g.Unlock()
+	}
+}
+
+// SetTTL sets the time to live of the node or the edge with the given ID. The
+// element is deleted by the graph of its host once the TTL elapsed without
+// any metadata update.
+func (g *Graph) SetTTL(id Identifier, ttl time.Duration) error {
+	var i interface{}
+	var e *graphElement
+	if n := g.GetNode(id); n != nil {
+		i, e = n, &n.graphElement
+	} else if edge := g.GetEdge(id); edge != nil {
+		i, e = edge, &edge.graphElement
+	} else {
+		return fmt.Errorf("No node or edge with ID %s", id)
+	}
+
+	if !g.AddMetadata(i, TTLMetadataKey, int64(ttl/time.Millisecond)) {
+		g.refreshExpiration(e)
+	}
+
+	return nil
+}
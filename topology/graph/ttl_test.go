@@ -0,0 +1,119 @@
+/*
+ * Copyright (C) 2016 Red Hat, Inc.
+ *
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ *
+ */
+
+package graph
+
+import (
+	"testing"
+	"time"
+)
+
+func TestTTL(t *testing.T) {
+	ttlCheckInterval = 10 * time.Millisecond
+	defer func() { ttlCheckInterval = time.Second }()
+
+	g := newGraph(t)
+
+	n1 := g.NewNode(Identifier("1"), Metadata{"Value": 1})
+	n2 := g.NewNode(Identifier("2"), Metadata{"Value": 2})
+	n3 := g.NewNode(Identifier("3"), Metadata{"Value": 3})
+	g.NewEdge(Identifier("e1"), n1, n2, nil)
+
+	listener := &FakeListener{}
+	g.AddEventListener(listener)
+
+	g.Lock()
+	if err := g.SetTTL(n2.ID, 50*time.Millisecond); err != nil {
+		t.Fatal(err)
+	}
+	if err := g.SetTTL(n3.ID, time.Hour); err != nil {
+		t.Fatal(err)
+	}
+	if err := g.SetTTL(Identifier("4"), time.Hour); err == nil {
+		t.Error("Should return an error for an unknown node")
+	}
+	g.Unlock()
+
+	time.Sleep(200 * time.Millisecond)
+
+	g.RLock()
+	defer g.RUnlock()
+
+	if g.GetNode(n2.ID) != nil {
+		t.Error("Node should have expired")
+	}
+
+	if g.GetEdge(Identifier("e1")) != nil {
+		t.Error("Edge of the expired node should have been deleted")
+	}
+
+	if listener.lastNodeDeleted == nil || listener.lastNodeDeleted.ID != n2.ID {
+		t.Error("Node deleted event not received")
+	}
+
+	if g.GetNode(n3.ID) == nil {
+		t.Error("Node should not have expired")
+	}
+}
+
+func TestTTLOnAdd(t *testing.T) {
+	ttlCheckInterval = 10 * time.Millisecond
+	defer func() { ttlCheckInterval = time.Second }()
+
+	g := newGraph(t)
+
+	g.Lock()
+	n1 := g.NewNode(Identifier("1"), Metadata{"Value": 1, TTLMetadataKey: int64(50)})
+	n2 := g.NewNode(Identifier("2"), Metadata{"Value": 2})
+	g.NewEdge(Identifier("e1"), n2, n2, Metadata{TTLMetadataKey: int64(50)})
+
+	// the node of another host is expired by the graph of that host
+	g.NewNode(Identifier("4"), Metadata{TTLMetadataKey: int64(50)}, "other")
+	g.Unlock()
+
+	n3 := newTestNode("3", Metadata{TTLMetadataKey: int64(50)})
+	n3.host = g.host
+	if err := g.AddNodes([]*Node{n3}); err != nil {
+		t.Fatal(err)
+	}
+
+	time.Sleep(200 * time.Millisecond)
+
+	g.RLock()
+	defer g.RUnlock()
+
+	if g.GetNode(n1.ID) != nil || g.GetNode(Identifier("3")) != nil {
+		t.Error("Nodes added with a TTL should have expired")
+	}
+
+	if g.GetEdge(Identifier("e1")) != nil {
+		t.Error("Edge added with a TTL should have expired")
+	}
+
+	if g.GetNode(n2.ID) == nil {
+		t.Error("Node without TTL should not have expired")
+	}
+
+	if g.GetNode(Identifier("4")) == nil {
+		t.Error("Node of another host should not have expired")
+	}
+}
@@ -0,0 +1,179 @@
+/*
+ * Copyright (C) 2016 Red Hat, Inc.
+ *
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ *
+ */
+
+package graph
+
+import (
+	"sync"
+
+	"golang.org/x/net/context"
+)
+
+// GraphEventType is the kind of a graph event sent by Watch
+type GraphEventType int
+
+const (
+	NodeAdded GraphEventType = iota + 1
+	NodeUpdated
+	NodeDeleted
+	EdgeAdded
+	EdgeUpdated
+	EdgeDeleted
+)
+
+func (t GraphEventType) String() string {
+	switch t {
+	case NodeAdded:
+		return "NodeAdded"
+	case NodeUpdated:
+		return "NodeUpdated"
+	case NodeDeleted:
+		return "NodeDeleted"
+	case EdgeAdded:
+		return "EdgeAdded"
+	case EdgeUpdated:
+		return "EdgeUpdated"
+	case EdgeDeleted:
+		return "EdgeDeleted"
+	}
+	return "Unknown"
+}
+
+// GraphEvent is a graph event sent by Watch, only one of Node and Edge
+// being set according to the event type
+type GraphEvent struct {
+	EventType GraphEventType
+	Node      *Node
+	Edge      *Edge
+}
+
+// graphWatcher is a graph listener queueing the events matching its filters
+// so that the graph is never blocked by a slow consumer
+type graphWatcher struct {
+	sync.Mutex
+	filters []Metadata
+	events  []GraphEvent
+	notify  chan struct{}
+}
+
+func (w *graphWatcher) match(e *graphElement) bool {
+	if len(w.filters) == 0 {
+		return true
+	}
+
+	for _, f := range w.filters {
+		if e.MatchMetadata(f) {
+			return true
+		}
+	}
+	return false
+}
+
+func (w *graphWatcher) push(ev GraphEvent) {
+	w.Lock()
+	w.events = append(w.events, ev)
+	w.Unlock()
+
+	select {
+	case w.notify <- struct{}{}:
+	default:
+	}
+}
+
+func (w *graphWatcher) pop() (events []GraphEvent) {
+	w.Lock()
+	events, w.events = w.events, nil
+	w.Unlock()
+	return
+}
+
+func (w *graphWatcher) onNode(t GraphEventType, n *Node) {
+	if w.match(&n.graphElement) {
+		w.push(GraphEvent{EventType: t, Node: n})
+	}
+}
+
+func (w *graphWatcher) onEdge(t GraphEventType, e *Edge) {
+	if w.match(&e.graphElement) {
+		w.push(GraphEvent{EventType: t, Edge: e})
+	}
+}
+
+func (w *graphWatcher) OnNodeUpdated(n *Node) {
+	w.onNode(NodeUpdated, n)
+}
+
+func (w *graphWatcher) OnNodeAdded(n *Node) {
+	w.onNode(NodeAdded, n)
+}
+
+func (w *graphWatcher) OnNodeDeleted(n *Node) {
+	w.onNode(NodeDeleted, n)
+}
+
+func (w *graphWatcher) OnEdgeUpdated(e *Edge) {
+	w.onEdge(EdgeUpdated, e)
+}
+
+func (w *graphWatcher) OnEdgeAdded(e *Edge) {
+	w.onEdge(EdgeAdded, e)
+}
+
+func (w *graphWatcher) OnEdgeDeleted(e *Edge) {
+	w.onEdge(EdgeDeleted, e)
+}
+
+// Watch returns a channel receiving the events of the nodes and edges
+// matching at least one of the given filters, or all the events if no filter
+// is given. The channel is closed when the context is cancelled. The graph
+// must be locked to access the metadata of the received elements.
+func (g *Graph) Watch(ctx context.Context, filters ...Metadata) <-chan GraphEvent {
+	w := &graphWatcher{
+		filters: filters,
+		notify:  make(chan struct{}, 1),
+	}
+	g.AddEventListener(w)
+
+	ch := make(chan GraphEvent)
+	go func() {
+		defer close(ch)
+		defer g.RemoveEventListener(w)
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-w.notify:
+			}
+
+			for _, ev := range w.pop() {
+				select {
+				case ch <- ev:
+				case <-ctx.Done():
+					return
+				}
+			}
+		}
+	}()
+
+	return ch
+}
@@ -0,0 +1,73 @@
+/*
+ * Copyright (C) 2016 Red Hat, Inc.
+ *
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ *
+ */
+
+package graph
+
+import (
+	"testing"
+	"time"
+
+	"golang.org/x/net/context"
+)
+
+func nextGraphEvent(t *testing.T, ch <-chan GraphEvent) GraphEvent {
+	select {
+	case ev, ok := <-ch:
+		if !ok {
+			t.Fatal("Channel closed")
+		}
+		return ev
+	case <-time.After(5 * time.Second):
+		t.Fatal("Timeout waiting for graph event")
+	}
+	return GraphEvent{}
+}
+
+func TestWatch(t *testing.T) {
+	g := newGraph(t)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	ch := g.Watch(ctx, Metadata{"Type": "intf"})
+
+	g.Lock()
+	n1 := g.NewNode(GenID(), Metadata{"Type": "host"})
+	n2 := g.NewNode(GenID(), Metadata{"Type": "intf"})
+	g.AddMetadata(n2, "MTU", 1500)
+	g.NewEdge(GenID(), n1, n2, Metadata{"Type": "intf"})
+	g.DelNode(n2)
+	g.Unlock()
+
+	expected := []GraphEventType{NodeAdded, NodeUpdated, EdgeAdded, EdgeDeleted, NodeDeleted}
+	for _, et := range expected {
+		ev := nextGraphEvent(t, ch)
+		if ev.EventType != et {
+			t.Fatalf("Expected event %d, got %d", et, ev.EventType)
+		}
+		if ev.Node != nil && ev.Node.ID != n2.ID {
+			t.Errorf("Wrong node: %s", ev.Node.ID)
+		}
+	}
+
+	cancel()
+	for range ch {
+	}
+}
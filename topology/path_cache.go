@@ -0,0 +1,170 @@
+/*
+ * Copyright (C) 2016 Red Hat, Inc.
+ *
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ *
+ */
+
+package topology
+
+import (
+	"sync"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/skydive-project/skydive/topology/graph"
+)
+
+var (
+	graphPathCacheHits = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "skydive_topology_graph_path_cache_hits_total",
+		Help: "Number of graph path lookups served by the cache",
+	})
+	graphPathCacheMisses = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "skydive_topology_graph_path_cache_misses_total",
+		Help: "Number of graph path lookups not served by the cache",
+	})
+)
+
+type graphPathCacheEntry struct {
+	path  string
+	nodes []graph.Identifier
+}
+
+// GraphPathCache keeps the graph path of the nodes. An entry is invalidated
+// when one of the nodes of the path, or an edge linking one of them, changes.
+// The hit rate is exposed through the hits and misses counters.
+type GraphPathCache struct {
+	sync.RWMutex
+	graph.DefaultGraphListener
+	Graph   *graph.Graph
+	entries map[graph.Identifier]*graphPathCacheEntry
+	byNode  map[graph.Identifier]map[graph.Identifier]bool
+}
+
+// GraphPath returns the graph path of the node, as returned by the GraphPath
+// function. The graph lock has to be held.
+func (c *GraphPathCache) GraphPath(n *graph.Node) string {
+	c.RLock()
+	entry, ok := c.entries[n.ID]
+	c.RUnlock()
+
+	if ok {
+		graphPathCacheHits.Inc()
+		return entry.path
+	}
+	graphPathCacheMisses.Inc()
+
+	nodes := c.Graph.LookupShortestPath(n, graph.Metadata{"Type": "host"}, graph.Metadata{"RelationType": "ownership"})
+	if len(nodes) == 0 {
+		return ""
+	}
+
+	path := NodePath(nodes).Marshal()
+	if path == "" {
+		return ""
+	}
+
+	c.add(n.ID, path, nodes)
+
+	return path
+}
+
+func (c *GraphPathCache) add(id graph.Identifier, path string, nodes []*graph.Node) {
+	c.Lock()
+	defer c.Unlock()
+
+	entry := &graphPathCacheEntry{path: path}
+	for _, node := range nodes {
+		entry.nodes = append(entry.nodes, node.ID)
+		if _, ok := c.byNode[node.ID]; !ok {
+			c.byNode[node.ID] = make(map[graph.Identifier]bool)
+		}
+		c.byNode[node.ID][id] = true
+	}
+	c.entries[id] = entry
+}
+
+// invalidate removes the entries whose path goes through one of the nodes
+func (c *GraphPathCache) invalidate(ids ...graph.Identifier) {
+	c.Lock()
+	defer c.Unlock()
+
+	for _, id := range ids {
+		for owner := range c.byNode[id] {
+			entry, ok := c.entries[owner]
+			if !ok {
+				continue
+			}
+
+			for _, n := range entry.nodes {
+				delete(c.byNode[n], owner)
+				if len(c.byNode[n]) == 0 {
+					delete(c.byNode, n)
+				}
+			}
+			delete(c.entries, owner)
+		}
+	}
+}
+
+func (c *GraphPathCache) OnNodeUpdated(n *graph.Node) {
+	c.invalidate(n.ID)
+}
+
+func (c *GraphPathCache) OnNodeDeleted(n *graph.Node) {
+	c.invalidate(n.ID)
+}
+
+func (c *GraphPathCache) onEdgeEvent(e *graph.Edge) {
+	c.invalidate(e.GetParent(), e.GetChild())
+}
+
+func (c *GraphPathCache) OnEdgeAdded(e *graph.Edge) {
+	c.onEdgeEvent(e)
+}
+
+func (c *GraphPathCache) OnEdgeUpdated(e *graph.Edge) {
+	c.onEdgeEvent(e)
+}
+
+func (c *GraphPathCache) OnEdgeDeleted(e *graph.Edge) {
+	c.onEdgeEvent(e)
+}
+
+// Stop stops maintaining the cache
+func (c *GraphPathCache) Stop() {
+	c.Graph.RemoveEventListener(c)
+}
+
+// NewGraphPathCache returns a graph path cache kept in sync with the graph
+func NewGraphPathCache(g *graph.Graph) *GraphPathCache {
+	c := &GraphPathCache{
+		Graph:   g,
+		entries: make(map[graph.Identifier]*graphPathCacheEntry),
+		byNode:  make(map[graph.Identifier]map[graph.Identifier]bool),
+	}
+	g.AddEventListener(c)
+
+	return c
+}
+
+func init() {
+	prometheus.MustRegister(graphPathCacheHits)
+	prometheus.MustRegister(graphPathCacheMisses)
+}
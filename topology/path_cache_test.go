@@ -0,0 +1,70 @@
+/*
+ * Copyright (C) 2016 Red Hat, Inc.
+ *
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ *
+ */
+
+package topology
+
+import (
+	"testing"
+
+	dto "github.com/prometheus/client_model/go"
+
+	"github.com/skydive-project/skydive/topology/graph"
+)
+
+func TestGraphPathCache(t *testing.T) {
+	g := newGraph(t)
+	c := NewGraphPathCache(g)
+	defer c.Stop()
+
+	host := g.NewNode(graph.GenID(), graph.Metadata{"Name": "host", "Type": "host"})
+	ns := g.NewNode(graph.GenID(), graph.Metadata{"Name": "ns", "Type": "netns"})
+	intf := g.NewNode(graph.GenID(), graph.Metadata{"Name": "eth0", "Type": "device"})
+	g.Link(host, ns, graph.Metadata{"RelationType": "ownership"})
+	g.Link(ns, intf, graph.Metadata{"RelationType": "ownership"})
+
+	var before dto.Metric
+	graphPathCacheHits.Write(&before)
+
+	if path := c.GraphPath(intf); path != "host[Type=host]/ns[Type=netns]/eth0[Type=device]" {
+		t.Errorf("Wrong path returned: %s", path)
+	}
+
+	if path := c.GraphPath(intf); path != GraphPath(g, intf) {
+		t.Errorf("Wrong cached path returned: %s", path)
+	}
+
+	var after dto.Metric
+	graphPathCacheHits.Write(&after)
+	if after.GetCounter().GetValue() != before.GetCounter().GetValue()+1 {
+		t.Error("Hit counter should be incremented")
+	}
+
+	g.AddMetadata(ns, "Name", "ns2")
+	if path := c.GraphPath(intf); path != "host[Type=host]/ns2[Type=netns]/eth0[Type=device]" {
+		t.Errorf("Path should be invalidated on node update: %s", path)
+	}
+
+	g.Unlink(ns, intf)
+	if path := c.GraphPath(intf); path != "" {
+		t.Errorf("Path should be invalidated on edge deletion: %s", path)
+	}
+}
@@ -0,0 +1,305 @@
+/*
+ * Copyright (C) 2016 Red Hat, Inc.
+ *
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ *
+ */
+
+package probes
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"os/exec"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/skydive-project/skydive/config"
+	"github.com/skydive-project/skydive/logging"
+	"github.com/skydive-project/skydive/topology/graph"
+)
+
+type bgpSession struct {
+	PeerAddr     string
+	PeerAS       int64
+	LocalAddr    string
+	SessionState string
+}
+
+// BGPProbe adds the BGP sessions of the local BIRD or FRR routing daemon to
+// the topology, the state of the sessions being updated at each poll
+type BGPProbe struct {
+	sync.Mutex
+	Graph    *graph.Graph
+	Root     *graph.Node
+	daemon   string
+	socket   string
+	interval time.Duration
+	nodes    map[string]*graph.Node
+	quit     chan struct{}
+	wg       sync.WaitGroup
+}
+
+// parseBirdProtocols parses the reply of the BIRD "show protocols all"
+// command, each line being prefixed by a reply code, the continuation lines
+// by a space
+func parseBirdProtocols(r io.Reader) []bgpSession {
+	var sessions []bgpSession
+	var current *bgpSession
+
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := scanner.Text()
+
+		var code string
+		if len(line) > 4 && line[0] >= '0' && line[0] <= '9' {
+			code, line = line[:4], line[5:]
+		}
+
+		switch code {
+		case "1002":
+			// protocol summary: name, proto, table, state, since, info
+			fields := strings.Fields(line)
+			if len(fields) < 2 || fields[1] != "BGP" {
+				current = nil
+				continue
+			}
+			sessions = append(sessions, bgpSession{})
+			current = &sessions[len(sessions)-1]
+		case "", "1006":
+			if current == nil {
+				continue
+			}
+
+			kv := strings.SplitN(line, ":", 2)
+			if len(kv) != 2 {
+				continue
+			}
+			value := strings.TrimSpace(kv[1])
+
+			switch strings.TrimSpace(kv[0]) {
+			case "Neighbor address":
+				current.PeerAddr = value
+			case "Neighbor AS":
+				current.PeerAS, _ = strconv.ParseInt(value, 10, 64)
+			case "Source address":
+				current.LocalAddr = value
+			case "BGP state":
+				current.SessionState = value
+			}
+		default:
+			current = nil
+		}
+	}
+
+	return sessions
+}
+
+// parseFRRNeighbors parses the output of the FRR
+// "show ip bgp neighbors json" command
+func parseFRRNeighbors(b []byte) ([]bgpSession, error) {
+	var neighbors map[string]struct {
+		RemoteAs  int64  `json:"remoteAs"`
+		BgpState  string `json:"bgpState"`
+		HostLocal string `json:"hostLocal"`
+	}
+	if err := json.Unmarshal(b, &neighbors); err != nil {
+		return nil, err
+	}
+
+	var sessions []bgpSession
+	for addr, neighbor := range neighbors {
+		sessions = append(sessions, bgpSession{
+			PeerAddr:     addr,
+			PeerAS:       neighbor.RemoteAs,
+			LocalAddr:    neighbor.HostLocal,
+			SessionState: neighbor.BgpState,
+		})
+	}
+
+	return sessions, nil
+}
+
+func (b *BGPProbe) birdSessions() ([]bgpSession, error) {
+	conn, err := net.DialTimeout("unix", b.socket, 5*time.Second)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	conn.SetDeadline(time.Now().Add(10 * time.Second))
+
+	reader := bufio.NewReader(conn)
+	// welcome message
+	if _, err := reader.ReadString('\n'); err != nil {
+		return nil, err
+	}
+
+	if _, err := conn.Write([]byte("show protocols all\n")); err != nil {
+		return nil, err
+	}
+
+	// the reply ends with the first line having a code of the 0xxx, 8xxx
+	// or 9xxx series not followed by a dash
+	var reply bytes.Buffer
+	for {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			return nil, err
+		}
+
+		if len(line) > 4 && line[4] == ' ' && strings.IndexByte("089", line[0]) != -1 {
+			if line[0] != '0' {
+				return nil, fmt.Errorf("BIRD error: %s", strings.TrimSpace(line[5:]))
+			}
+			break
+		}
+		reply.WriteString(line)
+	}
+
+	return parseBirdProtocols(&reply), nil
+}
+
+func (b *BGPProbe) frrSessions() ([]bgpSession, error) {
+	out, err := exec.Command("vtysh", "-c", "show ip bgp neighbors json").Output()
+	if err != nil {
+		return nil, err
+	}
+
+	return parseFRRNeighbors(out)
+}
+
+func (b *BGPProbe) sessions() ([]bgpSession, error) {
+	switch b.daemon {
+	case "bird":
+		return b.birdSessions()
+	case "frr":
+		return b.frrSessions()
+	default:
+		return nil, fmt.Errorf("Unknown routing daemon %s", b.daemon)
+	}
+}
+
+func (b *BGPProbe) sync() error {
+	sessions, err := b.sessions()
+	if err != nil {
+		return err
+	}
+
+	b.Lock()
+	defer b.Unlock()
+
+	b.Graph.Lock()
+	defer b.Graph.Unlock()
+
+	seen := make(map[string]bool)
+	for _, session := range sessions {
+		if session.PeerAddr == "" {
+			continue
+		}
+
+		m := graph.Metadata{
+			"Name":         session.PeerAddr,
+			"Type":         "bgp_peer",
+			"Manager":      b.daemon,
+			"PeerAS":       session.PeerAS,
+			"PeerAddr":     session.PeerAddr,
+			"LocalAddr":    session.LocalAddr,
+			"SessionState": session.SessionState,
+		}
+
+		// a state change updates the node so that the captures matching
+		// the state get started
+		if n, ok := b.nodes[session.PeerAddr]; ok {
+			b.Graph.SetMetadata(n, m)
+		} else {
+			n = b.Graph.NewNode(graph.GenID(), m)
+			b.Graph.Link(b.Root, n, ownershipMetadata)
+			b.nodes[session.PeerAddr] = n
+		}
+		seen[session.PeerAddr] = true
+	}
+
+	for addr, n := range b.nodes {
+		if !seen[addr] {
+			b.Graph.DelNode(n)
+			delete(b.nodes, addr)
+		}
+	}
+
+	return nil
+}
+
+func (b *BGPProbe) run() {
+	defer b.wg.Done()
+
+	ticker := time.NewTicker(b.interval)
+	defer ticker.Stop()
+
+	for {
+		if err := b.sync(); err != nil {
+			logging.GetLogger().Errorf("Failed to get BGP sessions from %s: %s", b.daemon, err.Error())
+		}
+
+		select {
+		case <-ticker.C:
+		case <-b.quit:
+			return
+		}
+	}
+}
+
+func (b *BGPProbe) Start() {
+	b.wg.Add(1)
+	go b.run()
+}
+
+func (b *BGPProbe) Stop() {
+	close(b.quit)
+	b.wg.Wait()
+}
+
+func NewBGPProbe(g *graph.Graph, n *graph.Node, daemon string, socket string, interval time.Duration) *BGPProbe {
+	return &BGPProbe{
+		Graph:    g,
+		Root:     n,
+		daemon:   daemon,
+		socket:   socket,
+		interval: interval,
+		nodes:    make(map[string]*graph.Node),
+		quit:     make(chan struct{}),
+	}
+}
+
+func NewBGPProbeFromConfig(g *graph.Graph, n *graph.Node) (*BGPProbe, error) {
+	daemon := config.GetConfig().GetString("agent.topology.bgp.daemon")
+	if daemon != "bird" && daemon != "frr" {
+		return nil, fmt.Errorf("Unknown routing daemon %s, should be bird or frr", daemon)
+	}
+
+	socket := config.GetConfig().GetString("agent.topology.bgp.socket")
+	interval := time.Duration(config.GetConfig().GetInt("agent.topology.bgp.poll_interval")) * time.Second
+
+	return NewBGPProbe(g, n, daemon, socket, interval), nil
+}
@@ -0,0 +1,368 @@
+/*
+ * Copyright (C) 2016 Red Hat, Inc.
+ *
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ *
+ */
+
+package probes
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/nu7hatch/gouuid"
+
+	"github.com/skydive-project/skydive/config"
+	"github.com/skydive-project/skydive/logging"
+	"github.com/skydive-project/skydive/topology/graph"
+)
+
+var (
+	k8sSelectorMetadata = graph.Metadata{"RelationType": "k8s_selector"}
+	k8sHostMetadata     = graph.Metadata{"RelationType": "k8s_scheduling"}
+)
+
+type k8sObjectMeta struct {
+	Name      string            `json:"name"`
+	Namespace string            `json:"namespace"`
+	UID       string            `json:"uid"`
+	Labels    map[string]string `json:"labels"`
+}
+
+type k8sNamespace struct {
+	Metadata k8sObjectMeta `json:"metadata"`
+}
+
+type k8sServicePort struct {
+	Name     string `json:"name"`
+	Protocol string `json:"protocol"`
+	Port     int    `json:"port"`
+	NodePort int    `json:"nodePort"`
+}
+
+type k8sService struct {
+	Metadata k8sObjectMeta `json:"metadata"`
+	Spec     struct {
+		Type      string            `json:"type"`
+		ClusterIP string            `json:"clusterIP"`
+		Ports     []k8sServicePort  `json:"ports"`
+		Selector  map[string]string `json:"selector"`
+	} `json:"spec"`
+}
+
+type k8sPod struct {
+	Metadata k8sObjectMeta `json:"metadata"`
+	Spec     struct {
+		NodeName string `json:"nodeName"`
+	} `json:"spec"`
+	Status struct {
+		PodIP string `json:"podIP"`
+	} `json:"status"`
+}
+
+// KubernetesProbe polls the Kubernetes API server and adds the namespaces,
+// services and pods of the cluster to the topology
+type KubernetesProbe struct {
+	sync.Mutex
+	Graph    *graph.Graph
+	url      string
+	token    string
+	client   *http.Client
+	interval time.Duration
+	nodes    map[string]*graph.Node
+	quit     chan struct{}
+	wg       sync.WaitGroup
+}
+
+func (k *KubernetesProbe) get(path string, v interface{}) error {
+	req, err := http.NewRequest("GET", k.url+path, nil)
+	if err != nil {
+		return err
+	}
+
+	if k.token != "" {
+		req.Header.Set("Authorization", "Bearer "+k.token)
+	}
+
+	resp, err := k.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("Failed to get %s: %s", path, resp.Status)
+	}
+
+	return json.NewDecoder(resp.Body).Decode(v)
+}
+
+func k8sNodeID(uid string) graph.Identifier {
+	u, _ := uuid.NewV5(uuid.NamespaceOID, []byte("k8s"+uid))
+	return graph.Identifier(u.String())
+}
+
+// setLabels flattens the labels so that they can be matched as Labels/<key>
+func setLabels(m graph.Metadata, prefix string, labels map[string]string) {
+	for k, v := range labels {
+		m[prefix+"/"+k] = v
+	}
+}
+
+// formatPorts returns the ports of the service the way kubectl displays them,
+// <port>[:<node port>]/<protocol>
+func formatPorts(ports []k8sServicePort) string {
+	var s []string
+	for _, p := range ports {
+		if p.NodePort != 0 {
+			s = append(s, fmt.Sprintf("%d:%d/%s", p.Port, p.NodePort, p.Protocol))
+		} else {
+			s = append(s, fmt.Sprintf("%d/%s", p.Port, p.Protocol))
+		}
+	}
+	sort.Strings(s)
+	return strings.Join(s, ",")
+}
+
+func selectorMatches(selector, labels map[string]string) bool {
+	if len(selector) == 0 {
+		return false
+	}
+
+	for k, v := range selector {
+		if labels[k] != v {
+			return false
+		}
+	}
+	return true
+}
+
+// syncNode creates or updates the node of the object having the given UID
+func (k *KubernetesProbe) syncNode(uid string, m graph.Metadata) *graph.Node {
+	if n, ok := k.nodes[uid]; ok {
+		k.Graph.SetMetadata(n, m)
+		return n
+	}
+
+	n := k.Graph.GetNode(k8sNodeID(uid))
+	if n == nil {
+		n = k.Graph.NewNode(k8sNodeID(uid), m, "")
+	} else {
+		k.Graph.SetMetadata(n, m)
+	}
+	k.nodes[uid] = n
+
+	return n
+}
+
+func (k *KubernetesProbe) linkOnce(parent, child *graph.Node, m graph.Metadata) {
+	if !k.Graph.AreLinked(parent, child, m) {
+		k.Graph.Link(parent, child, m)
+	}
+}
+
+// syncSelection links the service to the pods it selects and removes the
+// links to the pods no longer selected
+func (k *KubernetesProbe) syncSelection(service *graph.Node, pods []*graph.Node) {
+	selected := make(map[graph.Identifier]bool)
+	for _, pod := range pods {
+		selected[pod.ID] = true
+		k.linkOnce(service, pod, k8sSelectorMetadata)
+	}
+
+	for _, e := range k.Graph.GetNodeEdges(service, k8sSelectorMetadata) {
+		if e.GetParent() == service.ID && !selected[e.GetChild()] {
+			k.Graph.DelEdge(e)
+		}
+	}
+}
+
+func (k *KubernetesProbe) sync() error {
+	var namespaces struct {
+		Items []k8sNamespace `json:"items"`
+	}
+	if err := k.get("/api/v1/namespaces", &namespaces); err != nil {
+		return err
+	}
+
+	var services struct {
+		Items []k8sService `json:"items"`
+	}
+	if err := k.get("/api/v1/services", &services); err != nil {
+		return err
+	}
+
+	var pods struct {
+		Items []k8sPod `json:"items"`
+	}
+	if err := k.get("/api/v1/pods", &pods); err != nil {
+		return err
+	}
+
+	k.Lock()
+	defer k.Unlock()
+
+	k.Graph.Lock()
+	defer k.Graph.Unlock()
+
+	seen := make(map[string]bool)
+
+	nsNodes := make(map[string]*graph.Node)
+	for _, ns := range namespaces.Items {
+		m := graph.Metadata{
+			"Type":    "k8s_namespace",
+			"Manager": "k8s",
+			"Name":    ns.Metadata.Name,
+			"UID":     ns.Metadata.UID,
+		}
+		setLabels(m, "Labels", ns.Metadata.Labels)
+
+		nsNodes[ns.Metadata.Name] = k.syncNode(ns.Metadata.UID, m)
+		seen[ns.Metadata.UID] = true
+	}
+
+	podNodes := make(map[string][]*graph.Node)
+	podLabels := make(map[graph.Identifier]map[string]string)
+	for _, pod := range pods.Items {
+		m := graph.Metadata{
+			"Type":      "pod",
+			"Manager":   "k8s",
+			"Name":      pod.Metadata.Name,
+			"Namespace": pod.Metadata.Namespace,
+			"UID":       pod.Metadata.UID,
+		}
+		if pod.Spec.NodeName != "" {
+			m["NodeName"] = pod.Spec.NodeName
+		}
+		if pod.Status.PodIP != "" {
+			m["IPV4"] = pod.Status.PodIP
+		}
+		setLabels(m, "Labels", pod.Metadata.Labels)
+
+		n := k.syncNode(pod.Metadata.UID, m)
+		seen[pod.Metadata.UID] = true
+
+		if ns, ok := nsNodes[pod.Metadata.Namespace]; ok {
+			k.linkOnce(ns, n, ownershipMetadata)
+		}
+
+		// the pod is linked to the host it is scheduled on, once known
+		if pod.Spec.NodeName != "" {
+			if host := k.Graph.LookupFirstNode(graph.Metadata{"Type": "host", "Name": pod.Spec.NodeName}); host != nil {
+				k.linkOnce(host, n, k8sHostMetadata)
+			}
+		}
+
+		podNodes[pod.Metadata.Namespace] = append(podNodes[pod.Metadata.Namespace], n)
+		podLabels[n.ID] = pod.Metadata.Labels
+	}
+
+	for _, svc := range services.Items {
+		m := graph.Metadata{
+			"Type":        "k8s_service",
+			"Manager":     "k8s",
+			"Name":        svc.Metadata.Name,
+			"Namespace":   svc.Metadata.Namespace,
+			"UID":         svc.Metadata.UID,
+			"ServiceType": svc.Spec.Type,
+			"ClusterIP":   svc.Spec.ClusterIP,
+			"Ports":       formatPorts(svc.Spec.Ports),
+		}
+		setLabels(m, "Labels", svc.Metadata.Labels)
+		setLabels(m, "Selector", svc.Spec.Selector)
+
+		n := k.syncNode(svc.Metadata.UID, m)
+		seen[svc.Metadata.UID] = true
+
+		if ns, ok := nsNodes[svc.Metadata.Namespace]; ok {
+			k.linkOnce(ns, n, ownershipMetadata)
+		}
+
+		var selected []*graph.Node
+		for _, pod := range podNodes[svc.Metadata.Namespace] {
+			if selectorMatches(svc.Spec.Selector, podLabels[pod.ID]) {
+				selected = append(selected, pod)
+			}
+		}
+		k.syncSelection(n, selected)
+	}
+
+	for uid, n := range k.nodes {
+		if !seen[uid] {
+			k.Graph.DelNode(n)
+			delete(k.nodes, uid)
+		}
+	}
+
+	return nil
+}
+
+func (k *KubernetesProbe) run() {
+	defer k.wg.Done()
+
+	ticker := time.NewTicker(k.interval)
+	defer ticker.Stop()
+
+	for {
+		if err := k.sync(); err != nil {
+			logging.GetLogger().Errorf("Failed to synchronize with Kubernetes API server %s: %s", k.url, err.Error())
+		}
+
+		select {
+		case <-ticker.C:
+		case <-k.quit:
+			return
+		}
+	}
+}
+
+func (k *KubernetesProbe) Start() {
+	k.wg.Add(1)
+	go k.run()
+}
+
+func (k *KubernetesProbe) Stop() {
+	close(k.quit)
+	k.wg.Wait()
+}
+
+func NewKubernetesProbe(g *graph.Graph, url string, token string, interval time.Duration) *KubernetesProbe {
+	return &KubernetesProbe{
+		Graph:    g,
+		url:      strings.TrimSuffix(url, "/"),
+		token:    token,
+		client:   &http.Client{Timeout: 10 * time.Second},
+		interval: interval,
+		nodes:    make(map[string]*graph.Node),
+		quit:     make(chan struct{}),
+	}
+}
+
+func NewKubernetesProbeFromConfig(g *graph.Graph) *KubernetesProbe {
+	url := config.GetConfig().GetString("k8s.url")
+	token := config.GetConfig().GetString("k8s.token")
+	interval := time.Duration(config.GetConfig().GetInt("k8s.poll_interval")) * time.Second
+
+	return NewKubernetesProbe(g, url, token, interval)
+}
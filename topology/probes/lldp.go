@@ -0,0 +1,243 @@
+/*
+ * Copyright (C) 2016 Red Hat, Inc.
+ *
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ *
+ */
+
+package probes
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"os/exec"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/nu7hatch/gouuid"
+
+	"github.com/skydive-project/skydive/config"
+	"github.com/skydive-project/skydive/logging"
+	"github.com/skydive-project/skydive/topology/graph"
+)
+
+var lldpLinkMetadata = graph.Metadata{"RelationType": "layer2", "Type": "fabric"}
+
+// lldpIDKeys are the keys under which lldpcli reports the chassis and port
+// identifiers, according to their subtype
+var lldpIDKeys = []string{"mac", "local", "ifname", "ip", "ifalias"}
+
+type lldpNeighbor struct {
+	ChassisID       string
+	PortID          string
+	SystemName      string
+	PortDescription string
+}
+
+// LLDPProbe adds the neighbors of the host interfaces, as advertised by LLDP,
+// to the fabric layer of the topology
+type LLDPProbe struct {
+	sync.Mutex
+	Graph    *graph.Graph
+	Root     *graph.Node
+	interval time.Duration
+	nodes    map[graph.Identifier]*graph.Node
+	quit     chan struct{}
+	wg       sync.WaitGroup
+}
+
+// parseLLDPNeighbors parses the output of "lldpcli show neighbors -f keyvalue"
+// returning the neighbors by local interface name
+func parseLLDPNeighbors(r io.Reader) map[string]*lldpNeighbor {
+	fields := make(map[string]map[string]string)
+
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		kv := strings.SplitN(scanner.Text(), "=", 2)
+		if len(kv) != 2 || !strings.HasPrefix(kv[0], "lldp.") {
+			continue
+		}
+		key := strings.TrimPrefix(kv[0], "lldp.")
+
+		// the interface name may contain dots, it ends where the
+		// chassis or port section starts
+		var intf, field string
+		for _, section := range []string{".chassis.", ".port."} {
+			if i := strings.Index(key, section); i > 0 && (intf == "" || i < len(intf)) {
+				intf, field = key[:i], key[i+1:]
+			}
+		}
+		if intf == "" {
+			continue
+		}
+
+		if _, ok := fields[intf]; !ok {
+			fields[intf] = make(map[string]string)
+		}
+		// only the first value is kept for the multi valued keys
+		if _, ok := fields[intf][field]; !ok {
+			fields[intf][field] = kv[1]
+		}
+	}
+
+	neighbors := make(map[string]*lldpNeighbor)
+	for intf, f := range fields {
+		neighbor := &lldpNeighbor{
+			SystemName:      f["chassis.name"],
+			PortDescription: f["port.descr"],
+		}
+		for _, k := range lldpIDKeys {
+			if neighbor.ChassisID == "" {
+				neighbor.ChassisID = f["chassis."+k]
+			}
+			if neighbor.PortID == "" {
+				neighbor.PortID = f["port."+k]
+			}
+		}
+
+		if neighbor.ChassisID != "" && neighbor.PortID != "" {
+			neighbors[intf] = neighbor
+		}
+	}
+
+	return neighbors
+}
+
+func lldpNodeID(n *lldpNeighbor) graph.Identifier {
+	u, _ := uuid.NewV5(uuid.NamespaceOID, []byte("lldp"+n.ChassisID+n.PortID))
+	return graph.Identifier(u.String())
+}
+
+func (l *LLDPProbe) neighbors() (map[string]*lldpNeighbor, error) {
+	out, err := exec.Command("lldpcli", "show", "neighbors", "-f", "keyvalue").Output()
+	if err != nil {
+		return nil, err
+	}
+
+	return parseLLDPNeighbors(bytes.NewReader(out)), nil
+}
+
+func (l *LLDPProbe) sync() error {
+	neighbors, err := l.neighbors()
+	if err != nil {
+		return err
+	}
+
+	l.Lock()
+	defer l.Unlock()
+
+	l.Graph.Lock()
+	defer l.Graph.Unlock()
+
+	seen := make(map[graph.Identifier]bool)
+	for intf, neighbor := range neighbors {
+		local := l.Graph.LookupFirstChild(l.Root, graph.Metadata{"Name": intf})
+		if local == nil {
+			continue
+		}
+
+		name := neighbor.PortID
+		if neighbor.SystemName != "" {
+			name = neighbor.SystemName + "/" + neighbor.PortID
+		}
+
+		m := graph.Metadata{
+			"Name":            name,
+			"Type":            "device",
+			"Probe":           "lldp",
+			"ChassisID":       neighbor.ChassisID,
+			"PortID":          neighbor.PortID,
+			"SystemName":      neighbor.SystemName,
+			"PortDescription": neighbor.PortDescription,
+		}
+
+		id := lldpNodeID(neighbor)
+		n := l.Graph.GetNode(id)
+		if n == nil {
+			n = l.Graph.NewNode(id, m)
+		} else {
+			l.Graph.SetMetadata(n, m)
+		}
+		l.nodes[id] = n
+		seen[id] = true
+
+		if !l.Graph.AreLinked(local, n, lldpLinkMetadata) {
+			l.Graph.Link(local, n, lldpLinkMetadata)
+		}
+	}
+
+	for id, n := range l.nodes {
+		if !seen[id] {
+			l.Graph.DelNode(n)
+			delete(l.nodes, id)
+		}
+	}
+
+	return nil
+}
+
+func (l *LLDPProbe) run() {
+	defer l.wg.Done()
+
+	ticker := time.NewTicker(l.interval)
+	defer ticker.Stop()
+
+	for {
+		if err := l.sync(); err != nil {
+			logging.GetLogger().Errorf("Failed to get LLDP neighbors: %s", err.Error())
+		}
+
+		select {
+		case <-ticker.C:
+		case <-l.quit:
+			return
+		}
+	}
+}
+
+func (l *LLDPProbe) Start() {
+	l.wg.Add(1)
+	go l.run()
+}
+
+func (l *LLDPProbe) Stop() {
+	close(l.quit)
+	l.wg.Wait()
+}
+
+func NewLLDPProbe(g *graph.Graph, n *graph.Node, interval time.Duration) *LLDPProbe {
+	return &LLDPProbe{
+		Graph:    g,
+		Root:     n,
+		interval: interval,
+		nodes:    make(map[graph.Identifier]*graph.Node),
+		quit:     make(chan struct{}),
+	}
+}
+
+func NewLLDPProbeFromConfig(g *graph.Graph, n *graph.Node) (*LLDPProbe, error) {
+	if _, err := exec.LookPath("lldpcli"); err != nil {
+		return nil, fmt.Errorf("lldpcli not found, lldpd has to be installed: %s", err.Error())
+	}
+
+	interval := time.Duration(config.GetConfig().GetInt("agent.topology.lldp.poll_interval")) * time.Second
+	return NewLLDPProbe(g, n, interval), nil
+}
@@ -324,6 +324,10 @@ func (u *NetLinkProbe) addLinkToTopology(link netlink.Link) {
 
 	if vlan, ok := link.(*netlink.Vlan); ok {
 		metadata["Vlan"] = vlan.VlanId
+		metadata["VlanID"] = int64(vlan.VlanId)
+		if device := u.vlanRawDevice(link); device != "" {
+			metadata["VlanRawDevice"] = device
+		}
 	}
 
 	if (link.Attrs().Flags & net.FlagUp) > 0 {
@@ -0,0 +1,210 @@
+/*
+ * Copyright (C) 2016 Red Hat, Inc.
+ *
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ *
+ */
+
+package probes
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/skydive-project/skydive/logging"
+	"github.com/skydive-project/skydive/topology/graph"
+)
+
+var sysClassNet = "/sys/class/net"
+
+// SriovProbe adds the SR-IOV Virtual Functions of the physical interfaces of
+// the host, linked to their Physical Function
+type SriovProbe struct {
+	sync.Mutex
+	graph.DefaultGraphListener
+	Graph *graph.Graph
+	Root  *graph.Node
+	vfs   map[graph.Identifier][]*graph.Node
+}
+
+type sriovVF struct {
+	index      int64
+	pciAddress string
+	netdev     string
+	mac        string
+}
+
+func readSysfsString(path string) string {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(b))
+}
+
+// readVFs returns the Virtual Functions of the Physical Function interface
+func readVFs(pf string) []sriovVF {
+	device := filepath.Join(sysClassNet, pf, "device")
+
+	numvfs, err := strconv.Atoi(readSysfsString(filepath.Join(device, "sriov_numvfs")))
+	if err != nil || numvfs <= 0 {
+		return nil
+	}
+
+	var vfs []sriovVF
+	for i := 0; i < numvfs; i++ {
+		virtfn := filepath.Join(device, fmt.Sprintf("virtfn%d", i))
+
+		link, err := os.Readlink(virtfn)
+		if err != nil {
+			continue
+		}
+
+		vf := sriovVF{index: int64(i), pciAddress: filepath.Base(link)}
+
+		// the VF has a network device only when bound to a host driver
+		if netdevs, err := ioutil.ReadDir(filepath.Join(virtfn, "net")); err == nil && len(netdevs) > 0 {
+			vf.netdev = netdevs[0].Name()
+			vf.mac = readSysfsString(filepath.Join(sysClassNet, vf.netdev, "address"))
+		}
+
+		vfs = append(vfs, vf)
+	}
+
+	return vfs
+}
+
+func (p *SriovProbe) isHostInterface(n *graph.Node) bool {
+	if tp, _ := n.GetFieldString("Type"); tp != "device" {
+		return false
+	}
+
+	for _, parent := range p.Graph.LookupParents(n, graph.Metadata{}, ownershipMetadata) {
+		if parent.ID == p.Root.ID {
+			return true
+		}
+	}
+	return false
+}
+
+func (p *SriovProbe) onNodeEvent(pf *graph.Node) {
+	if !p.isHostInterface(pf) {
+		return
+	}
+
+	name, _ := pf.GetFieldString("Name")
+	if name == "" {
+		return
+	}
+
+	p.Lock()
+	defer p.Unlock()
+
+	vfs := readVFs(name)
+	if len(vfs) == len(p.vfs[pf.ID]) {
+		return
+	}
+	p.delVFs(pf)
+
+	for _, vf := range vfs {
+		vfName := vf.netdev
+		if vfName == "" {
+			vfName = fmt.Sprintf("%s-vf%d", name, vf.index)
+		}
+
+		metadata := graph.Metadata{
+			"Name":       vfName,
+			"Type":       "sriov_vf",
+			"PCIAddress": vf.pciAddress,
+			"VFIndex":    vf.index,
+		}
+		if vf.mac != "" {
+			metadata["MAC"] = vf.mac
+		}
+
+		node := p.Graph.NewNode(graph.GenID(), metadata)
+		p.Graph.Link(pf, node, layer2Metadata)
+		p.Graph.Link(pf, node, ownershipMetadata)
+
+		// the network device of the VF bound to a host driver
+		if vf.netdev != "" {
+			if intf := p.Graph.LookupFirstChild(p.Root, graph.Metadata{"Name": vf.netdev}); intf != nil {
+				p.Graph.Link(node, intf, layer2Metadata)
+			}
+		}
+
+		p.vfs[pf.ID] = append(p.vfs[pf.ID], node)
+		logging.GetLogger().Debugf("SR-IOV VF %s added to %s", vfName, name)
+	}
+}
+
+func (p *SriovProbe) delVFs(pf *graph.Node) {
+	for _, vf := range p.vfs[pf.ID] {
+		p.Graph.DelNode(vf)
+	}
+	delete(p.vfs, pf.ID)
+}
+
+func (p *SriovProbe) OnNodeAdded(n *graph.Node) {
+	p.onNodeEvent(n)
+}
+
+func (p *SriovProbe) OnNodeUpdated(n *graph.Node) {
+	p.onNodeEvent(n)
+}
+
+func (p *SriovProbe) OnEdgeAdded(e *graph.Edge) {
+	// interfaces are owned by the host once the edge added
+	if parent := e.GetParent(); parent != p.Root.ID {
+		return
+	}
+
+	if n := p.Graph.GetNode(e.GetChild()); n != nil {
+		p.onNodeEvent(n)
+	}
+}
+
+func (p *SriovProbe) OnNodeDeleted(n *graph.Node) {
+	p.Lock()
+	defer p.Unlock()
+
+	if _, ok := p.vfs[n.ID]; ok {
+		p.delVFs(n)
+	}
+}
+
+func (p *SriovProbe) Start() {
+	p.Graph.AddEventListener(p)
+}
+
+func (p *SriovProbe) Stop() {
+	p.Graph.RemoveEventListener(p)
+}
+
+func NewSriovProbe(g *graph.Graph, n *graph.Node) *SriovProbe {
+	return &SriovProbe{
+		Graph: g,
+		Root:  n,
+		vfs:   make(map[graph.Identifier][]*graph.Node),
+	}
+}
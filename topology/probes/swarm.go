@@ -0,0 +1,234 @@
+/*
+ * Copyright (C) 2016 Red Hat, Inc.
+ *
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ *
+ */
+
+package probes
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/docker/engine-api/client"
+	"github.com/docker/engine-api/types"
+	"github.com/docker/engine-api/types/filters"
+	"github.com/docker/engine-api/types/swarm"
+	"github.com/nu7hatch/gouuid"
+	"golang.org/x/net/context"
+
+	"github.com/skydive-project/skydive/config"
+	"github.com/skydive-project/skydive/logging"
+	"github.com/skydive-project/skydive/topology/graph"
+	sversion "github.com/skydive-project/skydive/version"
+)
+
+// SwarmClientAPIVersion is the first Docker API version exposing the Swarm
+// services and tasks
+const SwarmClientAPIVersion = "1.24"
+
+var swarmTaskMetadata = graph.Metadata{"RelationType": "swarm_task"}
+
+// SwarmProbe polls a Docker Swarm manager and adds the services of the Swarm
+// to the topology, linked to the containers running their tasks whatever the
+// host they are running on
+type SwarmProbe struct {
+	sync.Mutex
+	Graph    *graph.Graph
+	url      string
+	client   *client.Client
+	interval time.Duration
+	nodes    map[string]*graph.Node
+	quit     chan struct{}
+	wg       sync.WaitGroup
+}
+
+func swarmNodeID(serviceID string) graph.Identifier {
+	u, _ := uuid.NewV5(uuid.NamespaceOID, []byte("swarm"+serviceID))
+	return graph.Identifier(u.String())
+}
+
+// formatSwarmPorts returns the published ports of the service as
+// <published port>:<target port>/<protocol>
+func formatSwarmPorts(ports []swarm.PortConfig) string {
+	var s []string
+	for _, p := range ports {
+		s = append(s, fmt.Sprintf("%d:%d/%s", p.PublishedPort, p.TargetPort, p.Protocol))
+	}
+	sort.Strings(s)
+	return strings.Join(s, ",")
+}
+
+func serviceMetadata(service *swarm.Service, running int) graph.Metadata {
+	m := graph.Metadata{
+		"Type":    "swarm_service",
+		"Manager": "docker",
+		"Name":    service.Spec.Name,
+		"Image":   service.Spec.TaskTemplate.ContainerSpec.Image,
+		"Ports":   formatSwarmPorts(service.Endpoint.Ports),
+	}
+
+	// global services run a task per node, the running tasks are reported
+	// as replicas
+	if mode := service.Spec.Mode.Replicated; mode != nil && mode.Replicas != nil {
+		m["Mode"] = "replicated"
+		m["Replicas"] = int64(*mode.Replicas)
+	} else {
+		m["Mode"] = "global"
+		m["Replicas"] = int64(running)
+	}
+
+	return m
+}
+
+// syncTasks links the service to the containers of its running tasks and
+// removes the links to the containers of the tasks no longer running
+func (s *SwarmProbe) syncTasks(service *graph.Node, containers []string) {
+	running := make(map[graph.Identifier]bool)
+	for _, id := range containers {
+		container := s.Graph.LookupFirstNode(graph.Metadata{"Type": "container", "Docker/ContainerID": id})
+		if container == nil {
+			// not yet reported by the agent of the host
+			continue
+		}
+
+		running[container.ID] = true
+		if !s.Graph.AreLinked(service, container, swarmTaskMetadata) {
+			s.Graph.Link(service, container, swarmTaskMetadata)
+		}
+	}
+
+	for _, e := range s.Graph.GetNodeEdges(service, swarmTaskMetadata) {
+		if e.GetParent() == service.ID && !running[e.GetChild()] {
+			s.Graph.DelEdge(e)
+		}
+	}
+}
+
+func (s *SwarmProbe) sync() error {
+	services, err := s.client.ServiceList(context.Background(), types.ServiceListOptions{})
+	if err != nil {
+		return err
+	}
+
+	taskFilter := filters.NewArgs()
+	taskFilter.Add("desired-state", string(swarm.TaskStateRunning))
+
+	tasks, err := s.client.TaskList(context.Background(), types.TaskListOptions{Filter: taskFilter})
+	if err != nil {
+		return err
+	}
+
+	containers := make(map[string][]string)
+	for _, task := range tasks {
+		if task.Status.State != swarm.TaskStateRunning || task.Status.ContainerStatus.ContainerID == "" {
+			continue
+		}
+		containers[task.ServiceID] = append(containers[task.ServiceID], task.Status.ContainerStatus.ContainerID)
+	}
+
+	s.Lock()
+	defer s.Unlock()
+
+	s.Graph.Lock()
+	defer s.Graph.Unlock()
+
+	seen := make(map[string]bool)
+	for i := range services {
+		service := &services[i]
+		m := serviceMetadata(service, len(containers[service.ID]))
+
+		n, ok := s.nodes[service.ID]
+		if !ok {
+			if n = s.Graph.GetNode(swarmNodeID(service.ID)); n == nil {
+				n = s.Graph.NewNode(swarmNodeID(service.ID), m, "")
+			}
+			s.nodes[service.ID] = n
+		}
+		s.Graph.SetMetadata(n, m)
+
+		s.syncTasks(n, containers[service.ID])
+		seen[service.ID] = true
+	}
+
+	for id, n := range s.nodes {
+		if !seen[id] {
+			s.Graph.DelNode(n)
+			delete(s.nodes, id)
+		}
+	}
+
+	return nil
+}
+
+func (s *SwarmProbe) run() {
+	defer s.wg.Done()
+
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+
+	for {
+		if err := s.sync(); err != nil {
+			logging.GetLogger().Errorf("Failed to synchronize with Docker Swarm manager %s: %s", s.url, err.Error())
+		}
+
+		select {
+		case <-ticker.C:
+		case <-s.quit:
+			return
+		}
+	}
+}
+
+func (s *SwarmProbe) Start() {
+	s.wg.Add(1)
+	go s.run()
+}
+
+func (s *SwarmProbe) Stop() {
+	close(s.quit)
+	s.wg.Wait()
+}
+
+func NewSwarmProbe(g *graph.Graph, dockerURL string, interval time.Duration) (*SwarmProbe, error) {
+	defaultHeaders := map[string]string{"User-Agent": fmt.Sprintf("skydive-analyzer-%s", sversion.Version)}
+	c, err := client.NewClient(dockerURL, SwarmClientAPIVersion, nil, defaultHeaders)
+	if err != nil {
+		return nil, err
+	}
+
+	return &SwarmProbe{
+		Graph:    g,
+		url:      dockerURL,
+		client:   c,
+		interval: interval,
+		nodes:    make(map[string]*graph.Node),
+		quit:     make(chan struct{}),
+	}, nil
+}
+
+func NewSwarmProbeFromConfig(g *graph.Graph) (*SwarmProbe, error) {
+	dockerURL := config.GetConfig().GetString("docker.url")
+	interval := time.Duration(config.GetConfig().GetInt("docker.swarm_poll_interval")) * time.Second
+
+	return NewSwarmProbe(g, dockerURL, interval)
+}
@@ -0,0 +1,74 @@
+/*
+ * Copyright (C) 2016 Red Hat, Inc.
+ *
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ *
+ */
+
+package probes
+
+import (
+	"bufio"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/vishvananda/netlink"
+)
+
+const vlanConfigPath = "/proc/net/vlan/config"
+
+// parseVlanConfig returns the raw device of the VLAN interfaces listed in the
+// format of /proc/net/vlan/config
+func parseVlanConfig(r io.Reader) map[string]string {
+	devices := make(map[string]string)
+
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		fields := strings.Split(scanner.Text(), "|")
+		if len(fields) != 3 {
+			continue
+		}
+
+		name := strings.TrimSpace(fields[0])
+		if name == "" || name == "VLAN Dev name" {
+			continue
+		}
+		devices[name] = strings.TrimSpace(fields[2])
+	}
+
+	return devices
+}
+
+// vlanRawDevice returns the name of the device carrying the VLAN interface,
+// /proc/net/vlan/config being used if the parent link can't be retrieved
+func (u *NetLinkProbe) vlanRawDevice(link netlink.Link) string {
+	if index := link.Attrs().ParentIndex; index != 0 {
+		if parent, err := u.netlink.LinkByIndex(index); err == nil {
+			return parent.Attrs().Name
+		}
+	}
+
+	f, err := os.Open(vlanConfigPath)
+	if err != nil {
+		return ""
+	}
+	defer f.Close()
+
+	return parseVlanConfig(f)[link.Attrs().Name]
+}
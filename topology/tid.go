@@ -23,16 +23,34 @@
 package topology
 
 import (
+	"fmt"
+	"sync"
+
 	"github.com/nu7hatch/gouuid"
+	"github.com/prometheus/client_golang/prometheus"
 
+	"github.com/skydive-project/skydive/config"
 	"github.com/skydive-project/skydive/logging"
 	"github.com/skydive-project/skydive/topology/graph"
 )
 
+// defaultTIDMaxDepth is the default maximum depth of the TID propagation
+const defaultTIDMaxDepth = 10
+
+var tidCollisions = prometheus.NewCounter(prometheus.CounterOpts{
+	Name: "skydive_topology_tid_collisions_total",
+	Help: "Number of TIDs not assigned as already used by another node",
+})
+
 type TIDMapper struct {
+	sync.RWMutex
 	graph.DefaultGraphListener
-	Graph  *graph.Graph
-	hostID graph.Identifier
+	Graph     *graph.Graph
+	hostID    graph.Identifier
+	namespace *uuid.UUID
+	maxDepth  int
+	nodes     map[string]*graph.Node
+	tids      map[graph.Identifier]string
 }
 
 func (t *TIDMapper) Start() {
@@ -43,12 +61,71 @@ func (t *TIDMapper) Stop() {
 	t.Graph.RemoveEventListener(t)
 }
 
-func (t *TIDMapper) setTID(parent, child *graph.Node) {
+// GetNodeByTID returns the node having the given TID
+func (t *TIDMapper) GetNodeByTID(tid string) *graph.Node {
+	t.RLock()
+	defer t.RUnlock()
+
+	return t.nodes[tid]
+}
+
+// indexNode keeps the TID to node map in sync with the TID of the node
+func (t *TIDMapper) indexNode(n *graph.Node) {
+	t.Lock()
+	defer t.Unlock()
+
+	tid, _ := n.GetFieldString("TID")
+	if old, ok := t.tids[n.ID]; ok {
+		if old == tid {
+			return
+		}
+		delete(t.nodes, old)
+		delete(t.tids, n.ID)
+	}
+
+	if tid != "" {
+		t.nodes[tid] = n
+		t.tids[n.ID] = tid
+	}
+}
+
+// updateTID sets the TID of the node unless another node already uses it
+func (t *TIDMapper) updateTID(n *graph.Node, tid string) {
+	if other := t.GetNodeByTID(tid); other != nil && other.ID != n.ID {
+		logging.GetLogger().Errorf("TID collision: %s already used by node %s, not assigned to node %s", tid, other.ID, n.ID)
+		tidCollisions.Inc()
+		return
+	}
+
+	t.Graph.AddMetadata(n, "TID", tid)
+	t.indexNode(n)
+}
+
+// stableTIDFields are the fields holding the stable identifier of the "root"
+// nodes, their TID being computed from it rather than from the parent TID
+var stableTIDFields = map[string]string{
+	"netns":         "Path",
+	"ovsport":       "UUID",
+	"container":     "Docker/ContainerID",
+	"pod":           "UID",
+	"k8s_namespace": "UID",
+	"k8s_service":   "UID",
+}
+
+// setTID sets the TID of the child from the TID of its parent and propagates
+// it to the descendants of the child, up to the maximum depth
+func (t *TIDMapper) setTID(parent, child *graph.Node, depth int) {
 	tp, _ := child.GetFieldString("Type")
 	if tp == "" {
 		return
 	}
 
+	if field, ok := stableTIDFields[tp]; ok {
+		if id, _ := child.GetFieldString(field); id != "" {
+			return
+		}
+	}
+
 	name, _ := child.GetFieldString("Name")
 	if name == "" {
 		return
@@ -56,56 +133,55 @@ func (t *TIDMapper) setTID(parent, child *graph.Node) {
 
 	if tid, _ := parent.GetFieldString("TID"); tid != "" {
 		tid = tid + name + tp
-		u, _ := uuid.NewV5(uuid.NamespaceOID, []byte(tid))
-		t.Graph.AddMetadata(child, "TID", u.String())
+		u, _ := uuid.NewV5(t.namespace, []byte(tid))
+
+		if old, _ := child.GetFieldString("TID"); old != u.String() {
+			t.updateTID(child, u.String())
+			t.setChildrenTID(child, depth+1)
+		}
 	}
 }
 
-func (t *TIDMapper) setChildrenTID(parent *graph.Node) {
+func (t *TIDMapper) setChildrenTID(parent *graph.Node, depth int) {
+	if depth >= t.maxDepth {
+		logging.GetLogger().Warningf("Maximum TID propagation depth reached on node %s, ownership cycle ?", parent.ID)
+		return
+	}
+
 	children := t.Graph.LookupChildren(parent, graph.Metadata{}, graph.Metadata{"RelationType": "ownership"})
 	for _, child := range children {
-		t.setTID(parent, child)
+		t.setTID(parent, child, depth)
 	}
 }
 
 // onNodeEvent set TID
-// TID is UUIDV5(ID/UUID) of "root" node like host, netns, ovsport, fabric
+// TID is UUIDV5(ID/UUID) of "root" node like host, netns, ovsport, container,
+// Kubernetes pod, namespace and service, fabric
 // for other nodes TID is UUIDV5(rootTID + Name + Type)
 func (t *TIDMapper) onNodeEvent(n *graph.Node) {
 	if _, err := n.GetFieldString("TID"); err != nil {
 		if tp, err := n.GetFieldString("Type"); err == nil {
-			switch tp {
-			case "host":
+			if tp == "host" {
 				t.hostID = n.ID
-				t.Graph.AddMetadata(n, "TID", string(n.ID))
+				t.updateTID(n, string(n.ID))
 
-				t.setChildrenTID(n)
-			case "netns":
-				if path, _ := n.GetFieldString("Path"); path != "" {
-					tid := string(t.hostID) + path + tp
-					u, _ := uuid.NewV5(uuid.NamespaceOID, []byte(tid))
-					t.Graph.AddMetadata(n, "TID", u.String())
+				t.setChildrenTID(n, 0)
+			} else if field, ok := stableTIDFields[tp]; ok {
+				if id, _ := n.GetFieldString(field); id != "" {
+					tid := string(t.hostID) + id + tp
+					u, _ := uuid.NewV5(t.namespace, []byte(tid))
+					t.updateTID(n, u.String())
 
-					t.setChildrenTID(n)
+					t.setChildrenTID(n, 0)
 				}
-			case "ovsport":
-				if u, _ := n.GetFieldString("UUID"); u != "" {
-					tid := string(t.hostID) + u + tp
-					u, _ := uuid.NewV5(uuid.NamespaceOID, []byte(tid))
-					t.Graph.AddMetadata(n, "TID", u.String())
-
-					t.setChildrenTID(n)
-				}
-			default:
-				if probe, _ := n.GetFieldString("Probe"); probe == "fabric" {
-					t.Graph.AddMetadata(n, "TID", string(n.ID))
-				} else {
-					parents := t.Graph.LookupParents(n, graph.Metadata{}, graph.Metadata{"RelationType": "ownership"})
-					if len(parents) > 1 {
-						logging.GetLogger().Errorf("A should always only have one ownership parent: %v", n)
-					} else if len(parents) == 1 {
-						t.setTID(parents[0], n)
-					}
+			} else if probe, _ := n.GetFieldString("Probe"); probe == "fabric" {
+				t.updateTID(n, string(n.ID))
+			} else {
+				parents := t.Graph.LookupParents(n, graph.Metadata{}, graph.Metadata{"RelationType": "ownership"})
+				if len(parents) > 1 {
+					logging.GetLogger().Errorf("A should always only have one ownership parent: %v", n)
+				} else if len(parents) == 1 {
+					t.setTID(parents[0], n, 0)
 				}
 			}
 		}
@@ -114,10 +190,22 @@ func (t *TIDMapper) onNodeEvent(n *graph.Node) {
 
 func (t *TIDMapper) OnNodeUpdated(n *graph.Node) {
 	t.onNodeEvent(n)
+	t.indexNode(n)
 }
 
 func (t *TIDMapper) OnNodeAdded(n *graph.Node) {
 	t.onNodeEvent(n)
+	t.indexNode(n)
+}
+
+func (t *TIDMapper) OnNodeDeleted(n *graph.Node) {
+	t.Lock()
+	defer t.Unlock()
+
+	if tid, ok := t.tids[n.ID]; ok {
+		delete(t.nodes, tid)
+		delete(t.tids, n.ID)
+	}
 }
 
 // onEdgeEvent set TID for child TID nodes which is composed of the name
@@ -132,7 +220,7 @@ func (t *TIDMapper) onEdgeEvent(e *graph.Edge) {
 		return
 	}
 
-	t.setTID(parents[0], children[0])
+	t.setTID(parents[0], children[0], 0)
 }
 
 func (t *TIDMapper) OnEdgeUpdated(e *graph.Edge) {
@@ -143,8 +231,43 @@ func (t *TIDMapper) OnEdgeAdded(e *graph.Edge) {
 	t.onEdgeEvent(e)
 }
 
-func NewTIDMapper(g *graph.Graph) *TIDMapper {
+// NewTIDMapper returns a TIDMapper generating the TIDs in the given UUID
+// namespace, the OID namespace being used if nil
+func NewTIDMapper(g *graph.Graph, namespace *uuid.UUID) *TIDMapper {
+	if namespace == nil {
+		namespace = uuid.NamespaceOID
+	}
+
 	return &TIDMapper{
-		Graph: g,
+		Graph:     g,
+		namespace: namespace,
+		maxDepth:  defaultTIDMaxDepth,
+		nodes:     make(map[string]*graph.Node),
+		tids:      make(map[graph.Identifier]string),
+	}
+}
+
+// NewTIDMapperFromConfig returns a TIDMapper using the UUID namespace of the
+// "topology.tid_namespace" configuration key and the maximum propagation
+// depth of the "topology.tid_max_depth" one
+func NewTIDMapperFromConfig(g *graph.Graph) (*TIDMapper, error) {
+	var namespace *uuid.UUID
+	if ns := config.GetConfig().GetString("topology.tid_namespace"); ns != "" {
+		u, err := uuid.ParseHex(ns)
+		if err != nil {
+			return nil, fmt.Errorf("Invalid TID namespace %s: %s", ns, err.Error())
+		}
+		namespace = u
 	}
+
+	tm := NewTIDMapper(g, namespace)
+	if depth := config.GetConfig().GetInt("topology.tid_max_depth"); depth > 0 {
+		tm.maxDepth = depth
+	}
+
+	return tm, nil
+}
+
+func init() {
+	prometheus.MustRegister(tidCollisions)
 }
@@ -0,0 +1,181 @@
+/*
+ * Copyright (C) 2016 Red Hat, Inc.
+ *
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ *
+ */
+
+package topology
+
+import (
+	"testing"
+
+	"github.com/nu7hatch/gouuid"
+	dto "github.com/prometheus/client_model/go"
+
+	"github.com/skydive-project/skydive/topology/graph"
+)
+
+func newTIDMapper(t *testing.T) (*graph.Graph, *TIDMapper) {
+	g := newGraph(t)
+
+	tm := NewTIDMapper(g, nil)
+	tm.Start()
+
+	return g, tm
+}
+
+func expectedTID(s string) string {
+	u, _ := uuid.NewV5(uuid.NamespaceOID, []byte(s))
+	return u.String()
+}
+
+func TestTIDContainer(t *testing.T) {
+	g, tm := newTIDMapper(t)
+	defer tm.Stop()
+
+	host := g.NewNode(graph.Identifier("host"), graph.Metadata{"Name": "host", "Type": "host"})
+	container := g.NewNode(graph.GenID(), graph.Metadata{"Name": "c1", "Type": "container", "Docker/ContainerID": "123456"})
+	g.Link(host, container, graph.Metadata{"RelationType": "ownership"})
+
+	tid, _ := container.GetFieldString("TID")
+	if tid != expectedTID("host123456container") {
+		t.Errorf("Wrong container TID: %s", tid)
+	}
+
+	intf := g.NewNode(graph.GenID(), graph.Metadata{"Name": "eth0", "Type": "veth"})
+	g.Link(container, intf, graph.Metadata{"RelationType": "ownership"})
+
+	tid, _ = intf.GetFieldString("TID")
+	if tid != expectedTID(expectedTID("host123456container")+"eth0veth") {
+		t.Errorf("Wrong interface TID: %s", tid)
+	}
+}
+
+func TestTIDKubernetes(t *testing.T) {
+	g, tm := newTIDMapper(t)
+	defer tm.Stop()
+
+	host := g.NewNode(graph.Identifier("host"), graph.Metadata{"Name": "host", "Type": "host"})
+
+	for _, tp := range []string{"pod", "k8s_namespace", "k8s_service"} {
+		n := g.NewNode(graph.GenID(), graph.Metadata{"Name": "k8s-" + tp, "Type": tp, "UID": "uid-" + tp})
+		g.Link(host, n, graph.Metadata{"RelationType": "ownership"})
+
+		if tid, _ := n.GetFieldString("TID"); tid != expectedTID("hostuid-"+tp+tp) {
+			t.Errorf("Wrong %s TID: %s", tp, tid)
+		}
+	}
+}
+
+func TestTIDGetNodeByTID(t *testing.T) {
+	g, tm := newTIDMapper(t)
+	defer tm.Stop()
+
+	host := g.NewNode(graph.Identifier("host"), graph.Metadata{"Name": "host", "Type": "host"})
+	intf := g.NewNode(graph.GenID(), graph.Metadata{"Name": "eth0", "Type": "device"})
+	g.Link(host, intf, graph.Metadata{"RelationType": "ownership"})
+
+	if n := tm.GetNodeByTID("host"); n != host {
+		t.Errorf("Wrong node for host TID: %v", n)
+	}
+
+	tid, _ := intf.GetFieldString("TID")
+	if n := tm.GetNodeByTID(tid); n != intf {
+		t.Errorf("Wrong node for interface TID: %v", n)
+	}
+
+	other := g.NewNode(graph.GenID(), graph.Metadata{"Name": "remote", "TID": "remote-tid"})
+	if n := tm.GetNodeByTID("remote-tid"); n != other {
+		t.Errorf("Wrong node for TID set outside of the mapper: %v", n)
+	}
+
+	g.DelNode(intf)
+	if n := tm.GetNodeByTID(tid); n != nil {
+		t.Errorf("Deleted node should not be returned: %v", n)
+	}
+}
+
+func TestTIDCollision(t *testing.T) {
+	g, tm := newTIDMapper(t)
+	defer tm.Stop()
+
+	var before dto.Metric
+	tidCollisions.Write(&before)
+
+	host := g.NewNode(graph.Identifier("host"), graph.Metadata{"Name": "host", "Type": "host"})
+	ns1 := g.NewNode(graph.GenID(), graph.Metadata{"Name": "ns", "Type": "netns", "Path": "/var/run/netns/ns"})
+	g.Link(host, ns1, graph.Metadata{"RelationType": "ownership"})
+	ns2 := g.NewNode(graph.GenID(), graph.Metadata{"Name": "ns", "Type": "netns", "Path": "/var/run/netns/ns"})
+	g.Link(host, ns2, graph.Metadata{"RelationType": "ownership"})
+
+	tid, _ := ns1.GetFieldString("TID")
+	if tid == "" || tm.GetNodeByTID(tid) != ns1 {
+		t.Errorf("First node should keep its TID: %s", tid)
+	}
+
+	if tid, err := ns2.GetFieldString("TID"); err == nil {
+		t.Errorf("Colliding TID should not be assigned: %s", tid)
+	}
+
+	var after dto.Metric
+	tidCollisions.Write(&after)
+	if after.GetCounter().GetValue() <= before.GetCounter().GetValue() {
+		t.Error("Collision counter should be incremented")
+	}
+}
+
+func TestTIDNamespace(t *testing.T) {
+	g := newGraph(t)
+
+	namespace, _ := uuid.ParseHex("6ba7b811-9dad-11d1-80b4-00c04fd430c8")
+	tm := NewTIDMapper(g, namespace)
+	tm.Start()
+	defer tm.Stop()
+
+	host := g.NewNode(graph.Identifier("host"), graph.Metadata{"Name": "host", "Type": "host"})
+	ns := g.NewNode(graph.GenID(), graph.Metadata{"Name": "ns", "Type": "netns", "Path": "/var/run/netns/ns"})
+	g.Link(host, ns, graph.Metadata{"RelationType": "ownership"})
+
+	u, _ := uuid.NewV5(namespace, []byte("host/var/run/netns/nsnetns"))
+	if tid, _ := ns.GetFieldString("TID"); tid != u.String() {
+		t.Errorf("Wrong TID: %s", tid)
+	}
+}
+
+func TestTIDPropagation(t *testing.T) {
+	g, tm := newTIDMapper(t)
+	defer tm.Stop()
+
+	n1 := g.NewNode(graph.GenID(), graph.Metadata{"Name": "n1", "Type": "device"})
+	n2 := g.NewNode(graph.GenID(), graph.Metadata{"Name": "n2", "Type": "device"})
+	n3 := g.NewNode(graph.GenID(), graph.Metadata{"Name": "n3", "Type": "device"})
+	g.Link(n1, n2, graph.Metadata{"RelationType": "ownership"})
+	g.Link(n2, n3, graph.Metadata{"RelationType": "ownership"})
+
+	host := g.NewNode(graph.Identifier("host"), graph.Metadata{"Name": "host", "Type": "host"})
+	g.Link(host, n1, graph.Metadata{"RelationType": "ownership"})
+
+	tid1 := expectedTID("hostn1device")
+	if tid, _ := n3.GetFieldString("TID"); tid != expectedTID(expectedTID(tid1+"n2device")+"n3device") {
+		t.Errorf("TID should be propagated to the descendants: %s", tid)
+	}
+
+	// an ownership cycle must not loop forever
+	g.Link(n3, n1, graph.Metadata{"RelationType": "ownership"})
+}
@@ -23,7 +23,10 @@
 package topology
 
 import (
+	"encoding/json"
 	"fmt"
+	"strings"
+	"sync"
 
 	"github.com/skydive-project/skydive/common"
 	"github.com/skydive-project/skydive/logging"
@@ -53,6 +56,62 @@ func (p NodePath) Marshal() string {
 	return path
 }
 
+// Unmarshal parses a path as returned by Marshal. The nodes of the path only
+// hold the Name and Type metadata.
+func (p *NodePath) Unmarshal(path string) error {
+	segments := strings.Split(path, "/")
+
+	nodes := make(NodePath, len(segments))
+	for i, segment := range segments {
+		idx := strings.Index(segment, "[Type=")
+		if idx <= 0 || !strings.HasSuffix(segment, "]") {
+			return fmt.Errorf("Invalid path segment: %s", segment)
+		}
+
+		name := segment[:idx]
+		tp := segment[idx+len("[Type=") : len(segment)-1]
+		if tp == "" {
+			return fmt.Errorf("Invalid path segment, no type: %s", segment)
+		}
+
+		node := &graph.Node{}
+		node.Decode(map[string]interface{}{
+			"ID":   "",
+			"Host": "",
+			"Metadata": map[string]interface{}{
+				"Name": name,
+				"Type": tp,
+			},
+		})
+
+		// the path starts from the last node as for Marshal
+		nodes[len(segments)-1-i] = node
+	}
+
+	*p = nodes
+	return nil
+}
+
+// MarshalJSON serializes the path as the string returned by Marshal
+func (p NodePath) MarshalJSON() ([]byte, error) {
+	return json.Marshal(p.Marshal())
+}
+
+// UnmarshalJSON parses a path serialized by MarshalJSON
+func (p *NodePath) UnmarshalJSON(b []byte) error {
+	var path string
+	if err := json.Unmarshal(b, &path); err != nil {
+		return err
+	}
+
+	if path == "" {
+		*p = nil
+		return nil
+	}
+
+	return p.Unmarshal(path)
+}
+
 func GraphPath(g *graph.Graph, n *graph.Node) string {
 	nodes := g.LookupShortestPath(n, graph.Metadata{"Type": "host"}, graph.Metadata{"RelationType": "ownership"})
 	if len(nodes) > 0 {
@@ -108,3 +167,102 @@ func BuildHostNodeTIDMap(nodes []*graph.Node) HostNodeTIDMap {
 	}
 	return hnmap
 }
+
+type hostNodeTID struct {
+	host string
+	tid  string
+}
+
+// IncrementalHostNodeTIDMap maintains the TIDs of the nodes per host from the
+// graph events, avoiding a full scan of the nodes as BuildHostNodeTIDMap
+type IncrementalHostNodeTIDMap struct {
+	sync.RWMutex
+	graph.DefaultGraphListener
+	Graph *graph.Graph
+	hosts map[string]map[string]bool
+	nodes map[graph.Identifier]hostNodeTID
+}
+
+func (m *IncrementalHostNodeTIDMap) remove(id graph.Identifier) {
+	if old, ok := m.nodes[id]; ok {
+		delete(m.hosts[old.host], old.tid)
+		if len(m.hosts[old.host]) == 0 {
+			delete(m.hosts, old.host)
+		}
+		delete(m.nodes, id)
+	}
+}
+
+func (m *IncrementalHostNodeTIDMap) onNodeEvent(n *graph.Node) {
+	tid, _ := n.GetFieldString("TID")
+	entry := hostNodeTID{host: n.Host(), tid: tid}
+
+	m.Lock()
+	defer m.Unlock()
+
+	if old, ok := m.nodes[n.ID]; ok && old == entry {
+		return
+	}
+	m.remove(n.ID)
+
+	if tid == "" {
+		return
+	}
+
+	if _, ok := m.hosts[entry.host]; !ok {
+		m.hosts[entry.host] = make(map[string]bool)
+	}
+	m.hosts[entry.host][tid] = true
+	m.nodes[n.ID] = entry
+}
+
+func (m *IncrementalHostNodeTIDMap) OnNodeAdded(n *graph.Node) {
+	m.onNodeEvent(n)
+}
+
+func (m *IncrementalHostNodeTIDMap) OnNodeUpdated(n *graph.Node) {
+	m.onNodeEvent(n)
+}
+
+func (m *IncrementalHostNodeTIDMap) OnNodeDeleted(n *graph.Node) {
+	m.Lock()
+	m.remove(n.ID)
+	m.Unlock()
+}
+
+// GetTIDsForHost returns the TIDs of the nodes of the host
+func (m *IncrementalHostNodeTIDMap) GetTIDsForHost(host string) []string {
+	m.RLock()
+	defer m.RUnlock()
+
+	tids := make([]string, 0, len(m.hosts[host]))
+	for tid := range m.hosts[host] {
+		tids = append(tids, tid)
+	}
+	return tids
+}
+
+// Stop stops maintaining the map
+func (m *IncrementalHostNodeTIDMap) Stop() {
+	m.Graph.RemoveEventListener(m)
+}
+
+// NewIncrementalHostNodeTIDMap returns a map of the TIDs per host initialized
+// with the nodes of the graph and kept in sync with it
+func NewIncrementalHostNodeTIDMap(g *graph.Graph) *IncrementalHostNodeTIDMap {
+	m := &IncrementalHostNodeTIDMap{
+		Graph: g,
+		hosts: make(map[string]map[string]bool),
+		nodes: make(map[graph.Identifier]hostNodeTID),
+	}
+
+	g.AddEventListener(m)
+
+	g.RLock()
+	for _, n := range g.GetNodes(graph.Metadata{}) {
+		m.onNodeEvent(n)
+	}
+	g.RUnlock()
+
+	return m
+}
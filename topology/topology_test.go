@@ -23,6 +23,9 @@
 package topology
 
 import (
+	"encoding/json"
+	"reflect"
+	"sort"
 	"testing"
 
 	"github.com/skydive-project/skydive/topology/graph"
@@ -57,3 +60,85 @@ func TestMarshal(t *testing.T) {
 		t.Errorf("Wrong path returned: %s", path)
 	}
 }
+
+func TestUnmarshal(t *testing.T) {
+	var p NodePath
+	if err := p.Unmarshal("N1[Type=T1]/N2[Type=T2]/N3[Type=T3]"); err != nil {
+		t.Fatal(err.Error())
+	}
+
+	if len(p) != 3 {
+		t.Fatalf("Wrong number of nodes returned: %v", p)
+	}
+
+	if name, _ := p[0].GetFieldString("Name"); name != "N3" {
+		t.Errorf("Wrong first node returned: %v", p[0])
+	}
+
+	if path := p.Marshal(); path != "N1[Type=T1]/N2[Type=T2]/N3[Type=T3]" {
+		t.Errorf("Wrong path returned: %s", path)
+	}
+
+	for _, path := range []string{"", "N1", "N1[Type=]", "[Type=T1]", "N1[Type=T1]/N2"} {
+		if err := p.Unmarshal(path); err == nil {
+			t.Errorf("Should return an error for path: %s", path)
+		}
+	}
+}
+
+func TestNodePathJSON(t *testing.T) {
+	var p NodePath
+	if err := p.Unmarshal("N1[Type=T1]/N2[Type=T2]"); err != nil {
+		t.Fatal(err.Error())
+	}
+
+	b, err := json.Marshal(p)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	if string(b) != `"N1[Type=T1]/N2[Type=T2]"` {
+		t.Errorf("Wrong JSON returned: %s", string(b))
+	}
+
+	var p2 NodePath
+	if err := json.Unmarshal(b, &p2); err != nil {
+		t.Fatal(err.Error())
+	}
+
+	if path := p2.Marshal(); path != "N1[Type=T1]/N2[Type=T2]" {
+		t.Errorf("Wrong path returned: %s", path)
+	}
+}
+
+func TestIncrementalHostNodeTIDMap(t *testing.T) {
+	g := newGraph(t)
+
+	n1 := g.NewNode(graph.GenID(), graph.Metadata{"Name": "N1", "TID": "tid1"})
+	m := NewIncrementalHostNodeTIDMap(g)
+	defer m.Stop()
+
+	n2 := g.NewNode(graph.GenID(), graph.Metadata{"Name": "N2", "TID": "tid2"})
+	n3 := g.NewNode(graph.GenID(), graph.Metadata{"Name": "N3"})
+
+	host := n1.Host()
+	tids := m.GetTIDsForHost(host)
+	sort.Strings(tids)
+	if !reflect.DeepEqual(tids, []string{"tid1", "tid2"}) {
+		t.Errorf("Wrong TIDs returned: %v", tids)
+	}
+
+	g.AddMetadata(n3, "TID", "tid3")
+	g.AddMetadata(n2, "TID", "tid4")
+	g.DelNode(n1)
+
+	tids = m.GetTIDsForHost(host)
+	sort.Strings(tids)
+	if !reflect.DeepEqual(tids, []string{"tid3", "tid4"}) {
+		t.Errorf("Wrong TIDs returned after updates: %v", tids)
+	}
+
+	if tids := m.GetTIDsForHost("unknown"); len(tids) != 0 {
+		t.Errorf("No TID expected for an unknown host: %v", tids)
+	}
+}
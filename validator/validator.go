@@ -28,6 +28,8 @@ import (
 	"net"
 	"strings"
 
+	"github.com/google/gopacket/layers"
+	"github.com/google/gopacket/pcap"
 	valid "gopkg.in/validator.v2"
 
 	ftraversal "github.com/skydive-project/skydive/flow/traversal"
@@ -49,6 +51,12 @@ var (
 	GremlinNotValid = func(err error) error {
 		return valid.TextErr{Err: fmt.Errorf("Not a valid Gremlin expression: %s", err.Error())}
 	}
+	BPFFilterNotValid = func(err error) error {
+		return valid.TextErr{Err: fmt.Errorf("Not a valid BPF filter: %s", err.Error())}
+	}
+	SamplingRateNotValid = func() error {
+		return valid.TextErr{Err: errors.New("Not a valid sampling rate, should be between 0 and 1")}
+	}
 )
 
 func isIP(v interface{}, param string) error {
@@ -80,6 +88,31 @@ func isGremlinExpr(v interface{}, param string) error {
 	return nil
 }
 
+func isBPFFilter(v interface{}, param string) error {
+	bpfFilter, ok := v.(string)
+	if !ok {
+		return BPFFilterNotValid(errors.New("not a string"))
+	}
+
+	if bpfFilter == "" {
+		return nil
+	}
+
+	if _, err := pcap.CompileBPFFilter(layers.LinkTypeEthernet, 65535, bpfFilter); err != nil {
+		return BPFFilterNotValid(err)
+	}
+
+	return nil
+}
+
+func isSamplingRate(v interface{}, param string) error {
+	rate, ok := v.(float64)
+	if !ok || rate < 0 || rate > 1 {
+		return SamplingRateNotValid()
+	}
+	return nil
+}
+
 func Validate(v interface{}) error {
 	if err := skydiveValidator.Validate(v); err != nil {
 		return err
@@ -95,5 +128,7 @@ func Validate(v interface{}) error {
 func init() {
 	skydiveValidator.SetValidationFunc("isIP", isIP)
 	skydiveValidator.SetValidationFunc("isGremlinExpr", isGremlinExpr)
+	skydiveValidator.SetValidationFunc("isBPFFilter", isBPFFilter)
+	skydiveValidator.SetValidationFunc("isSamplingRate", isSamplingRate)
 	skydiveValidator.SetTag("valid")
 }